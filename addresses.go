@@ -223,6 +223,24 @@ func NewIntegratedAddress(addr string) *IntegratedAddress {
 	return a
 }
 
+// ParseAddress returns the network of a standard, sub or integrated
+// address, and the address itself or the base address of an integrated
+// one. Both are empty if addr is not a valid address.
+func ParseAddress(addr string) (net, base string) {
+	if a := NewAddress(addr); a != nil && a.Valid() {
+		return a.Net(), addr
+	}
+	if a := NewSubAddress(addr); a != nil && a.Valid() {
+		return a.Net(), addr
+	}
+	if a := NewIntegratedAddress(addr); a != nil && a.Valid() {
+		if b := a.BaseAddress(); b != nil {
+			return a.Net(), b.Addr
+		}
+	}
+	return "", ""
+}
+
 // PaymentID returns the integrated payment id.
 func (ia *IntegratedAddress) PaymentID() string {
 	l := len(ia.decoded)
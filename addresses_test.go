@@ -1,9 +1,14 @@
 package gonero
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/konraddical2/gonero/base58"
+	"github.com/konraddical2/gonero/crypto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAddresses(t *testing.T) {
@@ -147,3 +152,137 @@ func TestPaymentIds(t *testing.T) {
 		assert.Equal(t, tc.payID, integAddr.PaymentID())
 	}
 }
+
+func TestParseAddress(t *testing.T) {
+	stage := "55hKAMnUWXaWXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmCKyGuXY"
+	sub := "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF"
+	integrated := "5FPzBAby7o6WXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmHj8GPitKopZLZrtqSQ"
+
+	type test struct {
+		addr string
+		net  string
+		base string
+	}
+	tests := []test{
+		{stage, "stage", stage},
+		{sub, "main", sub},
+		{integrated, "stage", stage},
+		{"foo", "", ""},
+		{stage[:len(stage)-1] + "Z", "", ""},
+	}
+	for _, tc := range tests {
+		net, base := ParseAddress(tc.addr)
+		assert.Equal(t, tc.net, net, tc.addr)
+		assert.Equal(t, tc.base, base, tc.addr)
+	}
+}
+
+func TestSubaddress(t *testing.T) {
+	// wallet of the monerod functional tests
+	addr := NewAddress("42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm")
+	viewKey := "49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"
+
+	type test struct {
+		major, minor uint32
+		expected     string
+	}
+	tests := []test{
+		{0, 1, "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF"},
+		{1, 0, "82pP87g1Vkd3LUMssBCumk3MfyEsFqLAaGDf6oxddu61EgSFzt8gCwUD4tr3kp9TUfdPs2CnpD7xLZzyC1Ei9UsW3oyCWDf"},
+	}
+	for _, tc := range tests {
+		sub, err := addr.Subaddress(viewKey, tc.major, tc.minor)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, sub.Addr)
+		assert.True(t, sub.Valid())
+		assert.True(t, sub.IsMainnet())
+	}
+
+	_, err := addr.Subaddress(viewKey, 0, 0)
+	assert.Error(t, err)
+	_, err = addr.Subaddress("zzz", 0, 1)
+	assert.Error(t, err)
+	_, err = addr.Subaddress("148d78d2aba7dbca5cd8f6abcfb0b3c009ffbdbea1ff373d50ed94d78286640e", 0, 1)
+	assert.EqualError(t, err, "private view key does not match address")
+}
+
+func TestMessageSignature(t *testing.T) {
+	// wallet of the monerod functional tests
+	addr := NewAddress("42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm")
+	viewKey := "49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"
+	spendKey := NewSecret("148d78d2aba7dbca5cd8f6abcfb0b3c009ffbdbea1ff373d50ed94d78286640e")
+
+	sig, err := addr.SignMessage("login 1234", spendKey)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sig, "SigV2"))
+	key, err := addr.VerifyMessage("login 1234", sig)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageSpendKey, key)
+	_, err = addr.VerifyMessage("login 1235", sig)
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+	_, err = addr.VerifyMessage("login 1234", sig[:len(sig)-1])
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+	_, err = addr.VerifyMessage("login 1234", "SigV3"+sig[5:])
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+	// the integrated addresses of the address share its keys
+	_, err = addr.WithPaymentID("1234567890abcdef").VerifyMessage("login 1234", sig)
+	assert.NoError(t, err)
+
+	_, err = addr.SignMessage("login 1234", NewSecret(viewKey))
+	assert.EqualError(t, err, "private spend key does not match address")
+
+	// signed with the view key
+	view, err := crypto.ParseSecretKey(viewKey)
+	require.NoError(t, err)
+	b, err := crypto.Sign(addr.messageHash("login 1234", MessageViewKey), view)
+	require.NoError(t, err)
+	key, err = addr.VerifyMessage("login 1234", "SigV2"+base58.Encode(b.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, MessageViewKey, key)
+	assert.Equal(t, "view", key.String())
+
+	// v1 signatures sign the hash of the data
+	hash := crypto.Keccak256([]byte("login 1234"))
+	spend, err := crypto.ParseSecretKey(spendKey.Reveal())
+	require.NoError(t, err)
+	b, err = crypto.Sign(hash, spend)
+	require.NoError(t, err)
+	key, err = addr.VerifyMessage("login 1234", "SigV1"+base58.Encode(b.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, MessageSpendKey, key)
+
+	// signed by monero-wallet-rpc, from the sign example of its documentation
+	stagenet := NewAddress("55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt")
+	walletSig := "SigV14K6G151gycjiGxjQ74tKX6A2LwwghvuHjcDeuRFQio5LS6Gb27BNxjYQY1dPuUvXkEbGQUkiHSVLPj4nJAHRrrw3"
+	key, err = stagenet.VerifyMessage("This is sample data to be signed", walletSig)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageSpendKey, key)
+	_, err = stagenet.VerifyMessage("This is sample data to be signed.", walletSig)
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+	_, err = addr.VerifyMessage("This is sample data to be signed", walletSig)
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+
+	// a zero challenge is rejected
+	zero := crypto.Signature{R: b.R}
+	_, err = addr.VerifyMessage("login 1234", "SigV1"+base58.Encode(zero.Bytes()))
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+
+	// subaddresses sign with their own spend key
+	sub, err := addr.Subaddress(viewKey, 0, 1)
+	require.NoError(t, err)
+	subKey, err := addr.SubaddressSpendKey(spendKey, viewKey, 0, 1)
+	require.NoError(t, err)
+	sig, err = sub.SignMessage("login 1234", subKey)
+	require.NoError(t, err)
+	key, err = sub.VerifyMessage("login 1234", sig)
+	assert.NoError(t, err)
+	assert.Equal(t, MessageSpendKey, key)
+	_, err = addr.VerifyMessage("login 1234", sig)
+	assert.True(t, errors.Is(err, ErrInvalidMessageSignature), "%v", err)
+	_, err = sub.SignMessage("login 1234", spendKey)
+	assert.Error(t, err)
+	_, err = addr.SubaddressSpendKey(spendKey, viewKey, 0, 0)
+	assert.Error(t, err)
+	_, err = addr.SubaddressSpendKey(NewSecret(viewKey), viewKey, 0, 1)
+	assert.EqualError(t, err, "private spend key does not match address")
+}
@@ -0,0 +1,414 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = gonero.Secret("audit key")
+
+// rpcServer is a monero-wallet-rpc answering results by method
+type rpcServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	methods []string
+	results map[string]string
+	errors  map[string]string
+}
+
+func newRPCServer() *rpcServer {
+	s := &rpcServer{results: make(map[string]string), errors: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.methods = append(s.methods, req.Method)
+		result, ok := s.results[req.Method]
+		rpcErr := s.errors[req.Method]
+		s.mu.Unlock()
+		if !ok {
+			result = "{}"
+		}
+		if rpcErr != "" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"error":{"code":-1,"message":"` + rpcErr + `"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":` + result + `}`))
+	}))
+	return s
+}
+
+func (s *rpcServer) client(t *testing.T) wallet.Client {
+	cfg, err := gonero.NewRPCConfigFromURL(s.URL)
+	require.NoError(t, err)
+	return wallet.New(cfg)
+}
+
+func (s *rpcServer) lastMethod() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.methods) == 0 {
+		return ""
+	}
+	return s.methods[len(s.methods)-1]
+}
+
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	return filepath.Join(dir, "audit.log"), func() { os.RemoveAll(dir) }
+}
+
+func readEntries(t *testing.T, path string) []Entry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func verifyFile(t *testing.T, path string, key gonero.Secret, anchor *Head) (Head, error) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return Verify(f, key, anchor)
+}
+
+// TestMethods calls every method of wallet.Client through a Wallet and
+// fails when a state-changing method is not recorded, or when wallet.Client
+// gains a method, which must be reviewed.
+func TestMethods(t *testing.T) {
+	recorded := []string{
+		"SetDaemon", "CreateAddress", "LabelAddress", "CreateAccount", "LabelAccount", "TagAccounts",
+		"UntagAccounts", "SetAccountTagDescription", "Transfer", "TransferSplit", "SignTransfer",
+		"SubmitTransfer", "SweepDust", "SweepAll", "SweepSingle", "RelayTx", "Store", "StopWallet",
+		"RescanBlockchain", "SetTxNotes", "SetAttribute", "ImportOutputs", "ImportKeyImages",
+		"AddAddressBook", "EditAddressBook", "DeleteAddressBook", "AutoRefresh", "RescanSpent",
+		"StartMining", "StopMining", "CreateWallet", "GenerateFromKeys", "OpenWallet",
+		"RestoreDeterministicWallet", "CloseWallet", "ChangeWalletPassword", "PrepareMultisig",
+		"MakeMultisig", "ExportMultisigInfo", "ImportMultisigInfo", "FinalizeMultisig",
+		"ExchangeMultisigKeys", "SignMultisig", "SubmitMultisig", "Freeze", "Thaw", "SetLogLevel",
+		"SetLogCategories", "ScanTx", "SetSubaddressLookahead", "SetupBackgroundSync",
+		"StartBackgroundSync", "StopBackgroundSync",
+	}
+	passed := []string{
+		// only reads the state of the wallet
+		"GetBalance", "GetAddress", "GetAddressIndex", "ValidateAddress", "GetAccounts", "GetAccountTags",
+		"GetHeight", "GetPayments", "GetBulkPayments", "IncomingTransfers", "MakeIntegratedAddress",
+		"SplitIntegratedAddress", "GetTxNotes", "GetAttribute", "GetTxKey", "CheckTxKey", "GetTxProof",
+		"CheckTxProof", "GetSpendProof", "CheckSpendProof", "GetReserveProof", "CheckReserveProof",
+		"GetTransfers", "GetTransferByTxid", "DescribeTransfer", "Sign", "Verify", "ExportOutputs",
+		"ExportKeyImages", "MakeURI", "ParseURI", "GetAddressBook", "GetLanguages", "IsMultisig",
+		"GetVersion", "Frozen", "EstimateTxSizeAndWeight", "GetDefaultFeePriority", "QueryKey",
+		// refreshes are driven by the daemon, not by the caller
+		"Refresh",
+	}
+	isRecorded := make(map[string]bool)
+	for _, name := range recorded {
+		isRecorded[name] = true
+	}
+	reviewed := make(map[string]bool)
+	for _, name := range append(recorded, passed...) {
+		reviewed[name] = true
+	}
+
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	l, err := Open(path, testKey)
+	require.NoError(t, err)
+	defer l.Close()
+	srv := newRPCServer()
+	defer srv.Close()
+	w := reflect.ValueOf(New(srv.client(t), l, "alice"))
+
+	client := reflect.TypeOf((*wallet.Client)(nil)).Elem()
+	for i := 0; i < client.NumMethod(); i++ {
+		m := client.Method(i)
+		assert.True(t, reviewed[m.Name], "wallet.Client.%s is not reviewed by the audit", m.Name)
+		before := l.Head().Entries
+		out := w.MethodByName(m.Name).Call([]reflect.Value{reflect.New(m.Type.In(0).Elem())})
+		assert.True(t, out[1].IsNil(), "%s: %v", m.Name, out[1])
+		if !isRecorded[m.Name] {
+			assert.Equal(t, before, l.Head().Entries, "%s is recorded", m.Name)
+			continue
+		}
+		if assert.Equal(t, before+1, l.Head().Entries, "%s is not recorded", m.Name) {
+			entries := readEntries(t, path)
+			assert.Equal(t, srv.lastMethod(), entries[len(entries)-1].Method, m.Name)
+		}
+	}
+	assert.Equal(t, client.NumMethod(), len(reviewed))
+
+	head, err := verifyFile(t, path, testKey, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, l.Head(), head)
+	assert.Equal(t, uint64(len(recorded)), head.Entries)
+}
+
+func TestRecord(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	l, err := Open(path, testKey)
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	srv := newRPCServer()
+	defer srv.Close()
+	srv.results["transfer"] = `{"amount":5,"fee":1,"tx_hash":"aa","tx_key":"0123","tx_metadata":"meta"}`
+	srv.results["sweep_all"] = `{"tx_hash_list":["bb","cc"],"tx_key_list":["k1","k2"]}`
+	srv.results["relay_tx"] = `{"tx_hash":"dd"}`
+	srv.errors["change_wallet_password"] = "wrong password"
+	alice := New(srv.client(t), l, "alice")
+	bob := New(srv.client(t), l, "bob")
+
+	_, err = alice.OpenWallet(&wallet.OpenWalletRequest{Filename: "hot", Password: gonero.Secret("hunter2")})
+	require.NoError(t, err)
+	resp, err := alice.Transfer(&wallet.TransferRequest{
+		Destinations:  []wallet.Destination{{Amount: 5, Address: "addr"}},
+		GetTxKey:      true,
+		GetTxMetadata: true,
+		DoNotRelay:    true,
+	})
+	require.NoError(t, err)
+	// the caller still receives the secrets
+	assert.Equal(t, gonero.Secret("0123"), resp.TxKey)
+	_, err = bob.RelayTx(&wallet.RelayTxRequest{Hex: "meta"})
+	require.NoError(t, err)
+	_, err = bob.SweepAll(&wallet.SweepAllRequest{Address: "addr", GetTxKeys: true})
+	require.NoError(t, err)
+	_, err = bob.ChangeWalletPassword(&wallet.ChangeWalletPasswordRequest{OldPassword: gonero.Secret("a"), NewPassword: gonero.Secret("b")})
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotRecorded))
+	// reads are not recorded
+	_, err = bob.GetBalance(&wallet.GetBalanceRequest{})
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"hunter2", "0123", `"meta"`, "k1", `"b"`} {
+		assert.NotContains(t, string(data), secret)
+	}
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 5)
+	for i, e := range entries {
+		assert.Equal(t, uint64(i), e.Seq)
+		assert.Equal(t, now, e.Time)
+		if i > 0 {
+			assert.Equal(t, entries[i-1].Hash, e.Prev)
+		}
+	}
+	assert.Equal(t, "alice", entries[0].Caller)
+	assert.Equal(t, "open_wallet", entries[0].Method)
+	assert.JSONEq(t, `{"filename":"hot","password":"[REDACTED]"}`, string(entries[0].Request))
+
+	assert.Equal(t, "transfer", entries[1].Method)
+	assert.Equal(t, []string{"aa"}, entries[1].Txids)
+	var transfer map[string]interface{}
+	require.NoError(t, json.Unmarshal(entries[1].Response, &transfer))
+	assert.Equal(t, "[REDACTED]", transfer["tx_key"])
+	metadata := transfer["tx_metadata"].(string)
+	assert.True(t, strings.HasPrefix(metadata, "sha256:"), metadata)
+
+	// the relayed metadata is recognized by its digest
+	assert.Equal(t, "bob", entries[2].Caller)
+	assert.Equal(t, "relay_tx", entries[2].Method)
+	assert.JSONEq(t, `{"hex":"`+metadata+`"}`, string(entries[2].Request))
+	assert.Equal(t, []string{"dd"}, entries[2].Txids)
+
+	assert.Equal(t, []string{"bb", "cc"}, entries[3].Txids)
+	var sweep map[string]interface{}
+	require.NoError(t, json.Unmarshal(entries[3].Response, &sweep))
+	assert.Equal(t, []interface{}{"[REDACTED]", "[REDACTED]"}, sweep["tx_key_list"])
+
+	assert.Equal(t, "change_wallet_password", entries[4].Method)
+	assert.Contains(t, entries[4].Error, "wrong password")
+	assert.Empty(t, entries[4].Response)
+	assert.JSONEq(t, `{"old_password":"[REDACTED]","new_password":"[REDACTED]"}`, string(entries[4].Request))
+
+	head := l.Head()
+	assert.Equal(t, Head{Entries: 5, Hash: entries[4].Hash}, head)
+	assert.NoError(t, l.Close())
+	assert.Equal(t, ErrClosed, l.Close())
+	_, err = alice.Store(&wallet.StoreRequest{})
+	assert.True(t, errors.Is(err, ErrNotRecorded), "%v", err)
+
+	// the log is continued once reopened
+	l, err = Open(path, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, head, l.Head())
+	_, err = New(srv.client(t), l, "carol").Store(&wallet.StoreRequest{})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	entries = readEntries(t, path)
+	require.Len(t, entries, 6)
+	assert.Equal(t, "carol", entries[5].Caller)
+	_, err = verifyFile(t, path, testKey, &head)
+	assert.NoError(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	type test struct {
+		field string
+		v     interface{}
+	}
+	tests := []test{
+		{"unsigned_txset", &wallet.TransferResponse{UnsignedTxset: "set"}},
+		{"multisig_txset", &wallet.TransferResponse{MultisigTxset: "set"}},
+		{"unsigned_txset", &wallet.SignTransferRequest{UnsignedTxset: "set"}},
+		{"signed_txset", &wallet.SignTransferResponse{SignedTxset: "set"}},
+		{"tx_data_hex", &wallet.SubmitTransferRequest{TxDataHex: "set"}},
+		{"tx_data_hex", &wallet.SignMultisigRequest{TxDataHex: "set"}},
+		{"tx_data_hex", &wallet.SubmitMultisigRequest{TxDataHex: "set"}},
+		{"hex", &wallet.RelayTxRequest{Hex: "set"}},
+	}
+	for _, tc := range tests {
+		t.Run(reflect.TypeOf(tc.v).Elem().Name(), func(t *testing.T) {
+			fields := redact(tc.v).(map[string]interface{})
+			assert.Equal(t, "sha256:6ee0eb490ff832101cf82a3d387c35f29e4230be786978f7acf9e811febf6723", fields[tc.field])
+		})
+	}
+	// informational messages are kept
+	fields := redact(&wallet.GenerateFromKeysResponse{Info: "Wallet has been generated successfully."}).(map[string]interface{})
+	assert.Equal(t, "Wallet has been generated successfully.", fields["info"])
+}
+
+func TestVerify(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	l, err := Open(path, testKey)
+	require.NoError(t, err)
+	srv := newRPCServer()
+	defer srv.Close()
+	w := New(srv.client(t), l, "alice")
+	for i := 0; i < 4; i++ {
+		_, err := w.Store(&wallet.StoreRequest{})
+		require.NoError(t, err)
+	}
+	head := l.Head()
+	require.NoError(t, l.Close())
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	lines = lines[:len(lines)-1]
+	require.Len(t, lines, 4)
+
+	check := func(log string, key gonero.Secret, anchor *Head) error {
+		_, err := Verify(strings.NewReader(log), key, anchor)
+		return err
+	}
+	assert.NoError(t, check(string(data), testKey, &head))
+	assert.NoError(t, check("", testKey, nil))
+
+	// edited entry
+	edited := strings.Replace(string(data), `"caller":"alice"`, `"caller":"mallory"`, 1)
+	assert.True(t, errors.Is(check(edited, testKey, nil), ErrBroken))
+
+	// removed entry
+	removed := lines[0] + lines[1] + lines[3]
+	assert.True(t, errors.Is(check(removed, testKey, nil), ErrBroken))
+
+	// reordered entries
+	reordered := lines[0] + lines[2] + lines[1] + lines[3]
+	assert.True(t, errors.Is(check(reordered, testKey, nil), ErrBroken))
+
+	// removed last entries, only detected with an anchor
+	truncated := lines[0] + lines[1]
+	assert.NoError(t, check(truncated, testKey, nil))
+	assert.True(t, errors.Is(check(truncated, testKey, &head), ErrTruncated))
+
+	// torn entry
+	torn := string(data) + `{"seq":4`
+	assert.True(t, errors.Is(check(torn, testKey, nil), ErrBroken))
+
+	// entries chained again without the key
+	var rechained bytes.Buffer
+	prev := ""
+	for i, line := range lines {
+		var e Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		if i == 1 {
+			e.Caller = "mallory"
+		}
+		e.Prev = prev
+		e.Hash, err = sum([]byte("guess"), &e)
+		require.NoError(t, err)
+		prev = e.Hash
+		line, err := json.Marshal(&e)
+		require.NoError(t, err)
+		rechained.Write(append(line, '\n'))
+	}
+	assert.NoError(t, check(rechained.String(), gonero.Secret("guess"), nil))
+	assert.True(t, errors.Is(check(rechained.String(), testKey, nil), ErrBroken))
+	// a rewritten log of as many entries does not match the anchor
+	assert.True(t, errors.Is(check(rechained.String(), gonero.Secret("guess"), &head), ErrBroken))
+}
+
+func TestOpenTorn(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	l, err := Open(path, nil)
+	require.NoError(t, err)
+	srv := newRPCServer()
+	defer srv.Close()
+	w := New(srv.client(t), l, "alice")
+	_, err = w.Store(&wallet.StoreRequest{})
+	require.NoError(t, err)
+	head := l.Head()
+	require.NoError(t, l.Close())
+
+	// a crash tore the second entry
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`{"seq":1,"time":`))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = Open(path, nil)
+	require.NoError(t, err)
+	assert.Equal(t, head, l.Head())
+	_, err = New(srv.client(t), l, "alice").Store(&wallet.StoreRequest{})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	head, err = verifyFile(t, path, nil, &head)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), head.Entries)
+
+	// an edited log is not opened
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, bytes.Replace(data, []byte(`"seq":0`), []byte(`"seq":7`), 1), 0600))
+	_, err = Open(path, nil)
+	assert.True(t, errors.Is(err, ErrBroken))
+}
@@ -0,0 +1,230 @@
+// Package audit keeps a tamper-evident log of the state-changing calls
+// made to monero-wallet-rpc.
+//
+// Wallet decorates a wallet.Client and appends an Entry per call to a Log:
+// the caller, the redacted request and response or error, and the txids.
+// Entries are chained by hashes, keyed with HMAC-SHA256 when the log has a
+// key, so that Verify detects edited, reordered or removed entries.
+// Removing the last entries is only detected against a Head saved
+// elsewhere, such as the one of the last verification.
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+)
+
+// Audit errors
+var (
+	ErrBroken      = errors.New("audit: broken chain")
+	ErrTruncated   = errors.New("audit: truncated log")
+	ErrClosed      = errors.New("audit: closed")
+	ErrNotRecorded = errors.New("audit: call not recorded")
+)
+
+// Entry is a call recorded in a Log
+type Entry struct {
+	// Position of the entry in the log, from 0.
+	Seq uint64 `json:"seq"`
+	// Time of the call.
+	Time time.Time `json:"time"`
+	// Identity of the caller.
+	Caller string `json:"caller"`
+	// JSON-RPC method.
+	Method string `json:"method"`
+	// Request, with its secrets redacted.
+	Request json.RawMessage `json:"request"`
+	// Response of successful calls, with its secrets redacted.
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error of failed calls.
+	Error string `json:"error,omitempty"`
+	// Transactions of the response.
+	Txids []string `json:"txids,omitempty"`
+	// Hash of the previous entry, empty for the first one.
+	Prev string `json:"prev"`
+	// Hash of the entry.
+	Hash string `json:"hash"`
+}
+
+// Head identifies the last entry of a log
+type Head struct {
+	// Number of entries.
+	Entries uint64 `json:"entries"`
+	// Hash of the last entry, empty without entries.
+	Hash string `json:"hash"`
+}
+
+// Log is an append-only file of hash-chained JSON entries, one per line,
+// synced after each entry
+type Log struct {
+	key []byte
+	now func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	head   Head
+	closed bool
+}
+
+// Open verifies the log at path, creating it if needed, and opens it for
+// appending. An empty key chains the entries with plain SHA-256, which
+// anyone may recompute after editing the log. An entry torn by a crash
+// while appending is dropped.
+func Open(path string, key gonero.Secret) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{key: append([]byte{}, key...), now: time.Now, file: f}
+	head, size, err := verify(f, l.key, nil, true)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.head = head
+	return l, nil
+}
+
+// Head returns the head of the log, to be kept apart from it so that a
+// later Verify detects the removal of entries.
+func (l *Log) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	l.closed = true
+	return l.file.Close()
+}
+
+// record appends a call
+func (l *Log) record(caller, method string, req, resp interface{}, callErr error) error {
+	e := &Entry{Caller: caller, Method: method}
+	var err error
+	if e.Request, err = json.Marshal(redact(req)); err != nil {
+		return err
+	}
+	if callErr != nil {
+		e.Error = callErr.Error()
+	} else {
+		r := redact(resp)
+		if e.Response, err = json.Marshal(r); err != nil {
+			return err
+		}
+		e.Txids = txids(r)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	e.Seq = l.head.Entries
+	e.Time = l.now().UTC()
+	e.Prev = l.head.Hash
+	if e.Hash, err = sum(l.key, e); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.head = Head{Entries: e.Seq + 1, Hash: e.Hash}
+	return nil
+}
+
+// Verify checks the chain of the log read from r and returns its head.
+// The log must contain the entry identified by anchor, unless it is nil.
+func Verify(r io.Reader, key gonero.Secret, anchor *Head) (Head, error) {
+	head, _, err := verify(r, key, anchor, false)
+	return head, err
+}
+
+// verify checks the chain of the log and returns its head and the size of
+// its valid entries. A torn last line is tolerated if torn is set.
+func verify(r io.Reader, key []byte, anchor *Head, torn bool) (Head, int64, error) {
+	var head Head
+	var size int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 && !torn {
+				return head, size, fmt.Errorf("%w: torn entry %d", ErrBroken, head.Entries)
+			}
+			if anchor != nil && head.Entries < anchor.Entries {
+				return head, size, fmt.Errorf("%w: %d entries, %d anchored", ErrTruncated, head.Entries, anchor.Entries)
+			}
+			return head, size, nil
+		}
+		if err != nil {
+			return head, size, err
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return head, size, fmt.Errorf("%w: entry %d: %v", ErrBroken, head.Entries, err)
+		}
+		if e.Seq != head.Entries || e.Prev != head.Hash {
+			return head, size, fmt.Errorf("%w: entry %d does not follow entry %d", ErrBroken, head.Entries, int64(head.Entries)-1)
+		}
+		h, err := sum(key, &e)
+		if err != nil {
+			return head, size, err
+		}
+		if !hmac.Equal([]byte(h), []byte(e.Hash)) {
+			return head, size, fmt.Errorf("%w: entry %d was modified", ErrBroken, e.Seq)
+		}
+		head = Head{Entries: e.Seq + 1, Hash: e.Hash}
+		size += int64(len(line))
+		if anchor != nil && head.Entries == anchor.Entries && head.Hash != anchor.Hash {
+			return head, size, fmt.Errorf("%w: entry %d is not the anchored one", ErrBroken, e.Seq)
+		}
+	}
+}
+
+// sum returns the hash of an entry, computed without its Hash
+func sum(key []byte, e *Entry) (string, error) {
+	c := *e
+	c.Hash = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
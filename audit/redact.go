@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/konraddical2/gonero"
+)
+
+// redacted replaces the secrets of requests and responses
+const redacted = "[REDACTED]"
+
+// digested lists the JSON fields which are not gonero.Secret but hold key
+// material, such as the tx key of relay metadata or the outputs spent by
+// transaction sets. They are replaced by a digest, so that the same value
+// can still be recognized across entries.
+var digested = map[string]bool{
+	"hex":              true,
+	"tx_metadata":      true,
+	"tx_metadata_list": true,
+	"multisig_info":    true,
+	"unsigned_txset":   true,
+	"signed_txset":     true,
+	"multisig_txset":   true,
+	"tx_data_hex":      true,
+}
+
+var secretType = reflect.TypeOf(gonero.Secret{})
+
+// redact returns a JSON value of v, a request or a response, with its
+// secrets redacted and its key material digested
+func redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redactValue(reflect.ValueOf(v), false)
+}
+
+func redactValue(v reflect.Value, digest bool) interface{} {
+	if v.Type() == secretType {
+		if v.Len() == 0 {
+			return ""
+		}
+		return redacted
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), digest)
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, omitempty := jsonName(f)
+			if name == "" || omitempty && v.Field(i).IsZero() {
+				continue
+			}
+			fields[name] = redactValue(v.Field(i), digested[name])
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = redactValue(v.Index(i), digest)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = redactValue(v.MapIndex(k), digest)
+		}
+		return m
+	case reflect.String:
+		if digest && v.Len() > 0 {
+			sum := sha256.Sum256([]byte(v.String()))
+			return "sha256:" + hex.EncodeToString(sum[:])
+		}
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
+
+// jsonName returns the JSON name of a field, empty if it is not marshaled
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	omitempty := false
+	for _, opt := range parts[1:] {
+		omitempty = omitempty || opt == "omitempty"
+	}
+	return name, omitempty
+}
+
+// txids returns the transactions of a redacted response
+func txids(resp interface{}) []string {
+	fields, ok := resp.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var txids []string
+	for _, name := range []string{"tx_hash", "tx_hash_list", "txid", "txids"} {
+		switch v := fields[name].(type) {
+		case string:
+			if v != "" {
+				txids = append(txids, v)
+			}
+		case []interface{}:
+			for _, txid := range v {
+				if s, ok := txid.(string); ok && s != "" {
+					txids = append(txids, s)
+				}
+			}
+		}
+	}
+	return txids
+}
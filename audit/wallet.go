@@ -0,0 +1,361 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Wallet is a wallet.Client recording its state-changing calls in a Log.
+// Other calls are passed through to the decorated client, which cannot be
+// reached otherwise.
+type Wallet struct {
+	client
+
+	log    *Log
+	caller string
+}
+
+var _ wallet.Client = (*Wallet)(nil)
+
+// client is the decorated wallet.Client, embedded unexported so that its
+// state-changing methods are only reachable through the log
+type client interface {
+	wallet.Client
+}
+
+// New returns a Wallet recording the calls made through client by caller.
+// Callers share a Log with a Wallet each.
+func New(client wallet.Client, log *Log, caller string) *Wallet {
+	return &Wallet{client: client, log: log, caller: caller}
+}
+
+// record appends a call to the log. The call happened even when it cannot
+// be recorded, so its response is returned along with ErrNotRecorded.
+func (w *Wallet) record(method string, req, resp interface{}, err error) error {
+	if lerr := w.log.record(w.caller, method, req, resp, err); lerr != nil {
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v (call failed: %v)", ErrNotRecorded, method, lerr, err)
+		}
+		return fmt.Errorf("%w: %s: %v", ErrNotRecorded, method, lerr)
+	}
+	return err
+}
+
+// SetDaemon implements wallet.Client.
+func (w *Wallet) SetDaemon(req *wallet.SetDaemonRequest) (*wallet.SetDaemonResponse, error) {
+	resp, err := w.client.SetDaemon(req)
+	return resp, w.record("set_daemon", req, resp, err)
+}
+
+// CreateAddress implements wallet.Client.
+func (w *Wallet) CreateAddress(req *wallet.CreateAddressRequest) (*wallet.CreateAddressResponse, error) {
+	resp, err := w.client.CreateAddress(req)
+	return resp, w.record("create_address", req, resp, err)
+}
+
+// LabelAddress implements wallet.Client.
+func (w *Wallet) LabelAddress(req *wallet.LabelAddressRequest) (*wallet.LabelAddressResponse, error) {
+	resp, err := w.client.LabelAddress(req)
+	return resp, w.record("label_address", req, resp, err)
+}
+
+// CreateAccount implements wallet.Client.
+func (w *Wallet) CreateAccount(req *wallet.CreateAccountRequest) (*wallet.CreateAccountResponse, error) {
+	resp, err := w.client.CreateAccount(req)
+	return resp, w.record("create_account", req, resp, err)
+}
+
+// LabelAccount implements wallet.Client.
+func (w *Wallet) LabelAccount(req *wallet.LabelAccountRequest) (*wallet.LabelAccountResponse, error) {
+	resp, err := w.client.LabelAccount(req)
+	return resp, w.record("label_account", req, resp, err)
+}
+
+// TagAccounts implements wallet.Client.
+func (w *Wallet) TagAccounts(req *wallet.TagAccountsRequest) (*wallet.TagAccountsResponse, error) {
+	resp, err := w.client.TagAccounts(req)
+	return resp, w.record("tag_accounts", req, resp, err)
+}
+
+// UntagAccounts implements wallet.Client.
+func (w *Wallet) UntagAccounts(req *wallet.UntagAccountsRequest) (*wallet.UntagAccountsResponse, error) {
+	resp, err := w.client.UntagAccounts(req)
+	return resp, w.record("untag_accounts", req, resp, err)
+}
+
+// SetAccountTagDescription implements wallet.Client.
+func (w *Wallet) SetAccountTagDescription(req *wallet.SetAccountTagDescriptionRequest) (*wallet.SetAccountTagDescriptionResponse, error) {
+	resp, err := w.client.SetAccountTagDescription(req)
+	return resp, w.record("set_account_tag_description", req, resp, err)
+}
+
+// Transfer implements wallet.Client.
+func (w *Wallet) Transfer(req *wallet.TransferRequest) (*wallet.TransferResponse, error) {
+	resp, err := w.client.Transfer(req)
+	return resp, w.record("transfer", req, resp, err)
+}
+
+// TransferSplit implements wallet.Client.
+func (w *Wallet) TransferSplit(req *wallet.TransferSplitRequest) (*wallet.TransferSplitResponse, error) {
+	resp, err := w.client.TransferSplit(req)
+	return resp, w.record("transfer_split", req, resp, err)
+}
+
+// SignTransfer implements wallet.Client.
+func (w *Wallet) SignTransfer(req *wallet.SignTransferRequest) (*wallet.SignTransferResponse, error) {
+	resp, err := w.client.SignTransfer(req)
+	return resp, w.record("sign_transfer", req, resp, err)
+}
+
+// SubmitTransfer implements wallet.Client.
+func (w *Wallet) SubmitTransfer(req *wallet.SubmitTransferRequest) (*wallet.SubmitTransferResponse, error) {
+	resp, err := w.client.SubmitTransfer(req)
+	return resp, w.record("submit_transfer", req, resp, err)
+}
+
+// SweepDust implements wallet.Client.
+func (w *Wallet) SweepDust(req *wallet.SweepDustRequest) (*wallet.SweepDustResponse, error) {
+	resp, err := w.client.SweepDust(req)
+	return resp, w.record("sweep_dust", req, resp, err)
+}
+
+// SweepAll implements wallet.Client.
+func (w *Wallet) SweepAll(req *wallet.SweepAllRequest) (*wallet.SweepAllResponse, error) {
+	resp, err := w.client.SweepAll(req)
+	return resp, w.record("sweep_all", req, resp, err)
+}
+
+// SweepSingle implements wallet.Client.
+func (w *Wallet) SweepSingle(req *wallet.SweepSingleRequest) (*wallet.SweepSingleResponse, error) {
+	resp, err := w.client.SweepSingle(req)
+	return resp, w.record("sweep_single", req, resp, err)
+}
+
+// RelayTx implements wallet.Client.
+func (w *Wallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	resp, err := w.client.RelayTx(req)
+	return resp, w.record("relay_tx", req, resp, err)
+}
+
+// Store implements wallet.Client.
+func (w *Wallet) Store(req *wallet.StoreRequest) (*wallet.StoreResponse, error) {
+	resp, err := w.client.Store(req)
+	return resp, w.record("store", req, resp, err)
+}
+
+// StopWallet implements wallet.Client.
+func (w *Wallet) StopWallet(req *wallet.StopWalletRequest) (*wallet.StopWalletResponse, error) {
+	resp, err := w.client.StopWallet(req)
+	return resp, w.record("stop_wallet", req, resp, err)
+}
+
+// RescanBlockchain implements wallet.Client.
+func (w *Wallet) RescanBlockchain(req *wallet.RescanBlockchainRequest) (*wallet.RescanBlockchainResponse, error) {
+	resp, err := w.client.RescanBlockchain(req)
+	return resp, w.record("rescan_blockchain", req, resp, err)
+}
+
+// SetTxNotes implements wallet.Client.
+func (w *Wallet) SetTxNotes(req *wallet.SetTxNotesRequest) (*wallet.SetTxNotesResponse, error) {
+	resp, err := w.client.SetTxNotes(req)
+	return resp, w.record("set_tx_notes", req, resp, err)
+}
+
+// SetAttribute implements wallet.Client.
+func (w *Wallet) SetAttribute(req *wallet.SetAttributeRequest) (*wallet.SetAttributeResponse, error) {
+	resp, err := w.client.SetAttribute(req)
+	return resp, w.record("set_attribute", req, resp, err)
+}
+
+// ImportOutputs implements wallet.Client.
+func (w *Wallet) ImportOutputs(req *wallet.ImportOutputsRequest) (*wallet.ImportOutputsResponse, error) {
+	resp, err := w.client.ImportOutputs(req)
+	return resp, w.record("import_outputs", req, resp, err)
+}
+
+// ImportKeyImages implements wallet.Client.
+func (w *Wallet) ImportKeyImages(req *wallet.ImportKeyImagesRequest) (*wallet.ImportKeyImagesResponse, error) {
+	resp, err := w.client.ImportKeyImages(req)
+	return resp, w.record("import_key_images", req, resp, err)
+}
+
+// AddAddressBook implements wallet.Client.
+func (w *Wallet) AddAddressBook(req *wallet.AddAddressBookRequest) (*wallet.AddAddressBookResponse, error) {
+	resp, err := w.client.AddAddressBook(req)
+	return resp, w.record("add_address_book", req, resp, err)
+}
+
+// EditAddressBook implements wallet.Client.
+func (w *Wallet) EditAddressBook(req *wallet.EditAddressBookRequest) (*wallet.EditAddressBookResponse, error) {
+	resp, err := w.client.EditAddressBook(req)
+	return resp, w.record("edit_address_book", req, resp, err)
+}
+
+// DeleteAddressBook implements wallet.Client.
+func (w *Wallet) DeleteAddressBook(req *wallet.DeleteAddressBookRequest) (*wallet.DeleteAddressBookResponse, error) {
+	resp, err := w.client.DeleteAddressBook(req)
+	return resp, w.record("delete_address_book", req, resp, err)
+}
+
+// AutoRefresh implements wallet.Client.
+func (w *Wallet) AutoRefresh(req *wallet.AutoRefreshRequest) (*wallet.AutoRefreshResponse, error) {
+	resp, err := w.client.AutoRefresh(req)
+	return resp, w.record("auto_refresh", req, resp, err)
+}
+
+// RescanSpent implements wallet.Client.
+func (w *Wallet) RescanSpent(req *wallet.RescanSpentRequest) (*wallet.RescanSpentResponse, error) {
+	resp, err := w.client.RescanSpent(req)
+	return resp, w.record("rescan_spent", req, resp, err)
+}
+
+// StartMining implements wallet.Client.
+func (w *Wallet) StartMining(req *wallet.StartMiningRequest) (*wallet.StartMiningResponse, error) {
+	resp, err := w.client.StartMining(req)
+	return resp, w.record("start_mining", req, resp, err)
+}
+
+// StopMining implements wallet.Client.
+func (w *Wallet) StopMining(req *wallet.StopMiningRequest) (*wallet.StopMiningResponse, error) {
+	resp, err := w.client.StopMining(req)
+	return resp, w.record("stop_mining", req, resp, err)
+}
+
+// CreateWallet implements wallet.Client.
+func (w *Wallet) CreateWallet(req *wallet.CreateWalletRequest) (*wallet.CreateWalletResponse, error) {
+	resp, err := w.client.CreateWallet(req)
+	return resp, w.record("create_wallet", req, resp, err)
+}
+
+// GenerateFromKeys implements wallet.Client.
+func (w *Wallet) GenerateFromKeys(req *wallet.GenerateFromKeysRequest) (*wallet.GenerateFromKeysResponse, error) {
+	resp, err := w.client.GenerateFromKeys(req)
+	return resp, w.record("generate_from_keys", req, resp, err)
+}
+
+// OpenWallet implements wallet.Client.
+func (w *Wallet) OpenWallet(req *wallet.OpenWalletRequest) (*wallet.OpenWalletResponse, error) {
+	resp, err := w.client.OpenWallet(req)
+	return resp, w.record("open_wallet", req, resp, err)
+}
+
+// RestoreDeterministicWallet implements wallet.Client.
+func (w *Wallet) RestoreDeterministicWallet(req *wallet.RestoreDeterministicWalletRequest) (*wallet.RestoreDeterministicWalletResponse, error) {
+	resp, err := w.client.RestoreDeterministicWallet(req)
+	return resp, w.record("restore_deterministic_wallet", req, resp, err)
+}
+
+// CloseWallet implements wallet.Client.
+func (w *Wallet) CloseWallet(req *wallet.CloseWalletRequest) (*wallet.CloseWalletResponse, error) {
+	resp, err := w.client.CloseWallet(req)
+	return resp, w.record("close_wallet", req, resp, err)
+}
+
+// ChangeWalletPassword implements wallet.Client.
+func (w *Wallet) ChangeWalletPassword(req *wallet.ChangeWalletPasswordRequest) (*wallet.ChangeWalletPasswordResponse, error) {
+	resp, err := w.client.ChangeWalletPassword(req)
+	return resp, w.record("change_wallet_password", req, resp, err)
+}
+
+// PrepareMultisig implements wallet.Client.
+func (w *Wallet) PrepareMultisig(req *wallet.PrepareMultisigRequest) (*wallet.PrepareMultisigResponse, error) {
+	resp, err := w.client.PrepareMultisig(req)
+	return resp, w.record("prepare_multisig", req, resp, err)
+}
+
+// MakeMultisig implements wallet.Client.
+func (w *Wallet) MakeMultisig(req *wallet.MakeMultisigRequest) (*wallet.MakeMultisigResponse, error) {
+	resp, err := w.client.MakeMultisig(req)
+	return resp, w.record("make_multisig", req, resp, err)
+}
+
+// ExportMultisigInfo implements wallet.Client.
+func (w *Wallet) ExportMultisigInfo(req *wallet.ExportMultisigInfoRequest) (*wallet.ExportMultisigInfoResponse, error) {
+	resp, err := w.client.ExportMultisigInfo(req)
+	return resp, w.record("export_multisig_info", req, resp, err)
+}
+
+// ImportMultisigInfo implements wallet.Client.
+func (w *Wallet) ImportMultisigInfo(req *wallet.ImportMultisigInfoRequest) (*wallet.ImportMultisigInfoResponse, error) {
+	resp, err := w.client.ImportMultisigInfo(req)
+	return resp, w.record("import_multisig_info", req, resp, err)
+}
+
+// FinalizeMultisig implements wallet.Client.
+func (w *Wallet) FinalizeMultisig(req *wallet.FinalizeMultisigRequest) (*wallet.FinalizeMultisigResponse, error) {
+	resp, err := w.client.FinalizeMultisig(req)
+	return resp, w.record("finalize_multisig", req, resp, err)
+}
+
+// ExchangeMultisigKeys implements wallet.Client.
+func (w *Wallet) ExchangeMultisigKeys(req *wallet.ExchangeMultisigKeysRequest) (*wallet.ExchangeMultisigKeysResponse, error) {
+	resp, err := w.client.ExchangeMultisigKeys(req)
+	return resp, w.record("exchange_multisig_keys", req, resp, err)
+}
+
+// SignMultisig implements wallet.Client.
+func (w *Wallet) SignMultisig(req *wallet.SignMultisigRequest) (*wallet.SignMultisigResponse, error) {
+	resp, err := w.client.SignMultisig(req)
+	return resp, w.record("sign_multisig", req, resp, err)
+}
+
+// SubmitMultisig implements wallet.Client.
+func (w *Wallet) SubmitMultisig(req *wallet.SubmitMultisigRequest) (*wallet.SubmitMultisigResponse, error) {
+	resp, err := w.client.SubmitMultisig(req)
+	return resp, w.record("submit_multisig", req, resp, err)
+}
+
+// Freeze implements wallet.Client.
+func (w *Wallet) Freeze(req *wallet.FreezeRequest) (*wallet.FreezeResponse, error) {
+	resp, err := w.client.Freeze(req)
+	return resp, w.record("freeze", req, resp, err)
+}
+
+// Thaw implements wallet.Client.
+func (w *Wallet) Thaw(req *wallet.ThawRequest) (*wallet.ThawResponse, error) {
+	resp, err := w.client.Thaw(req)
+	return resp, w.record("thaw", req, resp, err)
+}
+
+// SetLogLevel implements wallet.Client.
+func (w *Wallet) SetLogLevel(req *wallet.SetLogLevelRequest) (*wallet.SetLogLevelResponse, error) {
+	resp, err := w.client.SetLogLevel(req)
+	return resp, w.record("set_log_level", req, resp, err)
+}
+
+// SetLogCategories implements wallet.Client.
+func (w *Wallet) SetLogCategories(req *wallet.SetLogCategoriesRequest) (*wallet.SetLogCategoriesResponse, error) {
+	resp, err := w.client.SetLogCategories(req)
+	return resp, w.record("set_log_categories", req, resp, err)
+}
+
+// ScanTx implements wallet.Client.
+func (w *Wallet) ScanTx(req *wallet.ScanTxRequest) (*wallet.ScanTxResponse, error) {
+	resp, err := w.client.ScanTx(req)
+	return resp, w.record("scan_tx", req, resp, err)
+}
+
+// SetSubaddressLookahead implements wallet.Client.
+func (w *Wallet) SetSubaddressLookahead(req *wallet.SetSubaddressLookaheadRequest) (*wallet.SetSubaddressLookaheadResponse, error) {
+	resp, err := w.client.SetSubaddressLookahead(req)
+	return resp, w.record("set_subaddress_lookahead", req, resp, err)
+}
+
+// SetupBackgroundSync implements wallet.Client.
+func (w *Wallet) SetupBackgroundSync(req *wallet.SetupBackgroundSyncRequest) (*wallet.SetupBackgroundSyncResponse, error) {
+	resp, err := w.client.SetupBackgroundSync(req)
+	return resp, w.record("setup_background_sync", req, resp, err)
+}
+
+// StartBackgroundSync implements wallet.Client.
+func (w *Wallet) StartBackgroundSync(req *wallet.StartBackgroundSyncRequest) (*wallet.StartBackgroundSyncResponse, error) {
+	resp, err := w.client.StartBackgroundSync(req)
+	return resp, w.record("start_background_sync", req, resp, err)
+}
+
+// StopBackgroundSync implements wallet.Client.
+func (w *Wallet) StopBackgroundSync(req *wallet.StopBackgroundSyncRequest) (*wallet.StopBackgroundSyncResponse, error) {
+	resp, err := w.client.StopBackgroundSync(req)
+	return resp, w.record("stop_background_sync", req, resp, err)
+}
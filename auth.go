@@ -0,0 +1,203 @@
+package gonero
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gabstv/httpdigest"
+)
+
+// Auth adds authentication to the requests sent to a monero RPC server.
+type Auth interface {
+	// Transport wraps next with the authentication scheme.
+	// A nil next means http.DefaultTransport.
+	Transport(next http.RoundTripper) http.RoundTripper
+}
+
+// DigestAuth is the HTTP digest authentication used by monerod and
+// monero-wallet-rpc when started with --rpc-login.
+type DigestAuth struct {
+	Username string
+	Password Secret
+}
+
+// Transport implements Auth.
+func (a DigestAuth) Transport(next http.RoundTripper) http.RoundTripper {
+	t := httpdigest.New(a.Username, a.Password.Reveal())
+	t.Transport = orDefault(next)
+	return t
+}
+
+// BasicAuth is HTTP basic authentication, as commonly set up
+// on reverse proxies such as nginx or Caddy in front of monerod.
+type BasicAuth struct {
+	Username string
+	Password Secret
+}
+
+// Transport implements Auth.
+func (a BasicAuth) Transport(next http.RoundTripper) http.RoundTripper {
+	return &headerTransport{
+		next: orDefault(next),
+		set: func(req *http.Request) {
+			req.SetBasicAuth(a.Username, a.Password.Reveal())
+		},
+	}
+}
+
+// BearerAuth is HTTP bearer token authentication,
+// as commonly set up on reverse proxies.
+type BearerAuth struct {
+	Token Secret
+}
+
+// Transport implements Auth.
+func (a BearerAuth) Transport(next http.RoundTripper) http.RoundTripper {
+	return &headerTransport{
+		next: orDefault(next),
+		set: func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+a.Token.Reveal())
+		},
+	}
+}
+
+// LoginFileAuth is HTTP digest authentication with the credentials read
+// from a "username:password" file, such as the monero-wallet-rpc.<port>.login
+// file written by monero-wallet-rpc or a file holding monerod's --rpc-login.
+// The file is read again whenever it changes or the server rejects the
+// credentials, so a restarted wallet-rpc with new credentials is picked up.
+type LoginFileAuth struct {
+	Path string
+}
+
+// WalletRPCLoginFile returns the path of the login file monero-wallet-rpc
+// writes in dir when it generates random RPC credentials for port.
+func WalletRPCLoginFile(dir string, port uint) string {
+	return filepath.Join(dir, fmt.Sprintf("monero-wallet-rpc.%d.login", port))
+}
+
+// ReadLoginFile reads "username:password" credentials from a login file.
+func ReadLoginFile(path string) (DigestAuth, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return DigestAuth{}, err
+	}
+	defer Secret(data).Wipe()
+	return parseLogin(data)
+}
+
+func parseLogin(data []byte) (DigestAuth, error) {
+	line, err := bufio.NewReader(bytes.NewReader(data)).ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		return DigestAuth{}, fmt.Errorf("empty login")
+	}
+	line = bytes.TrimRight(line, "\r\n")
+	i := bytes.IndexByte(line, ':')
+	if i <= 0 {
+		return DigestAuth{}, fmt.Errorf("login must be formatted as username:password")
+	}
+	password := make(Secret, len(line)-i-1)
+	copy(password, line[i+1:])
+	return DigestAuth{Username: string(line[:i]), Password: password}, nil
+}
+
+// Transport implements Auth.
+func (a LoginFileAuth) Transport(next http.RoundTripper) http.RoundTripper {
+	return &loginFileTransport{path: a.Path, next: orDefault(next)}
+}
+
+type headerTransport struct {
+	next http.RoundTripper
+	set  func(*http.Request)
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request it was given
+	req2 := req.Clone(req.Context())
+	t.set(req2)
+	return t.next.RoundTrip(req2)
+}
+
+type loginFileTransport struct {
+	path string
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	auth    DigestAuth
+	digest  http.RoundTripper
+}
+
+// current returns the digest transport for the current content of the login file,
+// reloading it if the file changed since it was last read or if force is set.
+func (t *loginFileTransport) current(force bool) (http.RoundTripper, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !force && t.digest != nil && info.ModTime().Equal(t.modTime) && info.Size() == t.size {
+		return t.digest, false, nil
+	}
+	auth, err := ReadLoginFile(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := t.digest == nil || auth.Username != t.auth.Username ||
+		!bytes.Equal(auth.Password, t.auth.Password)
+	if changed {
+		t.auth.Password.Wipe()
+		t.auth = auth
+		t.digest = auth.Transport(t.next)
+	}
+	t.modTime = info.ModTime()
+	t.size = info.Size()
+	return t.digest, changed, nil
+}
+
+func (t *loginFileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	digest, _, err := t.current(false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := digest.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// the credentials may have been rotated without the file
+	// metadata changing in a way we could notice, re-read it once
+	digest, changed, err := t.current(true)
+	if err != nil || !changed {
+		return resp, nil
+	}
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	resp.Body.Close()
+	return digest.RoundTrip(req)
+}
+
+func orDefault(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		return http.DefaultTransport
+	}
+	return next
+}
@@ -0,0 +1,172 @@
+package gonero
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// digestServer is a minimal stand-in for the monero RPC digest authentication
+type digestServer struct {
+	mu       sync.Mutex
+	username string
+	password string
+}
+
+func (d *digestServer) setLogin(username, password string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.username, d.password = username, password
+}
+
+func (d *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const realm, nonce = "monero-rpc", "dGVzdG5vbmNl"
+	d.mu.Lock()
+	username, password := d.username, d.password
+	d.mu.Unlock()
+
+	params := map[string]string{}
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Digest ") {
+		for _, kv := range strings.Split(header[len("Digest "):], ", ") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				params[parts[0]] = strings.Trim(parts[1], `"`)
+			}
+		}
+	}
+	md5hex := func(s string) string { return fmt.Sprintf("%x", md5.Sum([]byte(s))) }
+	ha1 := md5hex(username + ":" + realm + ":" + password)
+	ha2 := md5hex(r.Method + ":" + params["uri"])
+	expected := md5hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+	if params["username"] != username || params["response"] != expected {
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=MD5,realm="%s",nonce="%s",stale=false`, realm, nonce))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Write(body)
+}
+
+func post(t *testing.T, cfg *RPCConfig) (int, string) {
+	resp, err := (&http.Client{Transport: cfg.Transport}).Post(cfg.Address()+"/json_rpc", "application/json", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestDigestAuth(t *testing.T) {
+	d := &digestServer{}
+	d.setLogin("monero", "secret")
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	host, port := splitTestServer(t, srv.URL)
+
+	cfg, err := NewRPCConfig("http", host, port, "monero", "secret", "")
+	assert.NoError(t, err)
+	code, body := post(t, cfg)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ping", body)
+
+	cfg, err = NewRPCConfig("http", host, port, "monero", "wrong", "")
+	assert.NoError(t, err)
+	code, _ = post(t, cfg)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestHeaderAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+	host, port := splitTestServer(t, srv.URL)
+
+	cfg, err := NewRPCConfigAuth("http", host, port, BasicAuth{Username: "foo", Password: NewSecret("bar")}, nil)
+	assert.NoError(t, err)
+	_, body := post(t, cfg)
+	assert.Equal(t, "Basic Zm9vOmJhcg==", body)
+
+	cfg, err = NewRPCConfigAuth("http", host, port, BearerAuth{Token: NewSecret("t0k3n")}, nil)
+	assert.NoError(t, err)
+	_, body = post(t, cfg)
+	assert.Equal(t, "Bearer t0k3n", body)
+}
+
+func TestLoginFileAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &digestServer{}
+	d.setLogin("monero", "first")
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	host, port := splitTestServer(t, srv.URL)
+
+	path := WalletRPCLoginFile(dir, port)
+	assert.Equal(t, filepath.Join(dir, fmt.Sprintf("monero-wallet-rpc.%d.login", port)), path)
+
+	cfg, err := NewRPCConfigAuth("http", host, port, LoginFileAuth{Path: path}, nil)
+	assert.NoError(t, err)
+	_, err = (&http.Client{Transport: cfg.Transport}).Get(cfg.Address())
+	assert.Error(t, err, "login file does not exist yet")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("monero:first\n"), 0600))
+	code, body := post(t, cfg)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ping", body)
+
+	t.Run("refreshes when the file changes", func(t *testing.T) {
+		d.setLogin("monero", "second")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("monero:second"), 0600))
+		code, body := post(t, cfg)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ping", body)
+	})
+
+	t.Run("refreshes when credentials are rejected", func(t *testing.T) {
+		d.setLogin("monero", "sec0nd")
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		// same size and modification time as the previous login
+		assert.NoError(t, ioutil.WriteFile(path, []byte("monero:sec0nd"), 0600))
+		assert.NoError(t, os.Chtimes(path, time.Now(), info.ModTime()))
+		code, body := post(t, cfg)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ping", body)
+	})
+
+	t.Run("rejects malformed files", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("nopassword"), 0600))
+		_, err := ReadLoginFile(path)
+		assert.Error(t, err)
+		assert.NoError(t, ioutil.WriteFile(path, nil, 0600))
+		_, err = ReadLoginFile(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestReadLoginFile(t *testing.T) {
+	auth, err := parseLogin([]byte("user:pa:ss\r\nignored"))
+	assert.NoError(t, err)
+	assert.Equal(t, "user", auth.Username)
+	assert.Equal(t, "pa:ss", auth.Password.Reveal())
+	_, err = ReadLoginFile("Foobar.login")
+	assert.Error(t, err)
+}
@@ -95,7 +95,7 @@ func Encode(data []byte) string {
 	lastBlockSize := lData % fullBlockSize
 	resSize := fullBlockCount*fullEncodedBlockSize + encodedBlockSizes[lastBlockSize]
 
-	res := make([]byte, resSize) // [alphabet[0]]*)
+	res := bytes.Repeat(alphabet[:1], resSize)
 
 	for i := 0; i < fullBlockCount; i++ {
 		res, _ = encodeBlock(data[(i*fullBlockSize):(i*fullBlockSize+fullBlockSize)], res, i*fullEncodedBlockSize)
@@ -160,18 +160,26 @@ func Decode(b58Str string) []byte {
 
 	//try:
 	lastBlockDecodedSize := getEncodedBlockSizesIndex(lastBlockSize)
-	//except ValueError:
-	//    raise ValueError("Invalid encoded length: %d" % lEnc)
+	if lastBlockDecodedSize < 0 {
+		return []byte{}
+	}
 
 	dataSize := fullBlockCount*fullBlockSize + lastBlockDecodedSize
 
 	data := make([]byte, dataSize)
+	var err error
 	for i := 0; i < fullBlockCount; i++ {
-		data, _ = decodeBlock(enc[(i*fullEncodedBlockSize):(i*fullEncodedBlockSize+fullEncodedBlockSize)], data, i*fullBlockSize)
+		data, err = decodeBlock(enc[(i*fullEncodedBlockSize):(i*fullEncodedBlockSize+fullEncodedBlockSize)], data, i*fullBlockSize)
+		if err != nil {
+			return []byte{}
+		}
 	}
 
 	if lastBlockSize > 0 {
-		data, _ = decodeBlock(enc[(fullBlockCount*fullEncodedBlockSize):(fullBlockCount*fullEncodedBlockSize+lastBlockSize)], data, fullBlockCount*fullBlockSize)
+		data, err = decodeBlock(enc[(fullBlockCount*fullEncodedBlockSize):(fullBlockCount*fullEncodedBlockSize+lastBlockSize)], data, fullBlockCount*fullBlockSize)
+		if err != nil {
+			return []byte{}
+		}
 	}
 	return data
 }
@@ -101,6 +101,7 @@ func TestEncode(t *testing.T) {
 		{input: "dead", expected: "Hwr"},
 		{input: "beef", expected: "FXk"},
 		{input: "deadbeef", expected: "6h8cQN"},
+		{input: "0000000000000001", expected: "11111111112"},
 		{input: "1865fd9e473d77c9b0894118f312b7f94931079108a12018720840696fcd1f9bb7c97cdd4e0b7985be9e436a6f360947c795cb4e890bd89ca7e620a5960efd886465eea013",
 			expected: "55hKAMnUWXaWXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmCKyGuXY"},
 	}
@@ -122,6 +123,9 @@ func TestDecode(t *testing.T) {
 		{input: "Hwr", expected: "dead"},
 		{input: "FXk", expected: "beef"},
 		{input: "6h8cQN", expected: "deadbeef"},
+		{input: "11111111112", expected: "0000000000000001"},
+		{input: "1", expected: ""},
+		{input: "Hw0", expected: ""},
 		{input: "55hKAMnUWXaWXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmCKyGuXY",
 			expected: "1865fd9e473d77c9b0894118f312b7f94931079108a12018720840696fcd1f9bb7c97cdd4e0b7985be9e436a6f360947c795cb4e890bd89ca7e620a5960efd886465eea013"},
 	}
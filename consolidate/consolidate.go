@@ -0,0 +1,320 @@
+// Package consolidate merges the many small outputs of a wallet into fewer
+// ones, so that large amounts can be sent again without hitting the
+// transaction size limit.
+//
+// A Planner groups the available outputs of an account by subaddress and
+// unlock status, and proposes a SweepAll per subaddress sweeping its small
+// unlocked outputs back to the wallet. monero-wallet-rpc never mixes
+// subaddresses in a sweep transaction, and SweepSingle spends a single
+// output, so neither merging subaddresses nor SweepSingle would reduce the
+// output count. Sweeps are ranked by fee per removed output, so that a fee
+// budget removes as many outputs as possible.
+package consolidate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Planner default values
+const (
+	DefaultMaxInputs  = 100
+	DefaultMinOutputs = 2
+)
+
+// ErrNoFeeEstimate is returned when the daemon estimates no fee for the priority
+var ErrNoFeeEstimate = errors.New("consolidate: no fee estimate")
+
+// Config configures a Planner
+type Config struct {
+	// Account to consolidate.
+	AccountIndex uint64
+	// Outputs of this amount or more are left alone, 0 to consolidate
+	// every output. In atomic units.
+	Threshold gonero.AtomicXMR
+	// Subaddresses with fewer small outputs are left alone, defaults to
+	// DefaultMinOutputs.
+	MinOutputs int
+	// Inputs per transaction assumed by the estimates, defaults to
+	// DefaultMaxInputs. monero-wallet-rpc splits sweeps by weight.
+	MaxInputs int
+	// Estimated fee of the plan above which the costliest sweeps are
+	// deferred, 0 for no limit. In atomic units.
+	MaxFee gonero.AtomicXMR
+	// Priority of the sweeps, the default priority of the wallet if 0.
+	Priority wallet.PriorityType
+	// Address receiving every consolidated output. Each subaddress
+	// receives its own outputs if empty.
+	Address string
+}
+
+// Group is a set of outputs of a subaddress
+type Group struct {
+	// Subaddress of the outputs.
+	SubaddrIndex uint64 `json:"subaddr_index"`
+	// Whether the outputs can be spent now.
+	Unlocked bool `json:"unlocked"`
+	// Number of outputs.
+	Outputs int `json:"outputs"`
+	// Amount of the outputs, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+}
+
+// Sweep is a SweepAll call consolidating the small outputs of a subaddress
+type Sweep struct {
+	// Subaddress of the swept outputs.
+	SubaddrIndex uint64 `json:"subaddr_index"`
+	// Address receiving the consolidated outputs.
+	Address string `json:"address"`
+	// Outputs below this amount are swept, every output if 0. In atomic units.
+	BelowAmount gonero.AtomicXMR `json:"below_amount"`
+	// Number of swept outputs.
+	Inputs int `json:"inputs"`
+	// Amount of the swept outputs, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Estimated number of transactions, each creating one output.
+	Txs int `json:"txs"`
+	// Estimated fee, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// Removed returns the number of outputs removed by the sweep.
+func (s *Sweep) Removed() int {
+	return s.Inputs - s.Txs
+}
+
+// Plan is a consolidation of an account
+type Plan struct {
+	// Account to consolidate.
+	AccountIndex uint64 `json:"account_index"`
+	// Priority of the sweeps.
+	Priority wallet.PriorityType `json:"priority"`
+	// Fee per byte of the priority, in atomic units.
+	FeePerByte uint64 `json:"fee_per_byte"`
+	// Outputs of the account by subaddress and unlock status. Frozen
+	// outputs are left out.
+	Groups []Group `json:"groups"`
+	// Sweeps to make, cheapest per removed output first.
+	Sweeps []Sweep `json:"sweeps"`
+	// Sweeps exceeding the fee budget.
+	Deferred []Sweep `json:"deferred,omitempty"`
+	// Number of outputs of the account, before and after the sweeps.
+	OutputsBefore int `json:"outputs_before"`
+	OutputsAfter  int `json:"outputs_after"`
+	// Estimated fee of the sweeps, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// Planner plans and executes consolidations
+type Planner struct {
+	cfg    Config
+	client wallet.Client
+	daemon daemon.Client
+}
+
+// New returns a Planner of the wallet behind client, estimating fees with
+// the daemon.
+func New(client wallet.Client, daemon daemon.Client, cfg *Config) *Planner {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.MinOutputs <= 0 {
+		c.MinOutputs = DefaultMinOutputs
+	}
+	if c.MaxInputs <= 0 {
+		c.MaxInputs = DefaultMaxInputs
+	}
+	return &Planner{cfg: c, client: client, daemon: daemon}
+}
+
+// Plan reads the available outputs of the account and proposes the sweeps
+// consolidating them.
+func (p *Planner) Plan() (*Plan, error) {
+	incoming, err := p.client.IncomingTransfers(&wallet.IncomingTransfersRequest{
+		TransferType: wallet.TransferAvailable,
+		AccountIndex: p.cfg.AccountIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	priority, feePerByte, mask, err := p.fee()
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{AccountIndex: p.cfg.AccountIndex, Priority: priority, FeePerByte: feePerByte}
+
+	type key struct {
+		minor    uint64
+		unlocked bool
+	}
+	groups := make(map[key]*Group)
+	small := make(map[uint64]*Sweep)
+	for _, t := range incoming.Transfers {
+		if t.Spent {
+			continue
+		}
+		plan.OutputsBefore++
+		if t.Frozen {
+			continue
+		}
+		k := key{t.SubaddrIndex.Minor, t.Unlocked}
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{SubaddrIndex: k.minor, Unlocked: k.unlocked}
+			groups[k] = g
+		}
+		g.Outputs++
+		g.Amount += t.Amount
+		if !t.Unlocked || p.cfg.Threshold > 0 && t.Amount >= p.cfg.Threshold {
+			continue
+		}
+		s, ok := small[k.minor]
+		if !ok {
+			s = &Sweep{SubaddrIndex: k.minor, Address: p.cfg.Address, BelowAmount: p.cfg.Threshold}
+			small[k.minor] = s
+		}
+		s.Inputs++
+		s.Amount += t.Amount
+	}
+	for _, g := range groups {
+		plan.Groups = append(plan.Groups, *g)
+	}
+	sort.Slice(plan.Groups, func(i, j int) bool {
+		a, b := plan.Groups[i], plan.Groups[j]
+		if a.SubaddrIndex != b.SubaddrIndex {
+			return a.SubaddrIndex < b.SubaddrIndex
+		}
+		return a.Unlocked && !b.Unlocked
+	})
+
+	weights := make(map[int]uint64)
+	var sweeps []Sweep
+	for _, s := range small {
+		if s.Inputs < p.cfg.MinOutputs {
+			continue
+		}
+		for left := s.Inputs; left > 0; left -= p.cfg.MaxInputs {
+			inputs := left
+			if inputs > p.cfg.MaxInputs {
+				inputs = p.cfg.MaxInputs
+			}
+			weight, ok := weights[inputs]
+			if !ok {
+				est, err := p.client.EstimateTxSizeAndWeight(&wallet.EstimateTxSizeAndWeightRequest{
+					NInputs:  uint32(inputs),
+					NOutputs: 2,
+				})
+				if err != nil {
+					return nil, err
+				}
+				weight = est.Weight
+				weights[inputs] = weight
+			}
+			s.Txs++
+			s.Fee += gonero.AtomicXMR(quantize(weight*feePerByte, mask))
+		}
+		if s.Removed() > 0 {
+			sweeps = append(sweeps, *s)
+		}
+	}
+	sort.Slice(sweeps, func(i, j int) bool {
+		a, b := sweeps[i], sweeps[j]
+		// a.Fee/a.Removed() < b.Fee/b.Removed()
+		x, y := uint64(a.Fee)*uint64(b.Removed()), uint64(b.Fee)*uint64(a.Removed())
+		if x != y {
+			return x < y
+		}
+		return a.SubaddrIndex < b.SubaddrIndex
+	})
+
+	if p.cfg.Address == "" && len(sweeps) > 0 {
+		if err := p.addresses(sweeps); err != nil {
+			return nil, err
+		}
+	}
+	plan.OutputsAfter = plan.OutputsBefore
+	for _, s := range sweeps {
+		if p.cfg.MaxFee > 0 && plan.Fee+s.Fee > p.cfg.MaxFee {
+			plan.Deferred = append(plan.Deferred, s)
+			continue
+		}
+		plan.Sweeps = append(plan.Sweeps, s)
+		plan.Fee += s.Fee
+		plan.OutputsAfter -= s.Removed()
+	}
+	return plan, nil
+}
+
+// fee returns the priority of the sweeps, and the fee per byte and
+// quantization mask of the daemon for it
+func (p *Planner) fee() (wallet.PriorityType, uint64, uint64, error) {
+	priority := p.cfg.Priority
+	if priority == wallet.PriorityDefault {
+		resp, err := p.client.GetDefaultFeePriority(&wallet.GetDefaultFeePriorityRequest{})
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		priority = resp.Priority
+		if priority == wallet.PriorityDefault {
+			priority = wallet.PriorityUnimportant
+		}
+	}
+	est, err := p.daemon.GetFeeEstimate(&daemon.GetFeeEstimateRequest{})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var feePerByte uint64
+	if len(est.Fees) > 0 {
+		if int(priority) <= len(est.Fees) {
+			feePerByte = est.Fees[priority-1]
+		}
+	} else if int(priority) <= len(feeMultipliers) {
+		// daemons before v0.18 only return the base fee
+		feePerByte = est.Fee * feeMultipliers[priority-1]
+	}
+	if feePerByte == 0 {
+		return 0, 0, 0, fmt.Errorf("%w at priority %d", ErrNoFeeEstimate, priority)
+	}
+	return priority, feePerByte, est.QuantizationMask, nil
+}
+
+// feeMultipliers are the multipliers of the base fee for the priorities 1 to 4
+var feeMultipliers = []uint64{1, 5, 25, 1000}
+
+// quantize rounds a fee up to a multiple of mask
+func quantize(fee, mask uint64) uint64 {
+	if mask <= 1 {
+		return fee
+	}
+	return (fee + mask - 1) / mask * mask
+}
+
+// addresses sets the destination of each sweep to its subaddress
+func (p *Planner) addresses(sweeps []Sweep) error {
+	indices := make([]uint64, len(sweeps))
+	for i, s := range sweeps {
+		indices[i] = s.SubaddrIndex
+	}
+	resp, err := p.client.GetAddress(&wallet.GetAddressRequest{AccountIndex: p.cfg.AccountIndex, AddressIndex: indices})
+	if err != nil {
+		return err
+	}
+	addresses := make(map[uint64]string)
+	for _, a := range resp.Addresses {
+		addresses[a.AddressIndex] = a.Address
+	}
+	for i := range sweeps {
+		addr, ok := addresses[sweeps[i].SubaddrIndex]
+		if !ok {
+			return fmt.Errorf("consolidate: no address for subaddress %d", sweeps[i].SubaddrIndex)
+		}
+		sweeps[i].Address = addr
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package consolidate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// weight returns the weight of a transaction of the fake wallet
+func weight(inputs int) uint64 {
+	return 1000 + 500*uint64(inputs)
+}
+
+// fakeWallet is a stand-in wallet consolidating its outputs
+type fakeWallet struct {
+	wallet.Client
+
+	outputs   []wallet.IncomingTransfer
+	estimates map[uint32]int
+	sweeps    []*wallet.SweepAllRequest
+	sweepErr  error
+}
+
+func (w *fakeWallet) receive(minor uint64, n int, amount gonero.AtomicXMR, unlocked, frozen bool) {
+	for i := 0; i < n; i++ {
+		w.outputs = append(w.outputs, wallet.IncomingTransfer{
+			Amount:       amount,
+			SubaddrIndex: wallet.SubaddressIndex{Major: 1, Minor: minor},
+			Unlocked:     unlocked,
+			Frozen:       frozen,
+		})
+	}
+}
+
+func (w *fakeWallet) IncomingTransfers(req *wallet.IncomingTransfersRequest) (*wallet.IncomingTransfersResponse, error) {
+	if req.TransferType != wallet.TransferAvailable || req.AccountIndex != 1 {
+		return nil, errors.New("unexpected request")
+	}
+	return &wallet.IncomingTransfersResponse{Transfers: w.outputs}, nil
+}
+
+func (w *fakeWallet) GetDefaultFeePriority(req *wallet.GetDefaultFeePriorityRequest) (*wallet.GetDefaultFeePriorityResponse, error) {
+	return &wallet.GetDefaultFeePriorityResponse{Priority: wallet.PriorityNormal}, nil
+}
+
+func (w *fakeWallet) EstimateTxSizeAndWeight(req *wallet.EstimateTxSizeAndWeightRequest) (*wallet.EstimateTxSizeAndWeightResponse, error) {
+	if w.estimates == nil {
+		w.estimates = make(map[uint32]int)
+	}
+	w.estimates[req.NInputs]++
+	return &wallet.EstimateTxSizeAndWeightResponse{Weight: weight(int(req.NInputs))}, nil
+}
+
+func (w *fakeWallet) GetAddress(req *wallet.GetAddressRequest) (*wallet.GetAddressResponse, error) {
+	resp := &wallet.GetAddressResponse{}
+	for _, i := range req.AddressIndex {
+		resp.Addresses = append(resp.Addresses, wallet.Address{AddressIndex: i, Address: fmt.Sprintf("sub%d", i)})
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) SweepAll(req *wallet.SweepAllRequest) (*wallet.SweepAllResponse, error) {
+	if w.sweepErr != nil {
+		return nil, w.sweepErr
+	}
+	w.sweeps = append(w.sweeps, req)
+	resp := &wallet.SweepAllResponse{}
+	var swept gonero.AtomicXMR
+	for _, o := range w.outputs {
+		if o.SubaddrIndex.Minor == req.SubaddrIndices[0] && o.Unlocked && !o.Frozen && (req.BelowAmount == 0 || o.Amount < req.BelowAmount) {
+			swept += o.Amount
+		}
+	}
+	txid := fmt.Sprintf("sweep%d", len(w.sweeps))
+	resp.TxHashList = []string{txid}
+	resp.AmountList = []int64{int64(swept - 7)}
+	resp.FeeList = []int64{7}
+	return resp, nil
+}
+
+// fakeDaemon is a stand-in daemon estimating fees
+type fakeDaemon struct {
+	daemon.Client
+
+	resp daemon.GetFeeEstimateResponse
+}
+
+func (d *fakeDaemon) GetFeeEstimate(req *daemon.GetFeeEstimateRequest) (*daemon.GetFeeEstimateResponse, error) {
+	resp := d.resp
+	return &resp, nil
+}
+
+func newFakeDaemon() *fakeDaemon {
+	return &fakeDaemon{resp: daemon.GetFeeEstimateResponse{Fee: 20, Fees: []uint64{20, 80, 320, 4000}, QuantizationMask: 1000}}
+}
+
+func TestPlan(t *testing.T) {
+	w := &fakeWallet{}
+	w.receive(0, 3, 100, true, false)
+	w.receive(0, 1, 5000, true, false)
+	w.receive(0, 2, 100, false, false)
+	w.receive(1, 250, 10, true, false)
+	w.receive(2, 1, 10, true, false)
+	w.receive(3, 5, 10, true, true)
+	w.receive(4, 4, 10, true, false)
+	p := New(w, newFakeDaemon(), &Config{AccountIndex: 1, Threshold: 1000})
+
+	plan, err := p.Plan()
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.PriorityNormal, plan.Priority)
+	assert.Equal(t, uint64(80), plan.FeePerByte)
+	assert.Equal(t, []Group{
+		{SubaddrIndex: 0, Unlocked: true, Outputs: 4, Amount: 5300},
+		{SubaddrIndex: 0, Unlocked: false, Outputs: 2, Amount: 200},
+		{SubaddrIndex: 1, Unlocked: true, Outputs: 250, Amount: 2500},
+		{SubaddrIndex: 2, Unlocked: true, Outputs: 1, Amount: 10},
+		{SubaddrIndex: 4, Unlocked: true, Outputs: 4, Amount: 40},
+	}, plan.Groups)
+
+	fee := func(inputs int) gonero.AtomicXMR {
+		return gonero.AtomicXMR(quantize(weight(inputs)*80, 1000))
+	}
+	big := Sweep{SubaddrIndex: 1, Address: "sub1", BelowAmount: 1000, Inputs: 250, Amount: 2500, Txs: 3, Fee: 2*fee(100) + fee(50)}
+	four := Sweep{SubaddrIndex: 4, Address: "sub4", BelowAmount: 1000, Inputs: 4, Amount: 40, Txs: 1, Fee: fee(4)}
+	three := Sweep{SubaddrIndex: 0, Address: "sub0", BelowAmount: 1000, Inputs: 3, Amount: 300, Txs: 1, Fee: fee(3)}
+	// cheapest per removed output first
+	assert.Equal(t, []Sweep{big, four, three}, plan.Sweeps)
+	assert.Empty(t, plan.Deferred)
+	assert.Equal(t, 266, plan.OutputsBefore)
+	assert.Equal(t, 266-247-3-2, plan.OutputsAfter)
+	assert.Equal(t, big.Fee+four.Fee+three.Fee, plan.Fee)
+	// weights are estimated once per input count
+	assert.Equal(t, map[uint32]int{100: 1, 50: 1, 4: 1, 3: 1}, w.estimates)
+
+	var report bytes.Buffer
+	assert.NoError(t, plan.Report(&report))
+	assert.Contains(t, report.String(), "account 1: 266 outputs, 14 after 3 sweeps")
+	assert.Contains(t, report.String(), "locked")
+
+	// a fee budget defers the sweeps which do not fit
+	budget := gonero.AtomicXMR(2*quantize(weight(100)*20, 1000) + quantize(weight(50)*20, 1000) + quantize(weight(3)*20, 1000))
+	p = New(w, newFakeDaemon(), &Config{AccountIndex: 1, Threshold: 1000, MaxFee: budget, Address: "cold", Priority: wallet.PriorityUnimportant})
+	plan, err = p.Plan()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), plan.FeePerByte)
+	assert.Equal(t, budget, plan.Fee)
+	if assert.Len(t, plan.Sweeps, 2) && assert.Len(t, plan.Deferred, 1) {
+		assert.Equal(t, uint64(1), plan.Sweeps[0].SubaddrIndex)
+		assert.Equal(t, "cold", plan.Sweeps[0].Address)
+		assert.Equal(t, uint64(0), plan.Sweeps[1].SubaddrIndex)
+		assert.Equal(t, uint64(4), plan.Deferred[0].SubaddrIndex)
+	}
+	report.Reset()
+	assert.NoError(t, plan.Report(&report))
+	assert.Contains(t, report.String(), "deferred over the fee budget")
+}
+
+func TestFeeEstimate(t *testing.T) {
+	w := &fakeWallet{}
+	w.receive(0, 3, 100, true, false)
+	d := newFakeDaemon()
+
+	// daemons before v0.18 only return the base fee
+	d.resp.Fees = nil
+	plan, err := New(w, d, &Config{AccountIndex: 1, Priority: wallet.PriorityElevated}).Plan()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20*25), plan.FeePerByte)
+
+	d.resp.Fees = []uint64{20}
+	_, err = New(w, d, &Config{AccountIndex: 1, Priority: wallet.PriorityPriority}).Plan()
+	assert.True(t, errors.Is(err, ErrNoFeeEstimate), "%v", err)
+}
+
+func TestExecute(t *testing.T) {
+	w := &fakeWallet{}
+	w.receive(0, 3, 100, true, false)
+	w.receive(0, 1, 5000, true, false)
+	w.receive(2, 5, 10, true, false)
+	p := New(w, newFakeDaemon(), &Config{AccountIndex: 1, Threshold: 1000, Priority: wallet.PriorityUnimportant})
+	plan, err := p.Plan()
+	assert.NoError(t, err)
+	if !assert.Len(t, plan.Sweeps, 2) {
+		return
+	}
+
+	results, err := p.DryRun(plan)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	for _, req := range w.sweeps {
+		assert.True(t, req.DoNotRelay)
+	}
+
+	w.sweeps = nil
+	results, err = p.Execute(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Sweep: plan.Sweeps[0], Txids: []string{"sweep1"}, Amount: 50 - 7, Fee: 7},
+		{Sweep: plan.Sweeps[1], Txids: []string{"sweep2"}, Amount: 300 - 7, Fee: 7},
+	}, results)
+	assert.Equal(t, &wallet.SweepAllRequest{
+		Address:        "sub2",
+		AccountIndex:   1,
+		SubaddrIndices: []uint64{2},
+		Priority:       wallet.PriorityUnimportant,
+		BelowAmount:    1000,
+	}, w.sweeps[0])
+
+	w.sweepErr = errors.New("not enough money")
+	results, err = p.Execute(plan)
+	assert.True(t, errors.Is(err, w.sweepErr))
+	assert.Empty(t, results)
+}
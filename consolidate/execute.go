@@ -0,0 +1,92 @@
+package consolidate
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Result is the outcome of a sweep
+type Result struct {
+	// Sweep made.
+	Sweep Sweep `json:"sweep"`
+	// Transactions of the sweep.
+	Txids []string `json:"txids"`
+	// Amount received by the destination, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Fee of the transactions, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// Execute makes the sweeps of a plan, in order. It stops at the first
+// error, returning the results of the sweeps made.
+func (p *Planner) Execute(plan *Plan) ([]Result, error) {
+	return p.execute(plan, false)
+}
+
+// DryRun creates the transactions of the sweeps of a plan without relaying
+// them, returning their actual fees.
+func (p *Planner) DryRun(plan *Plan) ([]Result, error) {
+	return p.execute(plan, true)
+}
+
+func (p *Planner) execute(plan *Plan, dryRun bool) ([]Result, error) {
+	var results []Result
+	for _, s := range plan.Sweeps {
+		resp, err := p.client.SweepAll(&wallet.SweepAllRequest{
+			Address:        s.Address,
+			AccountIndex:   plan.AccountIndex,
+			SubaddrIndices: []uint64{s.SubaddrIndex},
+			Priority:       plan.Priority,
+			BelowAmount:    s.BelowAmount,
+			DoNotRelay:     dryRun,
+		})
+		if err != nil {
+			return results, fmt.Errorf("consolidate: sweep subaddress %d: %w", s.SubaddrIndex, err)
+		}
+		r := Result{Sweep: s, Txids: resp.TxHashList}
+		for _, a := range resp.AmountList {
+			r.Amount += gonero.AtomicXMR(a)
+		}
+		for _, f := range resp.FeeList {
+			r.Fee += gonero.AtomicXMR(f)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Report writes a human readable summary of the plan.
+func (plan *Plan) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "account %d: %d outputs, %d after %d sweeps, estimated fee %s XMR at priority %d\n\n",
+		plan.AccountIndex, plan.OutputsBefore, plan.OutputsAfter, len(plan.Sweeps), plan.Fee.Decimal(), plan.Priority)
+	fmt.Fprintln(tw, "subaddress\tstatus\toutputs\tamount")
+	for _, g := range plan.Groups {
+		status := "locked"
+		if g.Unlocked {
+			status = "unlocked"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", g.SubaddrIndex, status, g.Outputs, g.Amount.Decimal())
+	}
+	for _, sweeps := range []struct {
+		title string
+		list  []Sweep
+	}{{"sweeps", plan.Sweeps}, {"deferred over the fee budget", plan.Deferred}} {
+		if len(sweeps.list) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\nsubaddress\tinputs\tbelow\ttxs\tremoved\tfee\n", sweeps.title)
+		for _, s := range sweeps.list {
+			below := "any"
+			if s.BelowAmount > 0 {
+				below = s.BelowAmount.Decimal()
+			}
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%d\t%s\n", s.SubaddrIndex, s.Inputs, below, s.Txs, s.Removed(), s.Fee.Decimal())
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// generators of the original bulletproofs
+var bpGenerators = &generators{seed: "bulletproof"}
+
+// Bulletproof is an aggregated range proof of RingCT transactions from
+// v0.13 to v0.17, proving that the amounts of commitments are 64 bit values
+type Bulletproof struct {
+	// Commitments multiplied by 1/8. They are not serialized, but restored
+	// from the output commitments of the transaction.
+	V    []Key `json:"V"`
+	A    Key   `json:"A"`
+	S    Key   `json:"S"`
+	T1   Key   `json:"T1"`
+	T2   Key   `json:"T2"`
+	Taux Key   `json:"taux"`
+	Mu   Key   `json:"mu"`
+	L    []Key `json:"L"`
+	R    []Key `json:"R"`
+	// Final scalars of the inner product argument, and the inner product.
+	InnerA Key `json:"a"`
+	InnerB Key `json:"b"`
+	T      Key `json:"t"`
+}
+
+// Keys returns the keys of the proof hashed by the signatures of the
+// transaction: all of them but V.
+func (p *Bulletproof) Keys() []Key {
+	keys := []Key{p.A, p.S, p.T1, p.T2, p.Taux, p.Mu}
+	keys = append(keys, p.L...)
+	keys = append(keys, p.R...)
+	return append(keys, p.InnerA, p.InnerB, p.T)
+}
+
+// bpChallenges are the Fiat-Shamir challenges of a bulletproof
+type bpChallenges struct {
+	y, z, x, xIP *edwards25519.Scalar
+	w            []*edwards25519.Scalar
+}
+
+func newBPChallenges(p *Bulletproof) (*bpChallenges, error) {
+	c := &bpChallenges{}
+	transcript := transcriptUpdate(hashKeys(p.V), p.A, p.S)
+	c.y, _ = transcript.scalar()
+	transcript = HashToScalar(transcript[:])
+	c.z, _ = transcript.scalar()
+	transcript = transcriptUpdate(transcript, transcript, p.T1, p.T2)
+	c.x, _ = transcript.scalar()
+	transcript = transcriptUpdate(transcript, transcript, p.Taux, p.Mu, p.T)
+	c.xIP, _ = transcript.scalar()
+	c.w = make([]*edwards25519.Scalar, len(p.L))
+	for i := range p.L {
+		transcript = transcriptUpdate(transcript, p.L[i], p.R[i])
+		c.w[i], _ = transcript.scalar()
+	}
+	zero := edwards25519.NewScalar()
+	for _, s := range append([]*edwards25519.Scalar{c.y, c.z, c.x, c.xIP}, c.w...) {
+		if s.Equal(zero) == 1 {
+			return nil, fmt.Errorf("%w: zero challenge", ErrInvalidProof)
+		}
+	}
+	return c, nil
+}
+
+// VerifyBulletproof verifies a range proof, whose V must be set.
+func VerifyBulletproof(proof *Bulletproof) error {
+	if len(proof.V) == 0 || len(proof.V) > MaxRangeProofOutputs {
+		return fmt.Errorf("%w: %d commitments", ErrInvalidProof, len(proof.V))
+	}
+	m, logM := rangeProofSize(len(proof.V))
+	mn := m * rangeBits
+	logMN := logM + 6
+	if len(proof.L) != logMN || len(proof.R) != logMN {
+		return fmt.Errorf("%w: %d rounds for %d commitments", ErrInvalidProof, len(proof.L), len(proof.V))
+	}
+	scalars, err := scalarKeys([]Key{proof.Taux, proof.Mu, proof.InnerA, proof.InnerB, proof.T})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	taux, mu, a, b, t := scalars[0], scalars[1], scalars[2], scalars[3], scalars[4]
+	V, err := pointKeys(proof.V)
+	if err != nil {
+		return err
+	}
+	L, err := pointKeys(proof.L)
+	if err != nil {
+		return err
+	}
+	R, err := pointKeys(proof.R)
+	if err != nil {
+		return err
+	}
+	points, err := pointKeys([]Key{proof.A, proof.S, proof.T1, proof.T2})
+	if err != nil {
+		return err
+	}
+	A, S, T1, T2 := mul8(points[0]), mul8(points[1]), mul8(points[2]), mul8(points[3])
+	c, err := newBPChallenges(proof)
+	if err != nil {
+		return err
+	}
+	y, z, x := c.y, c.z, c.x
+
+	// t*H + taux*G = sum z^(2+j)*V[j] + delta*H + x*T1 + x^2*T2, with
+	// delta = (z - z^2)*sum y^i - sum z^(3+j)*(2^64 - 1)
+	zPow := powers(z, m+3)
+	yPow := powers(y, mn)
+	sumY := edwards25519.NewScalar()
+	for i := range yPow {
+		sumY.Add(sumY, yPow[i])
+	}
+	delta := new(edwards25519.Scalar).Subtract(z, zPow[2])
+	delta.Multiply(delta, sumY)
+	twoN := scalarFromUint64(^uint64(0))
+	t1 := edwards25519.NewScalar()
+	for j := 0; j < m; j++ {
+		t1.Add(t1, zPow[3+j])
+	}
+	delta.Subtract(delta, t1.Multiply(t1, twoN))
+
+	lhs := commit(0, taux)
+	lhs.Add(lhs, new(edwards25519.Point).ScalarMult(new(edwards25519.Scalar).Subtract(t, delta), hPoint))
+	rhsScalars := []*edwards25519.Scalar{x, new(edwards25519.Scalar).Multiply(x, x)}
+	rhsPoints := []*edwards25519.Point{T1, T2}
+	for j := range V {
+		rhsScalars = append(rhsScalars, zPow[2+j])
+		rhsPoints = append(rhsPoints, mul8(V[j]))
+	}
+	if lhs.Equal(multiScalarMult(rhsScalars, rhsPoints)) != 1 {
+		return ErrInvalidProof
+	}
+
+	// A + x*S + sum w^2*L + w^-2*R = mu*G + sum (a*s[i] + z)*G[i]
+	//   + sum (b/s[i]/y^i - z - z^(2+j)*2^k/y^i)*H[i] + (a*b - t)*xIP*H
+	gens := bpGenerators.get()
+	var ps []*edwards25519.Scalar
+	var pp []*edwards25519.Point
+	add := func(s *edwards25519.Scalar, p *edwards25519.Point) {
+		ps = append(ps, s)
+		pp = append(pp, p)
+	}
+	add(scalarFromUint64(1), A)
+	add(x, S)
+	inverses := make([]*edwards25519.Scalar, logMN)
+	for k, w := range c.w {
+		inverses[k] = invert(w)
+		add(new(edwards25519.Scalar).Multiply(w, w), mul8(L[k]))
+		add(new(edwards25519.Scalar).Multiply(inverses[k], inverses[k]), mul8(R[k]))
+	}
+	yInv := invert(y)
+	yInvPow := scalarFromUint64(1)
+	twos := powers(scalarFromUint64(2), rangeBits)
+	for i := 0; i < mn; i++ {
+		s := scalarFromUint64(1)
+		sInv := scalarFromUint64(1)
+		for k := 0; k < logMN; k++ {
+			if i>>(logMN-1-k)&1 == 1 {
+				s.Multiply(s, c.w[k])
+				sInv.Multiply(sInv, inverses[k])
+			} else {
+				s.Multiply(s, inverses[k])
+				sInv.Multiply(sInv, c.w[k])
+			}
+		}
+		g := new(edwards25519.Scalar).MultiplyAdd(a, s, z)
+		add(g.Negate(g), gens.G[i])
+
+		// z^(2+j)*2^k, for i = j*64 + k
+		zt := new(edwards25519.Scalar).Multiply(zPow[2+i/rangeBits], twos[i%rangeBits])
+		h := new(edwards25519.Scalar).Multiply(b, sInv)
+		h.Subtract(h, zt)
+		h.Multiply(h, yInvPow)
+		h.Subtract(h, z)
+		add(h.Negate(h), gens.H[i])
+		yInvPow.Multiply(yInvPow, yInv)
+	}
+	ab := new(edwards25519.Scalar).Multiply(a, b)
+	ab.Subtract(ab, t)
+	add(ab.Negate(ab.Multiply(ab, c.xIP)), hPoint)
+	sum := multiScalarMult(ps, pp)
+	sum.Subtract(sum, new(edwards25519.Point).ScalarBaseMult(mu))
+	if !isIdentity(sum) {
+		return ErrInvalidProof
+	}
+	return nil
+}
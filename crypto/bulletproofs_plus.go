@@ -0,0 +1,433 @@
+package crypto
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// range proof sizes
+const (
+	rangeBits = 64
+	// MaxRangeProofOutputs is the maximum number of amounts of a range proof
+	MaxRangeProofOutputs = 16
+)
+
+// generators are the vectors of generators of a bulletproof flavour
+type generators struct {
+	once sync.Once
+	seed string
+	G, H []*edwards25519.Point
+}
+
+// get derives the generators on first use: H[i] and G[i] are the hashes to
+// point of H || seed || 2i and H || seed || 2i+1
+func (g *generators) get() *generators {
+	g.once.Do(func() {
+		n := rangeBits * MaxRangeProofOutputs
+		g.G = make([]*edwards25519.Point, n)
+		g.H = make([]*edwards25519.Point, n)
+		exponent := func(i int) *edwards25519.Point {
+			return hashToPoint(Keccak256(H[:], []byte(g.seed), varint(nil, uint64(i))))
+		}
+		for i := 0; i < n; i++ {
+			g.H[i] = exponent(2 * i)
+			g.G[i] = exponent(2*i + 1)
+		}
+	})
+	return g
+}
+
+var (
+	bppGenerators = &generators{seed: "bulletproof_plus"}
+	bppTranscript = HashToPoint(Keccak256([]byte("bulletproof_plus_transcript")))
+)
+
+// BulletproofPlus is an aggregated range proof of RingCT transactions since
+// v0.18, proving that the amounts of commitments are 64 bit values
+type BulletproofPlus struct {
+	// Commitments multiplied by 1/8. They are not serialized, but restored
+	// from the output commitments of the transaction.
+	V  []Key `json:"V"`
+	A  Key   `json:"A"`
+	A1 Key   `json:"A1"`
+	B  Key   `json:"B"`
+	R1 Key   `json:"r1"`
+	S1 Key   `json:"s1"`
+	D1 Key   `json:"d1"`
+	L  []Key `json:"L"`
+	R  []Key `json:"R"`
+}
+
+// Keys returns the keys of the proof hashed by the signatures of the
+// transaction: all of them but V.
+func (p *BulletproofPlus) Keys() []Key {
+	keys := []Key{p.A, p.A1, p.B, p.R1, p.S1, p.D1}
+	keys = append(keys, p.L...)
+	return append(keys, p.R...)
+}
+
+// transcriptUpdate returns Hs(transcript || keys)
+func transcriptUpdate(transcript Key, keys ...Key) Key {
+	data := make([][]byte, 0, len(keys)+1)
+	data = append(data, transcript[:])
+	for i := range keys {
+		data = append(data, keys[i][:])
+	}
+	return HashToScalar(data...)
+}
+
+// hashKeys returns Hs of the concatenated keys
+func hashKeys(keys []Key) Key {
+	data := make([][]byte, len(keys))
+	for i := range keys {
+		data[i] = keys[i][:]
+	}
+	return HashToScalar(data...)
+}
+
+// rangeProofSize returns the number of padded amounts and the number of
+// rounds of a range proof of n amounts
+func rangeProofSize(n int) (int, int) {
+	m, logM := 1, 0
+	for m < n {
+		m *= 2
+		logM++
+	}
+	return m, logM
+}
+
+// powers returns 1, x, x^2, ..., x^(n-1)
+func powers(x *edwards25519.Scalar, n int) []*edwards25519.Scalar {
+	p := make([]*edwards25519.Scalar, n)
+	p[0] = scalarFromUint64(1)
+	for i := 1; i < n; i++ {
+		p[i] = new(edwards25519.Scalar).Multiply(p[i-1], x)
+	}
+	return p
+}
+
+// weightedInnerProduct returns the sum of a[i]*b[i]*y^(i+1)
+func weightedInnerProduct(a, b []*edwards25519.Scalar, y *edwards25519.Scalar) *edwards25519.Scalar {
+	r := edwards25519.NewScalar()
+	yi := scalarFromUint64(1)
+	t := edwards25519.NewScalar()
+	for i := range a {
+		yi.Multiply(yi, y)
+		r.Add(r, t.Multiply(t.Multiply(a[i], b[i]), yi))
+	}
+	return r
+}
+
+func scalarKeys(keys []Key) ([]*edwards25519.Scalar, error) {
+	s := make([]*edwards25519.Scalar, len(keys))
+	for i := range keys {
+		var err error
+		if s[i], err = keys[i].scalar(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func pointKeys(keys []Key) ([]*edwards25519.Point, error) {
+	p := make([]*edwards25519.Point, len(keys))
+	for i := range keys {
+		var err error
+		if p[i], err = keys[i].point(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ProveBulletproofPlus proves that the commitments masks[i]*G + amounts[i]*H
+// hold 64 bit amounts.
+func ProveBulletproofPlus(amounts []uint64, masks []SecretKey) (*BulletproofPlus, error) {
+	if len(amounts) == 0 || len(amounts) > MaxRangeProofOutputs || len(masks) != len(amounts) {
+		return nil, fmt.Errorf("%w: %d amounts and %d masks", ErrInvalidProof, len(amounts), len(masks))
+	}
+	gens := bppGenerators.get()
+	gammas := make([]*edwards25519.Scalar, len(masks))
+	for i := range masks {
+		var err error
+		if gammas[i], err = masks[i].scalar(); err != nil {
+			return nil, err
+		}
+	}
+	m, logM := rangeProofSize(len(amounts))
+	mn := m * rangeBits
+	logMN := logM + 6
+
+	proof := &BulletproofPlus{V: make([]Key, len(amounts))}
+	for i := range amounts {
+		proof.V[i] = pointKey(new(edwards25519.Point).ScalarMult(invEight, commit(amounts[i], gammas[i])))
+	}
+
+	// aL are the bits of the amounts, aR = aL - 1
+	one := scalarFromUint64(1)
+	minusOne := new(edwards25519.Scalar).Negate(one)
+	aL := make([]*edwards25519.Scalar, mn)
+	aR := make([]*edwards25519.Scalar, mn)
+	for j := 0; j < m; j++ {
+		for i := 0; i < rangeBits; i++ {
+			if j < len(amounts) && amounts[j]>>i&1 == 1 {
+				aL[j*rangeBits+i], aR[j*rangeBits+i] = one, edwards25519.NewScalar()
+			} else {
+				aL[j*rangeBits+i], aR[j*rangeBits+i] = edwards25519.NewScalar(), minusOne
+			}
+		}
+	}
+
+	transcript := transcriptUpdate(bppTranscript, hashKeys(proof.V))
+
+	alpha := randomScalar()
+	A := multiScalarMult(append(append([]*edwards25519.Scalar{}, aL...), aR...), append(append([]*edwards25519.Point{}, gens.G[:mn]...), gens.H[:mn]...))
+	A.Add(A, new(edwards25519.Point).ScalarBaseMult(alpha))
+	proof.A = pointKey(A.ScalarMult(invEight, A))
+
+	transcript = transcriptUpdate(transcript, proof.A)
+	y, _ := transcript.scalar()
+	transcript = HashToScalar(transcript[:])
+	z, _ := transcript.scalar()
+	zSq := new(edwards25519.Scalar).Multiply(z, z)
+
+	d := windowedVector(zSq, m)
+	yPow := powers(y, mn+2)
+
+	a := make([]*edwards25519.Scalar, mn)
+	b := make([]*edwards25519.Scalar, mn)
+	for i := range a {
+		a[i] = new(edwards25519.Scalar).Subtract(aL[i], z)
+		b[i] = new(edwards25519.Scalar).Add(aR[i], z)
+		b[i].MultiplyAdd(d[i], yPow[mn-i], b[i])
+	}
+	alpha1 := new(edwards25519.Scalar).Set(alpha)
+	zPow := scalarFromUint64(1)
+	for j := range gammas {
+		zPow.Multiply(zPow, zSq)
+		alpha1.MultiplyAdd(new(edwards25519.Scalar).Multiply(zPow, yPow[mn+1]), gammas[j], alpha1)
+	}
+
+	yInvPow := powers(invert(y), mn+1)
+	gp := append([]*edwards25519.Point{}, gens.G[:mn]...)
+	hp := append([]*edwards25519.Point{}, gens.H[:mn]...)
+	proof.L = make([]Key, 0, logMN)
+	proof.R = make([]Key, 0, logMN)
+	for n := mn / 2; n >= 1; n /= 2 {
+		aHi := make([]*edwards25519.Scalar, n)
+		for i := range aHi {
+			aHi[i] = new(edwards25519.Scalar).Multiply(a[n+i], yPow[n])
+		}
+		cL := weightedInnerProduct(a[:n], b[n:2*n], y)
+		cR := weightedInnerProduct(aHi, b[:n], y)
+		dL, dR := randomScalar(), randomScalar()
+
+		aLo := make([]*edwards25519.Scalar, n)
+		for i := range aLo {
+			aLo[i] = new(edwards25519.Scalar).Multiply(a[i], yInvPow[n])
+		}
+		L := multiScalarMult(append(append(aLo, b[n:2*n]...), cL), append(append(append([]*edwards25519.Point{}, gp[n:2*n]...), hp[:n]...), hPoint))
+		L.Add(L, new(edwards25519.Point).ScalarBaseMult(dL))
+		R := multiScalarMult(append(append(aHi, b[:n]...), cR), append(append(append([]*edwards25519.Point{}, gp[:n]...), hp[n:2*n]...), hPoint))
+		R.Add(R, new(edwards25519.Point).ScalarBaseMult(dR))
+		proof.L = append(proof.L, pointKey(L.ScalarMult(invEight, L)))
+		proof.R = append(proof.R, pointKey(R.ScalarMult(invEight, R)))
+
+		transcript = transcriptUpdate(transcript, proof.L[len(proof.L)-1], proof.R[len(proof.R)-1])
+		e, _ := transcript.scalar()
+		eInv := invert(e)
+
+		eyInv := new(edwards25519.Scalar).Multiply(e, yInvPow[n])
+		eInvY := new(edwards25519.Scalar).Multiply(eInv, yPow[n])
+		for i := 0; i < n; i++ {
+			gp[i] = multiScalarMult([]*edwards25519.Scalar{eInv, eyInv}, []*edwards25519.Point{gp[i], gp[n+i]})
+			hp[i] = multiScalarMult([]*edwards25519.Scalar{e, eInv}, []*edwards25519.Point{hp[i], hp[n+i]})
+			a[i] = new(edwards25519.Scalar).Multiply(e, a[i])
+			a[i].MultiplyAdd(eInvY, a[n+i], a[i])
+			b[i] = new(edwards25519.Scalar).Multiply(eInv, b[i])
+			b[i].MultiplyAdd(e, b[n+i], b[i])
+		}
+		alpha1.MultiplyAdd(dL, new(edwards25519.Scalar).Multiply(e, e), alpha1)
+		alpha1.MultiplyAdd(dR, new(edwards25519.Scalar).Multiply(eInv, eInv), alpha1)
+	}
+
+	r, s, d0, eta := randomScalar(), randomScalar(), randomScalar(), randomScalar()
+	// A1 = r*G' + s*H' + d*G + (r*y*b + s*y*a)*H
+	ry := new(edwards25519.Scalar).Multiply(r, y)
+	sy := new(edwards25519.Scalar).Multiply(s, y)
+	t := new(edwards25519.Scalar).Multiply(ry, b[0])
+	t.MultiplyAdd(sy, a[0], t)
+	A1 := multiScalarMult([]*edwards25519.Scalar{r, s, t}, []*edwards25519.Point{gp[0], hp[0], hPoint})
+	A1.Add(A1, new(edwards25519.Point).ScalarBaseMult(d0))
+	proof.A1 = pointKey(A1.ScalarMult(invEight, A1))
+	// B = eta*G + r*y*s*H
+	B := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(new(edwards25519.Scalar).Multiply(ry, s), hPoint, eta)
+	proof.B = pointKey(B.ScalarMult(invEight, B))
+
+	transcript = transcriptUpdate(transcript, proof.A1, proof.B)
+	e, _ := transcript.scalar()
+	proof.R1 = scalarKey(new(edwards25519.Scalar).MultiplyAdd(a[0], e, r))
+	proof.S1 = scalarKey(new(edwards25519.Scalar).MultiplyAdd(b[0], e, s))
+	d1 := new(edwards25519.Scalar).MultiplyAdd(d0, e, eta)
+	d1.MultiplyAdd(alpha1, new(edwards25519.Scalar).Multiply(e, e), d1)
+	proof.D1 = scalarKey(d1)
+	return proof, nil
+}
+
+// windowedVector returns d with d[j*64+i] = z^(2(j+1)) * 2^i
+func windowedVector(zSq *edwards25519.Scalar, m int) []*edwards25519.Scalar {
+	d := make([]*edwards25519.Scalar, m*rangeBits)
+	two := scalarFromUint64(2)
+	d[0] = new(edwards25519.Scalar).Set(zSq)
+	for i := 1; i < rangeBits; i++ {
+		d[i] = new(edwards25519.Scalar).Multiply(d[i-1], two)
+	}
+	for j := 1; j < m; j++ {
+		for i := 0; i < rangeBits; i++ {
+			d[j*rangeBits+i] = new(edwards25519.Scalar).Multiply(d[(j-1)*rangeBits+i], zSq)
+		}
+	}
+	return d
+}
+
+// VerifyBulletproofPlus verifies a range proof, whose V must be set.
+func VerifyBulletproofPlus(proof *BulletproofPlus) error {
+	if len(proof.V) == 0 || len(proof.V) > MaxRangeProofOutputs {
+		return fmt.Errorf("%w: %d commitments", ErrInvalidProof, len(proof.V))
+	}
+	m, logM := rangeProofSize(len(proof.V))
+	mn := m * rangeBits
+	logMN := logM + 6
+	if len(proof.L) != logMN || len(proof.R) != logMN {
+		return fmt.Errorf("%w: %d rounds for %d commitments", ErrInvalidProof, len(proof.L), len(proof.V))
+	}
+	gens := bppGenerators.get()
+	r1, err := proof.R1.scalar()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	s1, err := proof.S1.scalar()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	d1, err := proof.D1.scalar()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	V, err := pointKeys(proof.V)
+	if err != nil {
+		return err
+	}
+	L, err := pointKeys(proof.L)
+	if err != nil {
+		return err
+	}
+	R, err := pointKeys(proof.R)
+	if err != nil {
+		return err
+	}
+	points, err := pointKeys([]Key{proof.A, proof.A1, proof.B})
+	if err != nil {
+		return err
+	}
+
+	transcript := transcriptUpdate(bppTranscript, hashKeys(proof.V))
+	transcript = transcriptUpdate(transcript, proof.A)
+	y, _ := transcript.scalar()
+	transcript = HashToScalar(transcript[:])
+	z, _ := transcript.scalar()
+	challenges := make([]*edwards25519.Scalar, logMN)
+	for k := range challenges {
+		transcript = transcriptUpdate(transcript, proof.L[k], proof.R[k])
+		challenges[k], _ = transcript.scalar()
+	}
+	transcript = transcriptUpdate(transcript, proof.A1, proof.B)
+	e, _ := transcript.scalar()
+
+	eight := scalarFromUint64(8)
+	eSq := new(edwards25519.Scalar).Multiply(e, e)
+	eSq8 := new(edwards25519.Scalar).Multiply(eSq, eight)
+	zSq := new(edwards25519.Scalar).Multiply(z, z)
+	yPow := powers(y, mn+2)
+	yInvPow := powers(invert(y), mn)
+	d := windowedVector(zSq, m)
+
+	var scalars []*edwards25519.Scalar
+	var bases []*edwards25519.Point
+	add := func(s *edwards25519.Scalar, p *edwards25519.Point) {
+		scalars = append(scalars, s)
+		bases = append(bases, p)
+	}
+
+	// e^2*8*(A + sum z^(2(j+1))*y^(MN+1)*V[j] + sum c^2*L + c^-2*R) + e*8*A1 + 8*B
+	add(eSq8, points[0])
+	zPow := scalarFromUint64(1)
+	for j := range V {
+		zPow.Multiply(zPow, zSq)
+		add(new(edwards25519.Scalar).Multiply(eSq8, new(edwards25519.Scalar).Multiply(zPow, yPow[mn+1])), V[j])
+	}
+	inverses := make([]*edwards25519.Scalar, logMN)
+	for k, c := range challenges {
+		inverses[k] = invert(c)
+		cSq := new(edwards25519.Scalar).Multiply(c, c)
+		add(new(edwards25519.Scalar).Multiply(eSq8, cSq), L[k])
+		cInvSq := new(edwards25519.Scalar).Multiply(inverses[k], inverses[k])
+		add(new(edwards25519.Scalar).Multiply(eSq8, cInvSq), R[k])
+	}
+	add(new(edwards25519.Scalar).Multiply(e, eight), points[1])
+	add(eight, points[2])
+
+	// G' = sum g[i]*G[i] and H' = sum h[i]*H[i] from the folding challenges
+	r1e := new(edwards25519.Scalar).Multiply(r1, e)
+	s1e := new(edwards25519.Scalar).Multiply(s1, e)
+	minusZ := new(edwards25519.Scalar).Negate(z)
+	for i := 0; i < mn; i++ {
+		g := new(edwards25519.Scalar).Set(yInvPow[i])
+		h := scalarFromUint64(1)
+		for k := 0; k < logMN; k++ {
+			if i>>(logMN-1-k)&1 == 1 {
+				g.Multiply(g, challenges[k])
+				h.Multiply(h, inverses[k])
+			} else {
+				g.Multiply(g, inverses[k])
+				h.Multiply(h, challenges[k])
+			}
+		}
+		// e^2*(-z) - r1*e*g
+		gs := new(edwards25519.Scalar).Multiply(eSq, minusZ)
+		gs.Subtract(gs, g.Multiply(g, r1e))
+		add(gs, gens.G[i])
+		// e^2*(z + d[i]*y^(MN-i)) - s1*e*h
+		hs := new(edwards25519.Scalar).MultiplyAdd(d[i], yPow[mn-i], z)
+		hs.Multiply(hs, eSq)
+		hs.Subtract(hs, h.Multiply(h, s1e))
+		add(hs, gens.H[i])
+	}
+
+	// e^2*((z - z^2)*sum y^i - z*y^(MN+1)*sum d) - r1*y*s1 on H, and -d1 on G
+	sumY := edwards25519.NewScalar()
+	for i := 1; i <= mn; i++ {
+		sumY.Add(sumY, yPow[i])
+	}
+	sumD := edwards25519.NewScalar()
+	for i := range d {
+		sumD.Add(sumD, d[i])
+	}
+	hs := new(edwards25519.Scalar).Subtract(z, zSq)
+	hs.Multiply(hs, sumY)
+	t := new(edwards25519.Scalar).Multiply(z, yPow[mn+1])
+	hs.Subtract(hs, t.Multiply(t, sumD))
+	hs.Multiply(hs, eSq)
+	t = new(edwards25519.Scalar).Multiply(r1, y)
+	hs.Subtract(hs, t.Multiply(t, s1))
+	add(hs, hPoint)
+
+	sum := multiScalarMult(scalars, bases)
+	sum.Subtract(sum, new(edwards25519.Point).ScalarBaseMult(d1))
+	if !isIdentity(sum) {
+		return ErrInvalidProof
+	}
+	return nil
+}
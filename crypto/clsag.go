@@ -0,0 +1,233 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// CLSAG domain separators
+var (
+	clsagAgg0  = domain("CLSAG_agg_0")
+	clsagAgg1  = domain("CLSAG_agg_1")
+	clsagRound = domain("CLSAG_round")
+)
+
+// domain returns a domain separator padded to a Key
+func domain(s string) Key {
+	var k Key
+	copy(k[:], s)
+	return k
+}
+
+// RingMember is an output of a ring: its one-time key and its commitment
+type RingMember struct {
+	// One-time public key of the output.
+	Dest Key `json:"dest"`
+	// Commitment to the amount of the output.
+	Mask Key `json:"mask"`
+}
+
+// CLSAG is a ring signature of an input, as used by RingCT transactions
+// since v0.17. The key image of the input is stored with the input.
+type CLSAG struct {
+	// Responses, one per ring member.
+	S []Key `json:"s"`
+	// Challenge of the first ring member.
+	C1 Key `json:"c1"`
+	// Commitment key image, multiplied by 1/8.
+	D Key `json:"D"`
+}
+
+// clsagHasher holds the decoded ring and the hash prefixes of a signature
+type clsagHasher struct {
+	P, C       []*edwards25519.Point // keys, and commitments minus the pseudo output
+	Hp         []*edwards25519.Point // hash to point of the keys
+	muP, muC   *edwards25519.Scalar
+	round      [][]byte
+	roundFixed int
+}
+
+func newCLSAGHasher(message Key, ring []RingMember, pseudoOut, keyImage, d Key) (*clsagHasher, error) {
+	n := len(ring)
+	if n == 0 {
+		return nil, fmt.Errorf("%w: empty ring", ErrInvalidSignature)
+	}
+	offset, err := pseudoOut.point()
+	if err != nil {
+		return nil, err
+	}
+	h := &clsagHasher{
+		P:  make([]*edwards25519.Point, n),
+		C:  make([]*edwards25519.Point, n),
+		Hp: make([]*edwards25519.Point, n),
+	}
+	agg := make([][]byte, 0, 2*n+4)
+	agg = append(agg, nil)
+	for i, m := range ring {
+		if h.P[i], err = m.Dest.point(); err != nil {
+			return nil, err
+		}
+		c, err := m.Mask.point()
+		if err != nil {
+			return nil, err
+		}
+		h.C[i] = c.Subtract(c, offset)
+		h.Hp[i] = hashToPoint(m.Dest)
+		agg = append(agg, ring[i].Dest[:])
+	}
+	for i := range ring {
+		agg = append(agg, ring[i].Mask[:])
+	}
+	agg = append(agg, keyImage[:], d[:], pseudoOut[:])
+	agg[0] = clsagAgg0[:]
+	h.muP = hashToScalar(agg...)
+	agg[0] = clsagAgg1[:]
+	h.muC = hashToScalar(agg...)
+
+	// round hashes are domain || keys || commitments || pseudo out || message || L || R
+	h.round = make([][]byte, 0, 2*n+5)
+	h.round = append(h.round, clsagRound[:])
+	h.round = append(h.round, agg[1:2*n+1]...)
+	h.round = append(h.round, pseudoOut[:], message[:], nil, nil)
+	h.roundFixed = len(h.round) - 2
+	return h, nil
+}
+
+// challenge returns the challenge following the commitments l and r
+func (h *clsagHasher) challenge(l, r *edwards25519.Point) *edwards25519.Scalar {
+	h.round[h.roundFixed] = l.Bytes()
+	h.round[h.roundFixed+1] = r.Bytes()
+	return hashToScalar(h.round...)
+}
+
+// next returns the challenge following the ring member i with response s,
+// previous challenge c, key image I and commitment key image D
+func (h *clsagHasher) next(i int, s, c *edwards25519.Scalar, I, D *edwards25519.Point) *edwards25519.Scalar {
+	cP := new(edwards25519.Scalar).Multiply(h.muP, c)
+	cC := new(edwards25519.Scalar).Multiply(h.muC, c)
+	// L = s*G + cP*P + cC*C, R = s*Hp + cP*I + cC*D
+	l := new(edwards25519.Point).ScalarBaseMult(s)
+	l.Add(l, multiScalarMult([]*edwards25519.Scalar{cP, cC}, []*edwards25519.Point{h.P[i], h.C[i]}))
+	r := multiScalarMult([]*edwards25519.Scalar{s, cP, cC}, []*edwards25519.Point{h.Hp[i], I, D})
+	return h.challenge(l, r)
+}
+
+// SignCLSAG signs message with the ring member at index, whose one-time
+// secret key is secret. Its commitment is mask*G + amount*H, and the pseudo
+// output of the input is pseudoMask*G + amount*H. It returns the signature
+// and the key image of the input.
+func SignCLSAG(message Key, ring []RingMember, index int, secret, mask, pseudoMask SecretKey) (*CLSAG, Key, error) {
+	if index < 0 || index >= len(ring) {
+		return nil, Key{}, fmt.Errorf("%w: index %d out of the ring", ErrInvalidSignature, index)
+	}
+	p, err := secret.scalar()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	m, err := mask.scalar()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	pm, err := pseudoMask.scalar()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	if pointKey(new(edwards25519.Point).ScalarBaseMult(p)) != ring[index].Dest {
+		return nil, Key{}, fmt.Errorf("%w: secret key does not match the ring member", ErrInvalidSignature)
+	}
+	// z = mask - pseudoMask, so that C[index] - pseudoOut = z*G
+	z := new(edwards25519.Scalar).Subtract(m, pm)
+	c, err := ring[index].Mask.point()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	pseudo := c.Subtract(c, new(edwards25519.Point).ScalarBaseMult(z))
+
+	hp := hashToPoint(ring[index].Dest)
+	I := new(edwards25519.Point).ScalarMult(p, hp)
+	D := new(edwards25519.Point).ScalarMult(z, hp)
+	sig := &CLSAG{S: make([]Key, len(ring)), D: pointKey(new(edwards25519.Point).ScalarMult(invEight, D))}
+	keyImage := pointKey(I)
+
+	h, err := newCLSAGHasher(message, ring, pointKey(pseudo), keyImage, sig.D)
+	if err != nil {
+		return nil, Key{}, err
+	}
+
+	a := randomScalar()
+	ch := h.challenge(new(edwards25519.Point).ScalarBaseMult(a), new(edwards25519.Point).ScalarMult(a, hp))
+	n := len(ring)
+	for i := (index + 1) % n; i != index; i = (i + 1) % n {
+		if i == 0 {
+			sig.C1 = scalarKey(ch)
+		}
+		s := randomScalar()
+		sig.S[i] = scalarKey(s)
+		ch = h.next(i, s, ch, I, D)
+	}
+	if index == 0 {
+		sig.C1 = scalarKey(ch)
+	}
+	// s = a - c*(muP*p + muC*z)
+	s := new(edwards25519.Scalar).Multiply(h.muP, p)
+	s.MultiplyAdd(h.muC, z, s)
+	s.Multiply(ch, s)
+	sig.S[index] = scalarKey(s.Subtract(a, s))
+	return sig, keyImage, nil
+}
+
+// VerifyCLSAG verifies the signature of message by the ring, for the input
+// with the given pseudo output and key image.
+func VerifyCLSAG(message Key, ring []RingMember, pseudoOut, keyImage Key, sig *CLSAG) error {
+	if len(sig.S) != len(ring) {
+		return fmt.Errorf("%w: %d responses for %d ring members", ErrInvalidSignature, len(sig.S), len(ring))
+	}
+	I, err := keyImage.point()
+	if err != nil {
+		return err
+	}
+	if isIdentity(I) || !inPrimeSubgroup(I) {
+		return fmt.Errorf("%w: bad key image", ErrInvalidSignature)
+	}
+	D, err := sig.D.point()
+	if err != nil {
+		return err
+	}
+	D = mul8(D)
+	if isIdentity(D) {
+		return fmt.Errorf("%w: bad commitment key image", ErrInvalidSignature)
+	}
+	c1, err := sig.C1.scalar()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	h, err := newCLSAGHasher(message, ring, pseudoOut, keyImage, sig.D)
+	if err != nil {
+		return err
+	}
+	c := c1
+	for i := range ring {
+		s, err := sig.S[i].scalar()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		c = h.next(i, s, c, I, D)
+	}
+	if c.Equal(c1) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// orderMinusOne is l - 1, with l the order of the prime subgroup
+var orderMinusOne = func() *edwards25519.Scalar {
+	s := edwards25519.NewScalar()
+	return s.Subtract(s, scalarFromUint64(1))
+}()
+
+// inPrimeSubgroup tells whether l*p is the identity
+func inPrimeSubgroup(p *edwards25519.Point) bool {
+	lp := new(edwards25519.Point).ScalarMult(orderMinusOne, p)
+	return isIdentity(lp.Add(lp, p))
+}
@@ -0,0 +1,271 @@
+// Package crypto implements the Monero cryptography needed to build and
+// check transactions and proofs without a wallet: keys, derivations,
+// Pedersen commitments, Schnorr and ring signatures, and range proofs.
+//
+// Points, scalars and hashes are all 32 byte Keys, as in the rct code of
+// monerod. Secret scalars are SecretKeys, which redact themselves when
+// formatted.
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// Crypto errors
+var (
+	ErrInvalidKey       = errors.New("crypto: invalid key")
+	ErrInvalidScalar    = errors.New("crypto: invalid scalar")
+	ErrInvalidSignature = errors.New("crypto: invalid signature")
+	ErrInvalidProof     = errors.New("crypto: invalid proof")
+	ErrUnbalanced       = errors.New("crypto: inputs and outputs commitments do not balance")
+)
+
+// KeySize is the size of a Key
+const KeySize = 32
+
+// Key is a point, a scalar or a hash
+type Key [KeySize]byte
+
+// ParseKey parses a hex encoded Key.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return k, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if len(data) != len(k) {
+		return k, fmt.Errorf("%w: %d bytes", ErrInvalidKey, len(data))
+	}
+	copy(k[:], data)
+	return k, nil
+}
+
+// String returns the key as hex.
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k Key) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *Key) UnmarshalText(text []byte) error {
+	key, err := ParseKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// point decodes the key as a point
+func (k Key) point() (*edwards25519.Point, error) {
+	p, err := new(edwards25519.Point).SetBytes(k[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, k)
+	}
+	return p, nil
+}
+
+// scalar decodes the key as a reduced scalar
+func (k Key) scalar() (*edwards25519.Scalar, error) {
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(k[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidScalar, k)
+	}
+	return s, nil
+}
+
+// SecretKey is a secret scalar, such as a private key or a commitment mask.
+// It redacts itself whenever it is formatted.
+type SecretKey [32]byte
+
+// ParseSecretKey parses a hex encoded, reduced, secret scalar.
+func ParseSecretKey(s string) (SecretKey, error) {
+	k, err := ParseKey(s)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	if _, err := k.scalar(); err != nil {
+		return SecretKey{}, err
+	}
+	return SecretKey(k), nil
+}
+
+// NewSecretKey returns a random secret scalar.
+func NewSecretKey() SecretKey {
+	return secretKey(randomScalar())
+}
+
+// Reveal returns the secret key as hex.
+func (s SecretKey) Reveal() string {
+	return hex.EncodeToString(s[:])
+}
+
+// String implements fmt.Stringer and always returns a redacted placeholder.
+func (s SecretKey) String() string {
+	return "[REDACTED]"
+}
+
+// GoString implements fmt.GoStringer and always returns a redacted placeholder.
+func (s SecretKey) GoString() string {
+	return s.String()
+}
+
+// Format implements fmt.Formatter so that no verb can leak the secret key.
+func (s SecretKey) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, s.String())
+}
+
+// PublicKey returns the public key s*G.
+func (s SecretKey) PublicKey() (Key, error) {
+	x, err := s.scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(new(edwards25519.Point).ScalarBaseMult(x)), nil
+}
+
+func (s SecretKey) scalar() (*edwards25519.Scalar, error) {
+	x, err := edwards25519.NewScalar().SetCanonicalBytes(s[:])
+	if err != nil {
+		return nil, ErrInvalidScalar
+	}
+	return x, nil
+}
+
+func pointKey(p *edwards25519.Point) Key {
+	var k Key
+	copy(k[:], p.Bytes())
+	return k
+}
+
+func scalarKey(s *edwards25519.Scalar) Key {
+	var k Key
+	copy(k[:], s.Bytes())
+	return k
+}
+
+func secretKey(s *edwards25519.Scalar) SecretKey {
+	return SecretKey(scalarKey(s))
+}
+
+// Keccak256 returns the Keccak-256 hash of the concatenated data, the
+// cn_fast_hash of monerod.
+func Keccak256(data ...[]byte) Key {
+	var k Key
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	h.Sum(k[:0])
+	return k
+}
+
+// HashToScalar returns the Keccak-256 hash of the concatenated data reduced
+// to a scalar.
+func HashToScalar(data ...[]byte) Key {
+	return scalarKey(hashToScalar(data...))
+}
+
+func hashToScalar(data ...[]byte) *edwards25519.Scalar {
+	return reduce32(Keccak256(data...))
+}
+
+// reduce32 reduces 32 bytes modulo the group order, as sc_reduce32
+func reduce32(k Key) *edwards25519.Scalar {
+	var wide [64]byte
+	copy(wide[:], k[:])
+	s, err := edwards25519.NewScalar().SetUniformBytes(wide[:])
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// HashToPoint maps a key to a point of the prime order subgroup, as
+// hash_to_ec of monerod. Key images are x*HashToPoint(x*G).
+func HashToPoint(k Key) Key {
+	return pointKey(hashToPoint(k))
+}
+
+// randomScalar returns a uniformly random scalar
+func randomScalar() *edwards25519.Scalar {
+	var wide [64]byte
+	if _, err := rand.Read(wide[:]); err != nil {
+		panic(err)
+	}
+	s, err := edwards25519.NewScalar().SetUniformBytes(wide[:])
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// scalarFromUint64 returns n as a scalar
+func scalarFromUint64(n uint64) *edwards25519.Scalar {
+	var b [32]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// invert returns 1/s, computed as s^(l-2)
+func invert(s *edwards25519.Scalar) *edwards25519.Scalar {
+	// l - 2, little endian
+	exp := [32]byte{
+		0xeb, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10,
+	}
+	r := scalarFromUint64(1)
+	for i := 255; i >= 0; i-- {
+		r.Multiply(r, r)
+		if exp[i/8]>>(i%8)&1 == 1 {
+			r.Multiply(r, s)
+		}
+	}
+	return r
+}
+
+// multiScalarMult returns the sum of scalars[i]*points[i]
+func multiScalarMult(scalars []*edwards25519.Scalar, points []*edwards25519.Point) *edwards25519.Point {
+	sum := edwards25519.NewIdentityPoint()
+	var p edwards25519.Point
+	for i := range scalars {
+		sum.Add(sum, p.ScalarMult(scalars[i], points[i]))
+	}
+	return sum
+}
+
+// mul8 returns 8*p
+func mul8(p *edwards25519.Point) *edwards25519.Point {
+	r := new(edwards25519.Point).Add(p, p)
+	r.Add(r, r)
+	return r.Add(r, r)
+}
+
+// isIdentity tells whether p is the identity
+func isIdentity(p *edwards25519.Point) bool {
+	return p.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// varint appends n to b as a varint
+func varint(b []byte, n uint64) []byte {
+	for n >= 0x80 {
+		b = append(b, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(b, byte(n))
+}
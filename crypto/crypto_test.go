@@ -0,0 +1,739 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+	"github.com/konraddical2/gonero/internal/edwards25519/field"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keys of the address used across the tests of gonero,
+// 42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm,
+// and of its subaddress (0, 1). The root package imports crypto, so its
+// addresses cannot be decoded here.
+const (
+	testViewKey        = "49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"
+	testSpendKey       = "148d78d2aba7dbca5cd8f6abcfb0b3c009ffbdbea1ff373d50ed94d78286640e"
+	testPublicViewKey  = "231c9bf8341c6a870d92e3fb98063a90a355fb8dbf74a8561b9d7f9273247e99"
+	testPublicSpendKey = "1b3bd040020d3712ab84992b773d0a965134eb2df0392fb84af95de8a17be2ab"
+	testSubaddr01Spend = "337d469a9937f3a1aaa4d3a4f108d69ae727cff85a18232edb33f091ce9914be"
+)
+
+func TestH(t *testing.T) {
+	// H = 8*decompress(keccak(G))
+	g := new(edwards25519.Point).ScalarBaseMult(scalarFromUint64(1))
+	p, err := Keccak256(g.Bytes()).point()
+	require.NoError(t, err)
+	assert.Equal(t, H, pointKey(mul8(p)))
+}
+
+func TestKeys(t *testing.T) {
+	view, err := ParseSecretKey(testViewKey)
+	require.NoError(t, err)
+	spend, err := ParseSecretKey(testSpendKey)
+	require.NoError(t, err)
+	pub, err := view.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testPublicViewKey, pub.String())
+	pub, err = spend.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testPublicSpendKey, pub.String())
+
+	// subaddress (0, 1): D = B + m*G, C = a*D
+	m, err := SubaddressSecretKey(view, 0, 1).PublicKey()
+	require.NoError(t, err)
+	d := new(edwards25519.Point).Add(mustPoint(pub), mustPoint(m))
+	assert.Equal(t, testSubaddr01Spend, pointKey(d).String())
+	b, err := SubaddressSpendKey(pub, view, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, testSubaddr01Spend, b.String())
+
+	assert.Equal(t, "[REDACTED]", fmt.Sprintf("%v %s %x %#v", view, view, view, view)[:10])
+	assert.NotContains(t, fmt.Sprintf("%v %s %x %#v %+v", view, view, view, view, struct{ K SecretKey }{view}), testViewKey[:8])
+	assert.Equal(t, testViewKey, view.Reveal())
+
+	_, err = ParseSecretKey("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	assert.Error(t, err)
+	_, err = ParseKey("00")
+	assert.Error(t, err)
+}
+
+func TestHashToPoint(t *testing.T) {
+	one := new(field.Element).One()
+	// d = -121665/121666
+	d := new(field.Element).Negate(new(field.Element).Mult32(one, 121665))
+	d.Multiply(d, new(field.Element).Invert(new(field.Element).Mult32(one, 121666)))
+	for i := 0; i < 200; i++ {
+		b := Keccak256([]byte{byte(i)})
+		if i%2 == 0 {
+			b[31] |= 0x80
+		}
+		// -x^2 + y^2 = 1 + d*x^2*y^2
+		x, y := mapToCurve(b)
+		x2 := new(field.Element).Square(x)
+		y2 := new(field.Element).Square(y)
+		left := new(field.Element).Subtract(y2, x2)
+		right := new(field.Element).Multiply(d, new(field.Element).Multiply(x2, y2))
+		right.Add(right, one)
+		assert.Equal(t, 1, left.Equal(right), "%x", b)
+
+		// the result is in the prime order subgroup
+		p := hashToPoint(b)
+		assert.False(t, isIdentity(p))
+		assert.True(t, inPrimeSubgroup(p))
+	}
+
+	// hash_to_ec of monero tests/crypto/tests.txt
+	for k, p := range map[string]string{
+		"da66e9ba613919dec28ef367a125bb310d6d83fb9052e71034164b6dc4f392d0": "52b3f38753b4e13b74624862e253072cf12f745d43fcfafbe8c217701a6e5875",
+		"a7fbdeeccb597c2d5fdaf2ea2e10cbfcd26b5740903e7f6d46bcbf9a90384fc6": "f055ba2d0d9828ce2e203d9896bfda494d7830e7e3a27fa27d5eaa825a79a19c",
+		"ed6e6579368caba2cc4851672972e949c0ee586fee4d6d6a9476d4a908f64070": "da3ceda9a2ef6316bf9272566e6dffd785ac71f57855c0202f422bbb86af4ec0",
+	} {
+		assert.Equal(t, p, HashToPoint(mustKey(k)).String())
+	}
+}
+
+func TestHashToScalar(t *testing.T) {
+	// hash_to_scalar of monero tests/crypto/tests.txt
+	for data, s := range map[string]string{
+		"59d28aeade98016722948bf596af0b7deb5dd641f1aa2a906bd4e1": "7d0b25809fc4032a81dd5b0f721a2b21f7f68157c834374f580876f5d91f7409",
+		"60d9a4b96951481ab458":   "b0955682b297dbcae4a5c1b6f21addb211d6180632b538472045b5d592c38109",
+		"7d535b4896ddc350a5fdff": "7bb1a59783be93ada537801f31ef52b0d2ea135a084c47cbad9a7c6b0d2c990f",
+	} {
+		b, err := hex.DecodeString(data)
+		require.NoError(t, err)
+		assert.Equal(t, s, HashToScalar(b).String())
+	}
+}
+
+func TestInvert(t *testing.T) {
+	for i := uint64(1); i < 50; i++ {
+		s := scalarFromUint64(i * 7919)
+		assert.Equal(t, scalarKey(scalarFromUint64(1)), scalarKey(s.Multiply(s, invert(s))))
+	}
+	x := randomScalar()
+	assert.Equal(t, scalarKey(scalarFromUint64(1)), scalarKey(new(edwards25519.Scalar).Multiply(x, invert(x))))
+	assert.Equal(t, scalarKey(scalarFromUint64(1)), scalarKey(new(edwards25519.Scalar).Multiply(invEight, scalarFromUint64(8))))
+}
+
+func TestDerivation(t *testing.T) {
+	// the sender derives r*A, the receiver a*R
+	view, _ := ParseSecretKey(testViewKey)
+	spend, _ := ParseSecretKey(testSpendKey)
+	viewPub, _ := view.PublicKey()
+	spendPub, _ := spend.PublicKey()
+	r := NewSecretKey()
+	txPub, err := r.PublicKey()
+	require.NoError(t, err)
+	sent, err := KeyDerivation(viewPub, r)
+	require.NoError(t, err)
+	received, err := KeyDerivation(txPub, view)
+	require.NoError(t, err)
+	assert.Equal(t, sent, received)
+
+	out, err := DerivePublicKey(sent, 3, spendPub)
+	require.NoError(t, err)
+	sec, err := DeriveSecretKey(received, 3, spend)
+	require.NoError(t, err)
+	pub, err := sec.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, out, pub)
+	assert.Equal(t, ViewTag(sent, 3), ViewTag(received, 3))
+
+	ki, err := KeyImage(out, sec)
+	require.NoError(t, err)
+	ki2, err := KeyImage(out, sec)
+	require.NoError(t, err)
+	assert.Equal(t, ki, ki2)
+
+	shared := DerivationToScalar(sent, 3)
+	enc := EncodeAmount(1234567890, shared)
+	assert.Equal(t, uint64(1234567890), DecodeAmount(enc, shared))
+	assert.NotEqual(t, uint64(1234567890), DecodeAmount(enc, DerivationToScalar(sent, 4)))
+
+	// commitments balance when masks do
+	m1, m2 := NewSecretKey(), NewSecretKey()
+	sum, err := AddSecretKeys(m1, m2)
+	require.NoError(t, err)
+	c1, _ := Commit(700, m1)
+	c2, _ := Commit(200, m2)
+	in, _ := Commit(1000, sum)
+	assert.NoError(t, CheckBalance([]Key{in}, []Key{c1, c2}, 100))
+	assert.Equal(t, ErrUnbalanced, CheckBalance([]Key{in}, []Key{c1, c2}, 99))
+	var zero SecretKey
+	c, _ := Commit(5, zero)
+	assert.NoError(t, CheckBalance([]Key{c}, nil, 5))
+	one := SecretKey{1}
+	c, _ = Commit(5, one)
+	assert.Equal(t, ZeroCommit(5), c)
+
+	// output 1 of a stagenet transaction, received with its view key
+	view, _ = ParseSecretKey("8aa763d1c8d9da4ca75cb6ca22a021b5cca376c1367be8d62bcc9cdf4b926009")
+	derivation, err := KeyDerivation(mustKey("7302dd77bf4095baf868de43b7a32f4a36fe9d8b48ccfff537157a4a786fa364"), view)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x1a), ViewTag(derivation, 1))
+	out, err = DerivePublicKey(derivation, 1, mustKey("38e9908d33d034de0ba1281aa7afe3907b795cea14852b3d8fe276e8931cb130"))
+	require.NoError(t, err)
+	assert.Equal(t, "7e4f4427539b206740bed78b81b0dc10acb89aa1545880863f73264492ee0c16", out.String())
+	var amount [8]byte
+	_, err = hex.Decode(amount[:], []byte("5db33f80fd4990bc"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(550000000000), DecodeAmount(amount, DerivationToScalar(derivation, 1)))
+}
+
+// ring returns a ring of n random members, with a real one at index
+func ring(t *testing.T, n, index int, amount uint64) ([]RingMember, SecretKey, SecretKey) {
+	members := make([]RingMember, n)
+	for i := range members {
+		members[i].Dest, _ = NewSecretKey().PublicKey()
+		members[i].Mask, _ = Commit(uint64(i)*1000, NewSecretKey())
+	}
+	secret, mask := NewSecretKey(), NewSecretKey()
+	var err error
+	members[index].Dest, err = secret.PublicKey()
+	require.NoError(t, err)
+	members[index].Mask, err = Commit(amount, mask)
+	require.NoError(t, err)
+	return members, secret, mask
+}
+
+// verifyCLSAG verifies a signature as verRctCLSAGSimple of monerod, with
+// its own hashes rather than the clsagHasher shared by SignCLSAG and
+// VerifyCLSAG
+func verifyCLSAG(message Key, ring []RingMember, pseudoOut, keyImage Key, sig *CLSAG) bool {
+	n := len(ring)
+	padded := func(s string) []byte {
+		b := make([]byte, KeySize)
+		copy(b, s)
+		return b
+	}
+	// mu_P and mu_C hash the keys, the commitments, I, D/8 and the pseudo output
+	agg := [][]byte{nil}
+	for i := range ring {
+		agg = append(agg, ring[i].Dest[:])
+	}
+	for i := range ring {
+		agg = append(agg, ring[i].Mask[:])
+	}
+	agg = append(agg, keyImage[:], sig.D[:], pseudoOut[:])
+	agg[0] = padded("CLSAG_agg_0")
+	muP := hashToScalar(agg...)
+	agg[0] = padded("CLSAG_agg_1")
+	muC := hashToScalar(agg...)
+
+	I, D, offset := mustPoint(keyImage), mustPoint(sig.D), mustPoint(pseudoOut)
+	D.ScalarMult(scalarFromUint64(8), D)
+	c1, err := sig.C1.scalar()
+	if err != nil {
+		return false
+	}
+	c := c1
+	for i := 0; i < n; i++ {
+		s, err := sig.S[i].scalar()
+		if err != nil {
+			return false
+		}
+		cP := new(edwards25519.Scalar).Multiply(muP, c)
+		cC := new(edwards25519.Scalar).Multiply(muC, c)
+		P := mustPoint(ring[i].Dest)
+		C := new(edwards25519.Point).Subtract(mustPoint(ring[i].Mask), offset)
+		l := new(edwards25519.Point).ScalarBaseMult(s)
+		l.Add(l, new(edwards25519.Point).ScalarMult(cP, P))
+		l.Add(l, new(edwards25519.Point).ScalarMult(cC, C))
+		r := new(edwards25519.Point).ScalarMult(s, hashToPoint(ring[i].Dest))
+		r.Add(r, new(edwards25519.Point).ScalarMult(cP, I))
+		r.Add(r, new(edwards25519.Point).ScalarMult(cC, D))
+		round := [][]byte{padded("CLSAG_round")}
+		round = append(round, agg[1:2*n+1]...)
+		round = append(round, pseudoOut[:], message[:], l.Bytes(), r.Bytes())
+		c = hashToScalar(round...)
+	}
+	return c.Equal(c1) == 1
+}
+
+func TestCLSAG(t *testing.T) {
+	message := Keccak256([]byte("message"))
+	for _, index := range []int{0, 7, 15} {
+		members, secret, mask := ring(t, 16, index, 5000)
+		pseudoMask := NewSecretKey()
+		pseudoOut, _ := Commit(5000, pseudoMask)
+		sig, keyImage, err := SignCLSAG(message, members, index, secret, mask, pseudoMask)
+		require.NoError(t, err)
+		expected, _ := KeyImage(members[index].Dest, secret)
+		assert.Equal(t, expected, keyImage)
+		assert.NoError(t, VerifyCLSAG(message, members, pseudoOut, keyImage, sig))
+		assert.True(t, verifyCLSAG(message, members, pseudoOut, keyImage, sig))
+
+		// anything changed breaks the signature
+		assert.Equal(t, ErrInvalidSignature, VerifyCLSAG(Keccak256([]byte("other")), members, pseudoOut, keyImage, sig))
+		assert.False(t, verifyCLSAG(Keccak256([]byte("other")), members, pseudoOut, keyImage, sig))
+		other, _ := Commit(5001, pseudoMask)
+		assert.Equal(t, ErrInvalidSignature, VerifyCLSAG(message, members, other, keyImage, sig))
+		otherImage, _ := KeyImage(members[index].Dest, NewSecretKey())
+		assert.Equal(t, ErrInvalidSignature, VerifyCLSAG(message, members, pseudoOut, otherImage, sig))
+		swapped := append([]RingMember{}, members...)
+		swapped[1], swapped[2] = swapped[2], swapped[1]
+		assert.Equal(t, ErrInvalidSignature, VerifyCLSAG(message, swapped, pseudoOut, keyImage, sig))
+		bad := *sig
+		bad.S = append([]Key{}, sig.S...)
+		bad.S[3][0] ^= 1
+		assert.Error(t, VerifyCLSAG(message, members, pseudoOut, keyImage, &bad))
+		assert.Error(t, VerifyCLSAG(message, members[1:], pseudoOut, keyImage, sig))
+	}
+
+	// the amounts of the input and its pseudo output must match
+	members, secret, mask := ring(t, 11, 4, 5000)
+	pseudoMask := NewSecretKey()
+	pseudoOut, _ := Commit(4000, pseudoMask)
+	sig, keyImage, err := SignCLSAG(message, members, 4, secret, mask, pseudoMask)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidSignature, VerifyCLSAG(message, members, pseudoOut, keyImage, sig))
+
+	_, _, err = SignCLSAG(message, members, 5, secret, mask, pseudoMask)
+	assert.Error(t, err)
+}
+
+func TestBulletproofPlus(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 16} {
+		amounts := make([]uint64, n)
+		masks := make([]SecretKey, n)
+		for i := range amounts {
+			amounts[i] = uint64(i+1) * 123456789
+			masks[i] = NewSecretKey()
+		}
+		amounts[0] = ^uint64(0)
+		proof, err := ProveBulletproofPlus(amounts, masks)
+		require.NoError(t, err)
+		m, logM := rangeProofSize(n)
+		assert.Len(t, proof.L, logM+6, "%d", m)
+		assert.NoError(t, VerifyBulletproofPlus(proof))
+
+		// V are the commitments multiplied by 1/8
+		for i := range amounts {
+			c, _ := Commit(amounts[i], masks[i])
+			v := mustPoint(proof.V[i])
+			assert.Equal(t, c, pointKey(mul8(v)))
+		}
+
+		bad := *proof
+		bad.V = append([]Key{}, proof.V...)
+		bad.V[0] = pointKey(new(edwards25519.Point).ScalarMult(invEight, mustPoint(ZeroCommit(1))))
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproofPlus(&bad))
+		bad = *proof
+		bad.R1 = HashToScalar([]byte("r1"))
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproofPlus(&bad))
+		bad = *proof
+		bad.L = append([]Key{}, proof.L...)
+		bad.L[0], bad.L[1] = bad.L[1], bad.L[0]
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproofPlus(&bad))
+		bad = *proof
+		bad.L = proof.L[1:]
+		assert.Error(t, VerifyBulletproofPlus(&bad))
+	}
+
+	_, err := ProveBulletproofPlus(make([]uint64, 17), make([]SecretKey, 17))
+	assert.Error(t, err)
+	_, err = ProveBulletproofPlus([]uint64{1}, nil)
+	assert.Error(t, err)
+}
+
+// signMLSAG signs as monerod did before v0.17, to test the verifier. The
+// challenges are hashed here rather than with mlsagChallenge, which the
+// verifier uses.
+func signMLSAG(t *testing.T, message Key, ring []RingMember, index int, secret, mask, pseudoMask SecretKey) (*MLSAG, Key) {
+	x0, err := secret.scalar()
+	require.NoError(t, err)
+	m, _ := mask.scalar()
+	pm, _ := pseudoMask.scalar()
+	x1 := new(edwards25519.Scalar).Subtract(m, pm)
+	pseudo := new(edwards25519.Point).Subtract(mustPoint(ring[index].Mask), new(edwards25519.Point).ScalarBaseMult(x1))
+	I := new(edwards25519.Point).ScalarMult(x0, hashToPoint(ring[index].Dest))
+	commitment := func(i int) (Key, *edwards25519.Point) {
+		C := new(edwards25519.Point).Subtract(mustPoint(ring[i].Mask), pseudo)
+		return pointKey(C), C
+	}
+
+	sig := &MLSAG{SS: make([][]Key, len(ring))}
+	a0, a1 := randomScalar(), randomScalar()
+	ck, _ := commitment(index)
+	dest := ring[index].Dest
+	c := hashToScalar(message[:], dest[:], new(edwards25519.Point).ScalarBaseMult(a0).Bytes(),
+		new(edwards25519.Point).ScalarMult(a0, hashToPoint(dest)).Bytes(), ck[:], new(edwards25519.Point).ScalarBaseMult(a1).Bytes())
+	n := len(ring)
+	for i := (index + 1) % n; i != index; i = (i + 1) % n {
+		if i == 0 {
+			sig.CC = scalarKey(c)
+		}
+		s0, s1 := randomScalar(), randomScalar()
+		sig.SS[i] = []Key{scalarKey(s0), scalarKey(s1)}
+		ck, C := commitment(i)
+		P := ring[i].Dest
+		l0 := new(edwards25519.Point).ScalarBaseMult(s0)
+		l0.Add(l0, new(edwards25519.Point).ScalarMult(c, mustPoint(P)))
+		r0 := new(edwards25519.Point).ScalarMult(s0, hashToPoint(P))
+		r0.Add(r0, new(edwards25519.Point).ScalarMult(c, I))
+		l1 := new(edwards25519.Point).ScalarBaseMult(s1)
+		l1.Add(l1, new(edwards25519.Point).ScalarMult(c, C))
+		c = hashToScalar(message[:], P[:], l0.Bytes(), r0.Bytes(), ck[:], l1.Bytes())
+	}
+	if index == 0 {
+		sig.CC = scalarKey(c)
+	}
+	s0 := new(edwards25519.Scalar).Multiply(c, x0)
+	s1 := new(edwards25519.Scalar).Multiply(c, x1)
+	sig.SS[index] = []Key{scalarKey(s0.Subtract(a0, s0)), scalarKey(s1.Subtract(a1, s1))}
+	return sig, pointKey(I)
+}
+
+func TestMLSAG(t *testing.T) {
+	message := Keccak256([]byte("message"))
+	for _, index := range []int{0, 5, 10} {
+		members, secret, mask := ring(t, 11, index, 5000)
+		pseudoMask := NewSecretKey()
+		pseudoOut, _ := Commit(5000, pseudoMask)
+		sig, keyImage := signMLSAG(t, message, members, index, secret, mask, pseudoMask)
+		expected, _ := KeyImage(members[index].Dest, secret)
+		assert.Equal(t, expected, keyImage)
+		assert.NoError(t, VerifyMLSAG(message, members, pseudoOut, keyImage, sig))
+
+		assert.Equal(t, ErrInvalidSignature, VerifyMLSAG(Keccak256([]byte("other")), members, pseudoOut, keyImage, sig))
+		other, _ := Commit(5001, pseudoMask)
+		assert.Equal(t, ErrInvalidSignature, VerifyMLSAG(message, members, other, keyImage, sig))
+		otherImage, _ := KeyImage(members[index].Dest, NewSecretKey())
+		assert.Equal(t, ErrInvalidSignature, VerifyMLSAG(message, members, pseudoOut, otherImage, sig))
+		assert.Error(t, VerifyMLSAG(message, members[1:], pseudoOut, keyImage, sig))
+	}
+}
+
+// bpGenerator returns the generator i of the original bulletproofs, as
+// get_exponent of monerod: Hp(Keccak(H || "bulletproof" || varint(i)))
+func bpGenerator(i int) *edwards25519.Point {
+	v := make([]byte, binary.MaxVarintLen64)
+	v = v[:binary.PutUvarint(v, uint64(i))]
+	return hashToPoint(Keccak256(H[:], []byte("bulletproof"), v))
+}
+
+// proveBulletproof proves amounts as monerod did before v0.18, to test the
+// verifier. The generators and the transcript are computed here rather than
+// with bpGenerators and transcriptUpdate, which the verifier uses.
+func proveBulletproof(t *testing.T, amounts []uint64, masks []SecretKey) *Bulletproof {
+	m, logM := rangeProofSize(len(amounts))
+	mn := m * rangeBits
+	gensG := make([]*edwards25519.Point, mn)
+	gensH := make([]*edwards25519.Point, mn)
+	for i := 0; i < mn; i++ {
+		gensH[i] = bpGenerator(2 * i)
+		gensG[i] = bpGenerator(2*i + 1)
+	}
+	// hs returns Hs of the concatenated keys
+	hs := func(keys ...Key) Key {
+		data := make([][]byte, len(keys))
+		for i := range keys {
+			data[i] = keys[i][:]
+		}
+		return HashToScalar(data...)
+	}
+	p := &Bulletproof{}
+	gammas := make([]*edwards25519.Scalar, m)
+	for j := range gammas {
+		gammas[j] = edwards25519.NewScalar()
+		if j < len(amounts) {
+			gammas[j], _ = masks[j].scalar()
+			p.V = append(p.V, pointKey(new(edwards25519.Point).ScalarMult(invEight, commit(amounts[j], gammas[j]))))
+		}
+	}
+	one := scalarFromUint64(1)
+	aL := make([]*edwards25519.Scalar, mn)
+	aR := make([]*edwards25519.Scalar, mn)
+	sL := make([]*edwards25519.Scalar, mn)
+	sR := make([]*edwards25519.Scalar, mn)
+	for i := range aL {
+		var bit uint64
+		if i/rangeBits < len(amounts) {
+			bit = amounts[i/rangeBits] >> uint(i%rangeBits) & 1
+		}
+		aL[i] = scalarFromUint64(bit)
+		aR[i] = new(edwards25519.Scalar).Subtract(aL[i], one)
+		sL[i], sR[i] = randomScalar(), randomScalar()
+	}
+	// vector commitment, divided by 8
+	vector := func(a, b []*edwards25519.Scalar, G, H []*edwards25519.Point, blind *edwards25519.Scalar, extra *edwards25519.Point) Key {
+		scalars := append(append([]*edwards25519.Scalar{}, a...), b...)
+		points := append(append([]*edwards25519.Point{}, G...), H...)
+		sum := multiScalarMult(scalars, points)
+		if blind != nil {
+			sum.Add(sum, new(edwards25519.Point).ScalarBaseMult(blind))
+		}
+		if extra != nil {
+			sum.Add(sum, extra)
+		}
+		return pointKey(sum.ScalarMult(invEight, sum))
+	}
+	alpha, rho := randomScalar(), randomScalar()
+	p.A = vector(aL, aR, gensG, gensH, alpha, nil)
+	p.S = vector(sL, sR, gensG, gensH, rho, nil)
+	y := hs(hs(p.V...), p.A, p.S)
+	zk := hs(y)
+	ys, _ := y.scalar()
+	z, _ := zk.scalar()
+	zPow := powers(z, m+3)
+	yPow := powers(ys, mn)
+	twos := powers(scalarFromUint64(2), rangeBits)
+
+	l0, l1, r0, r1 := make([]*edwards25519.Scalar, mn), sL, make([]*edwards25519.Scalar, mn), make([]*edwards25519.Scalar, mn)
+	for i := range l0 {
+		l0[i] = new(edwards25519.Scalar).Subtract(aL[i], z)
+		r0[i] = new(edwards25519.Scalar).Add(aR[i], z)
+		r0[i].Multiply(r0[i], yPow[i])
+		r0[i].Add(r0[i], new(edwards25519.Scalar).Multiply(zPow[2+i/rangeBits], twos[i%rangeBits]))
+		r1[i] = new(edwards25519.Scalar).Multiply(sR[i], yPow[i])
+	}
+	inner := func(a, b []*edwards25519.Scalar) *edwards25519.Scalar {
+		r := edwards25519.NewScalar()
+		for i := range a {
+			r.MultiplyAdd(a[i], b[i], r)
+		}
+		return r
+	}
+	t1 := new(edwards25519.Scalar).Add(inner(l0, r1), inner(l1, r0))
+	t2 := inner(l1, r1)
+	tau1, tau2 := randomScalar(), randomScalar()
+	p.T1 = pointKey(new(edwards25519.Point).ScalarMult(invEight, commitScalar(t1, tau1)))
+	p.T2 = pointKey(new(edwards25519.Point).ScalarMult(invEight, commitScalar(t2, tau2)))
+	xk := hs(zk, zk, p.T1, p.T2)
+	x, _ := xk.scalar()
+	taux := new(edwards25519.Scalar).Multiply(tau1, x)
+	taux.Add(taux, new(edwards25519.Scalar).Multiply(tau2, new(edwards25519.Scalar).Multiply(x, x)))
+	for j := range gammas {
+		taux.MultiplyAdd(zPow[2+j], gammas[j], taux)
+	}
+	mu := new(edwards25519.Scalar).MultiplyAdd(x, rho, alpha)
+	a := make([]*edwards25519.Scalar, mn)
+	b := make([]*edwards25519.Scalar, mn)
+	for i := range a {
+		a[i] = new(edwards25519.Scalar).MultiplyAdd(x, l1[i], l0[i])
+		b[i] = new(edwards25519.Scalar).MultiplyAdd(x, r1[i], r0[i])
+	}
+	tt := inner(a, b)
+	p.Taux, p.Mu, p.T = scalarKey(taux), scalarKey(mu), scalarKey(tt)
+	transcript := hs(xk, xk, p.Taux, p.Mu, p.T)
+	xIP, _ := transcript.scalar()
+
+	G := append([]*edwards25519.Point{}, gensG...)
+	H := make([]*edwards25519.Point, mn)
+	yInv := invert(ys)
+	yInvPow := scalarFromUint64(1)
+	for i := range H {
+		H[i] = new(edwards25519.Point).ScalarMult(yInvPow, gensH[i])
+		yInvPow = new(edwards25519.Scalar).Multiply(yInvPow, yInv)
+	}
+	for n := mn / 2; n >= 1; n /= 2 {
+		cL, cR := inner(a[:n], b[n:]), inner(a[n:], b[:n])
+		p.L = append(p.L, vector(a[:n], b[n:], G[n:], H[:n], nil, new(edwards25519.Point).ScalarMult(cL.Multiply(cL, xIP), hPoint)))
+		p.R = append(p.R, vector(a[n:], b[:n], G[:n], H[n:], nil, new(edwards25519.Point).ScalarMult(cR.Multiply(cR, xIP), hPoint)))
+		transcript = hs(transcript, p.L[len(p.L)-1], p.R[len(p.R)-1])
+		w, _ := transcript.scalar()
+		wInv := invert(w)
+		for i := 0; i < n; i++ {
+			G[i] = multiScalarMult([]*edwards25519.Scalar{wInv, w}, []*edwards25519.Point{G[i], G[n+i]})
+			H[i] = multiScalarMult([]*edwards25519.Scalar{w, wInv}, []*edwards25519.Point{H[i], H[n+i]})
+			a[i] = new(edwards25519.Scalar).Multiply(w, a[i])
+			a[i].MultiplyAdd(wInv, a[n+i], a[i])
+			b[i] = new(edwards25519.Scalar).Multiply(wInv, b[i])
+			b[i].MultiplyAdd(w, b[n+i], b[i])
+		}
+		a, b, G, H = a[:n], b[:n], G[:n], H[:n]
+	}
+	require.Len(t, p.L, logM+6)
+	p.InnerA, p.InnerB = scalarKey(a[0]), scalarKey(b[0])
+	return p
+}
+
+// commitScalar returns mask*G + v*H
+func commitScalar(v, mask *edwards25519.Scalar) *edwards25519.Point {
+	return new(edwards25519.Point).VarTimeDoubleScalarBaseMult(v, hPoint, mask)
+}
+
+func TestBulletproof(t *testing.T) {
+	for _, n := range []int{1, 2, 3} {
+		amounts := make([]uint64, n)
+		masks := make([]SecretKey, n)
+		for i := range amounts {
+			amounts[i] = uint64(i+7) * 987654321
+			masks[i] = NewSecretKey()
+		}
+		amounts[n-1] = ^uint64(0)
+		proof := proveBulletproof(t, amounts, masks)
+		assert.NoError(t, VerifyBulletproof(proof))
+		for i := range amounts {
+			c, _ := Commit(amounts[i], masks[i])
+			assert.Equal(t, c, pointKey(mul8(mustPoint(proof.V[i]))))
+		}
+
+		bad := *proof
+		bad.V = append([]Key{}, proof.V...)
+		bad.V[0] = pointKey(new(edwards25519.Point).ScalarMult(invEight, mustPoint(ZeroCommit(1))))
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproof(&bad))
+		bad = *proof
+		bad.T = HashToScalar([]byte("t"))
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproof(&bad))
+		bad = *proof
+		bad.InnerA = HashToScalar([]byte("a"))
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproof(&bad))
+		bad = *proof
+		bad.R = append([]Key{}, proof.R...)
+		bad.R[2], bad.R[3] = bad.R[3], bad.R[2]
+		assert.Equal(t, ErrInvalidProof, VerifyBulletproof(&bad))
+		bad = *proof
+		bad.L = proof.L[1:]
+		assert.Error(t, VerifyBulletproof(&bad))
+	}
+
+	// amounts of 65 bits cannot be proven
+	proof := proveBulletproof(t, []uint64{1}, []SecretKey{NewSecretKey()})
+	c := mustPoint(proof.V[0])
+	proof.V[0] = pointKey(c.Add(c, new(edwards25519.Point).ScalarMult(invEight, new(edwards25519.Point).ScalarMult(scalarFromUint64(1<<63), mul8(mustPoint(H))))))
+	assert.Equal(t, ErrInvalidProof, VerifyBulletproof(proof))
+}
+
+func TestSignature(t *testing.T) {
+	sec := NewSecretKey()
+	pub, err := sec.PublicKey()
+	require.NoError(t, err)
+	hash := Keccak256([]byte("message"))
+	sig, err := Sign(hash, sec)
+	require.NoError(t, err)
+	assert.NoError(t, VerifySignature(hash, pub, sig))
+	parsed, err := ParseSignature(sig.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, sig, parsed)
+	_, err = ParseSignature(sig.Bytes()[1:])
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+
+	other, _ := NewSecretKey().PublicKey()
+	assert.True(t, errors.Is(VerifySignature(hash, other, sig), ErrInvalidSignature))
+	assert.True(t, errors.Is(VerifySignature(Keccak256([]byte("other")), pub, sig), ErrInvalidSignature))
+	assert.True(t, errors.Is(VerifySignature(hash, pub, Signature{R: sig.R}), ErrInvalidSignature))
+}
+
+func TestRingSignature(t *testing.T) {
+	hash := Keccak256([]byte("message"))
+	sec := NewSecretKey()
+	ring := make([]Key, 5)
+	for i := range ring {
+		ring[i], _ = NewSecretKey().PublicKey()
+	}
+	ring[3], _ = sec.PublicKey()
+	sigs, keyImage, err := SignRing(hash, ring, 3, sec)
+	require.NoError(t, err)
+	expected, _ := KeyImage(ring[3], sec)
+	assert.Equal(t, expected, keyImage)
+	assert.NoError(t, VerifyRing(hash, keyImage, ring, sigs))
+
+	// a ring of one, as reserve proofs
+	one, ki, err := SignRing(hash, ring[3:4], 0, sec)
+	require.NoError(t, err)
+	assert.Equal(t, keyImage, ki)
+	assert.NoError(t, VerifyRing(hash, keyImage, ring[3:4], one))
+
+	_, _, err = SignRing(hash, ring, 2, sec)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+	assert.True(t, errors.Is(VerifyRing(Keccak256([]byte("other")), keyImage, ring, sigs), ErrInvalidSignature))
+	assert.True(t, errors.Is(VerifyRing(hash, keyImage, ring[:4], sigs[:4]), ErrInvalidSignature))
+	other, _ := KeyImage(ring[3], NewSecretKey())
+	assert.True(t, errors.Is(VerifyRing(hash, other, ring, sigs), ErrInvalidSignature))
+
+	// check_ring_signature of an input of mainnet block 40646, and of
+	// monero tests/crypto/tests.txt
+	for _, v := range []struct {
+		hash, keyImage string
+		ring           []string
+		sigs           string
+		valid          bool
+	}{
+		{
+			"aeecb4170b276d2ac69a7abca86f82621f56d943c8d4a8900cd56192da8d442d",
+			"c9679ba9ca8a6fa87a1352985e46ea3723489d3699ab1af075532f711739b9c5",
+			[]string{"6646f168c842275b31ca863f6eac8eed9e5dfc5714d5864efb62f6c340298a30"},
+			"11b4d1bd92e85f38152848cbf100c6f8b15c9de5278e4506bb9131230807d60e658188593715e7980a9d9e188d2114f2a3b71541cfe66fb94413237edf36dc0a",
+			true,
+		},
+		{
+			"90660b84dd3be5705c7766695fec404348af6df58f8c5d58213f3b70b8b67a23",
+			"6289b9b151eeb263fc29e4b5e90978db7670f06f408403c8973bbfff2a884dd9",
+			[]string{"4af96f2c3a70ac1860d48132136989c1d38551367025d43f36aec0ffa8e7f28a", "376cc178d8ae3a68ce467bfbe719e88b22514617dbd1e764e0b94b4f6bc961af"},
+			"4ccadd504d1d03e385ebd25dc51b98c6f3a0e1c1be7e5694e44dc2377898510ca3202d7872294cc04b65d8c109e3a6e843c327b3416ca3a2b1c585fe4152260555441dd7b1543549f749acf5fc9a93a3f3c240425c5f7cadccdef4f06cef0702ae4ad477d0cb60a1a48c1da22f5a8b20c7c5672833c7ae13f78edeb3db1a7b01",
+			true,
+		},
+		{
+			"d280b24c280daade9d2bcd68c6dfd39d3a13eb1b0645c4f7d2b0613dd4b5af3d",
+			"f1b943daa1ef225726215f551dfd85f56a3b429ded8608a09a8310a90b8aa88a",
+			[]string{"2d4e494897c24b1730f018df65468c2647b2dc19f650d1a9e055b9319045ff13", "74db9c16b0cb4beb7d48ec77b654c63917529072aa57d381b5e3b8dbb06e0f5b"},
+			"8aae0a8523d65b3746c87994e4cffaf437ac147a82efe34389d270a976183006c7de37ef0362e13aab9287a85445748a8e0e1a357c6a0ba090f436937a1878b47b41de38a3737152453ca3c0c6546b65ceaff3298329273b0808d35af376a20c1217c85b153d40bc154108eca199175b3efa3f190740325c734d82cfb054d50f",
+			false,
+		},
+	} {
+		ring := make([]Key, len(v.ring))
+		for i := range ring {
+			ring[i] = mustKey(v.ring[i])
+		}
+		b, err := hex.DecodeString(v.sigs)
+		require.NoError(t, err)
+		sigs := make([]Signature, len(ring))
+		for i := range sigs {
+			sigs[i], err = ParseSignature(b[i*SignatureSize : (i+1)*SignatureSize])
+			require.NoError(t, err)
+		}
+		err = VerifyRing(mustKey(v.hash), mustKey(v.keyImage), ring, sigs)
+		assert.Equal(t, v.valid, err == nil, "%s: %v", v.hash, err)
+	}
+}
+
+func TestTxProof(t *testing.T) {
+	hash := Keccak256([]byte("txid and message"))
+	view := NewSecretKey()
+	A, _ := view.PublicKey()
+	r := NewSecretKey()
+	R, _ := r.PublicKey()
+	D, err := ScalarMult(r, A)
+	require.NoError(t, err)
+
+	// out proof: R = r*G, D = r*A
+	sig, err := SignTxProof(hash, R, A, nil, D, r)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyTxProof(hash, R, A, nil, D, sig))
+	// in proof: A = a*G, D = a*R
+	sig, err = SignTxProof(hash, A, R, nil, D, view)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyTxProof(hash, A, R, nil, D, sig))
+	assert.True(t, errors.Is(VerifyTxProof(hash, R, A, nil, D, sig), ErrInvalidSignature))
+	assert.True(t, errors.Is(VerifyTxProof(Keccak256([]byte("other")), A, R, nil, D, sig), ErrInvalidSignature))
+
+	// to a subaddress: R = r*B
+	B, _ := NewSecretKey().PublicKey()
+	RB, _ := ScalarMult(r, B)
+	sig, err = SignTxProof(hash, RB, A, &B, D, r)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyTxProof(hash, RB, A, &B, D, sig))
+	assert.True(t, errors.Is(VerifyTxProof(hash, RB, A, nil, D, sig), ErrInvalidSignature))
+
+	_, err = SignTxProof(hash, R, A, nil, A, r)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestDeriveSubaddressPublicKey(t *testing.T) {
+	spend, _ := NewSecretKey().PublicKey()
+	derivation := Keccak256([]byte("derivation"))
+	derivation, _ = ScalarMult(SecretKey{8}, HashToPoint(derivation))
+	key, err := DerivePublicKey(derivation, 3, spend)
+	require.NoError(t, err)
+	back, err := DeriveSubaddressPublicKey(key, derivation, 3)
+	require.NoError(t, err)
+	assert.Equal(t, spend, back)
+}
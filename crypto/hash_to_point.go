@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"github.com/konraddical2/gonero/internal/edwards25519"
+	"github.com/konraddical2/gonero/internal/edwards25519/field"
+)
+
+// constants of ge_fromfe_frombytes_vartime, with A the Montgomery curve
+// coefficient: -A, -A^2, sqrt(-1), sqrt(-2A(A+2)), sqrt(2A(A+2)),
+// sqrt(-sqrt(-1)A(A+2)) and sqrt(sqrt(-1)A(A+2)). They are package
+// variables rather than set by init, so that package variables hashed to
+// points, such as the Bulletproofs+ transcript, are computed after them.
+var feMA, feMA2, feSqrtM1, feFFFB1, feFFFB2, feFFFB3, feFFFB4, fe19 = fieldConstants()
+
+func fieldConstants() (ma, ma2, sqrtM1, fffb1, fffb2, fffb3, fffb4, c19 *field.Element) {
+	one := new(field.Element).One()
+	a := new(field.Element).Mult32(one, 486662)
+	ma = new(field.Element).Negate(a)
+	ma2 = new(field.Element).Negate(new(field.Element).Square(a))
+	c19 = new(field.Element).Mult32(one, 19)
+
+	sqrt := func(x *field.Element) *field.Element {
+		r, ok := new(field.Element).SqrtRatio(x, one)
+		if ok != 1 {
+			panic("crypto: no square root")
+		}
+		return r
+	}
+	sqrtM1 = sqrt(new(field.Element).Negate(one))
+	// A(A+2)
+	aa2 := new(field.Element).Multiply(a, new(field.Element).Add(a, new(field.Element).Mult32(one, 2)))
+	twoAA2 := new(field.Element).Add(aa2, aa2)
+	fffb1 = sqrt(new(field.Element).Negate(twoAA2))
+	fffb2 = sqrt(twoAA2)
+	iAA2 := new(field.Element).Multiply(sqrtM1, aa2)
+	fffb3 = sqrt(new(field.Element).Negate(iAA2))
+	fffb4 = sqrt(iAA2)
+	return
+}
+
+// hashToPoint is hash_to_ec: ge_fromfe_frombytes_vartime of the hash of
+// the key, multiplied by the cofactor
+func hashToPoint(k Key) *edwards25519.Point {
+	return mul8(fromFieldBytes(Keccak256(k[:])))
+}
+
+// fromFieldBytes maps 32 bytes to a point of the curve, as
+// ge_fromfe_frombytes_vartime of monerod
+func fromFieldBytes(b Key) *edwards25519.Point {
+	x, y := mapToCurve(b)
+	enc := y.Bytes()
+	enc[31] |= byte(x.IsNegative() << 7)
+	p, err := new(edwards25519.Point).SetBytes(enc)
+	if err != nil {
+		panic("crypto: point off the curve")
+	}
+	return p
+}
+
+// mapToCurve returns the affine coordinates of the point of
+// ge_fromfe_frombytes_vartime. The bytes are read as a field element
+// including their top bit.
+func mapToCurve(b Key) (*field.Element, *field.Element) {
+	u, _ := new(field.Element).SetBytes(b[:])
+	if b[31]&0x80 != 0 {
+		u.Add(u, fe19)
+	}
+
+	v := new(field.Element).Square(u)
+	v.Add(v, v) // 2u^2
+	w := new(field.Element).One()
+	w.Add(w, v) // 2u^2 + 1
+	x := new(field.Element).Square(w)
+	x.Add(x, new(field.Element).Multiply(feMA2, v)) // w^2 - 2A^2u^2
+	r := divPowM1(w, x)                             // (w/x)^((p+3)/8)
+	y := new(field.Element).Square(r)
+	x.Multiply(y, x)
+	y.Subtract(w, x)
+	z := new(field.Element).Set(feMA)
+	zero := new(field.Element).Zero()
+
+	var sign int
+	switch {
+	case y.Equal(zero) == 1:
+		r.Multiply(r, feFFFB2)
+		r.Multiply(r, u)
+		z.Multiply(z, v)
+	case y.Add(w, x).Equal(zero) == 1:
+		r.Multiply(r, feFFFB1)
+		r.Multiply(r, u)
+		z.Multiply(z, v)
+	default:
+		x.Multiply(x, feSqrtM1)
+		if y.Subtract(w, x).Equal(zero) == 1 {
+			r.Multiply(r, feFFFB4)
+		} else {
+			r.Multiply(r, feFFFB3)
+		}
+		sign = 1
+	}
+	if r.IsNegative() != sign {
+		r.Negate(r)
+	}
+
+	// (X : Y : Z) = (r(z + w) : z - w : z + w), so x = r and y = (z - w)/(z + w)
+	den := new(field.Element).Add(z, w)
+	ay := new(field.Element).Subtract(z, w)
+	return r, ay.Multiply(ay, den.Invert(den))
+}
+
+// divPowM1 returns u*v^3*(u*v^7)^((p-5)/8), which is (u/v)^((p+3)/8)
+func divPowM1(u, v *field.Element) *field.Element {
+	v3 := new(field.Element).Square(v)
+	v3.Multiply(v3, v)
+	uv7 := new(field.Element).Square(v3)
+	uv7.Multiply(uv7, v)
+	uv7.Multiply(uv7, u)
+	r := new(field.Element).Pow22523(uv7)
+	r.Multiply(r, v3)
+	return r.Multiply(r, u)
+}
@@ -0,0 +1,261 @@
+package crypto
+
+import (
+	"encoding/binary"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// H is the generator of the amounts of Pedersen commitments
+var H = mustKey("8b655970153799af2aeadc9ff1add0ea6c7251d54154cfa92c173a0dd39c1f94")
+
+var (
+	hPoint   = mustPoint(H)
+	invEight = invert(scalarFromUint64(8))
+)
+
+func mustKey(s string) Key {
+	k, err := ParseKey(s)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func mustPoint(k Key) *edwards25519.Point {
+	p, err := k.point()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// KeyDerivation returns the shared secret 8*sec*pub of a transaction
+// public key and a private view key, or of a public view key and a
+// transaction private key.
+func KeyDerivation(pub Key, sec SecretKey) (Key, error) {
+	p, err := pub.point()
+	if err != nil {
+		return Key{}, err
+	}
+	s, err := sec.scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(mul8(new(edwards25519.Point).ScalarMult(s, p))), nil
+}
+
+// DerivationToScalar returns Hs(derivation || index), the shared secret of
+// the output at index.
+func DerivationToScalar(derivation Key, index uint64) Key {
+	return scalarKey(derivationToScalar(derivation, index))
+}
+
+func derivationToScalar(derivation Key, index uint64) *edwards25519.Scalar {
+	return hashToScalar(derivation[:], varint(nil, index))
+}
+
+// DerivePublicKey returns the one-time public key Hs(derivation || index)*G
+// + base of the output at index sent to the spend key base.
+func DerivePublicKey(derivation Key, index uint64, base Key) (Key, error) {
+	b, err := base.point()
+	if err != nil {
+		return Key{}, err
+	}
+	p := new(edwards25519.Point).ScalarBaseMult(derivationToScalar(derivation, index))
+	return pointKey(p.Add(p, b)), nil
+}
+
+// DeriveSubaddressPublicKey returns the spend key key - Hs(derivation ||
+// index)*G of the subaddress receiving the output at index of one-time
+// public key key.
+func DeriveSubaddressPublicKey(key, derivation Key, index uint64) (Key, error) {
+	p, err := key.point()
+	if err != nil {
+		return Key{}, err
+	}
+	d := new(edwards25519.Point).ScalarBaseMult(derivationToScalar(derivation, index))
+	return pointKey(p.Subtract(p, d)), nil
+}
+
+// DeriveSecretKey returns the one-time secret key Hs(derivation || index)
+// + base of the output at index received with the spend secret key base.
+func DeriveSecretKey(derivation Key, index uint64, base SecretKey) (SecretKey, error) {
+	b, err := base.scalar()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	s := derivationToScalar(derivation, index)
+	return secretKey(s.Add(s, b)), nil
+}
+
+// ViewTag returns the view tag of the output at index, the first byte of
+// H("view_tag" || derivation || index).
+func ViewTag(derivation Key, index uint64) byte {
+	return Keccak256([]byte("view_tag"), derivation[:], varint(nil, index))[0]
+}
+
+// SubaddressSecretKey returns the secret key m of subaddress (major,
+// minor), Hs("SubAddr\0" || view || major || minor): its spend key is
+// B + m*G. The secret spend key of an output received to the subaddress
+// is the one derived from b + m.
+func SubaddressSecretKey(view SecretKey, major, minor uint32) SecretKey {
+	index := make([]byte, 8)
+	binary.LittleEndian.PutUint32(index, major)
+	binary.LittleEndian.PutUint32(index[4:], minor)
+	return secretKey(hashToScalar([]byte("SubAddr\x00"), view[:], index))
+}
+
+// SubaddressSpendKey returns the public spend key B + m*G of subaddress
+// (major, minor) of the address whose public spend key is spend, as
+// get_subaddress_spend_public_key of monerod. Its public view key is view
+// times this key.
+func SubaddressSpendKey(spend Key, view SecretKey, major, minor uint32) (Key, error) {
+	b, err := spend.point()
+	if err != nil {
+		return Key{}, err
+	}
+	m, err := SubaddressSecretKey(view, major, minor).scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(b.Add(b, new(edwards25519.Point).ScalarBaseMult(m))), nil
+}
+
+// AddSecretKeys returns a + b.
+func AddSecretKeys(a, b SecretKey) (SecretKey, error) {
+	x, err := a.scalar()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	y, err := b.scalar()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	return secretKey(x.Add(x, y)), nil
+}
+
+// SubSecretKeys returns a - b.
+func SubSecretKeys(a, b SecretKey) (SecretKey, error) {
+	x, err := a.scalar()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	y, err := b.scalar()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	return secretKey(x.Subtract(x, y)), nil
+}
+
+// KeyImage returns the key image sec*HashToPoint(pub) of the one-time key
+// pair (pub, sec).
+func KeyImage(pub Key, sec SecretKey) (Key, error) {
+	s, err := sec.scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(new(edwards25519.Point).ScalarMult(s, hashToPoint(pub))), nil
+}
+
+// Commit returns the Pedersen commitment mask*G + amount*H.
+func Commit(amount uint64, mask SecretKey) (Key, error) {
+	m, err := mask.scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(commit(amount, m)), nil
+}
+
+// ZeroCommit returns G + amount*H, the commitment of coinbase outputs and
+// of outputs without RingCT.
+func ZeroCommit(amount uint64) Key {
+	return pointKey(commit(amount, scalarFromUint64(1)))
+}
+
+// ScalarMult returns s*p, as the transaction public key r*D of a
+// transaction to a subaddress.
+func ScalarMult(s SecretKey, p Key) (Key, error) {
+	x, err := s.scalar()
+	if err != nil {
+		return Key{}, err
+	}
+	q, err := p.point()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(q.ScalarMult(x, q)), nil
+}
+
+// ScaleInvEight returns p/8, as range proofs store the commitments of the
+// outputs.
+func ScaleInvEight(p Key) (Key, error) {
+	q, err := p.point()
+	if err != nil {
+		return Key{}, err
+	}
+	return pointKey(q.ScalarMult(invEight, q)), nil
+}
+
+func commit(amount uint64, mask *edwards25519.Scalar) *edwards25519.Point {
+	return new(edwards25519.Point).VarTimeDoubleScalarBaseMult(scalarFromUint64(amount), hPoint, mask)
+}
+
+// CommitmentMask returns the mask of the commitment of an output from its
+// shared secret, Hs("commitment_mask" || sharedSecret).
+func CommitmentMask(sharedSecret Key) SecretKey {
+	return secretKey(hashToScalar([]byte("commitment_mask"), sharedSecret[:]))
+}
+
+// EncryptAmount encrypts the amount of an output with its shared secret,
+// as the compact ecdhInfo of RingCT transactions since v0.13. Decryption is
+// the same operation.
+func EncryptAmount(amount [8]byte, sharedSecret Key) [8]byte {
+	factor := Keccak256([]byte("amount"), sharedSecret[:])
+	for i := range amount {
+		amount[i] ^= factor[i]
+	}
+	return amount
+}
+
+// EncodeAmount encrypts an amount with the shared secret of its output.
+func EncodeAmount(amount uint64, sharedSecret Key) [8]byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], amount)
+	return EncryptAmount(b, sharedSecret)
+}
+
+// DecodeAmount decrypts the amount of an output with its shared secret.
+func DecodeAmount(encrypted [8]byte, sharedSecret Key) uint64 {
+	b := EncryptAmount(encrypted, sharedSecret)
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// CheckBalance tells whether the inputs commitments equal the outputs
+// commitments plus the fee.
+func CheckBalance(inputs, outputs []Key, fee uint64) error {
+	sum := func(keys []Key) (*edwards25519.Point, error) {
+		s := edwards25519.NewIdentityPoint()
+		for _, k := range keys {
+			p, err := k.point()
+			if err != nil {
+				return nil, err
+			}
+			s.Add(s, p)
+		}
+		return s, nil
+	}
+	in, err := sum(inputs)
+	if err != nil {
+		return err
+	}
+	out, err := sum(outputs)
+	if err != nil {
+		return err
+	}
+	out.Add(out, new(edwards25519.Point).ScalarMult(scalarFromUint64(fee), hPoint))
+	if in.Equal(out) != 1 {
+		return ErrUnbalanced
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// MLSAG is a ring signature of an input, as used by RingCT transactions
+// before v0.17. The key image of the input is stored with the input.
+type MLSAG struct {
+	// Responses, one pair per ring member: for its key and its commitment.
+	SS [][]Key `json:"ss"`
+	// Challenge of the first ring member.
+	CC Key `json:"cc"`
+}
+
+// VerifyMLSAG verifies the signature of message by the ring, for the input
+// with the given pseudo output and key image. The signature is over the
+// keys of the ring and their commitments minus the pseudo output, as the
+// simple RingCT types sign.
+func VerifyMLSAG(message Key, ring []RingMember, pseudoOut, keyImage Key, sig *MLSAG) error {
+	if len(ring) == 0 || len(sig.SS) != len(ring) {
+		return fmt.Errorf("%w: %d responses for %d ring members", ErrInvalidSignature, len(sig.SS), len(ring))
+	}
+	I, err := keyImage.point()
+	if err != nil {
+		return err
+	}
+	if isIdentity(I) || !inPrimeSubgroup(I) {
+		return fmt.Errorf("%w: bad key image", ErrInvalidSignature)
+	}
+	offset, err := pseudoOut.point()
+	if err != nil {
+		return err
+	}
+	c1, err := sig.CC.scalar()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	c := c1
+	for i, m := range ring {
+		if len(sig.SS[i]) != 2 {
+			return fmt.Errorf("%w: %d responses for ring member %d", ErrInvalidSignature, len(sig.SS[i]), i)
+		}
+		ss, err := scalarKeys(sig.SS[i])
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		P, err := m.Dest.point()
+		if err != nil {
+			return err
+		}
+		C, err := m.Mask.point()
+		if err != nil {
+			return err
+		}
+		C.Subtract(C, offset)
+		c = mlsagChallenge(message, m.Dest, pointKey(C), P, C, I, ss[0], ss[1], c)
+	}
+	if c.Equal(c1) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// mlsagChallenge returns the challenge following a ring member of key P
+// and commitment C, with responses s0 and s1 and previous challenge c:
+// Hs(message || P || s0*G + c*P || s0*Hp(P) + c*I || C || s1*G + c*C)
+func mlsagChallenge(message, dest, commitment Key, P, C, I *edwards25519.Point, s0, s1, c *edwards25519.Scalar) *edwards25519.Scalar {
+	l0 := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, P, s0)
+	r0 := multiScalarMult([]*edwards25519.Scalar{s0, c}, []*edwards25519.Point{hashToPoint(dest), I})
+	l1 := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, C, s1)
+	return hashToScalar(message[:], dest[:], l0.Bytes(), r0.Bytes(), commitment[:], l1.Bytes())
+}
@@ -0,0 +1,242 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/internal/edwards25519"
+)
+
+// txProofV2Separator is the domain separator of the v2 transaction proofs
+var txProofV2Separator = Keccak256([]byte("TXPROOF_V2"))
+
+// SignatureSize is the size of a serialized Signature
+const SignatureSize = 64
+
+// Signature is a Schnorr signature of monerod: its challenge C and its
+// response R. Ring signatures have one per ring member.
+type Signature struct {
+	C Key `json:"c"`
+	R Key `json:"r"`
+}
+
+// ParseSignature parses a serialized Signature, C followed by R.
+func ParseSignature(b []byte) (Signature, error) {
+	var sig Signature
+	if len(b) != SignatureSize {
+		return sig, fmt.Errorf("%w: %d bytes", ErrInvalidSignature, len(b))
+	}
+	copy(sig.C[:], b[:32])
+	copy(sig.R[:], b[32:])
+	return sig, nil
+}
+
+// Bytes returns the serialized signature.
+func (sig Signature) Bytes() []byte {
+	return append(append([]byte{}, sig.C[:]...), sig.R[:]...)
+}
+
+// scalars decodes the challenge and the response of the signature
+func (sig Signature) scalars() (*edwards25519.Scalar, *edwards25519.Scalar, error) {
+	c, err := sig.C.scalar()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	r, err := sig.R.scalar()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return c, r, nil
+}
+
+// Sign signs the hash of a message with the secret key of pub, as
+// generate_signature of monerod: c = Hs(hash || pub || k*G), r = k - c*sec.
+func Sign(hash Key, sec SecretKey) (Signature, error) {
+	x, err := sec.scalar()
+	if err != nil {
+		return Signature{}, err
+	}
+	pub := pointKey(new(edwards25519.Point).ScalarBaseMult(x))
+	k := randomScalar()
+	c := hashToScalar(hash[:], pub[:], new(edwards25519.Point).ScalarBaseMult(k).Bytes())
+	r := new(edwards25519.Scalar).Multiply(c, x)
+	return Signature{C: scalarKey(c), R: scalarKey(r.Subtract(k, r))}, nil
+}
+
+// VerifySignature verifies the signature of the hash of a message by the
+// public key pub, as check_signature of monerod.
+func VerifySignature(hash, pub Key, sig Signature) error {
+	P, err := pub.point()
+	if err != nil {
+		return err
+	}
+	c, r, err := sig.scalars()
+	if err != nil {
+		return err
+	}
+	if c.Equal(edwards25519.NewScalar()) == 1 {
+		return fmt.Errorf("%w: zero challenge", ErrInvalidSignature)
+	}
+	comm := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, P, r)
+	if isIdentity(comm) {
+		return ErrInvalidSignature
+	}
+	if hashToScalar(hash[:], pub[:], comm.Bytes()).Equal(c) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignRing signs the hash of a message with the ring member at index,
+// whose secret key is sec, as generate_ring_signature of monerod. The key
+// image of the member is sec*Hp(ring[index]).
+func SignRing(hash Key, ring []Key, index int, sec SecretKey) ([]Signature, Key, error) {
+	if index < 0 || index >= len(ring) {
+		return nil, Key{}, fmt.Errorf("%w: index %d out of the ring", ErrInvalidSignature, index)
+	}
+	x, err := sec.scalar()
+	if err != nil {
+		return nil, Key{}, err
+	}
+	if pointKey(new(edwards25519.Point).ScalarBaseMult(x)) != ring[index] {
+		return nil, Key{}, fmt.Errorf("%w: secret key does not match the ring member", ErrInvalidSignature)
+	}
+	I := new(edwards25519.Point).ScalarMult(x, hashToPoint(ring[index]))
+	sigs := make([]Signature, len(ring))
+	buf := [][]byte{hash[:]}
+	sum := edwards25519.NewScalar()
+	k := randomScalar()
+	for i, member := range ring {
+		if i == index {
+			buf = append(buf,
+				new(edwards25519.Point).ScalarBaseMult(k).Bytes(),
+				new(edwards25519.Point).ScalarMult(k, hashToPoint(member)).Bytes())
+			continue
+		}
+		P, err := member.point()
+		if err != nil {
+			return nil, Key{}, err
+		}
+		c, r := randomScalar(), randomScalar()
+		sigs[i] = Signature{C: scalarKey(c), R: scalarKey(r)}
+		a, b := ringPair(P, hashToPoint(member), I, c, r)
+		buf = append(buf, a.Bytes(), b.Bytes())
+		sum.Add(sum, c)
+	}
+	c := hashToScalar(buf...)
+	c.Subtract(c, sum)
+	r := new(edwards25519.Scalar).Multiply(c, x)
+	sigs[index] = Signature{C: scalarKey(c), R: scalarKey(r.Subtract(k, r))}
+	return sigs, pointKey(I), nil
+}
+
+// VerifyRing verifies the ring signature of the hash of a message by the
+// ring, for the key image, as check_ring_signature of monerod.
+func VerifyRing(hash, keyImage Key, ring []Key, sigs []Signature) error {
+	if len(ring) == 0 || len(sigs) != len(ring) {
+		return fmt.Errorf("%w: %d signatures for %d ring members", ErrInvalidSignature, len(sigs), len(ring))
+	}
+	I, err := keyImage.point()
+	if err != nil {
+		return err
+	}
+	if isIdentity(I) || !inPrimeSubgroup(I) {
+		return fmt.Errorf("%w: bad key image", ErrInvalidSignature)
+	}
+	buf := [][]byte{hash[:]}
+	sum := edwards25519.NewScalar()
+	for i, member := range ring {
+		P, err := member.point()
+		if err != nil {
+			return err
+		}
+		c, r, err := sigs[i].scalars()
+		if err != nil {
+			return err
+		}
+		a, b := ringPair(P, hashToPoint(member), I, c, r)
+		buf = append(buf, a.Bytes(), b.Bytes())
+		sum.Add(sum, c)
+	}
+	if hashToScalar(buf...).Equal(sum) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ringPair returns c*P + r*G and r*Hp(P) + c*I
+func ringPair(P, hp, I *edwards25519.Point, c, r *edwards25519.Scalar) (*edwards25519.Point, *edwards25519.Point) {
+	a := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, P, r)
+	b := multiScalarMult([]*edwards25519.Scalar{r, c}, []*edwards25519.Point{hp, I})
+	return a, b
+}
+
+// SignTxProof proves that D = r*A for the secret r of R = r*G, or of
+// R = r*B if B is not nil, signing the hash of a message as the v2
+// generate_tx_proof of monerod. Payment proofs use it with the transaction
+// key r, R the transaction public key and A the view key of the recipient,
+// or with the view key, swapping R and A.
+func SignTxProof(hash, R, A Key, B *Key, D Key, r SecretKey) (Signature, error) {
+	x, err := r.scalar()
+	if err != nil {
+		return Signature{}, err
+	}
+	Ap, err := A.point()
+	if err != nil {
+		return Signature{}, err
+	}
+	base := edwards25519.NewGeneratorPoint()
+	if B != nil {
+		if base, err = B.point(); err != nil {
+			return Signature{}, err
+		}
+	}
+	if pointKey(new(edwards25519.Point).ScalarMult(x, base)) != R || pointKey(new(edwards25519.Point).ScalarMult(x, Ap)) != D {
+		return Signature{}, fmt.Errorf("%w: secret key does not match the keys", ErrInvalidSignature)
+	}
+	k := randomScalar()
+	X := new(edwards25519.Point).ScalarMult(k, base)
+	Y := new(edwards25519.Point).ScalarMult(k, Ap)
+	c := txProofChallenge(hash, R, A, B, D, X, Y)
+	s := new(edwards25519.Scalar).Multiply(c, x)
+	return Signature{C: scalarKey(c), R: scalarKey(s.Subtract(k, s))}, nil
+}
+
+// VerifyTxProof verifies a proof of SignTxProof, as the v2 check_tx_proof
+// of monerod.
+func VerifyTxProof(hash, R, A Key, B *Key, D Key, sig Signature) error {
+	points, err := pointKeys([]Key{R, A, D})
+	if err != nil {
+		return err
+	}
+	Rp, Ap, Dp := points[0], points[1], points[2]
+	c, r, err := sig.scalars()
+	if err != nil {
+		return err
+	}
+	// X = c*R + r*G, or c*R + r*B; Y = c*D + r*A
+	var X *edwards25519.Point
+	if B == nil {
+		X = new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, Rp, r)
+	} else {
+		Bp, err := B.point()
+		if err != nil {
+			return err
+		}
+		X = multiScalarMult([]*edwards25519.Scalar{c, r}, []*edwards25519.Point{Rp, Bp})
+	}
+	Y := multiScalarMult([]*edwards25519.Scalar{c, r}, []*edwards25519.Point{Dp, Ap})
+	if txProofChallenge(hash, R, A, B, D, X, Y).Equal(c) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// txProofChallenge returns Hs(hash || D || X || Y || separator || R || A ||
+// B), B being zero if nil
+func txProofChallenge(hash, R, A Key, B *Key, D Key, X, Y *edwards25519.Point) *edwards25519.Scalar {
+	var b Key
+	if B != nil {
+		b = *B
+	}
+	return hashToScalar(hash[:], D[:], X.Bytes(), Y.Bytes(), txProofV2Separator[:], R[:], A[:], b[:])
+}
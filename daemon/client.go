@@ -9,6 +9,7 @@ import (
 
 	"github.com/gorilla/rpc/v2/json2"
 	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/epee"
 )
 
 // New returns a new monerod daemon RPC client.
@@ -116,3 +117,37 @@ func (c *client) doSlash(method string, in, out interface{}) error {
 	}
 	return json.NewDecoder(resp.Body).Decode(&out)
 }
+
+// Helper function for Binary RPC Methods
+// Requests and responses are encoded in epee portable storage format.
+func (c *client) doBin(method string, in, out interface{}) error {
+	payload, err := epee.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.addr+method, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if c.headers != nil {
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+	}
+	resp, err := c.httpcl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status %v", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return epee.Unmarshal(body, out)
+}
@@ -51,6 +51,20 @@ type Client interface {
 	GetTxpoolBacklog(*GetTxpoolBacklogRequest) (*GetTxpoolBacklogResponse, error)
 	// None
 	GetOutputDistribution(*GetOutputDistributionRequest) (*GetOutputDistributionResponse, error)
+	// Get the data needed to build a block template for the next block.
+	GetMinerData(*GetMinerDataRequest) (*GetMinerDataResponse, error)
+	// Calculate the proof-of-work hash of a block hashing blob.
+	CalcPow(*CalcPowRequest) (*CalcPowResponse, error)
+	// Add merge mining tags for auxiliary chains to a block template.
+	AddAuxPow(*AddAuxPowRequest) (*AddAuxPowResponse, error)
+	// Prune the blockchain, or check whether it is pruned.
+	PruneBlockchain(*PruneBlockchainRequest) (*PruneBlockchainResponse, error)
+	// Flush the bad transactions and bad blocks caches.
+	FlushCache(*FlushCacheRequest) (*FlushCacheResponse, error)
+	// Check if a host is banned.
+	Banned(*BannedRequest) (*BannedResponse, error)
+	// Look up transaction IDs matching the leading bits of a template, for private lookups.
+	GetTxidsLoose(*GetTxidsLooseRequest) (*GetTxidsLooseResponse, error)
 
 	// Other RPC Methods
 	// Get the node's current height.
@@ -99,6 +113,24 @@ type Client interface {
 	GetOuts(*GetOutsRequest) (*GetOutsResponse, error)
 	// Update daemon.
 	Update(*UpdateRequest) (*UpdateResponse, error)
+	// Get hashes from transaction pool.
+	GetTransactionPoolHashes(*GetTransactionPoolHashesRequest) (*GetTransactionPoolHashesResponse, error)
+	// Get the known peers advertising a public RPC port.
+	GetPublicNodes(*GetPublicNodesRequest) (*GetPublicNodesResponse, error)
+	// Get the daemon network traffic statistics.
+	GetNetStats(*GetNetStatsRequest) (*GetNetStatsResponse, error)
+	// Remove blocks from the top of the blockchain.
+	PopBlocks(*PopBlocksRequest) (*PopBlocksResponse, error)
+	// Set the bootstrap daemon used while the node is syncing.
+	SetBootstrapDaemon(*SetBootstrapDaemonRequest) (*SetBootstrapDaemonResponse, error)
+
+	// Binary RPC Methods
+	// Get the output distribution in binary format.
+	GetOutputDistributionBin(*GetOutputDistributionBinRequest) (*GetOutputDistributionResponse, error)
+	// Get blocks and their transactions by height.
+	GetBlocksByHeightBin(*GetBlocksByHeightBinRequest) (*GetBlocksByHeightBinResponse, error)
+
+	// Regtest RPC Methods
 	// Generate blocks in Regtest mode
 	GenerateBlocks(*GenerateBlocksRequest) (*GenerateBlocksResponse, error)
 }
@@ -1,5 +1,11 @@
 package daemon
 
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
 //#####################
 // General RPC Methods
 //#####################
@@ -224,6 +230,69 @@ func (c *client) GetOutputDistribution(req *GetOutputDistributionRequest) (resp
 	return
 }
 
+// GetMinerData Get the data needed to build a block template for the next block.
+func (c *client) GetMinerData(req *GetMinerDataRequest) (resp *GetMinerDataResponse, err error) {
+	err = c.do("get_miner_data", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// CalcPow Calculate the proof-of-work hash of a block hashing blob.
+func (c *client) CalcPow(req *CalcPowRequest) (resp *CalcPowResponse, err error) {
+	err = c.do("calc_pow", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// AddAuxPow Add merge mining tags for auxiliary chains to a block template.
+func (c *client) AddAuxPow(req *AddAuxPowRequest) (resp *AddAuxPowResponse, err error) {
+	err = c.do("add_aux_pow", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// PruneBlockchain Prune the blockchain, or check whether it is pruned.
+func (c *client) PruneBlockchain(req *PruneBlockchainRequest) (resp *PruneBlockchainResponse, err error) {
+	err = c.do("prune_blockchain", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// FlushCache Flush the bad transactions and bad blocks caches.
+func (c *client) FlushCache(req *FlushCacheRequest) (resp *FlushCacheResponse, err error) {
+	err = c.do("flush_cache", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Banned Check if a host is banned.
+func (c *client) Banned(req *BannedRequest) (resp *BannedResponse, err error) {
+	err = c.do("banned", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetTxidsLoose Look up transaction IDs matching the leading bits of a template, for private lookups.
+func (c *client) GetTxidsLoose(req *GetTxidsLooseRequest) (resp *GetTxidsLooseResponse, err error) {
+	err = c.do("get_txids_loose", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
 //#####################
 // Other RPC Methods
 //#####################
@@ -356,10 +425,23 @@ func (c *client) GetTransactionPool(req *GetTransactionPoolRequest) (resp *GetTr
 
 // GetTransactionPoolHashesBin Get hashes from transaction pool. Binary request.
 func (c *client) GetTransactionPoolHashesBin(req *GetTransactionPoolHashesBinRequest) (resp *GetTransactionPoolHashesBinResponse, err error) {
-	err = c.doSlash("/get_transaction_pool_hashes.bin", &req, &resp)
+	var out transactionPoolHashesBinResponse
+	err = c.doBin("/get_transaction_pool_hashes.bin", req, &out)
 	if err != nil {
 		return nil, err
 	}
+	if len(out.TxHashes)%32 != 0 {
+		return nil, fmt.Errorf("invalid tx hashes size %d", len(out.TxHashes))
+	}
+
+	resp = &GetTransactionPoolHashesBinResponse{
+		Status:    out.Status,
+		TxHashes:  make([]string, len(out.TxHashes)/32),
+		Untrusted: out.Untrusted,
+	}
+	for i := range resp.TxHashes {
+		resp.TxHashes[i] = hex.EncodeToString(out.TxHashes[i*32 : (i+1)*32])
+	}
 	return
 }
 
@@ -435,6 +517,126 @@ func (c *client) Update(req *UpdateRequest) (resp *UpdateResponse, err error) {
 	return
 }
 
+// GetTransactionPoolHashes Get hashes from transaction pool.
+func (c *client) GetTransactionPoolHashes(req *GetTransactionPoolHashesRequest) (resp *GetTransactionPoolHashesResponse, err error) {
+	err = c.doSlash("/get_transaction_pool_hashes", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetPublicNodes Get the known peers advertising a public RPC port.
+func (c *client) GetPublicNodes(req *GetPublicNodesRequest) (resp *GetPublicNodesResponse, err error) {
+	err = c.doSlash("/get_public_nodes", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetNetStats Get the daemon network traffic statistics.
+func (c *client) GetNetStats(req *GetNetStatsRequest) (resp *GetNetStatsResponse, err error) {
+	err = c.doSlash("/get_net_stats", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// PopBlocks Remove blocks from the top of the blockchain.
+func (c *client) PopBlocks(req *PopBlocksRequest) (resp *PopBlocksResponse, err error) {
+	err = c.doSlash("/pop_blocks", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetBootstrapDaemon Set the bootstrap daemon used while the node is syncing.
+func (c *client) SetBootstrapDaemon(req *SetBootstrapDaemonRequest) (resp *SetBootstrapDaemonResponse, err error) {
+	err = c.doSlash("/set_bootstrap_daemon", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+//#####################
+// Binary RPC Methods
+//#####################
+
+// GetOutputDistributionBin Get the output distribution in binary format. Uncompressed distributions are decoded from raw blobs and compressed ones from varints.
+func (c *client) GetOutputDistributionBin(req *GetOutputDistributionBinRequest) (resp *GetOutputDistributionResponse, err error) {
+	in := &outputDistributionBinRequest{Binary: true}
+	if req != nil {
+		in.GetOutputDistributionBinRequest = *req
+	}
+	var out outputDistributionBinResponse
+	err = c.doBin("/get_output_distribution.bin", in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &GetOutputDistributionResponse{
+		Distributions: make([]OuputDistribution, len(out.Distributions)),
+		Status:        out.Status,
+	}
+	for i, d := range out.Distributions {
+		dist := OuputDistribution{
+			Amount:      d.Amount,
+			Base:        d.Base,
+			StartHeight: d.StartHeight,
+		}
+		if d.Compress {
+			dist.Distribution, err = decompressIntegers(d.CompressedData)
+		} else {
+			dist.Distribution, err = decodeIntegers(d.Distribution)
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp.Distributions[i] = dist
+	}
+	return
+}
+
+// GetBlocksByHeightBin Get blocks and their transactions by height. Binary request.
+func (c *client) GetBlocksByHeightBin(req *GetBlocksByHeightBinRequest) (resp *GetBlocksByHeightBinResponse, err error) {
+	resp = &GetBlocksByHeightBinResponse{}
+	err = c.doBin("/get_blocks_by_height.bin", req, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// decodeIntegers decodes a blob of little endian uint64
+func decodeIntegers(blob []byte) ([]uint64, error) {
+	if len(blob)%8 != 0 {
+		return nil, fmt.Errorf("invalid distribution size %d", len(blob))
+	}
+	ints := make([]uint64, len(blob)/8)
+	for i := range ints {
+		ints[i] = binary.LittleEndian.Uint64(blob[i*8:])
+	}
+	return ints, nil
+}
+
+// decompressIntegers decodes a blob of varints
+func decompressIntegers(blob []byte) ([]uint64, error) {
+	var ints []uint64
+	for len(blob) > 0 {
+		v, n := binary.Uvarint(blob)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid compressed distribution")
+		}
+		ints = append(ints, v)
+		blob = blob[n:]
+	}
+	return ints, nil
+}
+
 //#####################
 // Regtest RPC Methods
 //#####################
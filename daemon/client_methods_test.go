@@ -1,12 +1,20 @@
 package daemon
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/epee"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,6 +62,13 @@ func TestClientMethods(t *testing.T) {
 		{method: "SyncInfo", request: &SyncInfoRequest{}},
 		{method: "GetTxpoolBacklog", request: &GetTxpoolBacklogRequest{}},
 		{method: "GetOutputDistribution", request: &GetOutputDistributionRequest{}},
+		{method: "GetMinerData", request: &GetMinerDataRequest{}},
+		{method: "CalcPow", request: &CalcPowRequest{}},
+		{method: "AddAuxPow", request: &AddAuxPowRequest{}},
+		//{method: "PruneBlockchain", request: &PruneBlockchainRequest{}},
+		{method: "FlushCache", request: &FlushCacheRequest{}},
+		{method: "Banned", request: &BannedRequest{}},
+		{method: "GetTxidsLoose", request: &GetTxidsLooseRequest{}},
 		{method: "GetHeight", request: &GetHeightRequest{}},
 		{method: "GetTransactions", request: &GetTransactionsRequest{}},
 		{method: "GetAltBlocksHashes", request: &GetAltBlocksHashesRequest{}},
@@ -77,6 +92,13 @@ func TestClientMethods(t *testing.T) {
 		{method: "InPeers", request: &InPeersRequest{}},
 		{method: "GetOuts", request: &GetOutsRequest{}},
 		{method: "Update", request: &UpdateRequest{}},
+		{method: "GetTransactionPoolHashes", request: &GetTransactionPoolHashesRequest{}},
+		{method: "GetPublicNodes", request: &GetPublicNodesRequest{}},
+		{method: "GetNetStats", request: &GetNetStatsRequest{}},
+		//{method: "PopBlocks", request: &PopBlocksRequest{}},
+		//{method: "SetBootstrapDaemon", request: &SetBootstrapDaemonRequest{}},
+		{method: "GetOutputDistributionBin", request: &GetOutputDistributionBinRequest{}},
+		{method: "GetBlocksByHeightBin", request: &GetBlocksByHeightBinRequest{Heights: []uint64{1}}},
 		{method: "GenerateBlocks", request: &GenerateBlocksRequest{}},
 	}
 
@@ -99,3 +121,304 @@ func TestClientMethods(t *testing.T) {
 		assert.EqualError(t, err, "-13: Regtest required when generating blocks")
 	})
 }
+
+// standIn is a stand-in monerod answering with canned results
+// and recording the params of the last request to each method
+type standIn struct {
+	results  map[string]string
+	requests map[string]string
+}
+
+func newStandIn(results map[string]string) (*standIn, Client, func()) {
+	s := &standIn{results: results, requests: make(map[string]string)}
+	srv := httptest.NewServer(s)
+	u, _ := url.Parse(srv.URL)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.ParseUint(portStr, 10, 0)
+	cfg, _ := gonero.NewRPCConfig("http", host, uint(port), "", "", "")
+	return s, New(cfg), srv.Close
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.URL.Path != "/json_rpc" {
+		s.requests[r.URL.Path] = string(body)
+		w.Write([]byte(s.results[r.URL.Path]))
+		return
+	}
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     uint64          `json:"id"`
+	}
+	json.Unmarshal(body, &req)
+	s.requests[req.Method] = string(req.Params)
+	result, ok := s.results[req.Method]
+	if !ok {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"Method not found"}}`, req.ID)
+		return
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+}
+
+func TestClientMethodsStandIn(t *testing.T) {
+	type test struct {
+		method   string
+		key      string
+		request  interface{}
+		params   string
+		result   string
+		expected interface{}
+	}
+
+	tests := []test{
+		{
+			method:  "GetMinerData",
+			key:     "get_miner_data",
+			request: &GetMinerDataRequest{},
+			params:  `{}`,
+			result:  `{"major_version":16,"height":2731375,"prev_id":"ab","seed_hash":"cd","difficulty":"0x2b4e24e0a9a","median_weight":300000,"already_generated_coins":18446744073709551615,"tx_backlog":[{"id":"ef","weight":1535,"fee":30700000}],"status":"OK","untrusted":false}`,
+			expected: &GetMinerDataResponse{
+				MajorVersion: 16, Height: 2731375, PrevID: "ab", SeedHash: "cd", Difficulty: "0x2b4e24e0a9a",
+				MedianWeight: 300000, AlreadyGeneratedCoins: 18446744073709551615,
+				TxBacklog: []MinerTxBacklogEntry{{ID: "ef", Weight: 1535, Fee: 30700000}}, Status: RPCStatusOk,
+			},
+		},
+		{
+			method:  "CalcPow",
+			key:     "calc_pow",
+			request: &CalcPowRequest{MajorVersion: 16, Height: 2286447, BlockBlob: "10", SeedHash: "d4"},
+			params:  `{"major_version":16,"height":2286447,"block_blob":"10","seed_hash":"d4"}`,
+			result:  `"d0402d6834e26fb94a9ce38c6424d27d2069896a9b8b1ce685d79936bca6e0a8"`,
+			expected: func() *CalcPowResponse {
+				r := CalcPowResponse("d0402d6834e26fb94a9ce38c6424d27d2069896a9b8b1ce685d79936bca6e0a8")
+				return &r
+			}(),
+		},
+		{
+			method:  "AddAuxPow",
+			key:     "add_aux_pow",
+			request: &AddAuxPowRequest{BlocktemplateBlob: "10", AuxPow: []AuxPow{{ID: "01", Hash: "02"}}},
+			params:  `{"blocktemplate_blob":"10","aux_pow":[{"id":"01","hash":"02"}]}`,
+			result:  `{"blocktemplate_blob":"11","blockhashing_blob":"12","merkle_root":"02","merkle_tree_depth":0,"aux_pow":[{"id":"01","hash":"02"}],"status":"OK"}`,
+			expected: &AddAuxPowResponse{
+				BlocktemplateBlob: "11", BlockhashingBlob: "12", MerkleRoot: "02",
+				AuxPow: []AuxPow{{ID: "01", Hash: "02"}}, Status: RPCStatusOk,
+			},
+		},
+		{
+			method:   "PruneBlockchain",
+			key:      "prune_blockchain",
+			request:  &PruneBlockchainRequest{Check: true},
+			params:   `{"check":true}`,
+			result:   `{"pruned":true,"pruning_seed":387,"status":"OK"}`,
+			expected: &PruneBlockchainResponse{Pruned: true, PruningSeed: 387, Status: RPCStatusOk},
+		},
+		{
+			method:   "FlushCache",
+			key:      "flush_cache",
+			request:  &FlushCacheRequest{BadTxs: true},
+			params:   `{"bad_txs":true}`,
+			result:   `{"status":"OK"}`,
+			expected: &FlushCacheResponse{Status: RPCStatusOk},
+		},
+		{
+			method:   "Banned",
+			key:      "banned",
+			request:  &BannedRequest{Address: "95.216.203.255"},
+			params:   `{"address":"95.216.203.255"}`,
+			result:   `{"banned":true,"seconds":3600,"status":"OK"}`,
+			expected: &BannedResponse{Banned: true, Seconds: 3600, Status: RPCStatusOk},
+		},
+		{
+			method:   "GetTxidsLoose",
+			key:      "get_txids_loose",
+			request:  &GetTxidsLooseRequest{TxidTemplate: "ab00", NumMatchingBits: 8},
+			params:   `{"txid_template":"ab00","num_matching_bits":8}`,
+			result:   `{"txids":["ab01","ab02"],"status":"OK"}`,
+			expected: &GetTxidsLooseResponse{Txids: []string{"ab01", "ab02"}, Status: RPCStatusOk},
+		},
+		{
+			method:   "GetTransactionPoolHashes",
+			key:      "/get_transaction_pool_hashes",
+			request:  &GetTransactionPoolHashesRequest{},
+			params:   `{}`,
+			result:   `{"tx_hashes":["aa","bb"],"status":"OK","untrusted":false}`,
+			expected: &GetTransactionPoolHashesResponse{TxHashes: []string{"aa", "bb"}, Status: RPCStatusOk},
+		},
+		{
+			method:  "GetPublicNodes",
+			key:     "/get_public_nodes",
+			request: &GetPublicNodesRequest{White: true},
+			params:  `{"gray":false,"white":true}`,
+			result:  `{"white":[{"host":"1.2.3.4","last_seen":1609459200,"rpc_port":18089,"rpc_credits_per_hash":0}],"status":"OK"}`,
+			expected: &GetPublicNodesResponse{
+				White:  []PublicNode{{Host: "1.2.3.4", LastSeen: 1609459200, RPCPort: 18089}},
+				Status: RPCStatusOk,
+			},
+		},
+		{
+			method:   "GetNetStats",
+			key:      "/get_net_stats",
+			request:  &GetNetStatsRequest{},
+			params:   `{}`,
+			result:   `{"start_time":1609459200,"total_packets_in":1,"total_bytes_in":2,"total_packets_out":3,"total_bytes_out":4,"status":"OK"}`,
+			expected: &GetNetStatsResponse{StartTime: 1609459200, TotalPacketsIn: 1, TotalBytesIn: 2, TotalPacketsOut: 3, TotalBytesOut: 4, Status: RPCStatusOk},
+		},
+		{
+			method:   "PopBlocks",
+			key:      "/pop_blocks",
+			request:  &PopBlocksRequest{NBlocks: 6},
+			params:   `{"nblocks":6}`,
+			result:   `{"height":76482,"status":"OK"}`,
+			expected: &PopBlocksResponse{Height: 76482, Status: RPCStatusOk},
+		},
+		{
+			method:   "SetBootstrapDaemon",
+			key:      "/set_bootstrap_daemon",
+			request:  &SetBootstrapDaemonRequest{Address: "node.example.com:18089", Username: "monero", Password: gonero.NewSecret("secret")},
+			params:   `{"address":"node.example.com:18089","username":"monero","password":"secret"}`,
+			result:   `{"status":"OK"}`,
+			expected: &SetBootstrapDaemonResponse{Status: RPCStatusOk},
+		},
+		{
+			method:  "MiningStatus",
+			key:     "/mining_status",
+			request: &MiningStatusRequest{},
+			params:  `{}`,
+			result:  `{"active":true,"pow_algorithm":"RandomX","block_target":120,"block_reward":600000000000,"difficulty":292022797663,"wide_difficulty":"0x43fdea455f","difficulty_top64":0,"bg_target":40,"status":"OK"}`,
+			expected: &MiningStatusResponse{
+				Active: true, PowAlgorithm: "RandomX", BlockTarget: 120, BlockReward: 600000000000,
+				Difficulty: 292022797663, WideDifficulty: "0x43fdea455f", BgTarget: 40, Status: RPCStatusOk,
+			},
+		},
+	}
+
+	results := make(map[string]string)
+	for _, tc := range tests {
+		results[tc.key] = tc.result
+	}
+	s, cl, done := newStandIn(results)
+	defer done()
+
+	clType := reflect.ValueOf(cl)
+	for _, tc := range tests {
+		t.Run(tc.method, func(t *testing.T) {
+			vals := clType.MethodByName(tc.method).Call([]reflect.Value{reflect.ValueOf(tc.request)})
+			assert.Nil(t, vals[1].Interface())
+			assert.Equal(t, tc.expected, vals[0].Interface())
+			assert.JSONEq(t, tc.params, s.requests[tc.key])
+		})
+	}
+
+	t.Run("returns RPC errors", func(t *testing.T) {
+		delete(results, "banned")
+		_, err := cl.Banned(&BannedRequest{})
+		assert.EqualError(t, err, "-32601: Method not found")
+	})
+}
+
+func TestGetOutputDistributionBin(t *testing.T) {
+	var wire outputDistributionBinResponse
+	wire.Status = RPCStatusOk
+	wire.Distributions = make([]struct {
+		Amount         gonero.AtomicXMR `json:"amount"`
+		StartHeight    uint64           `json:"start_height"`
+		Base           uint64           `json:"base"`
+		Binary         bool             `json:"binary"`
+		Compress       bool             `json:"compress"`
+		Distribution   []byte           `json:"distribution"`
+		CompressedData []byte           `json:"compressed_data"`
+	}, 2)
+	wire.Distributions[0].StartHeight = 100
+	wire.Distributions[0].Binary = true
+	wire.Distributions[0].Distribution = []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	wire.Distributions[1].Amount = 1000
+	wire.Distributions[1].Binary = true
+	wire.Distributions[1].Compress = true
+	wire.Distributions[1].CompressedData = []byte{0x05, 0xac, 0x02}
+	payload, err := epee.Marshal(&wire)
+	assert.NoError(t, err)
+
+	s, cl, done := newStandIn(map[string]string{"/get_output_distribution.bin": string(payload)})
+	defer done()
+
+	resp, err := cl.GetOutputDistributionBin(&GetOutputDistributionBinRequest{Amounts: []gonero.AtomicXMR{0, 1000}, Cumulative: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &GetOutputDistributionResponse{
+		Distributions: []OuputDistribution{
+			{Amount: 0, StartHeight: 100, Distribution: []uint64{1, 256}},
+			{Amount: 1000, Distribution: []uint64{5, 300}},
+		},
+		Status: RPCStatusOk,
+	}, resp)
+
+	var req outputDistributionBinRequest
+	assert.NoError(t, epee.Unmarshal([]byte(s.requests["/get_output_distribution.bin"]), &req))
+	assert.True(t, req.Binary)
+	assert.True(t, req.Cumulative)
+	assert.Equal(t, []gonero.AtomicXMR{0, 1000}, req.Amounts)
+
+	wire.Distributions[0].Distribution = []byte{1, 2, 3}
+	payload, _ = epee.Marshal(&wire)
+	s.results["/get_output_distribution.bin"] = string(payload)
+	_, err = cl.GetOutputDistributionBin(&GetOutputDistributionBinRequest{})
+	assert.Error(t, err)
+
+	s.results["/get_output_distribution.bin"] = `{"status":"OK"}`
+	_, err = cl.GetOutputDistributionBin(nil)
+	assert.Equal(t, epee.ErrSignature, err)
+}
+
+func TestGetBlocksByHeightBin(t *testing.T) {
+	payload, err := epee.Marshal(&GetBlocksByHeightBinResponse{
+		Blocks: []BlockCompleteEntry{{Block: []byte{0x10, 0x10}, Txs: [][]byte{{2}, {3}}}},
+		Status: RPCStatusOk,
+	})
+	assert.NoError(t, err)
+
+	s, cl, done := newStandIn(map[string]string{"/get_blocks_by_height.bin": string(payload)})
+	defer done()
+
+	resp, err := cl.GetBlocksByHeightBin(&GetBlocksByHeightBinRequest{Heights: []uint64{2731375}})
+	assert.NoError(t, err)
+	assert.Equal(t, &GetBlocksByHeightBinResponse{
+		Blocks: []BlockCompleteEntry{{Block: []byte{0x10, 0x10}, Txs: [][]byte{{2}, {3}}}},
+		Status: RPCStatusOk,
+	}, resp)
+
+	var req GetBlocksByHeightBinRequest
+	assert.NoError(t, epee.Unmarshal([]byte(s.requests["/get_blocks_by_height.bin"]), &req))
+	assert.Equal(t, []uint64{2731375}, req.Heights)
+}
+
+func TestGetTransactionPoolHashesBin(t *testing.T) {
+	blob := make([]byte, 64)
+	blob[0], blob[63] = 0xab, 0xcd
+	payload, err := epee.Marshal(&transactionPoolHashesBinResponse{Status: RPCStatusOk, TxHashes: blob})
+	assert.NoError(t, err)
+
+	s, cl, done := newStandIn(map[string]string{"/get_transaction_pool_hashes.bin": string(payload)})
+	defer done()
+
+	resp, err := cl.GetTransactionPoolHashesBin(&GetTransactionPoolHashesBinRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &GetTransactionPoolHashesBinResponse{
+		Status: RPCStatusOk,
+		TxHashes: []string{
+			"ab00000000000000000000000000000000000000000000000000000000000000",
+			"00000000000000000000000000000000000000000000000000000000000000cd",
+		},
+	}, resp)
+
+	payload, _ = epee.Marshal(&transactionPoolHashesBinResponse{Status: RPCStatusOk})
+	s.results["/get_transaction_pool_hashes.bin"] = string(payload)
+	resp, err = cl.GetTransactionPoolHashesBin(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.TxHashes)
+
+	payload, _ = epee.Marshal(&transactionPoolHashesBinResponse{TxHashes: blob[:33]})
+	s.results["/get_transaction_pool_hashes.bin"] = string(payload)
+	_, err = cl.GetTransactionPoolHashesBin(nil)
+	assert.EqualError(t, err, "invalid tx hashes size 33")
+}
@@ -0,0 +1,326 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Follower default values
+const (
+	DefaultFollowerPollInterval        = 10 * time.Second
+	DefaultFollowerBatchSize    uint64 = 100
+)
+
+// ErrFollowerClosed is returned when using a closed Follower
+var ErrFollowerClosed = errors.New("daemon: follower closed")
+
+// FollowerCursor is the last block processed by a Follower.
+// Persist the cursor of each handled event to resume after a restart.
+type FollowerCursor struct {
+	// Height of the block.
+	Height uint64 `json:"height"`
+	// Hash of the block, empty to trust the main chain block at Height.
+	Hash string `json:"hash"`
+}
+
+// FollowerEventType tells whether a block was added to or removed from the main chain
+type FollowerEventType int
+
+const (
+	// BlockConnected is emitted when a block is added to the main chain
+	BlockConnected FollowerEventType = iota + 1
+	// BlockDisconnected is emitted when a block is removed from the main chain by a reorg
+	BlockDisconnected
+)
+
+func (t FollowerEventType) String() string {
+	switch t {
+	case BlockConnected:
+		return "BlockConnected"
+	case BlockDisconnected:
+		return "BlockDisconnected"
+	}
+	return fmt.Sprintf("FollowerEventType(%d)", int(t))
+}
+
+// FollowerEvent is a change of the main chain
+type FollowerEvent struct {
+	// BlockConnected or BlockDisconnected
+	Type FollowerEventType
+	// Header of the connected or disconnected block
+	Header BlockHeader
+	// Block and transaction blobs of connected blocks, when FetchBlocks is set
+	Block *BlockCompleteEntry
+	// Cursor once the event is handled: the connected block,
+	// or the parent of the disconnected block
+	Cursor FollowerCursor
+}
+
+// FollowerConfig configures a Follower
+type FollowerConfig struct {
+	// Block to resume from. Defaults to the current top block,
+	// in which case no event is emitted until the next block.
+	Cursor *FollowerCursor
+	// Interval between polls of the top block.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll when it receives a value, e.g. from
+	// a goroutine forwarding zmqsub.Subscriber.ChainMain notifications.
+	// PollInterval still applies, so missed notifications only delay events.
+	Notify <-chan struct{}
+	// Number of block headers fetched per request while catching up.
+	BatchSize uint64
+	// Fetch the block and transaction blobs of connected blocks
+	// with GetBlocksByHeightBin.
+	FetchBlocks bool
+	// Buffer size of the Events channel.
+	BufferSize int
+}
+
+// Follower follows the main chain of a daemon, emitting BlockConnected and
+// BlockDisconnected events in order. Reorgs are detected through the PrevHash
+// linkage of the headers: blocks of the old chain are disconnected down to
+// the fork point before the blocks of the new chain are connected.
+type Follower struct {
+	// Events of the main chain, blocks until they are received.
+	Events <-chan FollowerEvent
+	// Errors receives RPC errors, after which the follower retries
+	// at the next poll. Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg    FollowerConfig
+	client Client
+	events chan FollowerEvent
+	errors chan error
+
+	mu      sync.Mutex
+	cursor  FollowerCursor
+	started bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewFollower starts following the main chain of the daemon behind client.
+func NewFollower(client Client, cfg *FollowerConfig) *Follower {
+	c := FollowerConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultFollowerPollInterval
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultFollowerBatchSize
+	}
+
+	f := &Follower{
+		cfg:    c,
+		client: client,
+		events: make(chan FollowerEvent, c.BufferSize),
+		errors: make(chan error, 16),
+		done:   make(chan struct{}),
+	}
+	if c.Cursor != nil {
+		f.cursor = *c.Cursor
+		f.started = c.Cursor.Hash != ""
+	}
+	f.Events = f.events
+	f.Errors = f.errors
+
+	f.wg.Add(1)
+	go f.run()
+	return f
+}
+
+// Cursor returns the cursor of the last emitted event.
+func (f *Follower) Cursor() FollowerCursor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cursor
+}
+
+// Close stops the follower and closes its channels.
+func (f *Follower) Close() error {
+	err := ErrFollowerClosed
+	f.closeOnce.Do(func() {
+		err = nil
+		close(f.done)
+	})
+	f.wg.Wait()
+	return err
+}
+
+func (f *Follower) run() {
+	defer f.wg.Done()
+	defer close(f.errors)
+	defer close(f.events)
+
+	notify := f.cfg.Notify
+	ticker := time.NewTicker(f.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := f.sync(); err != nil {
+			if err == ErrFollowerClosed {
+				return
+			}
+			select {
+			case f.errors <- err:
+			default:
+			}
+		}
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+			}
+		}
+	}
+}
+
+// sync emits the events bringing the cursor to the top block
+func (f *Follower) sync() error {
+	for {
+		last, err := f.client.GetLastBlockHeader(&GetLastBlockHeaderRequest{})
+		if err != nil {
+			return err
+		}
+		top := last.BlockHeader
+
+		if !f.started {
+			if f.cfg.Cursor == nil {
+				f.setCursor(FollowerCursor{Height: top.Height, Hash: top.Hash})
+			} else {
+				hdr, err := f.client.GetBlockHeaderByHeight(&GetBlockHeaderByHeightRequest{Height: f.cursor.Height})
+				if err != nil {
+					return err
+				}
+				f.setCursor(FollowerCursor{Height: hdr.BlockHeader.Height, Hash: hdr.BlockHeader.Hash})
+			}
+			f.started = true
+		}
+
+		cursor := f.Cursor()
+		if top.Hash == cursor.Hash {
+			return nil
+		}
+
+		// the top block often is the only new one
+		if top.Height == cursor.Height+1 && top.PrevHash == cursor.Hash {
+			return f.connect([]BlockHeader{top})
+		}
+
+		onMain := false
+		if cursor.Height <= top.Height {
+			hdr, err := f.client.GetBlockHeaderByHeight(&GetBlockHeaderByHeightRequest{Height: cursor.Height})
+			if err != nil {
+				return err
+			}
+			onMain = hdr.BlockHeader.Hash == cursor.Hash
+		}
+		if !onMain {
+			if err := f.disconnect(cursor); err != nil {
+				return err
+			}
+			continue
+		}
+
+		end := cursor.Height + f.cfg.BatchSize
+		if end > top.Height {
+			end = top.Height
+		}
+		headers, err := f.client.GetBlockHeadersRange(&GetBlockHeadersRangeRequest{StartHeight: cursor.Height + 1, EndHeight: end})
+		if err != nil {
+			return err
+		}
+		if err := f.connect(headers.Headers); err != nil {
+			return err
+		}
+	}
+}
+
+// connect emits BlockConnected events for headers linked to the cursor,
+// stopping at the first unlinked header, which the next loop of sync
+// treats as a reorg
+func (f *Follower) connect(headers []BlockHeader) error {
+	cursor := f.Cursor()
+	for i, hdr := range headers {
+		if hdr.PrevHash != cursor.Hash || hdr.Height != cursor.Height+1 {
+			headers = headers[:i]
+			break
+		}
+		cursor = FollowerCursor{Height: hdr.Height, Hash: hdr.Hash}
+	}
+	if len(headers) == 0 {
+		return fmt.Errorf("daemon: block %d does not link to %s", cursor.Height+1, cursor.Hash)
+	}
+
+	var blocks []BlockCompleteEntry
+	if f.cfg.FetchBlocks {
+		heights := make([]uint64, len(headers))
+		for i, hdr := range headers {
+			heights[i] = hdr.Height
+		}
+		resp, err := f.client.GetBlocksByHeightBin(&GetBlocksByHeightBinRequest{Heights: heights})
+		if err != nil {
+			return err
+		}
+		if len(resp.Blocks) != len(headers) {
+			return fmt.Errorf("daemon: requested %d blocks, got %d", len(headers), len(resp.Blocks))
+		}
+		blocks = resp.Blocks
+	}
+
+	for i, hdr := range headers {
+		ev := FollowerEvent{
+			Type:   BlockConnected,
+			Header: hdr,
+			Cursor: FollowerCursor{Height: hdr.Height, Hash: hdr.Hash},
+		}
+		if blocks != nil {
+			ev.Block = &blocks[i]
+		}
+		if err := f.emit(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// disconnect emits a BlockDisconnected event for the block at the cursor
+func (f *Follower) disconnect(cursor FollowerCursor) error {
+	resp, err := f.client.GetBlockHeaderByHash(&GetBlockHeaderByHashRequest{Hash: cursor.Hash})
+	if err != nil {
+		return fmt.Errorf("daemon: header of disconnected block %s: %w", cursor.Hash, err)
+	}
+	hdr := resp.BlockHeader
+	if hdr.Height == 0 {
+		return fmt.Errorf("daemon: cannot disconnect the genesis block %s", hdr.Hash)
+	}
+	return f.emit(FollowerEvent{
+		Type:   BlockDisconnected,
+		Header: hdr,
+		Cursor: FollowerCursor{Height: hdr.Height - 1, Hash: hdr.PrevHash},
+	})
+}
+
+func (f *Follower) emit(ev FollowerEvent) error {
+	select {
+	case f.events <- ev:
+		f.setCursor(ev.Cursor)
+		return nil
+	case <-f.done:
+		return ErrFollowerClosed
+	}
+}
+
+func (f *Follower) setCursor(c FollowerCursor) {
+	f.mu.Lock()
+	f.cursor = c
+	f.mu.Unlock()
+}
@@ -0,0 +1,226 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chain is a stand-in daemon serving a mutable chain of headers
+type chain struct {
+	Client
+
+	mu     sync.Mutex
+	main   []BlockHeader
+	byHash map[string]BlockHeader
+	err    error
+}
+
+func newChain(height uint64) *chain {
+	c := &chain{byHash: make(map[string]BlockHeader)}
+	c.extend("a", height+1)
+	return c
+}
+
+// extend adds n blocks named after tag to the main chain
+func (c *chain) extend(tag string, n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := uint64(0); i < n; i++ {
+		hdr := BlockHeader{Height: uint64(len(c.main)), Hash: fmt.Sprintf("%s%d", tag, len(c.main))}
+		if len(c.main) > 0 {
+			hdr.PrevHash = c.main[len(c.main)-1].Hash
+		}
+		c.main = append(c.main, hdr)
+		c.byHash[hdr.Hash] = hdr
+	}
+}
+
+// reorg replaces the top depth blocks with n blocks named after tag
+func (c *chain) reorg(depth uint64, tag string, n uint64) {
+	c.mu.Lock()
+	c.main = c.main[:uint64(len(c.main))-depth]
+	c.mu.Unlock()
+	c.extend(tag, n)
+}
+
+func (c *chain) failWith(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+func (c *chain) GetLastBlockHeader(*GetLastBlockHeaderRequest) (*GetLastBlockHeaderResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &GetLastBlockHeaderResponse{BlockHeader: c.main[len(c.main)-1]}, nil
+}
+
+func (c *chain) GetBlockHeaderByHeight(req *GetBlockHeaderByHeightRequest) (*GetBlockHeaderByHeightResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if req.Height >= uint64(len(c.main)) {
+		return nil, errors.New("-2: too big height")
+	}
+	return &GetBlockHeaderByHeightResponse{BlockHeader: c.main[req.Height]}, nil
+}
+
+func (c *chain) GetBlockHeaderByHash(req *GetBlockHeaderByHashRequest) (*GetBlockHeaderByHashResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hdr, ok := c.byHash[req.Hash]
+	if !ok {
+		return nil, errors.New("-5: internal error")
+	}
+	return &GetBlockHeaderByHashResponse{BlockHeader: hdr}, nil
+}
+
+func (c *chain) GetBlockHeadersRange(req *GetBlockHeadersRangeRequest) (*GetBlockHeadersRangeResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if req.EndHeight >= uint64(len(c.main)) || req.StartHeight > req.EndHeight {
+		return nil, errors.New("-2: too big height")
+	}
+	return &GetBlockHeadersRangeResponse{Headers: append([]BlockHeader{}, c.main[req.StartHeight:req.EndHeight+1]...)}, nil
+}
+
+func (c *chain) GetBlocksByHeightBin(req *GetBlocksByHeightBinRequest) (*GetBlocksByHeightBinResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := &GetBlocksByHeightBinResponse{Status: RPCStatusOk}
+	for _, h := range req.Heights {
+		resp.Blocks = append(resp.Blocks, BlockCompleteEntry{Block: []byte(c.main[h].Hash)})
+	}
+	return resp, nil
+}
+
+type event struct {
+	Type FollowerEventType
+	Hash string
+}
+
+// expect receives len(expected) events and compares their type and block hash
+func expect(t *testing.T, f *Follower, expected ...event) []FollowerEvent {
+	var events []FollowerEvent
+	for range expected {
+		select {
+		case ev := <-f.Events:
+			events = append(events, ev)
+		case err := <-f.Errors:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for events")
+		}
+	}
+	got := make([]event, len(events))
+	for i, ev := range events {
+		got[i] = event{ev.Type, ev.Header.Hash}
+	}
+	assert.Equal(t, expected, got)
+	return events
+}
+
+func TestFollower(t *testing.T) {
+	c := newChain(5)
+	notify := make(chan struct{}, 1)
+	poll := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	f := NewFollower(c, &FollowerConfig{PollInterval: time.Hour, Notify: notify})
+
+	t.Run("starts at the top block", func(t *testing.T) {
+		for f.Cursor().Hash == "" {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, FollowerCursor{Height: 5, Hash: "a5"}, f.Cursor())
+		c.extend("a", 2)
+		poll()
+		events := expect(t, f,
+			event{BlockConnected, "a6"},
+			event{BlockConnected, "a7"},
+		)
+		assert.Equal(t, FollowerCursor{Height: 7, Hash: "a7"}, events[1].Cursor)
+		assert.Nil(t, events[0].Block)
+	})
+
+	t.Run("handles reorgs", func(t *testing.T) {
+		c.reorg(2, "b", 3)
+		poll()
+		events := expect(t, f,
+			event{BlockDisconnected, "a7"},
+			event{BlockDisconnected, "a6"},
+			event{BlockConnected, "b6"},
+			event{BlockConnected, "b7"},
+			event{BlockConnected, "b8"},
+		)
+		assert.Equal(t, FollowerCursor{Height: 6, Hash: "a6"}, events[0].Cursor)
+		assert.Equal(t, FollowerCursor{Height: 5, Hash: "a5"}, events[1].Cursor)
+		assert.Equal(t, FollowerCursor{Height: 8, Hash: "b8"}, f.Cursor())
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		c.failWith(errors.New("connection refused"))
+		poll()
+		assert.EqualError(t, <-f.Errors, "connection refused")
+		c.failWith(nil)
+		c.extend("b", 1)
+		poll()
+		expect(t, f, event{BlockConnected, "b9"})
+	})
+
+	assert.NoError(t, f.Close())
+	assert.Equal(t, ErrFollowerClosed, f.Close())
+	_, ok := <-f.Events
+	assert.False(t, ok)
+}
+
+func TestFollowerResume(t *testing.T) {
+	c := newChain(7)
+	c.reorg(2, "b", 4)
+
+	t.Run("from an orphaned block", func(t *testing.T) {
+		f := NewFollower(c, &FollowerConfig{
+			Cursor:      &FollowerCursor{Height: 7, Hash: "a7"},
+			BatchSize:   2,
+			FetchBlocks: true,
+		})
+		defer f.Close()
+		events := expect(t, f,
+			event{BlockDisconnected, "a7"},
+			event{BlockDisconnected, "a6"},
+			event{BlockConnected, "b6"},
+			event{BlockConnected, "b7"},
+			event{BlockConnected, "b8"},
+			event{BlockConnected, "b9"},
+		)
+		assert.Equal(t, []byte("b6"), events[2].Block.Block)
+		assert.Equal(t, []byte("b9"), events[5].Block.Block)
+	})
+
+	t.Run("from a height", func(t *testing.T) {
+		f := NewFollower(c, &FollowerConfig{Cursor: &FollowerCursor{Height: 8}})
+		defer f.Close()
+		expect(t, f, event{BlockConnected, "b9"})
+	})
+
+	t.Run("from an unknown block", func(t *testing.T) {
+		f := NewFollower(c, &FollowerConfig{Cursor: &FollowerCursor{Height: 7, Hash: "c7"}})
+		defer f.Close()
+		select {
+		case err := <-f.Errors:
+			assert.EqualError(t, err, "daemon: header of disconnected block c7: -5: internal error")
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for error")
+		}
+	})
+}
@@ -0,0 +1,411 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PoolWatcher default values
+const (
+	DefaultPoolWatcherPollInterval = 5 * time.Second
+)
+
+// ErrPoolWatcherClosed is returned when using a closed PoolWatcher
+var ErrPoolWatcherClosed = errors.New("daemon: pool watcher closed")
+
+// PoolEventType tells why a transaction entered or left the pool
+type PoolEventType int
+
+const (
+	// TxAdded is emitted when a transaction enters the pool
+	TxAdded PoolEventType = iota + 1
+	// TxMined is emitted when a transaction leaves the pool because it was mined
+	TxMined
+	// TxDropped is emitted when a transaction leaves the pool without being mined,
+	// e.g. because it expired or the daemon flushed it
+	TxDropped
+	// TxDoubleSpent is emitted when a transaction leaves the pool because
+	// another transaction spent one of its key images
+	TxDoubleSpent
+)
+
+func (t PoolEventType) String() string {
+	switch t {
+	case TxAdded:
+		return "TxAdded"
+	case TxMined:
+		return "TxMined"
+	case TxDropped:
+		return "TxDropped"
+	case TxDoubleSpent:
+		return "TxDoubleSpent"
+	}
+	return fmt.Sprintf("PoolEventType(%d)", int(t))
+}
+
+// PoolTx is a transaction of the local view of the pool
+type PoolTx struct {
+	// The transaction ID hash.
+	Hash string
+	// The amount of the mining fee included in the transaction, in atomic units.
+	Fee uint64
+	// The size of the full transaction blob.
+	BlobSize uint64
+	// Key images of the inputs.
+	KeyImages []string
+	// States if this transaction has been seen as double spend.
+	DoubleSpendSeen bool
+	// The Unix time that the transaction was first seen by the node, 0 if unknown.
+	ReceiveTime uint64
+}
+
+// PoolEvent is a change of the pool
+type PoolEvent struct {
+	// TxAdded, TxMined, TxDropped or TxDoubleSpent
+	Type PoolEventType
+	// The transaction as last seen in the pool
+	Tx PoolTx
+	// Height of the block including the transaction, for TxMined
+	BlockHeight uint64
+}
+
+// PoolWatcherConfig configures a PoolWatcher
+type PoolWatcherConfig struct {
+	// Interval between polls of the pool hashes.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll when it receives a value, e.g. from
+	// a goroutine forwarding zmqsub.Subscriber.TxPoolAdd notifications.
+	Notify <-chan struct{}
+	// Buffer size of the Events channel.
+	BufferSize int
+}
+
+// PoolWatcher keeps a local view of the transaction pool and emits events
+// when transactions enter or leave it. The view is loaded once with
+// GetTransactionPool, then each poll costs one GetTransactionPoolHashesBin
+// call: new hashes are fetched with GetTransactions and the fate of
+// removed ones is resolved with GetTransactions and IsKeyImageSpent.
+type PoolWatcher struct {
+	// Events of the pool, blocks until they are received.
+	// The transactions of the initial view are emitted as TxAdded events.
+	Events <-chan PoolEvent
+	// Errors receives RPC errors, after which the watcher retries
+	// at the next poll. Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg    PoolWatcherConfig
+	client Client
+	events chan PoolEvent
+	errors chan error
+
+	mu      sync.Mutex
+	pool    map[string]PoolTx
+	started bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewPoolWatcher starts watching the pool of the daemon behind client.
+func NewPoolWatcher(client Client, cfg *PoolWatcherConfig) *PoolWatcher {
+	c := PoolWatcherConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultPoolWatcherPollInterval
+	}
+
+	w := &PoolWatcher{
+		cfg:    c,
+		client: client,
+		events: make(chan PoolEvent, c.BufferSize),
+		errors: make(chan error, 16),
+		pool:   make(map[string]PoolTx),
+		done:   make(chan struct{}),
+	}
+	w.Events = w.events
+	w.Errors = w.errors
+
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Pool returns the local view of the pool, sorted by hash.
+func (w *PoolWatcher) Pool() []PoolTx {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	txs := make([]PoolTx, 0, len(w.pool))
+	for _, tx := range w.pool {
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Hash < txs[j].Hash })
+	return txs
+}
+
+// Close stops the watcher and closes its channels.
+func (w *PoolWatcher) Close() error {
+	err := ErrPoolWatcherClosed
+	w.closeOnce.Do(func() {
+		err = nil
+		close(w.done)
+	})
+	w.wg.Wait()
+	return err
+}
+
+func (w *PoolWatcher) run() {
+	defer w.wg.Done()
+	defer close(w.errors)
+	defer close(w.events)
+
+	notify := w.cfg.Notify
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		var err error
+		if w.started {
+			err = w.poll()
+		} else {
+			err = w.load()
+		}
+		if err != nil {
+			if err == ErrPoolWatcherClosed {
+				return
+			}
+			select {
+			case w.errors <- err:
+			default:
+			}
+		}
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+			}
+		}
+	}
+}
+
+// load builds the initial view from the full pool
+func (w *PoolWatcher) load() error {
+	resp, err := w.client.GetTransactionPool(&GetTransactionPoolRequest{})
+	if err != nil {
+		return err
+	}
+	keyImages := make(map[string][]string)
+	for _, ki := range resp.SpentKeyImages {
+		for _, hash := range ki.TxsHashes {
+			keyImages[hash] = append(keyImages[hash], ki.IDHash)
+		}
+	}
+	w.started = true
+	for _, mtx := range resp.Transactions {
+		tx := PoolTx{
+			Hash:            mtx.IDHash,
+			Fee:             mtx.Fee,
+			BlobSize:        mtx.BlobSize,
+			KeyImages:       keyImages[mtx.IDHash],
+			DoubleSpendSeen: mtx.DoubleSpendSeen,
+			ReceiveTime:     mtx.ReceiveTime,
+		}
+		if err := w.add(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poll compares the pool hashes with the view
+func (w *PoolWatcher) poll() error {
+	resp, err := w.client.GetTransactionPoolHashesBin(&GetTransactionPoolHashesBinRequest{})
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool, len(resp.TxHashes))
+	var added []string
+	for _, hash := range resp.TxHashes {
+		current[hash] = true
+		if !w.has(hash) {
+			added = append(added, hash)
+		}
+	}
+	var removed []string
+	w.mu.Lock()
+	for hash := range w.pool {
+		if !current[hash] {
+			removed = append(removed, hash)
+		}
+	}
+	w.mu.Unlock()
+	sort.Strings(removed)
+
+	if err := w.resolve(removed); err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	txs, err := w.client.GetTransactions(&GetTransactionsRequest{TxsHashes: added, DecodeAsJSON: true})
+	if err != nil {
+		return err
+	}
+	for _, t := range txs.Txs {
+		// mined between the two calls, the next poll sees it as neither added nor removed
+		if !t.InPool {
+			continue
+		}
+		tx, err := decodePoolTx(t)
+		if err != nil {
+			return err
+		}
+		if err := w.add(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve emits TxMined, TxDropped or TxDoubleSpent for transactions which left the pool
+func (w *PoolWatcher) resolve(removed []string) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	resp, err := w.client.GetTransactions(&GetTransactionsRequest{TxsHashes: removed})
+	if err != nil {
+		return err
+	}
+	found := make(map[string]Transaction, len(resp.Txs))
+	for _, t := range resp.Txs {
+		found[t.TxHash] = t
+	}
+
+	var mined []PoolEvent
+	for _, hash := range removed {
+		t, ok := found[hash]
+		switch {
+		case ok && t.InPool:
+			// back in the pool, e.g. after a reorg
+		case ok:
+			mined = append(mined, PoolEvent{Type: TxMined, Tx: w.get(hash), BlockHeight: t.BlockHeight})
+		default:
+			ev, err := w.dropped(w.get(hash))
+			if err != nil {
+				return err
+			}
+			if err := w.remove(ev); err != nil {
+				return err
+			}
+		}
+	}
+	sort.SliceStable(mined, func(i, j int) bool { return mined[i].BlockHeight < mined[j].BlockHeight })
+	for _, ev := range mined {
+		if err := w.remove(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropped tells whether a transaction missing from the pool and the chain was double spent
+func (w *PoolWatcher) dropped(tx PoolTx) (PoolEvent, error) {
+	ev := PoolEvent{Type: TxDropped, Tx: tx}
+	if tx.DoubleSpendSeen {
+		ev.Type = TxDoubleSpent
+		return ev, nil
+	}
+	if len(tx.KeyImages) == 0 {
+		return ev, nil
+	}
+	resp, err := w.client.IsKeyImageSpent(&IsKeyImageSpentRequest{KeyImages: tx.KeyImages})
+	if err != nil {
+		return ev, err
+	}
+	for _, status := range resp.SpentStatus {
+		if status != 0 {
+			ev.Type = TxDoubleSpent
+			break
+		}
+	}
+	return ev, nil
+}
+
+func (w *PoolWatcher) has(hash string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.pool[hash]
+	return ok
+}
+
+func (w *PoolWatcher) get(hash string) PoolTx {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pool[hash]
+}
+
+func (w *PoolWatcher) add(tx PoolTx) error {
+	if err := w.emit(PoolEvent{Type: TxAdded, Tx: tx}); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.pool[tx.Hash] = tx
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *PoolWatcher) remove(ev PoolEvent) error {
+	if err := w.emit(ev); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	delete(w.pool, ev.Tx.Hash)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *PoolWatcher) emit(ev PoolEvent) error {
+	select {
+	case w.events <- ev:
+		return nil
+	case <-w.done:
+		return ErrPoolWatcherClosed
+	}
+}
+
+// decodePoolTx reads the fee and key images of a transaction decoded as JSON
+func decodePoolTx(t Transaction) (PoolTx, error) {
+	var decoded struct {
+		Vin []struct {
+			Key *struct {
+				KImage string `json:"k_image"`
+			} `json:"key"`
+		} `json:"vin"`
+		RctSignatures struct {
+			TxnFee uint64 `json:"txnFee"`
+		} `json:"rct_signatures"`
+	}
+	if err := json.Unmarshal([]byte(t.AsJSON), &decoded); err != nil {
+		return PoolTx{}, fmt.Errorf("daemon: transaction %s: %w", t.TxHash, err)
+	}
+	tx := PoolTx{
+		Hash:            t.TxHash,
+		Fee:             decoded.RctSignatures.TxnFee,
+		BlobSize:        uint64(len(t.AsHex) / 2),
+		DoubleSpendSeen: t.DoubleSpendSeen,
+	}
+	for _, in := range decoded.Vin {
+		if in.Key != nil {
+			tx.KeyImages = append(tx.KeyImages, in.Key.KImage)
+		}
+	}
+	return tx, nil
+}
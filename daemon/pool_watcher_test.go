@@ -0,0 +1,252 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mempool is a stand-in daemon serving a mutable pool and chain
+type mempool struct {
+	Client
+
+	mu      sync.Mutex
+	pool    map[string][]string // hash -> key images
+	mined   map[string]uint64   // hash -> height
+	spent   map[string]bool     // key images spent on chain
+	doubles map[string]bool     // hashes seen as double spends
+	err     error
+}
+
+func newMempool() *mempool {
+	return &mempool{
+		pool:    make(map[string][]string),
+		mined:   make(map[string]uint64),
+		spent:   make(map[string]bool),
+		doubles: make(map[string]bool),
+	}
+}
+
+func (m *mempool) add(hash string, keyImages ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool[hash] = keyImages
+}
+
+func (m *mempool) mine(height uint64, hashes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, hash := range hashes {
+		for _, ki := range m.pool[hash] {
+			m.spent[ki] = true
+		}
+		delete(m.pool, hash)
+		m.mined[hash] = height
+	}
+}
+
+func (m *mempool) drop(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pool, hash)
+}
+
+func (m *mempool) txJSON(hash string) string {
+	s := `{"version":2,"vin":[`
+	for i, ki := range m.pool[hash] {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf(`{"key":{"amount":0,"key_offsets":[1],"k_image":"%s"}}`, ki)
+	}
+	return s + `],"rct_signatures":{"type":6,"txnFee":30000000}}`
+}
+
+func (m *mempool) GetTransactionPool(*GetTransactionPoolRequest) (*GetTransactionPoolResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	resp := &GetTransactionPoolResponse{Status: RPCStatusOk}
+	for hash, keyImages := range m.pool {
+		resp.Transactions = append(resp.Transactions, MempoolTransaction{
+			IDHash:          hash,
+			Fee:             30000000,
+			BlobSize:        1500,
+			ReceiveTime:     1666000000,
+			DoubleSpendSeen: m.doubles[hash],
+		})
+		for _, ki := range keyImages {
+			resp.SpentKeyImages = append(resp.SpentKeyImages, SpentKeyImage{IDHash: ki, TxsHashes: []string{hash}})
+		}
+	}
+	sort.Slice(resp.Transactions, func(i, j int) bool { return resp.Transactions[i].IDHash < resp.Transactions[j].IDHash })
+	return resp, nil
+}
+
+func (m *mempool) GetTransactionPoolHashesBin(*GetTransactionPoolHashesBinRequest) (*GetTransactionPoolHashesBinResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	resp := &GetTransactionPoolHashesBinResponse{Status: RPCStatusOk}
+	for hash := range m.pool {
+		resp.TxHashes = append(resp.TxHashes, hash)
+	}
+	sort.Strings(resp.TxHashes)
+	return resp, nil
+}
+
+func (m *mempool) GetTransactions(req *GetTransactionsRequest) (*GetTransactionsResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp := &GetTransactionsResponse{Status: RPCStatusOk}
+	for _, hash := range req.TxsHashes {
+		switch {
+		case m.pool[hash] != nil:
+			t := Transaction{TxHash: hash, InPool: true, AsHex: "0200", DoubleSpendSeen: m.doubles[hash]}
+			if req.DecodeAsJSON {
+				t.AsJSON = m.txJSON(hash)
+			}
+			resp.Txs = append(resp.Txs, t)
+		case m.mined[hash] > 0:
+			resp.Txs = append(resp.Txs, Transaction{TxHash: hash, BlockHeight: m.mined[hash]})
+		default:
+			resp.MissedTx = append(resp.MissedTx, hash)
+		}
+	}
+	return resp, nil
+}
+
+func (m *mempool) IsKeyImageSpent(req *IsKeyImageSpentRequest) (*IsKeyImageSpentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp := &IsKeyImageSpentResponse{Status: RPCStatusOk}
+	for _, ki := range req.KeyImages {
+		var status uint64
+		if m.spent[ki] {
+			status = 1
+		}
+		resp.SpentStatus = append(resp.SpentStatus, status)
+	}
+	return resp, nil
+}
+
+type poolEvent struct {
+	Type PoolEventType
+	Hash string
+}
+
+func expectPool(t *testing.T, w *PoolWatcher, expected ...poolEvent) []PoolEvent {
+	var events []PoolEvent
+	for range expected {
+		select {
+		case ev := <-w.Events:
+			events = append(events, ev)
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for events")
+		}
+	}
+	got := make([]poolEvent, len(events))
+	for i, ev := range events {
+		got[i] = poolEvent{ev.Type, ev.Tx.Hash}
+	}
+	assert.Equal(t, expected, got)
+	return events
+}
+
+func TestPoolWatcher(t *testing.T) {
+	m := newMempool()
+	m.add("t1", "k1")
+	m.add("t2", "k2", "k3")
+
+	notify := make(chan struct{}, 1)
+	poll := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	w := NewPoolWatcher(m, &PoolWatcherConfig{PollInterval: time.Hour, Notify: notify})
+
+	t.Run("loads the pool", func(t *testing.T) {
+		events := expectPool(t, w,
+			poolEvent{TxAdded, "t1"},
+			poolEvent{TxAdded, "t2"},
+		)
+		assert.Equal(t, PoolTx{
+			Hash:        "t2",
+			Fee:         30000000,
+			BlobSize:    1500,
+			KeyImages:   []string{"k2", "k3"},
+			ReceiveTime: 1666000000,
+		}, events[1].Tx)
+	})
+
+	t.Run("fetches new transactions only", func(t *testing.T) {
+		m.add("t3", "k4", "k5")
+		poll()
+		events := expectPool(t, w, poolEvent{TxAdded, "t3"})
+		assert.Equal(t, PoolTx{Hash: "t3", Fee: 30000000, BlobSize: 2, KeyImages: []string{"k4", "k5"}}, events[0].Tx)
+		assert.Len(t, w.Pool(), 3)
+	})
+
+	t.Run("resolves removed transactions", func(t *testing.T) {
+		m.mine(101, "t3")
+		m.mine(100, "t1")
+		m.drop("t2")
+		m.add("t4", "k2")
+		m.mine(102, "t4")
+		poll()
+		events := expectPool(t, w,
+			poolEvent{TxDoubleSpent, "t2"},
+			poolEvent{TxMined, "t1"},
+			poolEvent{TxMined, "t3"},
+		)
+		assert.Equal(t, uint64(100), events[1].BlockHeight)
+		assert.Equal(t, uint64(101), events[2].BlockHeight)
+		assert.Empty(t, w.Pool())
+
+		m.add("t5", "k6")
+		poll()
+		expectPool(t, w, poolEvent{TxAdded, "t5"})
+		m.drop("t5")
+		poll()
+		expectPool(t, w, poolEvent{TxDropped, "t5"})
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		m.mu.Lock()
+		m.err = errors.New("connection refused")
+		m.mu.Unlock()
+		poll()
+		assert.EqualError(t, <-w.Errors, "connection refused")
+	})
+
+	assert.NoError(t, w.Close())
+	assert.Equal(t, ErrPoolWatcherClosed, w.Close())
+	_, ok := <-w.Events
+	assert.False(t, ok)
+}
+
+func TestPoolWatcherDoubleSpendSeen(t *testing.T) {
+	m := newMempool()
+	m.add("t1", "k1")
+	m.doubles["t1"] = true
+	w := NewPoolWatcher(m, &PoolWatcherConfig{PollInterval: 10 * time.Millisecond})
+	defer w.Close()
+
+	events := expectPool(t, w, poolEvent{TxAdded, "t1"})
+	assert.True(t, events[0].Tx.DoubleSpendSeen)
+	m.drop("t1")
+	expectPool(t, w, poolEvent{TxDoubleSpent, "t1"})
+}
@@ -551,6 +551,8 @@ type GetFeeEstimateRequest struct {
 type GetFeeEstimateResponse struct {
 	// Amount of fees estimated per byte in atomic units
 	Fee uint64 `json:"fee"`
+	// Fees per byte of the priorities 1 (unimportant) to 4 (priority), in atomic units.
+	Fees []uint64 `json:"fees"`
 	// Final fee should be rounded up to an even multiple of this value
 	QuantizationMask uint64 `json:"quantization_mask"`
 	// General RPC error code. "OK" means everything looks good.
@@ -712,6 +714,163 @@ type OuputDistribution struct {
 	StartHeight uint64 `json:"start_height"`
 }
 
+// GetMinerDataRequest is a struct for GetMinerData() requests
+type GetMinerDataRequest struct {
+	// None
+}
+
+// GetMinerDataResponse is a struct for GetMinerData() responses
+type GetMinerDataResponse struct {
+	// Major version of the next block.
+	MajorVersion uint8 `json:"major_version"`
+	// Height of the next block.
+	Height uint64 `json:"height"`
+	// Hash of the top block, which is the previous block of the next one.
+	PrevID string `json:"prev_id"`
+	// RandomX seed hash for the next block.
+	SeedHash string `json:"seed_hash"`
+	// Network difficulty for the next block, as a hex string.
+	Difficulty string `json:"difficulty"`
+	// Median block weight of the last 100000 blocks.
+	MedianWeight uint64 `json:"median_weight"`
+	// Coins mined by the network so far.
+	AlreadyGeneratedCoins gonero.AtomicXMR `json:"already_generated_coins"`
+	// Mineable transactions in the transaction pool.
+	TxBacklog []MinerTxBacklogEntry `json:"tx_backlog"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// MinerTxBacklogEntry is a struct for GetMinerData()
+type MinerTxBacklogEntry struct {
+	// Transaction ID
+	ID string `json:"id"`
+	// Transaction weight
+	Weight uint64 `json:"weight"`
+	// Transaction fee
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// CalcPowRequest is a struct for CalcPow() requests
+type CalcPowRequest struct {
+	// Major version of the block.
+	MajorVersion uint8 `json:"major_version"`
+	// Height of the block.
+	Height uint64 `json:"height"`
+	// Hashing blob of the block.
+	BlockBlob string `json:"block_blob"`
+	// RandomX seed hash of the block.
+	SeedHash string `json:"seed_hash"`
+}
+
+// CalcPowResponse is a struct for CalcPow() responses
+// Proof-of-work hash of the block
+type CalcPowResponse string
+
+// AddAuxPowRequest is a struct for AddAuxPow() requests
+type AddAuxPowRequest struct {
+	// Block template on which to merge mine the auxiliary chains.
+	BlocktemplateBlob string `json:"blocktemplate_blob"`
+	// Auxiliary chains to merge mine.
+	AuxPow []AuxPow `json:"aux_pow"`
+}
+
+// AddAuxPowResponse is a struct for AddAuxPow() responses
+type AddAuxPowResponse struct {
+	// Block template with the merge mining tag.
+	BlocktemplateBlob string `json:"blocktemplate_blob"`
+	// Block hashing blob of the new block template.
+	BlockhashingBlob string `json:"blockhashing_blob"`
+	// Merkle root of the auxiliary chains.
+	MerkleRoot string `json:"merkle_root"`
+	// Depth of the merkle tree of the auxiliary chains.
+	MerkleTreeDepth uint32 `json:"merkle_tree_depth"`
+	// Auxiliary chains, in merkle tree order.
+	AuxPow []AuxPow `json:"aux_pow"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// AuxPow is an auxiliary chain for AddAuxPow()
+type AuxPow struct {
+	// Auxiliary chain ID.
+	ID string `json:"id"`
+	// Block hash on the auxiliary chain.
+	Hash string `json:"hash"`
+}
+
+// PruneBlockchainRequest is a struct for PruneBlockchain() requests
+type PruneBlockchainRequest struct {
+	// Optional (false by default). If set true, only check whether the blockchain is pruned.
+	Check bool `json:"check,omitempty"`
+}
+
+// PruneBlockchainResponse is a struct for PruneBlockchain() responses
+type PruneBlockchainResponse struct {
+	// States if the blockchain is pruned (true) or not (false).
+	Pruned bool `json:"pruned"`
+	// Blockchain pruning seed.
+	PruningSeed uint32 `json:"pruning_seed"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// FlushCacheRequest is a struct for FlushCache() requests
+type FlushCacheRequest struct {
+	// Optional (false by default). Flush the bad transactions cache.
+	BadTxs bool `json:"bad_txs,omitempty"`
+	// Optional (false by default). Flush the bad blocks cache.
+	BadBlocks bool `json:"bad_blocks,omitempty"`
+}
+
+// FlushCacheResponse is a struct for FlushCache() responses
+type FlushCacheResponse struct {
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// BannedRequest is a struct for Banned() requests
+type BannedRequest struct {
+	// Host to check.
+	Address string `json:"address"`
+}
+
+// BannedResponse is a struct for Banned() responses
+type BannedResponse struct {
+	// States if the host is banned (true) or not (false).
+	Banned bool `json:"banned"`
+	// Seconds left in the ban.
+	Seconds uint32 `json:"seconds"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+}
+
+// GetTxidsLooseRequest is a struct for GetTxidsLoose() requests
+type GetTxidsLooseRequest struct {
+	// Transaction ID with only the first NumMatchingBits bits meaningful.
+	TxidTemplate string `json:"txid_template"`
+	// Number of leading bits of TxidTemplate to match.
+	NumMatchingBits uint32 `json:"num_matching_bits"`
+}
+
+// GetTxidsLooseResponse is a struct for GetTxidsLoose() responses
+type GetTxidsLooseResponse struct {
+	// Transaction IDs matching the template, on chain and in the pool.
+	Txids []string `json:"txids"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
 //#####################
 // Other RPC Structs
 //#####################
@@ -934,6 +1093,28 @@ type MiningStatusResponse struct {
 	Status RPCStatus `json:"status"`
 	// Number of running mining threads.
 	ThreadsCount uint64 `json:"threads_count"`
+	// Proof-of-work algorithm currently used, e.g. "RandomX".
+	PowAlgorithm string `json:"pow_algorithm"`
+	// Target block time in seconds.
+	BlockTarget uint32 `json:"block_target"`
+	// Reward of the block being mined.
+	BlockReward gonero.AtomicXMR `json:"block_reward"`
+	// Least-significant 64 bits of the network difficulty.
+	Difficulty uint64 `json:"difficulty"`
+	// Network difficulty as a hex string.
+	WideDifficulty string `json:"wide_difficulty"`
+	// Most-significant 64 bits of the network difficulty.
+	DifficultyTop64 uint64 `json:"difficulty_top64"`
+	// Minimum CPU idle percentage before background mining starts.
+	BgIdleThreshold uint8 `json:"bg_idle_threshold"`
+	// Minimum idle time in seconds before background mining starts.
+	BgMinIdleSeconds uint8 `json:"bg_min_idle_seconds"`
+	// States if background mining ignores the battery state.
+	BgIgnoreBattery bool `json:"bg_ignore_battery"`
+	// Maximum CPU usage percentage of background mining.
+	BgTarget uint8 `json:"bg_target"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
 }
 
 // SaveBcRequest is a struct for SaveBc() requests
@@ -1126,8 +1307,8 @@ type GetTransactionPoolHashesBinRequest struct {
 type GetTransactionPoolHashesBinResponse struct {
 	// General RPC error code. "OK" means everything looks good.
 	Status RPCStatus `json:"status"`
-	// binary array of transaction hashes.
-	TxHashes [][]byte `json:"tx_hashes"`
+	// List of transaction hashes, decoded from the binary array sent by the daemon.
+	TxHashes []string `json:"tx_hashes"`
 	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
 	Untrusted bool `json:"untrusted"`
 }
@@ -1255,30 +1436,16 @@ type InPeersResponse struct {
 
 // GetOutsRequest is a struct for GetOuts() requests
 type GetOutsRequest struct {
-	// index - unsigned int
-	Outputs Outputs `json:"outputs"`
-	// unsigned int
-	Amount gonero.AtomicXMR `json:"amount"`
-	// unsigned int
-	Index uint64 `json:"index"`
+	// Outputs to get, by amount and global index. RingCT outputs have amount 0.
+	Outputs []Outputs `json:"outputs"`
 	// If true, a txid will included for each output in the response.
 	GetTxid bool `json:"get_txid"`
 }
 
 // GetOutsResponse is a struct for GetOuts() responses
 type GetOutsResponse struct {
-	// height;unsigned int;block height of the output key - String;the public key of the output mask - String txid - String;transaction id unlocked - boolean;States if output is locked (false) or not (true)
-	Outs Outs `json:"outs"`
-	// block height of the output
-	Height uint64 `json:"height"`
-	// the public key of the output
-	Key string `json:"key"`
-	// String
-	Mask string `json:"mask"`
-	// transaction id
-	Txid string `json:"txid"`
-	// States if output is locked (false) or not (true)
-	Unlocked bool `json:"unlocked"`
+	// Outputs, in the order of the request.
+	Outs []Outs `json:"outs"`
 	// General RPC error code. "OK" means everything looks good.
 	Status RPCStatus `json:"status"`
 	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
@@ -1333,6 +1500,186 @@ type UpdateResponse struct {
 	Version string `json:"version"`
 }
 
+// GetPublicNodesRequest is a struct for GetPublicNodes() requests
+type GetPublicNodesRequest struct {
+	// States if gray (not recently seen) nodes should be returned.
+	Gray bool `json:"gray"`
+	// States if white (recently seen) nodes should be returned.
+	White bool `json:"white"`
+	// Optional (false by default). States if banned nodes should be returned.
+	IncludeBlocked bool `json:"include_blocked,omitempty"`
+}
+
+// GetPublicNodesResponse is a struct for GetPublicNodes() responses
+type GetPublicNodesResponse struct {
+	// array of gray public nodes
+	Gray []PublicNode `json:"gray"`
+	// array of white public nodes
+	White []PublicNode `json:"white"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// PublicNode is a node advertising a public RPC port, for GetPublicNodes()
+type PublicNode struct {
+	// IP address of the node.
+	Host string `json:"host"`
+	// Last time the node was seen, as a unix timestamp.
+	LastSeen uint64 `json:"last_seen"`
+	// Public RPC port of the node.
+	RPCPort uint16 `json:"rpc_port"`
+	// RPC payment credits per hash, 0 if the node is free.
+	RPCCreditsPerHash uint32 `json:"rpc_credits_per_hash"`
+}
+
+// GetNetStatsRequest is a struct for GetNetStats() requests
+type GetNetStatsRequest struct {
+	// None
+}
+
+// GetNetStatsResponse is a struct for GetNetStats() responses
+type GetNetStatsResponse struct {
+	// Unix start time.
+	StartTime uint64 `json:"start_time"`
+	// Total number of packets received.
+	TotalPacketsIn uint64 `json:"total_packets_in"`
+	// Total bytes received.
+	TotalBytesIn uint64 `json:"total_bytes_in"`
+	// Total number of packets sent.
+	TotalPacketsOut uint64 `json:"total_packets_out"`
+	// Total bytes sent.
+	TotalBytesOut uint64 `json:"total_bytes_out"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// PopBlocksRequest is a struct for PopBlocks() requests
+type PopBlocksRequest struct {
+	// Number of blocks to remove from the top of the chain.
+	NBlocks uint64 `json:"nblocks"`
+}
+
+// PopBlocksResponse is a struct for PopBlocks() responses
+type PopBlocksResponse struct {
+	// New height of the chain.
+	Height uint64 `json:"height"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// SetBootstrapDaemonRequest is a struct for SetBootstrapDaemon() requests
+type SetBootstrapDaemonRequest struct {
+	// Bootstrap daemon address, "auto" to pick a public node, or empty to disable.
+	Address string `json:"address"`
+	// Optional, username for the bootstrap daemon RPC.
+	Username string `json:"username,omitempty"`
+	// Optional, password for the bootstrap daemon RPC.
+	Password gonero.Secret `json:"password,omitempty"`
+	// Optional, SOCKS proxy (ip:port) used to connect to the bootstrap daemon.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// SetBootstrapDaemonResponse is a struct for SetBootstrapDaemon() responses
+type SetBootstrapDaemonResponse struct {
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+}
+
+// GetTransactionPoolHashesRequest is a struct for GetTransactionPoolHashes() requests
+type GetTransactionPoolHashesRequest struct {
+	// None
+}
+
+// GetTransactionPoolHashesResponse is a struct for GetTransactionPoolHashes() responses
+type GetTransactionPoolHashesResponse struct {
+	// List of transaction hashes in the pool.
+	TxHashes []string `json:"tx_hashes"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+//#####################
+// Binary RPC Structs
+//#####################
+
+// GetOutputDistributionBinRequest is a struct for GetOutputDistributionBin() requests
+type GetOutputDistributionBinRequest struct {
+	// amounts to look for
+	Amounts []gonero.AtomicXMR `json:"amounts"`
+	// (optional, default is false) States if the result should be cumulative (true) or not (false)
+	Cumulative bool `json:"cumulative,omitempty"`
+	// (optional, default is 0) starting height to check from
+	FromHeight uint64 `json:"from_height,omitempty"`
+	// (optional, default is 0) ending height to check up to
+	ToHeight uint64 `json:"to_height,omitempty"`
+	// (optional, default is false) States if the distribution should be sent varint compressed
+	Compress bool `json:"compress,omitempty"`
+}
+
+// outputDistributionBinRequest is the GetOutputDistributionBin() request on the wire
+type outputDistributionBinRequest struct {
+	GetOutputDistributionBinRequest
+	Binary bool `json:"binary"`
+}
+
+// outputDistributionBinResponse is the GetOutputDistributionBin() response on the wire
+type outputDistributionBinResponse struct {
+	Distributions []struct {
+		Amount         gonero.AtomicXMR `json:"amount"`
+		StartHeight    uint64           `json:"start_height"`
+		Base           uint64           `json:"base"`
+		Binary         bool             `json:"binary"`
+		Compress       bool             `json:"compress"`
+		Distribution   []byte           `json:"distribution"`
+		CompressedData []byte           `json:"compressed_data"`
+	} `json:"distributions"`
+	Status    RPCStatus `json:"status"`
+	Untrusted bool      `json:"untrusted"`
+}
+
+// GetBlocksByHeightBinRequest is a struct for GetBlocksByHeightBin() requests
+type GetBlocksByHeightBinRequest struct {
+	// list of block heights
+	Heights []uint64 `json:"heights"`
+}
+
+// GetBlocksByHeightBinResponse is a struct for GetBlocksByHeightBin() responses
+type GetBlocksByHeightBinResponse struct {
+	// blocks in the order of the requested heights
+	Blocks []BlockCompleteEntry `json:"blocks"`
+	// General RPC error code. "OK" means everything looks good.
+	Status RPCStatus `json:"status"`
+	// States if the result is obtained using the bootstrap mode, and is therefore not trusted (true), or when the daemon is fully synced (false).
+	Untrusted bool `json:"untrusted"`
+}
+
+// BlockCompleteEntry is a block blob and the blobs of its transactions
+type BlockCompleteEntry struct {
+	// States if the transactions are pruned, which is not supported
+	Pruned bool `json:"pruned,omitempty"`
+	// block blob
+	Block []byte `json:"block"`
+	// block weight, may be 0 for unpruned blocks
+	BlockWeight uint64 `json:"block_weight,omitempty"`
+	// transaction blobs, not including the coinbase transaction
+	Txs [][]byte `json:"txs"`
+}
+
+// transactionPoolHashesBinResponse is the GetTransactionPoolHashesBin() response on the wire
+type transactionPoolHashesBinResponse struct {
+	Status    RPCStatus `json:"status"`
+	TxHashes  []byte    `json:"tx_hashes"`
+	Untrusted bool      `json:"untrusted"`
+}
+
 ////////////////
 // Regtest only
 ////////////////
@@ -0,0 +1,242 @@
+package decoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// parameters of the audit of rings
+const (
+	// DefaultSamples is the number of picks estimating the distribution
+	// of the ages of decoys
+	DefaultSamples = 20000
+	// newest members whose bias is below newestBiasThreshold stand out
+	newestBiasThreshold = 0.01
+	// critical value of the Kolmogorov-Smirnov distance at the 1% level,
+	// divided by the square root of the ring size
+	deviationCritical = 1.63
+)
+
+// Weakness is a privacy weakness of a ring
+type Weakness string
+
+// Weaknesses of rings
+const (
+	// The newest member is much newer than the others, and likely the
+	// real spend.
+	WeaknessNewestBias Weakness = "newest_bias"
+	// The ages of the members do not follow the distribution of wallet2,
+	// so the decoys were picked by another wallet, or are real spends.
+	WeaknessDeviation Weakness = "deviation"
+	// The ring is smaller than DefaultRingSize.
+	WeaknessSmallRing Weakness = "small_ring"
+	// A member is in another ring of the transaction, which may reveal
+	// the real spends.
+	WeaknessSharedMember Weakness = "shared_member"
+)
+
+// RingScore is the audit of the ring of an input
+type RingScore struct {
+	// Global indices of the ring members.
+	Indices []uint64 `json:"indices"`
+	// Ages of the ring members in blocks, at the height of the
+	// transaction.
+	Ages []uint64 `json:"ages"`
+	// Quantiles of the ages of the ring members among the ages of decoys
+	// picked by wallet2, 0 for the newest.
+	Quantiles []float64 `json:"quantiles"`
+	// Probability that the decoys picked by wallet2 are all older than the
+	// second newest member. Low values single out the newest member.
+	NewestBias float64 `json:"newest_bias"`
+	// Kolmogorov-Smirnov distance between the quantiles and the uniform
+	// distribution they follow for decoys picked by wallet2.
+	Deviation float64 `json:"deviation"`
+	// Weaknesses found in the ring.
+	Weaknesses []Weakness `json:"weaknesses,omitempty"`
+}
+
+// TxAudit is the audit of the rings of a transaction
+type TxAudit struct {
+	// Hash of the transaction.
+	Hash string `json:"hash"`
+	// Height of the block of the transaction, the height of the chain if
+	// in the pool.
+	Height uint64 `json:"height"`
+	// Scores of the rings, in the order of the inputs.
+	Rings []RingScore `json:"rings"`
+}
+
+// Weak tells whether a ring of the transaction has a weakness.
+func (a *TxAudit) Weak() bool {
+	for _, r := range a.Rings {
+		if len(r.Weaknesses) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Auditor scores the rings of transactions for privacy weaknesses,
+// against the distribution of decoys picked by wallet2 at the height of
+// the transactions
+type Auditor struct {
+	client  daemon.Client
+	samples int
+	rnd     *rand.Rand
+}
+
+// NewAuditor returns an Auditor reading transactions and the output
+// distribution from client.
+func NewAuditor(client daemon.Client) *Auditor {
+	return newAuditor(client, DefaultSamples, rand.New(cryptoSource{}))
+}
+
+func newAuditor(client daemon.Client, samples int, rnd *rand.Rand) *Auditor {
+	return &Auditor{client: client, samples: samples, rnd: rnd}
+}
+
+// Audit returns the audits of the transactions, from the chain or the
+// pool.
+func (a *Auditor) Audit(hashes ...string) ([]TxAudit, error) {
+	resp, err := a.client.GetTransactions(&daemon.GetTransactionsRequest{TxsHashes: hashes, DecodeAsJSON: true})
+	if err != nil {
+		return nil, fmt.Errorf("decoy: get transactions: %w", err)
+	}
+	if len(resp.MissedTx) != 0 || len(resp.Txs) != len(hashes) {
+		return nil, fmt.Errorf("%w: %d transactions missed", ErrUnexpected, len(hashes)-len(resp.Txs))
+	}
+	offsets, err := (&Selector{client: a.client}).Distribution()
+	if err != nil {
+		return nil, err
+	}
+	audits := make([]TxAudit, len(resp.Txs))
+	for i, tx := range resp.Txs {
+		audits[i] = TxAudit{Hash: tx.TxHash, Height: tx.BlockHeight}
+		if tx.InPool {
+			audits[i].Height = uint64(len(offsets))
+		}
+		rings, err := decodeRings(tx)
+		if err != nil {
+			return nil, err
+		}
+		if audits[i].Height > uint64(len(offsets)) {
+			return nil, fmt.Errorf("%w: transaction %s above the distribution", ErrUnexpected, tx.TxHash)
+		}
+		if audits[i].Rings, err = a.Score(offsets[:audits[i].Height], rings); err != nil {
+			return nil, err
+		}
+	}
+	return audits, nil
+}
+
+// Score returns the scores of the rings of a transaction, given by global
+// indices, built with the cumulative output distribution offsets of the
+// chain.
+func (a *Auditor) Score(offsets []uint64, rings [][]uint64) ([]RingScore, error) {
+	picker, err := newPicker(offsets, a.rnd)
+	if err != nil {
+		return nil, err
+	}
+	// ages of decoys in outputs, as picked by wallet2
+	ages := make([]uint64, 0, a.samples)
+	for len(ages) < a.samples {
+		if index, ok := picker.Pick(); ok {
+			ages = append(ages, picker.NumOutputs()-1-index)
+		}
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+
+	seen := map[uint64]int{}
+	for _, ring := range rings {
+		for _, index := range ring {
+			seen[index]++
+		}
+	}
+	scores := make([]RingScore, len(rings))
+	for i, ring := range rings {
+		s := &scores[i]
+		s.Indices = ring
+		s.Ages = make([]uint64, len(ring))
+		s.Quantiles = make([]float64, len(ring))
+		shared := false
+		for j, index := range ring {
+			s.Ages[j] = uint64(len(offsets) - block(offsets, index))
+			if index < picker.NumOutputs() {
+				s.Quantiles[j] = quantile(ages, picker.NumOutputs()-1-index)
+			}
+			shared = shared || seen[index] > 1
+		}
+		s.score()
+		if len(ring) < DefaultRingSize {
+			s.Weaknesses = append(s.Weaknesses, WeaknessSmallRing)
+		}
+		if shared {
+			s.Weaknesses = append(s.Weaknesses, WeaknessSharedMember)
+		}
+	}
+	return scores, nil
+}
+
+// score sets the newest bias and the deviation of the ring from its
+// quantiles
+func (s *RingScore) score() {
+	q := append([]float64(nil), s.Quantiles...)
+	sort.Float64s(q)
+	n := float64(len(q))
+	if len(q) < 2 {
+		return
+	}
+	s.NewestBias = math.Pow(1-q[1], n-1)
+	for i, x := range q {
+		d := math.Max(float64(i+1)/n-x, x-float64(i)/n)
+		s.Deviation = math.Max(s.Deviation, d)
+	}
+	if s.NewestBias < newestBiasThreshold {
+		s.Weaknesses = append(s.Weaknesses, WeaknessNewestBias)
+	}
+	if s.Deviation > deviationCritical/math.Sqrt(n) {
+		s.Weaknesses = append(s.Weaknesses, WeaknessDeviation)
+	}
+}
+
+// quantile returns the fraction of the sorted ages below age, counting
+// equal ages as half
+func quantile(ages []uint64, age uint64) float64 {
+	below := sort.Search(len(ages), func(i int) bool { return ages[i] >= age })
+	equal := sort.Search(len(ages), func(i int) bool { return ages[i] > age }) - below
+	return (float64(below) + float64(equal)/2) / float64(len(ages))
+}
+
+// decodeRings returns the global indices of the rings of a transaction
+// decoded as JSON
+func decodeRings(tx daemon.Transaction) ([][]uint64, error) {
+	var decoded struct {
+		Vin []struct {
+			Key *struct {
+				KeyOffsets []uint64 `json:"key_offsets"`
+			} `json:"key"`
+		} `json:"vin"`
+	}
+	if err := json.Unmarshal([]byte(tx.AsJSON), &decoded); err != nil {
+		return nil, fmt.Errorf("%w: transaction %s: %v", ErrUnexpected, tx.TxHash, err)
+	}
+	var rings [][]uint64
+	for _, in := range decoded.Vin {
+		if in.Key == nil {
+			continue
+		}
+		ring := make([]uint64, len(in.Key.KeyOffsets))
+		var index uint64
+		for i, offset := range in.Key.KeyOffsets {
+			index += offset
+			ring[i] = index
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
@@ -0,0 +1,196 @@
+// Package decoy selects the decoys of the rings of RingCT inputs as
+// wallet2 does, from the output distribution and the outputs of a daemon,
+// and audits the rings of transactions for privacy weaknesses.
+package decoy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// DefaultRingSize is the ring size enforced since v0.18
+const DefaultRingSize = 16
+
+// maxPicks bounds the picks of a ring, bad and duplicate picks included
+const maxPicks = 100
+
+// Decoy errors
+var (
+	ErrNotEnoughOutputs = errors.New("decoy: not enough outputs")
+	ErrUnexpected       = errors.New("decoy: unexpected daemon response")
+)
+
+// Ring is the ring of an input
+type Ring struct {
+	// Global indices of the ring members, sorted.
+	Indices []uint64 `json:"indices"`
+	// Ring members, in the order of Indices.
+	Members []crypto.RingMember `json:"members"`
+	// Position of the real output in the ring.
+	RealIndex int `json:"real_index"`
+}
+
+// Selector picks the decoys of rings
+type Selector struct {
+	client   daemon.Client
+	ringSize int
+	rnd      *rand.Rand
+}
+
+// New returns a Selector of rings of ringSize members, DefaultRingSize if
+// 0, reading outputs from client.
+func New(client daemon.Client, ringSize int) *Selector {
+	return newSelector(client, ringSize, rand.New(cryptoSource{}))
+}
+
+func newSelector(client daemon.Client, ringSize int, rnd *rand.Rand) *Selector {
+	if ringSize == 0 {
+		ringSize = DefaultRingSize
+	}
+	return &Selector{client: client, ringSize: ringSize, rnd: rnd}
+}
+
+// Distribution returns the cumulative number of RingCT outputs per block.
+func (s *Selector) Distribution() ([]uint64, error) {
+	resp, err := s.client.GetOutputDistribution(&daemon.GetOutputDistributionRequest{
+		Amounts:    []gonero.AtomicXMR{0},
+		Cumulative: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoy: get output distribution: %w", err)
+	}
+	if len(resp.Distributions) != 1 || len(resp.Distributions[0].Distribution) == 0 {
+		return nil, fmt.Errorf("%w: %d distributions", ErrUnexpected, len(resp.Distributions))
+	}
+	return resp.Distributions[0].Distribution, nil
+}
+
+// Select returns a ring for each of the real outputs, given by global
+// index. Decoys are unlocked outputs picked by a Picker, never one of the
+// real outputs.
+func (s *Selector) Select(real []uint64) ([]Ring, error) {
+	offsets, err := s.Distribution()
+	if err != nil {
+		return nil, err
+	}
+	picker, err := newPicker(offsets, s.rnd)
+	if err != nil {
+		return nil, err
+	}
+	if picker.NumOutputs() < uint64(s.ringSize+len(real)) {
+		return nil, fmt.Errorf("%w: %d spendable outputs", ErrNotEnoughOutputs, picker.NumOutputs())
+	}
+	excluded := make(map[uint64]bool, len(real))
+	for _, index := range real {
+		excluded[index] = true
+	}
+
+	rings := make([]Ring, len(real))
+	for i, index := range real {
+		rings[i], err = s.ring(picker, index, excluded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rings, nil
+}
+
+// ring returns the ring of the real output, with decoys neither excluded
+// nor locked
+func (s *Selector) ring(picker *Picker, real uint64, excluded map[uint64]bool) (Ring, error) {
+	members := map[uint64]*crypto.RingMember{}
+	locked := map[uint64]bool{}
+	for picks := 0; ; {
+		// pick the missing decoys
+		var fetch []uint64
+		for len(members)+len(fetch) < s.ringSize-1 {
+			if picks++; picks > maxPicks*s.ringSize {
+				return Ring{}, fmt.Errorf("%w: no decoys for output %d", ErrNotEnoughOutputs, real)
+			}
+			index, ok := picker.Pick()
+			if !ok || excluded[index] || locked[index] || members[index] != nil || contains(fetch, index) {
+				continue
+			}
+			fetch = append(fetch, index)
+		}
+		if len(fetch) == 0 {
+			break
+		}
+		outs, err := s.outputs(fetch)
+		if err != nil {
+			return Ring{}, err
+		}
+		for i, index := range fetch {
+			if !outs[i].unlocked {
+				locked[index] = true
+				continue
+			}
+			members[index] = &outs[i].RingMember
+		}
+	}
+
+	outs, err := s.outputs([]uint64{real})
+	if err != nil {
+		return Ring{}, err
+	}
+	members[real] = &outs[0].RingMember
+	ring := Ring{Indices: make([]uint64, 0, len(members))}
+	for index := range members {
+		ring.Indices = append(ring.Indices, index)
+	}
+	sort.Slice(ring.Indices, func(i, j int) bool { return ring.Indices[i] < ring.Indices[j] })
+	ring.Members = make([]crypto.RingMember, len(ring.Indices))
+	for i, index := range ring.Indices {
+		ring.Members[i] = *members[index]
+		if index == real {
+			ring.RealIndex = i
+		}
+	}
+	return ring, nil
+}
+
+type output struct {
+	crypto.RingMember
+	unlocked bool
+}
+
+// outputs returns the RingCT outputs of the global indices
+func (s *Selector) outputs(indices []uint64) ([]output, error) {
+	req := &daemon.GetOutsRequest{Outputs: make([]daemon.Outputs, len(indices))}
+	for i, index := range indices {
+		req.Outputs[i].Index = index
+	}
+	resp, err := s.client.GetOuts(req)
+	if err != nil {
+		return nil, fmt.Errorf("decoy: get outs: %w", err)
+	}
+	if len(resp.Outs) != len(indices) {
+		return nil, fmt.Errorf("%w: %d outputs for %d indices", ErrUnexpected, len(resp.Outs), len(indices))
+	}
+	outs := make([]output, len(indices))
+	for i, o := range resp.Outs {
+		outs[i].unlocked = o.Unlocked
+		if outs[i].Dest, err = crypto.ParseKey(o.Key); err != nil {
+			return nil, fmt.Errorf("%w: output %d: %v", ErrUnexpected, indices[i], err)
+		}
+		if outs[i].Mask, err = crypto.ParseKey(o.Mask); err != nil {
+			return nil, fmt.Errorf("%w: output %d: %v", ErrUnexpected, indices[i], err)
+		}
+	}
+	return outs, nil
+}
+
+func contains(indices []uint64, index uint64) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package decoy
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offsets returns the cumulative distribution of blocks of n outputs each
+func offsets(blocks int, n uint64) []uint64 {
+	o := make([]uint64, blocks)
+	for i := range o {
+		o[i] = uint64(i+1) * n
+	}
+	return o
+}
+
+func TestPicker(t *testing.T) {
+	_, err := NewPicker(offsets(SpendableAge, 10))
+	assert.True(t, errors.Is(err, ErrNotEnoughOutputs))
+	_, err = NewPicker(make([]uint64, 100))
+	assert.True(t, errors.Is(err, ErrNotEnoughOutputs))
+
+	dist := offsets(300000, 10)
+	p, err := newPicker(dist, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	assert.Equal(t, uint64((300000-SpendableAge)*10), p.NumOutputs())
+
+	var ages []int
+	for len(ages) < 10000 {
+		index, ok := p.Pick()
+		if !ok {
+			continue
+		}
+		require.True(t, index < p.NumOutputs())
+		ages = append(ages, 300000-1-int(index/10))
+	}
+	// decoys are young: half of them are less than a few days old
+	sort.Ints(ages)
+	median := ages[len(ages)/2]
+	assert.True(t, median > 300 && median < 5000, "%d", median)
+	assert.True(t, ages[0] >= SpendableAge, "%d", ages[0])
+	assert.True(t, ages[len(ages)-1] > 20000, "%d", ages[len(ages)-1])
+
+	// blocks without outputs are bad picks
+	dist = offsets(20000, 1)
+	for i := 15000; i < len(dist); i++ {
+		dist[i] = dist[14999]
+	}
+	p, err = newPicker(dist, rand.New(rand.NewSource(2)))
+	require.NoError(t, err)
+	bad := 0
+	for i := 0; i < 1000; i++ {
+		index, ok := p.Pick()
+		if !ok {
+			bad++
+			continue
+		}
+		assert.True(t, index < 15000)
+	}
+	assert.True(t, bad > 0)
+}
+
+func TestLowerBound(t *testing.T) {
+	// blocks of 2, 2, 0 and 2 outputs
+	offsets := []uint64{2, 4, 4, 6}
+	for _, c := range []struct {
+		index       uint64
+		lower, real int
+	}{
+		{0, 0, 0},
+		{1, 0, 0},
+		{2, 0, 1},
+		{3, 1, 1},
+		{4, 1, 3},
+		{5, 3, 3},
+		{6, 3, 4},
+	} {
+		assert.Equal(t, c.lower, lowerBound(offsets, c.index), "index %d", c.index)
+		assert.Equal(t, c.real, block(offsets, c.index), "index %d", c.index)
+	}
+}
+
+// fakeDaemon is a stand-in daemon serving a chain of random outputs
+type fakeDaemon struct {
+	daemon.Client
+
+	blocks  int
+	outputs map[uint64]daemon.Outs
+	locked  map[uint64]bool
+	fetched []uint64
+	txs     map[string]daemon.Transaction
+}
+
+func (d *fakeDaemon) GetTransactions(req *daemon.GetTransactionsRequest) (*daemon.GetTransactionsResponse, error) {
+	if !req.DecodeAsJSON {
+		return nil, errors.New("unexpected request")
+	}
+	resp := &daemon.GetTransactionsResponse{Status: daemon.RPCStatusOk}
+	for _, hash := range req.TxsHashes {
+		tx, ok := d.txs[hash]
+		if !ok {
+			resp.MissedTx = append(resp.MissedTx, hash)
+			continue
+		}
+		resp.Txs = append(resp.Txs, tx)
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) GetOutputDistribution(req *daemon.GetOutputDistributionRequest) (*daemon.GetOutputDistributionResponse, error) {
+	if len(req.Amounts) != 1 || req.Amounts[0] != 0 || !req.Cumulative {
+		return nil, errors.New("unexpected request")
+	}
+	return &daemon.GetOutputDistributionResponse{Distributions: []daemon.OuputDistribution{{Distribution: offsets(d.blocks, 2)}}}, nil
+}
+
+func (d *fakeDaemon) GetOuts(req *daemon.GetOutsRequest) (*daemon.GetOutsResponse, error) {
+	resp := &daemon.GetOutsResponse{}
+	for _, o := range req.Outputs {
+		d.fetched = append(d.fetched, o.Index)
+		out, ok := d.outputs[o.Index]
+		if !ok {
+			dest, _ := crypto.NewSecretKey().PublicKey()
+			mask, _ := crypto.Commit(o.Index, crypto.NewSecretKey())
+			out = daemon.Outs{Key: dest.String(), Mask: mask.String(), Unlocked: !d.locked[o.Index]}
+			d.outputs[o.Index] = out
+		}
+		resp.Outs = append(resp.Outs, out)
+	}
+	return resp, nil
+}
+
+func TestSelect(t *testing.T) {
+	d := &fakeDaemon{blocks: 2000, outputs: map[uint64]daemon.Outs{}, locked: map[uint64]bool{}}
+	// the most recent outputs are locked
+	for i := uint64(3900); i < 4000; i++ {
+		d.locked[i] = true
+	}
+	s := newSelector(d, 0, rand.New(rand.NewSource(3)))
+	real := []uint64{3950, 100, 3500}
+	rings, err := s.Select(real)
+	require.NoError(t, err)
+	require.Len(t, rings, 3)
+	for i, ring := range rings {
+		assert.Len(t, ring.Indices, DefaultRingSize)
+		assert.Len(t, ring.Members, DefaultRingSize)
+		assert.True(t, sort.SliceIsSorted(ring.Indices, func(a, b int) bool { return ring.Indices[a] < ring.Indices[b] }))
+		assert.Equal(t, real[i], ring.Indices[ring.RealIndex])
+		for j, index := range ring.Indices {
+			if j > 0 {
+				assert.NotEqual(t, ring.Indices[j-1], index)
+			}
+			key, _ := crypto.ParseKey(d.outputs[index].Key)
+			assert.Equal(t, key, ring.Members[j].Dest)
+			if j == ring.RealIndex {
+				continue
+			}
+			assert.False(t, d.locked[index], "%d", index)
+			assert.NotContains(t, real, index)
+		}
+	}
+
+	// too few outputs for the rings
+	d = &fakeDaemon{blocks: SpendableAge + 8, outputs: map[uint64]daemon.Outs{}}
+	_, err = newSelector(d, 0, rand.New(rand.NewSource(4))).Select([]uint64{0})
+	assert.True(t, errors.Is(err, ErrNotEnoughOutputs), "%v", err)
+}
+
+func TestScore(t *testing.T) {
+	dist := offsets(300000, 10)
+	a := newAuditor(nil, DefaultSamples, rand.New(rand.NewSource(5)))
+	picker, err := newPicker(dist, rand.New(rand.NewSource(6)))
+	require.NoError(t, err)
+
+	// rings picked as wallet2 does are rarely weak
+	var rings [][]uint64
+	for len(rings) < 50 {
+		var ring []uint64
+		for len(ring) < DefaultRingSize {
+			if index, ok := picker.Pick(); ok && !contains(ring, index) {
+				ring = append(ring, index)
+			}
+		}
+		sort.Slice(ring, func(i, j int) bool { return ring[i] < ring[j] })
+		rings = append(rings, ring)
+	}
+	weak := 0
+	for _, ring := range rings {
+		scores, err := a.Score(dist, [][]uint64{ring})
+		require.NoError(t, err)
+		assert.Len(t, scores[0].Quantiles, DefaultRingSize)
+		if len(scores[0].Weaknesses) != 0 {
+			weak++
+		}
+	}
+	assert.True(t, weak < 5, "%d", weak)
+
+	// old decoys picked uniformly, with a recent real spend
+	var ring []uint64
+	for i := uint64(0); i < DefaultRingSize-1; i++ {
+		ring = append(ring, 1000+i*100000)
+	}
+	ring = append(ring, picker.NumOutputs()-30)
+	// a smaller ring sharing members with the first
+	small := []uint64{1000, 2000, 3000}
+	scores, err := a.Score(dist, [][]uint64{ring, small})
+	require.NoError(t, err)
+	assert.Equal(t, []Weakness{WeaknessNewestBias, WeaknessDeviation, WeaknessSharedMember}, scores[0].Weaknesses)
+	assert.True(t, scores[0].NewestBias < 1e-6, "%v", scores[0].NewestBias)
+	assert.Equal(t, uint64(300000-1000/10), scores[0].Ages[0])
+	assert.Equal(t, uint64(SpendableAge+3), scores[0].Ages[DefaultRingSize-1])
+	assert.Contains(t, scores[1].Weaknesses, WeaknessSmallRing)
+	assert.Contains(t, scores[1].Weaknesses, WeaknessSharedMember)
+
+	_, err = a.Score(dist[:SpendableAge], rings)
+	assert.True(t, errors.Is(err, ErrNotEnoughOutputs), "%v", err)
+}
+
+func TestAudit(t *testing.T) {
+	asJSON := func(rings ...[]uint64) string {
+		type vin struct {
+			Key struct {
+				KeyOffsets []uint64 `json:"key_offsets"`
+			} `json:"key"`
+		}
+		var decoded struct {
+			Vin []vin `json:"vin"`
+		}
+		for _, ring := range rings {
+			var in vin
+			var last uint64
+			for _, index := range ring {
+				in.Key.KeyOffsets = append(in.Key.KeyOffsets, index-last)
+				last = index
+			}
+			decoded.Vin = append(decoded.Vin, in)
+		}
+		b, _ := json.Marshal(decoded)
+		return string(b)
+	}
+	d := &fakeDaemon{blocks: 2000, txs: map[string]daemon.Transaction{
+		"mined": {TxHash: "mined", BlockHeight: 1500, AsJSON: asJSON([]uint64{10, 500, 2970, 2980})},
+		"pool":  {TxHash: "pool", InPool: true, AsJSON: asJSON([]uint64{1, 2}, []uint64{2, 3970})},
+		"bad":   {TxHash: "bad", AsJSON: "{"},
+		"above": {TxHash: "above", BlockHeight: 3000, AsJSON: asJSON([]uint64{1, 2})},
+	}}
+	a := newAuditor(d, 1000, rand.New(rand.NewSource(7)))
+	audits, err := a.Audit("mined", "pool")
+	require.NoError(t, err)
+	require.Len(t, audits, 2)
+	assert.Equal(t, uint64(1500), audits[0].Height)
+	assert.Equal(t, []uint64{10, 500, 2970, 2980}, audits[0].Rings[0].Indices)
+	assert.Equal(t, []uint64{1500 - 5, 1500 - 250, 1500 - 1485, 1500 - 1490}, audits[0].Rings[0].Ages)
+	assert.Equal(t, uint64(2000), audits[1].Height)
+	require.Len(t, audits[1].Rings, 2)
+	assert.Contains(t, audits[1].Rings[1].Weaknesses, WeaknessSharedMember)
+	assert.True(t, audits[1].Weak())
+
+	for _, hash := range []string{"missing", "bad", "above"} {
+		_, err = a.Audit(hash)
+		assert.True(t, errors.Is(err, ErrUnexpected), "%s: %v", hash, err)
+	}
+}
@@ -0,0 +1,153 @@
+package decoy
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// parameters of the gamma picker of wallet2
+const (
+	// Outputs of the last SpendableAge blocks cannot be spent.
+	SpendableAge = 10
+	blockTime    = 120
+	gammaShape   = 19.28
+	gammaScale   = 1 / 1.61
+	// the picker draws output ages in seconds, the youngest spendable
+	// outputs being SpendableAge blocks old
+	unlockTime = SpendableAge * blockTime
+	// ages drawn below unlockTime are replaced by a uniform age in the
+	// recent spend window
+	recentSpendWindow = 15 * blockTime
+	blocksInAYear     = 86400 * 365 / blockTime
+)
+
+// Picker draws global indices of RingCT outputs as wallet2 does: the age
+// of a decoy follows a gamma distribution fitted to the age of real spends,
+// converted to an output index with the average time between outputs of
+// the last year.
+type Picker struct {
+	offsets           []uint64
+	numOutputs        uint64
+	averageOutputTime float64
+	rnd               *rand.Rand
+}
+
+// NewPicker returns a Picker over the cumulative number of RingCT outputs
+// per block, the Distribution of daemon.GetOutputDistribution with
+// Cumulative set and amount 0.
+func NewPicker(offsets []uint64) (*Picker, error) {
+	return newPicker(offsets, rand.New(cryptoSource{}))
+}
+
+func newPicker(offsets []uint64, rnd *rand.Rand) (*Picker, error) {
+	if len(offsets) <= SpendableAge {
+		return nil, fmt.Errorf("%w: %d blocks in the distribution", ErrNotEnoughOutputs, len(offsets))
+	}
+	blocks := len(offsets)
+	if blocks > blocksInAYear {
+		blocks = blocksInAYear
+	}
+	outputs := offsets[len(offsets)-1]
+	if blocks < len(offsets) {
+		outputs -= offsets[len(offsets)-blocks-1]
+	}
+	p := &Picker{
+		offsets:    offsets[:len(offsets)-SpendableAge],
+		numOutputs: offsets[len(offsets)-SpendableAge-1],
+		rnd:        rnd,
+	}
+	if p.numOutputs == 0 || outputs == 0 {
+		return nil, fmt.Errorf("%w: no RingCT outputs", ErrNotEnoughOutputs)
+	}
+	p.averageOutputTime = float64(blockTime*blocks) / float64(outputs)
+	return p, nil
+}
+
+// NumOutputs returns the number of spendable outputs, the bound of the
+// picked indices.
+func (p *Picker) NumOutputs() uint64 {
+	return p.numOutputs
+}
+
+// Pick draws the global index of an output. It returns false for a bad
+// pick, older than the first output or in a block without outputs, which
+// should be drawn again.
+func (p *Picker) Pick() (uint64, bool) {
+	x := math.Exp(p.gamma())
+	if x > unlockTime {
+		x -= unlockTime
+	} else {
+		x = float64(p.rnd.Int63n(recentSpendWindow))
+	}
+	index := uint64(x / p.averageOutputTime)
+	if index >= p.numOutputs {
+		return 0, false
+	}
+	index = p.numOutputs - 1 - index
+
+	b := lowerBound(p.offsets, index)
+	var first uint64
+	if b > 0 {
+		first = p.offsets[b-1]
+	}
+	n := p.offsets[b] - first
+	if n == 0 {
+		return 0, false
+	}
+	return first + uint64(p.rnd.Int63n(int64(n))), true
+}
+
+// lowerBound returns the first block with at least index outputs, as
+// std::lower_bound in the gamma picker of wallet2: an index equal to the
+// cumulative outputs of a block picks in that block, not in the next one
+// which holds the output.
+func lowerBound(offsets []uint64, index uint64) int {
+	return sort.Search(len(offsets), func(b int) bool { return offsets[b] >= index })
+}
+
+// block returns the block of the output at index, the first block with
+// more outputs than index, or len(offsets) if there is none
+func block(offsets []uint64, index uint64) int {
+	return sort.Search(len(offsets), func(b int) bool { return offsets[b] > index })
+}
+
+// gamma draws from the gamma distribution of wallet2, with the method of
+// Marsaglia and Tsang
+func (p *Picker) gamma() float64 {
+	d := gammaShape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := p.rnd.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := p.rnd.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v * gammaScale
+		}
+	}
+}
+
+// cryptoSource is a rand.Source reading crypto/rand, as decoys must not be
+// predictable
+type cryptoSource struct{}
+
+func (cryptoSource) Seed(int64) {}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
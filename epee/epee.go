@@ -0,0 +1,647 @@
+// Package epee implements the epee portable storage format used by
+// monerod for its binary (.bin) RPC endpoints and the levin P2P protocol.
+//
+// Struct fields are mapped by their `epee` tag, falling back to the `json` tag.
+// Integers, floats, bools, strings, byte slices/arrays (as strings),
+// structs (as objects) and slices (as arrays) are supported.
+package epee
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Signature is the header of every portable storage payload
+var Signature = []byte{0x01, 0x11, 0x01, 0x01, 0x01, 0x01, 0x02, 0x01, 0x01}
+
+// Entry types
+const (
+	TypeInt64  byte = 1
+	TypeInt32  byte = 2
+	TypeInt16  byte = 3
+	TypeInt8   byte = 4
+	TypeUint64 byte = 5
+	TypeUint32 byte = 6
+	TypeUint16 byte = 7
+	TypeUint8  byte = 8
+	TypeDouble byte = 9
+	TypeString byte = 10
+	TypeBool   byte = 11
+	TypeObject byte = 12
+	TypeArray  byte = 13
+
+	// FlagArray is or'ed with an entry type to mark an array of that type
+	FlagArray byte = 0x80
+)
+
+// maxDepth limits nesting of objects and arrays when decoding
+const maxDepth = 100
+
+var (
+	// ErrSignature is returned when a payload does not start with Signature
+	ErrSignature = errors.New("epee: invalid signature")
+	// ErrTruncated is returned when a payload ends unexpectedly
+	ErrTruncated = errors.New("epee: truncated payload")
+)
+
+//#####################
+// Varints
+//#####################
+
+// PutVarint appends an epee varint to buf.
+// The two low bits of the first byte hold the size of the integer.
+func PutVarint(buf []byte, v uint64) ([]byte, error) {
+	var b [8]byte
+	switch {
+	case v <= 63:
+		return append(buf, byte(v<<2)), nil
+	case v <= 16383:
+		binary.LittleEndian.PutUint16(b[:], uint16(v<<2|1))
+		return append(buf, b[:2]...), nil
+	case v <= 1073741823:
+		binary.LittleEndian.PutUint32(b[:], uint32(v<<2|2))
+		return append(buf, b[:4]...), nil
+	case v <= 4611686018427387903:
+		binary.LittleEndian.PutUint64(b[:], v<<2|3)
+		return append(buf, b[:]...), nil
+	}
+	return buf, fmt.Errorf("epee: varint %d too large", v)
+}
+
+// ReadVarint reads an epee varint from r.
+func ReadVarint(r io.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return 0, truncated(err)
+	}
+	size := 1 << (b[0] & 3)
+	if size > 1 {
+		if _, err := io.ReadFull(r, b[1:size]); err != nil {
+			return 0, truncated(err)
+		}
+	}
+	return binary.LittleEndian.Uint64(b[:]) >> 2, nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
+
+//#####################
+// Encoding
+//#####################
+
+// Marshal returns the portable storage encoding of v,
+// which must be a struct or a pointer to a struct.
+// A nil pointer is encoded as the zero value of the struct.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv = reflect.New(rv.Type().Elem())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("epee: cannot marshal %T, need a struct", v)
+	}
+	buf := append([]byte{}, Signature...)
+	return appendSection(buf, rv)
+}
+
+func appendSection(buf []byte, rv reflect.Value) ([]byte, error) {
+	fields := structFields(rv.Type())
+	present := make([]field, 0, len(fields))
+	for _, f := range fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			continue
+		}
+		present = append(present, f)
+	}
+
+	buf, err := PutVarint(buf, uint64(len(present)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range present {
+		buf = append(buf, byte(len(f.name)))
+		buf = append(buf, f.name...)
+		if buf, err = appendEntry(buf, rv.FieldByIndex(f.index)); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return buf, nil
+}
+
+func appendEntry(buf []byte, rv reflect.Value) ([]byte, error) {
+	rv = reflect.Indirect(rv)
+	typ, err := entryType(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	if typ&FlagArray == 0 {
+		return appendValue(append(buf, typ), typ, rv)
+	}
+	buf = append(buf, typ)
+	if buf, err = PutVarint(buf, uint64(rv.Len())); err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if buf, err = appendValue(buf, typ&^FlagArray, reflect.Indirect(rv.Index(i))); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendValue(buf []byte, typ byte, rv reflect.Value) ([]byte, error) {
+	var b [8]byte
+	switch typ {
+	case TypeInt64:
+		binary.LittleEndian.PutUint64(b[:], uint64(rv.Int()))
+		return append(buf, b[:8]...), nil
+	case TypeInt32:
+		binary.LittleEndian.PutUint32(b[:], uint32(rv.Int()))
+		return append(buf, b[:4]...), nil
+	case TypeInt16:
+		binary.LittleEndian.PutUint16(b[:], uint16(rv.Int()))
+		return append(buf, b[:2]...), nil
+	case TypeInt8:
+		return append(buf, byte(rv.Int())), nil
+	case TypeUint64:
+		binary.LittleEndian.PutUint64(b[:], rv.Uint())
+		return append(buf, b[:8]...), nil
+	case TypeUint32:
+		binary.LittleEndian.PutUint32(b[:], uint32(rv.Uint()))
+		return append(buf, b[:4]...), nil
+	case TypeUint16:
+		binary.LittleEndian.PutUint16(b[:], uint16(rv.Uint()))
+		return append(buf, b[:2]...), nil
+	case TypeUint8:
+		return append(buf, byte(rv.Uint())), nil
+	case TypeDouble:
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(rv.Float()))
+		return append(buf, b[:8]...), nil
+	case TypeBool:
+		if rv.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case TypeString:
+		var data []byte
+		switch rv.Kind() {
+		case reflect.String:
+			data = []byte(rv.String())
+		case reflect.Array:
+			data = make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+		default:
+			data = rv.Bytes()
+		}
+		buf, err := PutVarint(buf, uint64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		return append(buf, data...), nil
+	case TypeObject:
+		return appendSection(buf, rv)
+	}
+	return nil, fmt.Errorf("epee: unsupported type %d", typ)
+}
+
+// entryType maps a Go type to its portable storage type
+func entryType(t reflect.Type) (byte, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int64, reflect.Int:
+		return TypeInt64, nil
+	case reflect.Int32:
+		return TypeInt32, nil
+	case reflect.Int16:
+		return TypeInt16, nil
+	case reflect.Int8:
+		return TypeInt8, nil
+	case reflect.Uint64, reflect.Uint:
+		return TypeUint64, nil
+	case reflect.Uint32:
+		return TypeUint32, nil
+	case reflect.Uint16:
+		return TypeUint16, nil
+	case reflect.Uint8:
+		return TypeUint8, nil
+	case reflect.Float64, reflect.Float32:
+		return TypeDouble, nil
+	case reflect.Bool:
+		return TypeBool, nil
+	case reflect.String:
+		return TypeString, nil
+	case reflect.Struct:
+		return TypeObject, nil
+	case reflect.Array, reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return TypeString, nil
+		}
+		if t.Kind() == reflect.Array {
+			break
+		}
+		elem, err := entryType(t.Elem())
+		if err != nil {
+			return 0, err
+		}
+		if elem&FlagArray != 0 {
+			return 0, fmt.Errorf("epee: nested arrays are not supported")
+		}
+		return elem | FlagArray, nil
+	}
+	return 0, fmt.Errorf("epee: unsupported type %v", t)
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array:
+		return v.Len() == 0 || v.IsZero()
+	case reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+//#####################
+// Decoding
+//#####################
+
+// Unmarshal parses a portable storage payload into v,
+// which must be a pointer to a struct or to an interface{}.
+// Unknown entries are skipped and integers are converted
+// between sizes as long as the value fits.
+// Decoding into an interface{} yields map[string]interface{},
+// []interface{}, int64, uint64, float64, string and bool values.
+func Unmarshal(data []byte, v interface{}) error {
+	if !bytes.HasPrefix(data, Signature) {
+		return ErrSignature
+	}
+	return NewDecoder(bytes.NewReader(data[len(Signature):])).Decode(v)
+}
+
+// Decoder reads a portable storage section without the Signature header.
+type Decoder struct {
+	r     *bytes.Reader
+	depth int
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r *bytes.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads one section into v.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("epee: cannot unmarshal into %T", v)
+	}
+	return d.section(rv.Elem())
+}
+
+func (d *Decoder) section(rv reflect.Value) error {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return fmt.Errorf("epee: payload nested too deeply")
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+
+	var fields map[string]field
+	var generic map[string]interface{}
+	switch {
+	case rv.Kind() == reflect.Struct:
+		fields = make(map[string]field)
+		for _, f := range structFields(rv.Type()) {
+			fields[f.name] = f
+		}
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		generic = make(map[string]interface{})
+		rv.Set(reflect.ValueOf(generic))
+	default:
+		return fmt.Errorf("epee: cannot unmarshal object into %v", rv.Type())
+	}
+
+	n, err := ReadVarint(d.r)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		name, err := d.name()
+		if err != nil {
+			return err
+		}
+		if generic != nil {
+			var value interface{}
+			if err := d.entry(reflect.ValueOf(&value).Elem()); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			generic[name] = value
+			continue
+		}
+		var target reflect.Value
+		if f, ok := fields[name]; ok {
+			target = rv.FieldByIndex(f.index)
+		}
+		if err := d.entry(target); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) name() (string, error) {
+	l, err := d.r.ReadByte()
+	if err != nil {
+		return "", truncated(err)
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", truncated(err)
+	}
+	return string(b), nil
+}
+
+// entry reads a typed entry into rv, or skips it if rv is invalid
+func (d *Decoder) entry(rv reflect.Value) error {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return truncated(err)
+	}
+	if typ&FlagArray == 0 {
+		return d.value(typ, rv)
+	}
+	n, err := ReadVarint(d.r)
+	if err != nil {
+		return err
+	}
+	if n > uint64(d.r.Len()) {
+		return ErrTruncated
+	}
+	typ &^= FlagArray
+
+	if !rv.IsValid() {
+		for i := uint64(0); i < n; i++ {
+			if err := d.value(typ, rv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		list := make([]interface{}, n)
+		for i := range list {
+			if err := d.value(typ, reflect.ValueOf(&list[i]).Elem()); err != nil {
+				return err
+			}
+		}
+		rv.Set(reflect.ValueOf(list))
+		return nil
+	}
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("epee: cannot unmarshal array into %v", rv.Type())
+	}
+	slice := reflect.MakeSlice(rv.Type(), int(n), int(n))
+	for i := 0; i < int(n); i++ {
+		if err := d.value(typ, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+func (d *Decoder) value(typ byte, rv reflect.Value) error {
+	if rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	var b [8]byte
+	switch typ {
+	case TypeInt64, TypeInt32, TypeInt16, TypeInt8:
+		size := intSize(typ)
+		if _, err := io.ReadFull(d.r, b[:size]); err != nil {
+			return truncated(err)
+		}
+		u := binary.LittleEndian.Uint64(b[:])
+		shift := uint(64 - 8*size)
+		return setInt(rv, int64(u<<shift)>>shift)
+	case TypeUint64, TypeUint32, TypeUint16, TypeUint8:
+		size := intSize(typ)
+		if _, err := io.ReadFull(d.r, b[:size]); err != nil {
+			return truncated(err)
+		}
+		return setUint(rv, binary.LittleEndian.Uint64(b[:]))
+	case TypeDouble:
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return truncated(err)
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+		switch {
+		case !rv.IsValid():
+		case rv.Kind() == reflect.Float64 || rv.Kind() == reflect.Float32:
+			rv.SetFloat(f)
+		case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+			rv.Set(reflect.ValueOf(f))
+		default:
+			return fmt.Errorf("epee: cannot unmarshal double into %v", rv.Type())
+		}
+		return nil
+	case TypeBool:
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return truncated(err)
+		}
+		switch {
+		case !rv.IsValid():
+		case rv.Kind() == reflect.Bool:
+			rv.SetBool(c != 0)
+		case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+			rv.Set(reflect.ValueOf(c != 0))
+		default:
+			return fmt.Errorf("epee: cannot unmarshal bool into %v", rv.Type())
+		}
+		return nil
+	case TypeString:
+		l, err := ReadVarint(d.r)
+		if err != nil {
+			return err
+		}
+		if l > uint64(d.r.Len()) {
+			return ErrTruncated
+		}
+		data := make([]byte, l)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return truncated(err)
+		}
+		return setBytes(rv, data)
+	case TypeObject:
+		if !rv.IsValid() {
+			var skip interface{}
+			rv = reflect.ValueOf(&skip).Elem()
+		}
+		return d.section(rv)
+	}
+	return fmt.Errorf("epee: unknown type %d", typ)
+}
+
+func intSize(typ byte) int {
+	switch typ {
+	case TypeInt64, TypeUint64:
+		return 8
+	case TypeInt32, TypeUint32:
+		return 4
+	case TypeInt16, TypeUint16:
+		return 2
+	}
+	return 1
+}
+
+func setInt(rv reflect.Value, i int64) error {
+	switch {
+	case !rv.IsValid():
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		if rv.OverflowInt(i) {
+			return fmt.Errorf("epee: %d overflows %v", i, rv.Type())
+		}
+		rv.SetInt(i)
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uint64:
+		if i < 0 || rv.OverflowUint(uint64(i)) {
+			return fmt.Errorf("epee: %d overflows %v", i, rv.Type())
+		}
+		rv.SetUint(uint64(i))
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(i))
+	default:
+		return fmt.Errorf("epee: cannot unmarshal integer into %v", rv.Type())
+	}
+	return nil
+}
+
+func setUint(rv reflect.Value, u uint64) error {
+	switch {
+	case !rv.IsValid():
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uint64:
+		if rv.OverflowUint(u) {
+			return fmt.Errorf("epee: %d overflows %v", u, rv.Type())
+		}
+		rv.SetUint(u)
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		if u > math.MaxInt64 || rv.OverflowInt(int64(u)) {
+			return fmt.Errorf("epee: %d overflows %v", u, rv.Type())
+		}
+		rv.SetInt(int64(u))
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(u))
+	default:
+		return fmt.Errorf("epee: cannot unmarshal integer into %v", rv.Type())
+	}
+	return nil
+}
+
+func setBytes(rv reflect.Value, data []byte) error {
+	switch {
+	case !rv.IsValid():
+	case rv.Kind() == reflect.String:
+		rv.SetString(string(data))
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(data)
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		if rv.Len() != len(data) {
+			return fmt.Errorf("epee: cannot unmarshal %d bytes into %v", len(data), rv.Type())
+		}
+		reflect.Copy(rv, reflect.ValueOf(data))
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(string(data)))
+	default:
+		return fmt.Errorf("epee: cannot unmarshal string into %v", rv.Type())
+	}
+	return nil
+}
+
+//#####################
+// Struct fields
+//#####################
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields lists the encoded fields of a struct type,
+// flattening embedded structs
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag, ok := sf.Tag.Lookup("epee")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if sf.Anonymous && parts[0] == "" && sf.Type.Kind() == reflect.Struct {
+			for _, f := range structFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		f := field{name: parts[0], index: []int{i}}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
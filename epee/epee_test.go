@@ -0,0 +1,163 @@
+package epee
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarint(t *testing.T) {
+	type test struct {
+		input    uint64
+		expected []byte
+	}
+
+	tests := []test{
+		{input: 0, expected: []byte{0x00}},
+		{input: 63, expected: []byte{0xfc}},
+		{input: 64, expected: []byte{0x01, 0x01}},
+		{input: 16383, expected: []byte{0xfd, 0xff}},
+		{input: 16384, expected: []byte{0x02, 0x00, 0x01, 0x00}},
+		{input: 1073741824, expected: []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}},
+	}
+
+	for _, tc := range tests {
+		out, err := PutVarint(nil, tc.input)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, out)
+
+		v, err := ReadVarint(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.input, v)
+	}
+
+	_, err := PutVarint(nil, 1<<62)
+	assert.Error(t, err)
+	_, err = ReadVarint(bytes.NewReader([]byte{0x01}))
+	assert.Equal(t, ErrTruncated, err)
+}
+
+type inner struct {
+	Hash [4]byte `epee:"hash"`
+	Ok   bool    `epee:"ok"`
+}
+
+type outer struct {
+	Height   uint64   `json:"height"`
+	Offset   int32    `epee:"offset"`
+	Name     string   `epee:"name,omitempty"`
+	Blob     []byte   `epee:"blob"`
+	Amounts  []uint64 `epee:"amounts"`
+	Rate     float64  `epee:"rate"`
+	Inner    inner    `epee:"inner"`
+	List     []inner  `epee:"list"`
+	Optional *inner   `epee:"optional"`
+	Skipped  string   `epee:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("matches monerod encoding", func(t *testing.T) {
+		data, err := Marshal(&struct {
+			Height uint64   `epee:"height"`
+			Bin    bool     `epee:"binary"`
+			Txs    []string `epee:"txs"`
+		}{Height: 1, Bin: true, Txs: []string{"ab"}})
+		assert.NoError(t, err)
+		expected := append(append([]byte{}, Signature...),
+			0x0c,
+			6, 'h', 'e', 'i', 'g', 'h', 't', TypeUint64, 1, 0, 0, 0, 0, 0, 0, 0,
+			6, 'b', 'i', 'n', 'a', 'r', 'y', TypeBool, 1,
+			3, 't', 'x', 's', TypeString|FlagArray, 0x04, 0x08, 'a', 'b',
+		)
+		assert.Equal(t, expected, data)
+	})
+
+	t.Run("round trips", func(t *testing.T) {
+		in := outer{
+			Height:  1234567,
+			Offset:  -42,
+			Blob:    []byte{0, 1, 2},
+			Amounts: []uint64{1, 1 << 40},
+			Rate:    0.5,
+			Inner:   inner{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}, Ok: true},
+			List:    []inner{{Ok: true}, {Hash: [4]byte{1}}},
+			Skipped: "foo",
+		}
+		data, err := Marshal(in)
+		assert.NoError(t, err)
+
+		var out outer
+		assert.NoError(t, Unmarshal(data, &out))
+		in.Skipped = ""
+		assert.Equal(t, in, out)
+	})
+
+	t.Run("rejects unsupported types", func(t *testing.T) {
+		_, err := Marshal(1)
+		assert.Error(t, err)
+		_, err = Marshal(struct {
+			M map[string]string `epee:"m"`
+		}{M: map[string]string{}})
+		assert.Error(t, err)
+		_, err = Marshal(struct {
+			A [][]uint64 `epee:"a"`
+		}{})
+		assert.Error(t, err)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	data, err := Marshal(outer{
+		Height:  7,
+		Offset:  -1,
+		Name:    "node",
+		Amounts: []uint64{3},
+		List:    []inner{{Ok: true}},
+	})
+	assert.NoError(t, err)
+
+	t.Run("converts integers and skips unknown entries", func(t *testing.T) {
+		var out struct {
+			Height uint8  `epee:"height"`
+			Offset int64  `epee:"offset"`
+			Name   []byte `epee:"name"`
+		}
+		assert.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, uint8(7), out.Height)
+		assert.Equal(t, int64(-1), out.Offset)
+		assert.Equal(t, []byte("node"), out.Name)
+	})
+
+	t.Run("decodes into interface", func(t *testing.T) {
+		var out interface{}
+		assert.NoError(t, Unmarshal(data, &out))
+		m := out.(map[string]interface{})
+		assert.Equal(t, uint64(7), m["height"])
+		assert.Equal(t, int64(-1), m["offset"])
+		assert.Equal(t, "node", m["name"])
+		assert.Equal(t, []interface{}{uint64(3)}, m["amounts"])
+		assert.Equal(t, true, m["list"].([]interface{})[0].(map[string]interface{})["ok"])
+	})
+
+	t.Run("fails on bad payloads", func(t *testing.T) {
+		var out outer
+		assert.Equal(t, ErrSignature, Unmarshal([]byte("{}"), &out))
+		assert.True(t, errors.Is(Unmarshal(data[:len(data)-3], &out), ErrTruncated))
+		assert.Error(t, Unmarshal(data, out))
+
+		var small struct {
+			Offset uint32 `epee:"offset"`
+		}
+		assert.Error(t, Unmarshal(data, &small), "negative into unsigned")
+		var wrong struct {
+			Name int `epee:"name"`
+		}
+		assert.Error(t, Unmarshal(data, &wrong))
+		var hash struct {
+			Name [32]byte `epee:"name"`
+		}
+		assert.Error(t, Unmarshal(data, &hash))
+	})
+}
@@ -1,15 +1,24 @@
 package gonero
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
-
-	"github.com/gabstv/httpdigest"
+	"strconv"
+	"strings"
 )
 
+// ErrFingerprintMismatch is returned when the certificate presented by
+// the RPC server does not match any of the pinned fingerprints.
+var ErrFingerprintMismatch = errors.New("tls: server certificate fingerprint not allowed")
+
 // RPCConfig holds the configuration of a monero RPC client.
 type RPCConfig struct {
 	Protocol      string
@@ -25,12 +34,57 @@ func (cfg RPCConfig) Address() string {
 	if p == "" {
 		p = "http"
 	}
-	return fmt.Sprintf("%s://%s:%d", p, cfg.Host, cfg.Port)
+	// IPv6 hosts must be enclosed in brackets
+	host := strings.TrimSuffix(strings.TrimPrefix(cfg.Host, "["), "]")
+	return fmt.Sprintf("%s://%s", p, net.JoinHostPort(host, strconv.FormatUint(uint64(cfg.Port), 10)))
+}
+
+// TLSConfig holds the TLS settings used to connect to a monero RPC server.
+//
+// By default the server certificate is verified against CAFile,
+// or the system roots if CAFile is empty.
+// Self-signed certificates, such as the ones monerod generates
+// by default, can be accepted by pinning their SHA-256 fingerprints.
+type TLSConfig struct {
+	// (Optional) PEM file of the certificate authorities to trust.
+	CAFile string
+	// (Optional) SHA-256 fingerprints of the accepted server certificates,
+	// hex encoded with or without colons, as passed to monerod's
+	// --rpc-ssl-allowed-fingerprints.
+	Fingerprints []string
+	// (Optional) PEM client certificate and key files for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// (Optional) Minimum TLS version, defaults to tls.VersionTLS12.
+	MinVersion uint16
+	// (Optional) Server name used to verify the certificate, defaults to the host.
+	ServerName string
+	// Skip all verification of the server certificate.
+	// This must be set explicitly and is only meant for testing.
+	Insecure bool
 }
 
 // NewRPCConfig creates a new RPCConfig struct
 // with a Transport for authentication if needed
 func NewRPCConfig(protocol, host string, port uint, username, password, caFile string) (*RPCConfig, error) {
+	return NewRPCConfigTLS(protocol, host, port, username, password, &TLSConfig{CAFile: caFile})
+}
+
+// NewRPCConfigTLS creates a new RPCConfig struct
+// using the given TLS settings for https connections
+func NewRPCConfigTLS(protocol, host string, port uint, username, password string, tlsCfg *TLSConfig) (*RPCConfig, error) {
+	var auth Auth
+	// Use http digest auth for RPC username:password
+	if username != "" {
+		auth = DigestAuth{Username: username, Password: NewSecret(password)}
+	}
+	return NewRPCConfigAuth(protocol, host, port, auth, tlsCfg)
+}
+
+// NewRPCConfigAuth creates a new RPCConfig struct
+// using the given authentication and TLS settings.
+// A nil auth disables authentication.
+func NewRPCConfigAuth(protocol, host string, port uint, auth Auth, tlsCfg *TLSConfig) (*RPCConfig, error) {
 
 	cfg := &RPCConfig{
 		Protocol: protocol,
@@ -39,43 +93,115 @@ func NewRPCConfig(protocol, host string, port uint, username, password, caFile s
 	}
 	// Add TLS configuration
 	if cfg.Protocol == "https" {
-		t, err := tlsTransport(caFile)
+		if tlsCfg == nil {
+			tlsCfg = &TLSConfig{}
+		}
+		t, err := tlsCfg.transport()
 		if err != nil {
 			return nil, err
 		}
 		cfg.Transport = t
 	}
-	// Use http digest auth for RPC username:password
-	if username != "" {
-		t := httpdigest.New(username, password)
+	if auth != nil {
 		// chain previous transport
-		t.Transport = cfg.Transport
-		// set updated transport
-		cfg.Transport = t
+		cfg.Transport = auth.Transport(cfg.Transport)
 	}
 	return cfg, nil
 }
 
-func tlsTransport(caFile string) (*http.Transport, error) {
-	var tlsConfig *tls.Config
+func (t *TLSConfig) transport() (*http.Transport, error) {
+	tlsConfig, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{TLSClientConfig: tlsConfig}, nil
+}
 
-	if caFile != "" {
+// Config builds a *tls.Config from the TLS settings.
+func (t *TLSConfig) Config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: t.MinVersion,
+		ServerName: t.ServerName,
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if t.CAFile != "" {
 		// Load CA cert
-		caCert, err := ioutil.ReadFile(caFile)
+		caCert, err := ioutil.ReadFile(t.CAFile)
 		if err != nil {
 			return nil, err
 		}
 		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
 
-		// Setup HTTPS client
-		tlsConfig = &tls.Config{
-			RootCAs: caCertPool,
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
 		}
-		tlsConfig.BuildNameToCertificate()
-	} else {
-		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
+	if len(t.Fingerprints) > 0 {
+		pins, err := parseFingerprints(t.Fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		// a pinned certificate is trusted on its own unless a CA
+		// was given too, in which case both checks must pass
+		tlsConfig.InsecureSkipVerify = t.CAFile == ""
+		tlsConfig.VerifyPeerCertificate = verifyFingerprint(pins)
+		return tlsConfig, nil
+	}
+
+	tlsConfig.InsecureSkipVerify = t.Insecure
+	return tlsConfig, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded certificate
+// in the colon separated hex format used by monerod.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func parseFingerprints(fingerprints []string) ([][]byte, error) {
+	pins := make([][]byte, 0, len(fingerprints))
+	for _, f := range fingerprints {
+		cleaned := strings.Replace(strings.TrimSpace(f), ":", "", -1)
+		pin, err := hex.DecodeString(cleaned)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint %q: %v", f, err)
+		}
+		if len(pin) != sha256.Size {
+			return nil, fmt.Errorf("invalid fingerprint %q: not a SHA-256 hash", f)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+func verifyFingerprint(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return ErrFingerprintMismatch
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+		return ErrFingerprintMismatch
 	}
-	return &http.Transport{TLSClientConfig: tlsConfig}, nil
 }
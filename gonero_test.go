@@ -1,7 +1,13 @@
 package gonero
 
 import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -18,6 +24,8 @@ func TestRPCConfigAddress(t *testing.T) {
 		{input: RPCConfig{Protocol: "http", Host: "localhost", Port: 18081}, expected: "http://localhost:18081"},
 		{input: RPCConfig{Protocol: "https", Host: "127.0.0.1", Port: 38081}, expected: "https://127.0.0.1:38081"},
 		{input: RPCConfig{Protocol: "", Host: "localhost", Port: 18081}, expected: "http://localhost:18081"},
+		{input: RPCConfig{Protocol: "https", Host: "::1", Port: 18089}, expected: "https://[::1]:18089"},
+		{input: RPCConfig{Protocol: "http", Host: "[2001:db8::1]", Port: 18081}, expected: "http://[2001:db8::1]:18081"},
 	}
 
 	for _, tc := range tests {
@@ -86,3 +94,120 @@ func TestNewRPCConfig(t *testing.T) {
 		assert.Equal(t, &os.PathError{Op: "open", Path: tc.caFile, Err: syscall.Errno(2)}, err)
 	}
 }
+
+func newTLSServer(t *testing.T, clientAuth tls.ClientAuthType) *httptest.Server {
+	cert, err := tls.LoadX509KeyPair("tests/cert.tls", "tests/key.tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if clientAuth != tls.NoClientCert && len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: clientAuth}
+	srv.StartTLS()
+	return srv
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := newTLSServer(t, tls.NoClientCert)
+	defer srv.Close()
+	fingerprint := Fingerprint(srv.TLS.Certificates[0].Certificate[0])
+
+	get := func(tlsCfg *TLSConfig) error {
+		host, port := splitTestServer(t, srv.URL)
+		cfg, err := NewRPCConfigTLS("https", host, port, "", "", tlsCfg)
+		if err != nil {
+			return err
+		}
+		resp, err := (&http.Client{Transport: cfg.Transport}).Get(cfg.Address())
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	t.Run("no silent InsecureSkipVerify", func(t *testing.T) {
+		cfg, err := NewRPCConfig("https", "localhost", 18081, "", "", "")
+		assert.NoError(t, err)
+		assert.False(t, cfg.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+		assert.Error(t, get(&TLSConfig{}))
+	})
+
+	t.Run("explicit insecure", func(t *testing.T) {
+		assert.NoError(t, get(&TLSConfig{Insecure: true}))
+	})
+
+	t.Run("pinned fingerprint", func(t *testing.T) {
+		assert.NoError(t, get(&TLSConfig{Fingerprints: []string{fingerprint}}))
+		assert.NoError(t, get(&TLSConfig{Fingerprints: []string{strings.ToLower(strings.Replace(fingerprint, ":", "", -1))}}))
+	})
+
+	t.Run("fingerprint mismatch", func(t *testing.T) {
+		other := strings.Repeat("00:", 31) + "00"
+		err := get(&TLSConfig{Fingerprints: []string{other}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), ErrFingerprintMismatch.Error())
+	})
+
+	t.Run("invalid fingerprint", func(t *testing.T) {
+		assert.Error(t, get(&TLSConfig{Fingerprints: []string{"zz"}}))
+		assert.Error(t, get(&TLSConfig{Fingerprints: []string{"00:11"}}))
+	})
+
+	t.Run("minimum version", func(t *testing.T) {
+		cfg, err := (&TLSConfig{}).Config()
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+		cfg, err = (&TLSConfig{MinVersion: tls.VersionTLS13}).Config()
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	})
+
+	t.Run("CA file without certificates", func(t *testing.T) {
+		_, err := (&TLSConfig{CAFile: "tests/key.tls"}).Config()
+		assert.Error(t, err)
+	})
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	srv := newTLSServer(t, tls.RequireAnyClientCert)
+	defer srv.Close()
+	host, port := splitTestServer(t, srv.URL)
+	fingerprint := Fingerprint(srv.TLS.Certificates[0].Certificate[0])
+
+	cfg, err := NewRPCConfigTLS("https", host, port, "", "", &TLSConfig{Fingerprints: []string{fingerprint}})
+	assert.NoError(t, err)
+	_, err = (&http.Client{Transport: cfg.Transport}).Get(cfg.Address())
+	assert.Error(t, err, "handshake fails without a client certificate")
+
+	cfg, err = NewRPCConfigTLS("https", host, port, "", "", &TLSConfig{
+		Fingerprints: []string{fingerprint},
+		CertFile:     "tests/cert.tls",
+		KeyFile:      "tests/key.tls",
+	})
+	assert.NoError(t, err)
+	resp, err := (&http.Client{Transport: cfg.Transport}).Get(cfg.Address())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	_, err = NewRPCConfigTLS("https", host, port, "", "", &TLSConfig{CertFile: "tests/cert.tls", KeyFile: "Foobar.tls"})
+	assert.Error(t, err)
+}
+
+func splitTestServer(t *testing.T, rawurl string) (string, uint) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.ParseUint(u.Port(), 10, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Hostname(), uint(port)
+}
@@ -0,0 +1,82 @@
+package invoice
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// invoiceResponse is an invoice with its payment URI
+type invoiceResponse struct {
+	*Invoice
+	URI string `json:"uri"`
+}
+
+// Handler returns the HTTP API of the manager, meant for merchant backends
+// and to be wrapped with their authentication:
+//
+//	POST /invoices       creates an invoice from a CreateRequest
+//	GET  /invoices       lists the invoices
+//	GET  /invoices/{id}  returns an invoice
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(m.serveHTTP)
+}
+
+func (m *Manager) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "invoices" && r.Method == http.MethodPost:
+		req := &CreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Amount == 0 {
+			writeError(w, http.StatusBadRequest, errors.New("amount must be positive"))
+			return
+		}
+		inv, err := m.Create(req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, &invoiceResponse{inv, inv.URI()})
+	case path == "invoices" && r.Method == http.MethodGet:
+		invoices, err := m.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		resp := make([]*invoiceResponse, len(invoices))
+		for i, inv := range invoices {
+			resp[i] = &invoiceResponse{inv, inv.URI()}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case strings.HasPrefix(path, "invoices/") && r.Method == http.MethodGet:
+		inv, err := m.Get(strings.TrimPrefix(path, "invoices/"))
+		if err == ErrNotFound {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &invoiceResponse{inv, inv.URI()})
+	case path == "invoices" || strings.HasPrefix(path, "invoices/"):
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
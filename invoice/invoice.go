@@ -0,0 +1,161 @@
+// Package invoice implements payment requests on top of wallet subaddresses.
+//
+// Each invoice gets a fresh subaddress of one account, either created by the
+// wallet with CreateAddress or derived offline from the address and private
+// view key. Incoming transfers found by a wallet.DepositMonitor are
+// aggregated per invoice, and merchant backends are told about status
+// changes through a small HTTP API and HMAC signed webhooks.
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Status is the payment status of an invoice
+type Status string
+
+const (
+	// StatusPending is an invoice which has not received anything yet
+	StatusPending Status = "pending"
+	// StatusUnderpaid is an invoice which received less than its amount
+	StatusUnderpaid Status = "underpaid"
+	// StatusPaid is an invoice which received its amount, waiting for confirmations
+	StatusPaid Status = "paid"
+	// StatusSettled is an invoice which received its exact amount with the
+	// required confirmations before expiring
+	StatusSettled Status = "settled"
+	// StatusOverpaid is a settled invoice which received more than its amount
+	StatusOverpaid Status = "overpaid"
+	// StatusExpired is an invoice which expired before receiving its amount
+	StatusExpired Status = "expired"
+	// StatusExpiredPaid is an invoice which received its amount with the
+	// required confirmations, but completed after it expired
+	StatusExpiredPaid Status = "expired_paid"
+)
+
+// Payment is an incoming transfer to the subaddress of an invoice
+type Payment struct {
+	// Transaction ID.
+	Txid string `json:"txid"`
+	// Amount received, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Height of the block including the transfer, 0 while in the pool.
+	Height uint64 `json:"height"`
+	// State of the transfer.
+	State wallet.DepositState `json:"state"`
+	// Time the transfer was first seen.
+	SeenAt time.Time `json:"seen_at"`
+}
+
+// counted tells whether the payment counts toward the received amount
+func (p *Payment) counted() bool {
+	return p.State != wallet.DepositReorged && p.State != wallet.DepositDropped
+}
+
+// confirmed tells whether the payment has the required confirmations
+func (p *Payment) confirmed() bool {
+	return p.State == wallet.DepositConfirmed || p.State == wallet.DepositUnlocked
+}
+
+// Invoice is a request for a payment to a dedicated subaddress
+type Invoice struct {
+	// Random identifier of the invoice.
+	ID string `json:"id"`
+	// Subaddress receiving the payment.
+	Address string `json:"address"`
+	// Account and subaddress index of Address.
+	SubaddrIndex wallet.SubaddressIndex `json:"subaddr_index"`
+	// Expected amount, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Free text of the merchant.
+	Description string `json:"description,omitempty"`
+	// Confirmations required to settle, 0 for the wallet's suggestion.
+	RequiredConfirmations uint64 `json:"required_confirmations"`
+	// Creation time of the invoice.
+	CreatedAt time.Time `json:"created_at"`
+	// Time from which payments completing the invoice are late.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Payment status.
+	Status Status `json:"status"`
+	// Amount of the payments seen, in atomic units.
+	Received gonero.AtomicXMR `json:"received"`
+	// Amount of the payments with the required confirmations, in atomic units.
+	Confirmed gonero.AtomicXMR `json:"confirmed"`
+	// Time the payments seen first reached Amount.
+	PaidAt *time.Time `json:"paid_at,omitempty"`
+	// Payments of the invoice, by deposit key.
+	Payments map[string]*Payment `json:"payments"`
+
+	// Last status delivered to the webhook.
+	NotifiedStatus Status `json:"notified_status,omitempty"`
+}
+
+// URI returns the monero: payment URI of the invoice.
+func (inv *Invoice) URI() string {
+	return fmt.Sprintf("monero:%s?tx_amount=%s", inv.Address, inv.Amount.Decimal())
+}
+
+// update recomputes the amounts and status of the invoice at time now
+func (inv *Invoice) update(now time.Time) {
+	inv.Received = 0
+	inv.Confirmed = 0
+	for _, p := range inv.Payments {
+		if !p.counted() {
+			continue
+		}
+		inv.Received += p.Amount
+		if p.confirmed() {
+			inv.Confirmed += p.Amount
+		}
+	}
+	if inv.Received < inv.Amount {
+		inv.PaidAt = nil
+	} else if inv.PaidAt == nil {
+		// the payment completing the invoice is the last one seen
+		var paidAt time.Time
+		for _, p := range inv.Payments {
+			if p.counted() && p.SeenAt.After(paidAt) {
+				paidAt = p.SeenAt
+			}
+		}
+		inv.PaidAt = &paidAt
+	}
+
+	expired := !now.Before(inv.ExpiresAt)
+	switch {
+	case inv.Confirmed >= inv.Amount && !inv.PaidAt.Before(inv.ExpiresAt):
+		inv.Status = StatusExpiredPaid
+	case inv.Confirmed > inv.Amount:
+		inv.Status = StatusOverpaid
+	case inv.Confirmed == inv.Amount:
+		inv.Status = StatusSettled
+	case inv.Received >= inv.Amount:
+		inv.Status = StatusPaid
+	case expired:
+		inv.Status = StatusExpired
+	case inv.Received > 0:
+		inv.Status = StatusUnderpaid
+	default:
+		inv.Status = StatusPending
+	}
+}
+
+// clone returns a deep copy of the invoice
+func (inv *Invoice) clone() *Invoice {
+	c := *inv
+	if inv.PaidAt != nil {
+		paidAt := *inv.PaidAt
+		c.PaidAt = &paidAt
+	}
+	c.Payments = make(map[string]*Payment, len(inv.Payments))
+	for k, p := range inv.Payments {
+		cp := *p
+		c.Payments[k] = &cp
+	}
+	return &c
+}
@@ -0,0 +1,91 @@
+package invoice
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvoiceUpdate(t *testing.T) {
+	created := time.Unix(1666000000, 0)
+	expires := created.Add(time.Hour)
+	before := created.Add(time.Minute)
+	after := expires.Add(time.Minute)
+
+	type payment struct {
+		amount gonero.AtomicXMR
+		state  wallet.DepositState
+		seen   time.Time
+	}
+	type test struct {
+		name      string
+		payments  []payment
+		now       time.Time
+		status    Status
+		received  gonero.AtomicXMR
+		confirmed gonero.AtomicXMR
+	}
+	tests := []test{
+		{"pending", nil, before, StatusPending, 0, 0},
+		{"expired", nil, after, StatusExpired, 0, 0},
+		{"underpaid", []payment{{400, wallet.DepositConfirmed, before}}, before, StatusUnderpaid, 400, 400},
+		{"expired underpaid", []payment{{400, wallet.DepositConfirmed, before}}, after, StatusExpired, 400, 400},
+		{"paid", []payment{{400, wallet.DepositConfirmed, before}, {600, wallet.DepositSeen, before}}, after, StatusPaid, 1000, 400},
+		{"settled", []payment{{400, wallet.DepositUnlocked, before}, {600, wallet.DepositConfirmed, before}}, after, StatusSettled, 1000, 1000},
+		{"overpaid", []payment{{1200, wallet.DepositConfirmed, before}}, before, StatusOverpaid, 1200, 1200},
+		{"expired paid", []payment{{400, wallet.DepositConfirmed, before}, {600, wallet.DepositConfirmed, after}}, after, StatusExpiredPaid, 1000, 1000},
+		{"reorged", []payment{{1000, wallet.DepositReorged, before}, {500, wallet.DepositSeen, before}}, before, StatusUnderpaid, 500, 0},
+		{"dropped", []payment{{1000, wallet.DepositDropped, before}}, before, StatusPending, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			inv := &Invoice{Amount: 1000, CreatedAt: created, ExpiresAt: expires, Payments: make(map[string]*Payment)}
+			for i, p := range tc.payments {
+				inv.Payments[strconv.Itoa(i)] = &Payment{Amount: p.amount, State: p.state, SeenAt: p.seen}
+			}
+			inv.update(tc.now)
+			assert.Equal(t, tc.status, inv.Status)
+			assert.Equal(t, tc.received, inv.Received)
+			assert.Equal(t, tc.confirmed, inv.Confirmed)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec")
+	body := []byte(`{"previous_status":"pending","invoice":{"id":"a1"}}`)
+	now := time.Now().Unix()
+
+	type test struct {
+		name      string
+		timestamp int64
+		signature string
+		err       error
+	}
+	tests := []test{
+		{"valid", now, Sign(secret, now, body), nil},
+		{"wrong secret", now, Sign([]byte("other"), now, body), ErrInvalidSignature},
+		{"wrong timestamp", now + 1, Sign(secret, now, body), ErrInvalidSignature},
+		{"expired", now - 600, Sign(secret, now-600, body), ErrExpiredSignature},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook", bytes.NewReader(body))
+			r.Header.Set(TimestampHeader, strconv.FormatInt(tc.timestamp, 10))
+			r.Header.Set(SignatureHeader, tc.signature)
+			ev, err := Verify(secret, r, 5*time.Minute)
+			assert.Equal(t, tc.err, err)
+			if err == nil {
+				assert.Equal(t, StatusPending, ev.PreviousStatus)
+				assert.Equal(t, "a1", ev.Invoice.ID)
+			}
+		})
+	}
+}
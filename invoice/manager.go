@@ -0,0 +1,360 @@
+package invoice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Manager default values
+const (
+	DefaultExpiry       = time.Hour
+	DefaultPollInterval = 10 * time.Second
+)
+
+// ErrClosed is returned when using a closed Manager
+var ErrClosed = errors.New("invoice: manager closed")
+
+// Config configures a Manager
+type Config struct {
+	// Store of the invoices. Defaults to a MemoryStore.
+	Store Store
+	// Store of the deposit monitor. Defaults to a wallet.MemoryDepositStore.
+	Deposits wallet.DepositStore
+	// Account whose subaddresses are given to invoices.
+	AccountIndex uint32
+	// Address and PrivateViewKey derive subaddresses offline instead of
+	// calling CreateAddress, e.g. for a view-only wallet. Derived indices
+	// follow the highest index of the stored invoices, so the account
+	// should be reserved to invoices, and the wallet has to look ahead far
+	// enough to see transfers to them.
+	Address        *gonero.Address
+	PrivateViewKey gonero.Secret
+	// Default time to pay an invoice.
+	Expiry time.Duration
+	// Confirmations required to settle an invoice, 0 for the wallet's suggestion.
+	Confirmations uint64
+	// Interval between polls of the wallet and checks of expiries.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll of the wallet, see wallet.DepositMonitorConfig.
+	Notify <-chan struct{}
+	// URL receiving a signed Event on each status change, none if empty.
+	WebhookURL string
+	// Secret key of the webhook signatures.
+	WebhookSecret gonero.Secret
+	// Client of the webhook requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// CreateRequest is a request for a new invoice
+type CreateRequest struct {
+	// Expected amount, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// (Optional) Free text of the merchant.
+	Description string `json:"description,omitempty"`
+	// (Optional) Time to pay the invoice, in seconds. Defaults to Config.Expiry.
+	Expiry uint64 `json:"expiry,omitempty"`
+}
+
+// Manager creates invoices and tracks their payments with a
+// wallet.DepositMonitor. Webhooks are delivered for the latest status of
+// each invoice until the webhook acknowledges it, so intermediate statuses
+// may be skipped but the last one is never lost, even across restarts.
+type Manager struct {
+	// Errors receives wallet, store and webhook errors, after which the
+	// manager retries at the next poll. Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg     Config
+	client  wallet.Client
+	monitor *wallet.DepositMonitor
+	errors  chan error
+	wake    chan struct{}
+	now     func() time.Time
+
+	// mu serializes changes of invoices
+	mu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New starts a Manager of invoices paid to the wallet behind client.
+func New(client wallet.Client, cfg *Config) *Manager {
+	return newManager(client, cfg, time.Now)
+}
+
+func newManager(client wallet.Client, cfg *Config, now func() time.Time) *Manager {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.Expiry == 0 {
+		c.Expiry = DefaultExpiry
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+
+	m := &Manager{
+		cfg:    c,
+		client: client,
+		errors: make(chan error, 16),
+		wake:   make(chan struct{}, 1),
+		now:    now,
+		done:   make(chan struct{}),
+	}
+	m.Errors = m.errors
+	m.monitor = wallet.NewDepositMonitor(client, m.handleDeposit, &wallet.DepositMonitorConfig{
+		Store:         c.Deposits,
+		Accounts:      []wallet.DepositAccount{{Index: uint64(c.AccountIndex)}},
+		Confirmations: c.Confirmations,
+		PollInterval:  c.PollInterval,
+		Notify:        c.Notify,
+	})
+
+	m.wg.Add(1)
+	go m.run()
+	return m
+}
+
+// Create allocates a subaddress to a new invoice.
+func (m *Manager) Create(req *CreateRequest) (*Invoice, error) {
+	select {
+	case <-m.done:
+		return nil, ErrClosed
+	default:
+	}
+	if req.Amount == 0 {
+		return nil, fmt.Errorf("invoice: amount must be positive")
+	}
+	expiry := m.cfg.Expiry
+	if req.Expiry > 0 {
+		expiry = time.Duration(req.Expiry) * time.Second
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	address, index, err := m.allocate()
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	inv := &Invoice{
+		ID:                    hex.EncodeToString(id),
+		Address:               address,
+		SubaddrIndex:          index,
+		Amount:                req.Amount,
+		Description:           req.Description,
+		RequiredConfirmations: m.cfg.Confirmations,
+		CreatedAt:             now,
+		ExpiresAt:             now.Add(expiry),
+		Payments:              make(map[string]*Payment),
+	}
+	inv.update(now)
+	inv.NotifiedStatus = inv.Status
+	if err := m.cfg.Store.Put(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// Get returns the invoice with the given ID, or ErrNotFound.
+func (m *Manager) Get(id string) (*Invoice, error) {
+	return m.cfg.Store.Get(id)
+}
+
+// List returns every invoice, oldest first.
+func (m *Manager) List() ([]*Invoice, error) {
+	return m.cfg.Store.List()
+}
+
+// Close stops the manager and its deposit monitor.
+func (m *Manager) Close() error {
+	err := ErrClosed
+	m.closeOnce.Do(func() {
+		err = nil
+		close(m.done)
+	})
+	m.wg.Wait()
+	return err
+}
+
+// allocate returns a fresh subaddress, with m.mu held
+func (m *Manager) allocate() (string, wallet.SubaddressIndex, error) {
+	if m.cfg.Address == nil {
+		resp, err := m.client.CreateAddress(&wallet.CreateAddressRequest{AccountIndex: uint64(m.cfg.AccountIndex)})
+		if err != nil {
+			return "", wallet.SubaddressIndex{}, err
+		}
+		return resp.Address, wallet.SubaddressIndex{Major: uint64(m.cfg.AccountIndex), Minor: resp.AddressIndex}, nil
+	}
+
+	invoices, err := m.cfg.Store.List()
+	if err != nil {
+		return "", wallet.SubaddressIndex{}, err
+	}
+	minor := uint64(1)
+	for _, inv := range invoices {
+		if inv.SubaddrIndex.Major == uint64(m.cfg.AccountIndex) && inv.SubaddrIndex.Minor >= minor {
+			minor = inv.SubaddrIndex.Minor + 1
+		}
+	}
+	sub, err := m.cfg.Address.Subaddress(m.cfg.PrivateViewKey.Reveal(), m.cfg.AccountIndex, uint32(minor))
+	if err != nil {
+		return "", wallet.SubaddressIndex{}, fmt.Errorf("invoice: %w", err)
+	}
+	return sub.Addr, wallet.SubaddressIndex{Major: uint64(m.cfg.AccountIndex), Minor: minor}, nil
+}
+
+// handleDeposit records a payment of the deposit monitor
+func (m *Manager) handleDeposit(d wallet.Deposit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, err := m.cfg.Store.BySubaddress(d.SubaddrIndex)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p, ok := inv.Payments[d.Key()]
+	if !ok {
+		p = &Payment{Txid: d.Txid, Amount: d.Amount, SeenAt: m.now()}
+		inv.Payments[d.Key()] = p
+	}
+	p.Height = d.Height
+	p.State = d.State
+	inv.update(m.now())
+	if err := m.cfg.Store.Put(inv); err != nil {
+		return err
+	}
+	m.signal()
+	return nil
+}
+
+func (m *Manager) run() {
+	defer m.wg.Done()
+	defer close(m.errors)
+	defer m.monitor.Close()
+
+	monitorErrors := m.monitor.Errors
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.expire(); err != nil {
+			m.report(err)
+		}
+		if err := m.notify(); err != nil {
+			m.report(err)
+		}
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		case err, ok := <-monitorErrors:
+			if !ok {
+				monitorErrors = nil
+				continue
+			}
+			m.report(err)
+		}
+	}
+}
+
+// expire updates the status of invoices which expired since the last check
+func (m *Manager) expire() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	invoices, err := m.cfg.Store.List()
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	for _, inv := range invoices {
+		if inv.Status != StatusPending && inv.Status != StatusUnderpaid {
+			continue
+		}
+		inv.update(now)
+		if inv.Status != StatusExpired {
+			continue
+		}
+		if err := m.cfg.Store.Put(inv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify delivers the webhooks of invoices whose status changed
+func (m *Manager) notify() error {
+	if m.cfg.WebhookURL == "" {
+		return nil
+	}
+	invoices, err := m.cfg.Store.List()
+	if err != nil {
+		return err
+	}
+	for _, inv := range invoices {
+		if inv.Status == inv.NotifiedStatus {
+			continue
+		}
+		select {
+		case <-m.done:
+			return nil
+		default:
+		}
+		if err := m.deliver(&Event{PreviousStatus: inv.NotifiedStatus, Invoice: inv}); err != nil {
+			return err
+		}
+		if err := m.acknowledge(inv.ID, inv.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acknowledge records the delivery of a status
+func (m *Manager) acknowledge(id string, status Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inv, err := m.cfg.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	inv.NotifiedStatus = status
+	return m.cfg.Store.Put(inv)
+}
+
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) report(err error) {
+	select {
+	case m.errors <- err:
+	default:
+	}
+}
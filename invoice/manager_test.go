@@ -0,0 +1,321 @@
+package invoice
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWallet is a stand-in wallet creating subaddresses and receiving transfers
+type fakeWallet struct {
+	wallet.Client
+
+	mu        sync.Mutex
+	height    uint64
+	minor     uint64
+	transfers []wallet.Transfer
+}
+
+func (w *fakeWallet) CreateAddress(req *wallet.CreateAddressRequest) (*wallet.CreateAddressResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.minor++
+	return &wallet.CreateAddressResponse{Address: fmt.Sprintf("sub-%d-%d", req.AccountIndex, w.minor), AddressIndex: w.minor}, nil
+}
+
+// pay adds a transfer to the pool
+func (w *fakeWallet) pay(index wallet.SubaddressIndex, amount gonero.AtomicXMR) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.transfers = append(w.transfers, wallet.Transfer{
+		Txid:         fmt.Sprintf("tx%d", len(w.transfers)),
+		Amount:       amount,
+		SubaddrIndex: index,
+	})
+}
+
+// mine includes the pool transfers in a block and adds n blocks
+func (w *fakeWallet) mine(n uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.transfers {
+		if w.transfers[i].Height == 0 {
+			w.transfers[i].Height = w.height
+		}
+	}
+	w.height += n
+}
+
+func (w *fakeWallet) GetHeight(*wallet.GetHeightRequest) (*wallet.GetHeightResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &wallet.GetHeightResponse{Height: w.height}, nil
+}
+
+func (w *fakeWallet) GetTransfers(req *wallet.GetTransfersRequest) (*wallet.GetTransfersResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	resp := &wallet.GetTransfersResponse{}
+	for _, t := range w.transfers {
+		if t.SubaddrIndex.Major != req.AccountIndex {
+			continue
+		}
+		if t.Height == 0 {
+			t.Type = "pool"
+			resp.Pool = append(resp.Pool, t)
+			continue
+		}
+		if t.Height <= req.MinHeight {
+			continue
+		}
+		t.Type = "in"
+		t.Confirmations = w.height - t.Height
+		t.Locked = t.Confirmations < 10
+		resp.In = append(resp.In, t)
+	}
+	return resp, nil
+}
+
+// clock is a settable time
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// webhook is a stand-in merchant backend receiving verified events
+type webhook struct {
+	srv    *httptest.Server
+	events chan *Event
+	fail   bool
+	mu     sync.Mutex
+}
+
+func newWebhook(t *testing.T, secret []byte) *webhook {
+	h := &webhook{events: make(chan *Event, 16)}
+	h.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		fail := h.fail
+		h.mu.Unlock()
+		if fail {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		// the manager signs with its clock, far from the real time
+		ev, err := Verify(secret, r, 100*365*24*time.Hour)
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		h.events <- ev
+	}))
+	return h
+}
+
+func (h *webhook) setFail(fail bool) {
+	h.mu.Lock()
+	h.fail = fail
+	h.mu.Unlock()
+}
+
+func (h *webhook) expect(t *testing.T, id string, statuses ...Status) {
+	for _, status := range statuses {
+		select {
+		case ev := <-h.events:
+			assert.Equal(t, id, ev.Invoice.ID)
+			assert.Equal(t, status, ev.Invoice.Status)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", status)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	w := &fakeWallet{height: 100}
+	c := &clock{now: time.Unix(1666000000, 0)}
+	secret := gonero.NewSecret("whsec")
+	hook := newWebhook(t, secret)
+	defer hook.srv.Close()
+	notify := make(chan struct{}, 1)
+	poll := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	m := newManager(w, &Config{
+		AccountIndex:  1,
+		Confirmations: 2,
+		PollInterval:  10 * time.Millisecond,
+		Notify:        notify,
+		WebhookURL:    hook.srv.URL,
+		WebhookSecret: secret,
+	}, c.Now)
+	defer m.Close()
+
+	t.Run("settles partial payments", func(t *testing.T) {
+		inv, err := m.Create(&CreateRequest{Amount: 1000, Description: "order 1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "sub-1-1", inv.Address)
+		assert.Equal(t, wallet.SubaddressIndex{Major: 1, Minor: 1}, inv.SubaddrIndex)
+		assert.Equal(t, uint64(2), inv.RequiredConfirmations)
+		assert.Equal(t, c.Now().Add(DefaultExpiry), inv.ExpiresAt)
+		assert.Equal(t, StatusPending, inv.Status)
+
+		w.pay(inv.SubaddrIndex, 400)
+		poll()
+		hook.expect(t, inv.ID, StatusUnderpaid)
+		w.pay(inv.SubaddrIndex, 600)
+		poll()
+		hook.expect(t, inv.ID, StatusPaid)
+		w.mine(2)
+		poll()
+		hook.expect(t, inv.ID, StatusSettled)
+
+		inv, err = m.Get(inv.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, gonero.AtomicXMR(1000), inv.Confirmed)
+		assert.Len(t, inv.Payments, 2)
+	})
+
+	t.Run("reports overpayments", func(t *testing.T) {
+		inv, err := m.Create(&CreateRequest{Amount: 1000})
+		assert.NoError(t, err)
+		w.pay(inv.SubaddrIndex, 1500)
+		w.mine(2)
+		poll()
+		hook.expect(t, inv.ID, StatusOverpaid)
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		inv, err := m.Create(&CreateRequest{Amount: 1000, Expiry: 60})
+		assert.NoError(t, err)
+		c.advance(time.Minute)
+		hook.expect(t, inv.ID, StatusExpired)
+
+		w.pay(inv.SubaddrIndex, 1000)
+		w.mine(2)
+		poll()
+		hook.expect(t, inv.ID, StatusExpiredPaid)
+	})
+
+	t.Run("retries webhooks", func(t *testing.T) {
+		hook.setFail(true)
+		inv, err := m.Create(&CreateRequest{Amount: 1000})
+		assert.NoError(t, err)
+		w.pay(inv.SubaddrIndex, 1000)
+		poll()
+		select {
+		case err := <-m.Errors:
+			assert.EqualError(t, err, fmt.Sprintf("invoice: webhook of %s answered 503 Service Unavailable", inv.ID))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for error")
+		}
+		hook.setFail(false)
+		hook.expect(t, inv.ID, StatusPaid)
+	})
+
+	assert.NoError(t, m.Close())
+	assert.Equal(t, ErrClosed, m.Close())
+	_, err := m.Create(&CreateRequest{Amount: 1})
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestManagerOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "invoices")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "invoices.json")
+
+	cfg := &Config{
+		Address:        gonero.NewAddress("42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm"),
+		PrivateViewKey: gonero.NewSecret("49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"),
+		PollInterval:   time.Hour,
+	}
+	cfg.Store, err = NewFileStore(path)
+	assert.NoError(t, err)
+	m := New(&fakeWallet{}, cfg)
+	inv, err := m.Create(&CreateRequest{Amount: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF", inv.Address)
+	assert.NoError(t, m.Close())
+
+	cfg.Store, err = NewFileStore(path)
+	assert.NoError(t, err)
+	m = New(&fakeWallet{}, cfg)
+	defer m.Close()
+	stored, err := m.Get(inv.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, inv.Address, stored.Address)
+	inv, err = m.Create(&CreateRequest{Amount: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.SubaddressIndex{Major: 0, Minor: 2}, inv.SubaddrIndex)
+}
+
+func TestHandler(t *testing.T) {
+	m := New(&fakeWallet{}, &Config{PollInterval: time.Hour})
+	defer m.Close()
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/invoices", "application/json", strings.NewReader(`{"amount":1000000000000,"description":"order 1","expiry":600}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created struct {
+		ID     string `json:"id"`
+		Status Status `json:"status"`
+		URI    string `json:"uri"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	assert.Equal(t, StatusPending, created.Status)
+	assert.Equal(t, "monero:sub-0-1?tx_amount=1.000000000000", created.URI)
+
+	type test struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}
+	tests := []test{
+		{"GET", "/invoices/" + created.ID, "", http.StatusOK},
+		{"GET", "/invoices", "", http.StatusOK},
+		{"GET", "/invoices/unknown", "", http.StatusNotFound},
+		{"POST", "/invoices", `{"amount":0}`, http.StatusBadRequest},
+		{"POST", "/invoices", `{`, http.StatusBadRequest},
+		{"DELETE", "/invoices/" + created.ID, "", http.StatusMethodNotAllowed},
+		{"GET", "/", "", http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tc.code, resp.StatusCode, tc.method+" "+tc.path)
+	}
+}
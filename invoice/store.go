@@ -0,0 +1,176 @@
+package invoice
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// ErrNotFound is returned for unknown invoices
+var ErrNotFound = errors.New("invoice: not found")
+
+// Store persists invoices
+type Store interface {
+	// Put stores an invoice, replacing the one with the same ID.
+	Put(*Invoice) error
+	// Get returns the invoice with the given ID, or ErrNotFound.
+	Get(id string) (*Invoice, error)
+	// BySubaddress returns the invoice paid to a subaddress, or ErrNotFound.
+	BySubaddress(wallet.SubaddressIndex) (*Invoice, error)
+	// List returns every invoice, oldest first.
+	List() ([]*Invoice, error)
+}
+
+// MemoryStore is a Store kept in memory
+type MemoryStore struct {
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{invoices: make(map[string]*Invoice)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(inv *Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invoices[inv.ID] = inv.clone()
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(id string) (*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.invoices[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return inv.clone(), nil
+}
+
+// BySubaddress implements Store.
+func (s *MemoryStore) BySubaddress(index wallet.SubaddressIndex) (*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inv := range s.invoices {
+		if inv.SubaddrIndex == index {
+			return inv.clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoices := make([]*Invoice, 0, len(s.invoices))
+	for _, inv := range s.invoices {
+		invoices = append(invoices, inv.clone())
+	}
+	sort.Slice(invoices, func(i, j int) bool {
+		if invoices[i].CreatedAt.Equal(invoices[j].CreatedAt) {
+			return invoices[i].ID < invoices[j].ID
+		}
+		return invoices[i].CreatedAt.Before(invoices[j].CreatedAt)
+	})
+	return invoices, nil
+}
+
+// FileStore is a Store saved as a JSON file, rewritten atomically on each change
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// NewFileStore opens the store saved at path, which is created on the
+// first change if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var invoices []*Invoice
+	if err := json.Unmarshal(data, &invoices); err != nil {
+		return nil, err
+	}
+	for _, inv := range invoices {
+		s.mem.invoices[inv.ID] = inv
+	}
+	return s, nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(inv *Invoice) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev, ok := s.mem.invoices[inv.ID]
+	s.mem.invoices[inv.ID] = inv.clone()
+	if err := s.save(); err != nil {
+		if ok {
+			s.mem.invoices[inv.ID] = prev
+		} else {
+			delete(s.mem.invoices, inv.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *FileStore) Get(id string) (*Invoice, error) {
+	return s.mem.Get(id)
+}
+
+// BySubaddress implements Store.
+func (s *FileStore) BySubaddress(index wallet.SubaddressIndex) (*Invoice, error) {
+	return s.mem.BySubaddress(index)
+}
+
+// List implements Store.
+func (s *FileStore) List() ([]*Invoice, error) {
+	return s.mem.List()
+}
+
+// save writes the file through a temporary file, with s.mem.mu held
+func (s *FileStore) save() error {
+	invoices := make([]*Invoice, 0, len(s.mem.invoices))
+	for _, inv := range s.mem.invoices {
+		invoices = append(invoices, inv)
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].ID < invoices[j].ID })
+	data, err := json.Marshal(invoices)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
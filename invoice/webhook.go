@@ -0,0 +1,97 @@
+package invoice
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook request headers
+const (
+	// SignatureHeader holds the hex HMAC-SHA256 of the timestamp, a dot and the body
+	SignatureHeader = "X-Invoice-Signature"
+	// TimestampHeader holds the Unix time the webhook was signed
+	TimestampHeader = "X-Invoice-Timestamp"
+)
+
+// Webhook verification errors
+var (
+	ErrInvalidSignature = errors.New("invoice: invalid webhook signature")
+	ErrExpiredSignature = errors.New("invoice: expired webhook signature")
+)
+
+// Event is the body of a webhook request, sent when the status of an invoice changes
+type Event struct {
+	// Status last delivered for the invoice.
+	PreviousStatus Status `json:"previous_status"`
+	// The invoice in its new status.
+	Invoice *Invoice `json:"invoice"`
+}
+
+// Sign returns the signature of a webhook body sent at timestamp.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a webhook request signed less than
+// tolerance ago, and returns its event.
+func Verify(secret []byte, r *http.Request, tolerance time.Duration) (*Event, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader))) {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return nil, ErrExpiredSignature
+	}
+	ev := &Event{}
+	if err := json.Unmarshal(body, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// deliver posts a signed event to the webhook
+func (m *Manager) deliver(ev *Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, m.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := m.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(m.cfg.WebhookSecret, timestamp, body))
+	resp, err := m.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("invoice: webhook of %s answered %s", ev.Invoice.ID, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,615 @@
+// Package ledger implements a double-entry ledger of custodial user
+// accounts, each mapped to a subaddress of one wallet account.
+//
+// Confirmed incoming transfers are credited to the user owning the
+// receiving subaddress and reversed if a reorg removes them. Withdrawals
+// debit the user for the amount and the fee before their transactions are
+// relayed, then settle each relayed transaction and refund the others. The
+// ledger is reconciled against GetBalance to flag any drift between the
+// recorded and the actual funds.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Ledger accounts
+const (
+	// WalletAccount is the asset account of the funds held by the wallet
+	WalletAccount = "wallet"
+	// UnassignedAccount is the liability account of deposits to
+	// subaddresses which belong to no user
+	UnassignedAccount = "unassigned"
+	// PendingAccount is the liability account of withdrawals debited from
+	// their users whose transactions are not settled yet
+	PendingAccount = "withdrawals:pending"
+	// userAccountPrefix prefixes the liability account of each user
+	userAccountPrefix = "users:"
+)
+
+// Ledger errors
+var (
+	ErrClosed            = errors.New("ledger: closed")
+	ErrUnknownUser       = errors.New("ledger: unknown user")
+	ErrUserExists        = errors.New("ledger: user exists")
+	ErrInsufficientFunds = errors.New("ledger: insufficient funds")
+	ErrUnbalancedEntry   = errors.New("ledger: unbalanced entry")
+)
+
+// UserAccount returns the ledger account of a user.
+func UserAccount(user string) string {
+	return userAccountPrefix + user
+}
+
+// EntryType tells what an entry records
+type EntryType string
+
+const (
+	// EntryDeposit credits a user with a confirmed incoming transfer
+	EntryDeposit EntryType = "deposit"
+	// EntryReversal debits a user with a credited deposit removed by a reorg
+	EntryReversal EntryType = "reversal"
+	// EntryWithdrawal debits a user with a withdrawal and its fee
+	EntryWithdrawal EntryType = "withdrawal"
+	// EntrySettlement pays a relayed transaction of a pending withdrawal
+	// from the wallet
+	EntrySettlement EntryType = "settlement"
+	// EntryRefund credits a user back with a transaction of a pending
+	// withdrawal which was never relayed
+	EntryRefund EntryType = "refund"
+)
+
+// PendingTx is a transaction of a withdrawal created but not settled by
+// the ledger
+type PendingTx struct {
+	// Hash of the transaction.
+	Txid string `json:"txid"`
+	// Metadata relaying the transaction, see wallet.RelayTxRequest.
+	Metadata string `json:"metadata"`
+	// Amount sent, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Network fee, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// Line is a debit or a credit of an account
+type Line struct {
+	// Account debited or credited.
+	Account string `json:"account"`
+	// Amount debited, in atomic units.
+	Debit gonero.AtomicXMR `json:"debit,omitempty"`
+	// Amount credited, in atomic units.
+	Credit gonero.AtomicXMR `json:"credit,omitempty"`
+}
+
+// Entry is a balanced journal entry
+type Entry struct {
+	// Unique identifier, recording an entry twice is a no-op.
+	ID string `json:"id"`
+	// Time the entry was recorded.
+	Time time.Time `json:"time"`
+	// What the entry records.
+	Type EntryType `json:"type"`
+	// User of the entry.
+	User string `json:"user,omitempty"`
+	// Transactions of the entry.
+	Txids []string `json:"txids,omitempty"`
+	// Deposit key of deposits and reversals, see wallet.Deposit.Key.
+	DepositKey string `json:"deposit_key,omitempty"`
+	// Amount of the deposit or withdrawal, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Network fee of withdrawals, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee,omitempty"`
+	// Transactions of withdrawals debited before being relayed, each
+	// settled or refunded by a later entry.
+	Pending []PendingTx `json:"pending,omitempty"`
+	// Debits and credits, which sum to the same amount.
+	Lines []Line `json:"lines"`
+}
+
+// balanced tells whether the debits and credits of the entry sum to the same amount
+func (e *Entry) balanced() bool {
+	var debit, credit gonero.AtomicXMR
+	for _, l := range e.Lines {
+		debit += l.Debit
+		credit += l.Credit
+	}
+	return debit == credit && debit > 0
+}
+
+// User is a custodial user
+type User struct {
+	// Identifier of the user.
+	ID string `json:"id"`
+	// Deposit address of the user.
+	Address string `json:"address"`
+	// Account and subaddress index of Address.
+	SubaddrIndex wallet.SubaddressIndex `json:"subaddr_index"`
+}
+
+// Store persists the ledger
+type Store interface {
+	// Load returns the users and the entries in the order they were appended.
+	Load() ([]User, []*Entry, error)
+	// PutUser stores a user.
+	PutUser(User) error
+	// Append stores an entry.
+	Append(*Entry) error
+}
+
+// Config configures a Ledger
+type Config struct {
+	// Store of the ledger. Defaults to a MemoryStore.
+	Store Store
+	// Store of the deposit monitor. Defaults to a wallet.MemoryDepositStore.
+	Deposits wallet.DepositStore
+	// Account whose subaddresses are given to users.
+	AccountIndex uint64
+	// Confirmations required to credit a deposit, 0 for the wallet's suggestion.
+	Confirmations uint64
+	// Interval between polls of the wallet.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll of the wallet, see wallet.DepositMonitorConfig.
+	Notify <-chan struct{}
+}
+
+// Ledger keeps the balances of custodial users. Entries are only appended,
+// deposits are credited at most once per confirmation whatever the
+// redeliveries of the deposit monitor.
+type Ledger struct {
+	// Errors receives wallet and store errors of deposits, after which they
+	// are retried at the next poll. Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg     Config
+	client  wallet.Client
+	monitor *wallet.DepositMonitor
+	now     func() time.Time
+
+	mu         sync.Mutex
+	users      map[string]User
+	bySubaddr  map[wallet.SubaddressIndex]string
+	entries    []*Entry
+	ids        map[string]bool
+	balances   map[string]int64
+	credited   map[string]int // deposit key -> credits minus reversals
+	deposits   map[string]int // deposit key -> entries of the key
+	unsettled  map[string]pendingTx
+	withdrawMu sync.Mutex
+}
+
+// New loads the ledger and starts crediting the deposits of the wallet behind client.
+func New(client wallet.Client, cfg *Config) (*Ledger, error) {
+	return newLedger(client, cfg, time.Now)
+}
+
+func newLedger(client wallet.Client, cfg *Config, now func() time.Time) (*Ledger, error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+
+	l := &Ledger{
+		cfg:       c,
+		client:    client,
+		now:       now,
+		users:     make(map[string]User),
+		bySubaddr: make(map[wallet.SubaddressIndex]string),
+		ids:       make(map[string]bool),
+		balances:  make(map[string]int64),
+		credited:  make(map[string]int),
+		deposits:  make(map[string]int),
+		unsettled: make(map[string]pendingTx),
+	}
+	users, entries, err := c.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		l.addUser(u)
+	}
+	for _, e := range entries {
+		l.apply(e)
+	}
+
+	l.monitor = wallet.NewDepositMonitor(client, l.handleDeposit, &wallet.DepositMonitorConfig{
+		Store:         c.Deposits,
+		Accounts:      []wallet.DepositAccount{{Index: c.AccountIndex}},
+		Confirmations: c.Confirmations,
+		PollInterval:  c.PollInterval,
+		Notify:        c.Notify,
+	})
+	l.Errors = l.monitor.Errors
+	return l, nil
+}
+
+// Close stops crediting deposits.
+func (l *Ledger) Close() error {
+	if err := l.monitor.Close(); err != nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// AddUser gives a new subaddress to a new user. The subaddress is created
+// without holding the ledger lock, so a concurrent AddUser for the same ID
+// may leave an unused subaddress in the wallet.
+func (l *Ledger) AddUser(id string) (*User, error) {
+	if _, err := l.User(id); err == nil {
+		return nil, ErrUserExists
+	}
+	resp, err := l.client.CreateAddress(&wallet.CreateAddressRequest{AccountIndex: l.cfg.AccountIndex, Label: id})
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.users[id]; ok {
+		return nil, ErrUserExists
+	}
+	u := User{ID: id, Address: resp.Address, SubaddrIndex: wallet.SubaddressIndex{Major: l.cfg.AccountIndex, Minor: resp.AddressIndex}}
+	if err := l.cfg.Store.PutUser(u); err != nil {
+		return nil, err
+	}
+	l.addUser(u)
+	return &u, nil
+}
+
+// User returns a user, or ErrUnknownUser.
+func (l *Ledger) User(id string) (*User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.users[id]
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+	return &u, nil
+}
+
+// Users returns every user, sorted by ID.
+func (l *Ledger) Users() []User {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	users := make([]User, 0, len(l.users))
+	for _, u := range l.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+// Balance returns the balance of an account: credits minus debits for
+// liabilities such as users, debits minus credits for WalletAccount.
+func (l *Ledger) Balance(account string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if account == WalletAccount {
+		return -l.balances[account]
+	}
+	return l.balances[account]
+}
+
+// History returns the entries of a user, oldest first.
+func (l *Ledger) History(user string) []*Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	account := UserAccount(user)
+	var history []*Entry
+	for _, e := range l.entries {
+		for _, line := range e.Lines {
+			if line.Account == account {
+				history = append(history, e)
+				break
+			}
+		}
+	}
+	return history
+}
+
+// Entries returns every entry, oldest first.
+func (l *Ledger) Entries() []*Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*Entry{}, l.entries...)
+}
+
+// Withdraw sends amount to address with TransferSplit and debits the user
+// with the amount and the network fee. Withdrawals are serialized so that
+// a user cannot overdraw with concurrent ones.
+//
+// The user is debited before the transactions are relayed, then each
+// relayed transaction is settled. When a relay fails, the transactions the
+// wallet does not know are refunded and an error is returned: the history
+// of the user tells which ones were sent. Withdrawals whose settlement
+// could not be recorded, after a crash for instance, are completed by
+// Resume.
+func (l *Ledger) Withdraw(user, address string, amount gonero.AtomicXMR, priority wallet.PriorityType) (*Entry, error) {
+	l.withdrawMu.Lock()
+	defer l.withdrawMu.Unlock()
+	if _, err := l.User(user); err != nil {
+		return nil, err
+	}
+	if l.Balance(UserAccount(user)) < int64(amount) {
+		return nil, ErrInsufficientFunds
+	}
+
+	// the fee is only known once the transactions are created
+	req := &wallet.TransferSplitRequest{
+		Destinations:  []wallet.Destination{{Amount: amount, Address: address}},
+		AccountIndex:  l.cfg.AccountIndex,
+		Priority:      priority,
+		DoNotRelay:    true,
+		GetTxMetadata: true,
+	}
+	resp, err := l.client.TransferSplit(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.TxMetadataList) != len(resp.TxHashList) || len(resp.AmountList) != len(resp.TxHashList) || len(resp.FeeList) != len(resp.TxHashList) {
+		return nil, errors.New("ledger: incomplete transfer split response")
+	}
+	var fee gonero.AtomicXMR
+	pending := make([]PendingTx, len(resp.TxHashList))
+	for i, txid := range resp.TxHashList {
+		pending[i] = PendingTx{
+			Txid:     txid,
+			Metadata: resp.TxMetadataList[i],
+			Amount:   gonero.AtomicXMR(resp.AmountList[i]),
+			Fee:      gonero.AtomicXMR(resp.FeeList[i]),
+		}
+		fee += pending[i].Fee
+	}
+
+	l.mu.Lock()
+	if l.balances[UserAccount(user)] < int64(amount+fee) {
+		l.mu.Unlock()
+		return nil, ErrInsufficientFunds
+	}
+	e, err := l.record(&Entry{
+		ID:      fmt.Sprintf("withdrawal:%s", joinTxids(resp.TxHashList)),
+		Type:    EntryWithdrawal,
+		User:    user,
+		Txids:   resp.TxHashList,
+		Amount:  amount,
+		Fee:     fee,
+		Pending: pending,
+		Lines: []Line{
+			{Account: UserAccount(user), Debit: amount + fee},
+			{Account: PendingAccount, Credit: amount + fee},
+		},
+	})
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tx := range pending {
+		if _, err := l.client.RelayTx(&wallet.RelayTxRequest{Hex: tx.Metadata}); err != nil {
+			// the relay may have failed after broadcasting the transaction
+			err = fmt.Errorf("ledger: relay %s: %w", tx.Txid, err)
+			if rerr := l.resolve(pending[i:]); rerr != nil {
+				return nil, fmt.Errorf("%v; %v", err, rerr)
+			}
+			return nil, err
+		}
+		if err := l.settle(tx.Txid, EntrySettlement); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Resume settles the pending withdrawal transactions known by the wallet,
+// which are relayed then, and refunds the others. Call it after restarting
+// or after Withdraw failed.
+func (l *Ledger) Resume() error {
+	l.withdrawMu.Lock()
+	defer l.withdrawMu.Unlock()
+	return l.resolve(l.Pending())
+}
+
+// Pending returns the transactions of withdrawals neither settled nor
+// refunded yet.
+func (l *Ledger) Pending() []PendingTx {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var pending []PendingTx
+	for _, e := range l.entries {
+		for _, tx := range e.Pending {
+			if _, ok := l.unsettled[tx.Txid]; ok {
+				pending = append(pending, tx)
+			}
+		}
+	}
+	return pending
+}
+
+// resolve settles the transactions known by the wallet and refunds the
+// others, with l.withdrawMu held
+func (l *Ledger) resolve(pending []PendingTx) error {
+	for _, tx := range pending {
+		known, err := l.known(tx.Txid)
+		if err != nil {
+			return err
+		}
+		typ := EntryRefund
+		if known {
+			typ = EntrySettlement
+		}
+		if err := l.settle(tx.Txid, typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settle records the settlement or the refund of a pending transaction
+func (l *Ledger) settle(txid string, typ EntryType) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p, ok := l.unsettled[txid]
+	if !ok {
+		return nil
+	}
+	account := WalletAccount
+	if typ == EntryRefund {
+		account = UserAccount(p.user)
+	}
+	_, err := l.record(&Entry{
+		ID:     fmt.Sprintf("%s:%s", typ, txid),
+		Type:   typ,
+		User:   p.user,
+		Txids:  []string{txid},
+		Amount: p.tx.Amount,
+		Fee:    p.tx.Fee,
+		Lines: []Line{
+			{Account: PendingAccount, Debit: p.tx.Amount + p.tx.Fee},
+			{Account: account, Credit: p.tx.Amount + p.tx.Fee},
+		},
+	})
+	return err
+}
+
+// known tells whether the wallet knows a transaction, which is relayed then
+func (l *Ledger) known(txid string) (bool, error) {
+	resp, err := l.client.GetTransferByTxid(&wallet.GetTransferByTxidRequest{Txid: txid, AccountIndex: l.cfg.AccountIndex})
+	if rerr, ok := err.(*wallet.RPCError); ok && rerr.Code == wallet.ErrWrongTxid {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.Transfer.Type != "failed", nil
+}
+
+// RecordWithdrawal debits a user with a withdrawal sent outside of Withdraw.
+// The entry is identified by the txids, recording it again is a no-op.
+func (l *Ledger) RecordWithdrawal(user string, txids []string, amount, fee gonero.AtomicXMR) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.users[user]; !ok {
+		return nil, ErrUnknownUser
+	}
+	e := &Entry{
+		ID:     fmt.Sprintf("withdrawal:%s", joinTxids(txids)),
+		Type:   EntryWithdrawal,
+		User:   user,
+		Txids:  txids,
+		Amount: amount,
+		Fee:    fee,
+		Lines: []Line{
+			{Account: UserAccount(user), Debit: amount + fee},
+			{Account: WalletAccount, Credit: amount + fee},
+		},
+	}
+	return l.record(e)
+}
+
+// handleDeposit credits confirmed deposits and reverses reorged ones
+func (l *Ledger) handleDeposit(d wallet.Deposit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := d.Key()
+	account := UnassignedAccount
+	user := l.bySubaddr[d.SubaddrIndex]
+	if user != "" {
+		account = UserAccount(user)
+	}
+
+	var e *Entry
+	switch {
+	case d.State == wallet.DepositConfirmed && l.credited[key] == 0:
+		e = &Entry{
+			Type:  EntryDeposit,
+			Lines: []Line{{Account: WalletAccount, Debit: d.Amount}, {Account: account, Credit: d.Amount}},
+		}
+	case d.State == wallet.DepositReorged && l.credited[key] > 0:
+		e = &Entry{
+			Type:  EntryReversal,
+			Lines: []Line{{Account: account, Debit: d.Amount}, {Account: WalletAccount, Credit: d.Amount}},
+		}
+	default:
+		return nil
+	}
+	e.ID = fmt.Sprintf("%s:%s:%d", e.Type, key, l.deposits[key])
+	e.User = user
+	e.Txids = []string{d.Txid}
+	e.DepositKey = key
+	e.Amount = d.Amount
+	_, err := l.record(e)
+	return err
+}
+
+// record appends a new entry, with l.mu held
+func (l *Ledger) record(e *Entry) (*Entry, error) {
+	if l.ids[e.ID] {
+		for _, prev := range l.entries {
+			if prev.ID == e.ID {
+				return prev, nil
+			}
+		}
+	}
+	if !e.balanced() {
+		return nil, ErrUnbalancedEntry
+	}
+	e.Time = l.now()
+	if err := l.cfg.Store.Append(e); err != nil {
+		return nil, err
+	}
+	l.apply(e)
+	return e, nil
+}
+
+// apply updates the balances with an entry, with l.mu held
+func (l *Ledger) apply(e *Entry) {
+	l.entries = append(l.entries, e)
+	l.ids[e.ID] = true
+	for _, line := range e.Lines {
+		l.balances[line.Account] += int64(line.Credit) - int64(line.Debit)
+	}
+	switch e.Type {
+	case EntryDeposit:
+		l.credited[e.DepositKey]++
+		l.deposits[e.DepositKey]++
+	case EntryReversal:
+		l.credited[e.DepositKey]--
+		l.deposits[e.DepositKey]++
+	case EntryWithdrawal:
+		for _, tx := range e.Pending {
+			l.unsettled[tx.Txid] = pendingTx{user: e.User, tx: tx}
+		}
+	case EntrySettlement, EntryRefund:
+		for _, txid := range e.Txids {
+			delete(l.unsettled, txid)
+		}
+	}
+}
+
+// addUser indexes a user, with l.mu held
+func (l *Ledger) addUser(u User) {
+	l.users[u.ID] = u
+	l.bySubaddr[u.SubaddrIndex] = u.ID
+}
+
+// pendingTx is an unsettled transaction of a user
+type pendingTx struct {
+	user string
+	tx   PendingTx
+}
+
+func joinTxids(txids []string) string {
+	sorted := append([]string{}, txids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
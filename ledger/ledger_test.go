@@ -0,0 +1,424 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWallet is a stand-in wallet receiving and sending transfers
+type fakeWallet struct {
+	wallet.Client
+
+	mu        sync.Mutex
+	height    uint64
+	minor     uint64
+	transfers []wallet.Transfer
+	sent      gonero.AtomicXMR
+	extra     gonero.AtomicXMR // funds the ledger knows nothing about
+	relayed   []string
+	split     int // transactions per withdrawal
+	failRelay int // fails the nth relay
+	scans     int
+	onCreate  func() // runs inside CreateAddress
+}
+
+func (w *fakeWallet) CreateAddress(req *wallet.CreateAddressRequest) (*wallet.CreateAddressResponse, error) {
+	if w.onCreate != nil {
+		w.onCreate()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.minor++
+	return &wallet.CreateAddressResponse{Address: fmt.Sprintf("sub-%d-%d", req.AccountIndex, w.minor), AddressIndex: w.minor}, nil
+}
+
+// pay mines a transfer to a subaddress and confirms it
+func (w *fakeWallet) pay(minor uint64, amount gonero.AtomicXMR) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	txid := fmt.Sprintf("tx%d", len(w.transfers))
+	w.transfers = append(w.transfers, wallet.Transfer{
+		Txid:         txid,
+		Amount:       amount,
+		Height:       w.height,
+		SubaddrIndex: wallet.SubaddressIndex{Minor: minor},
+	})
+	w.height += 2
+	return txid
+}
+
+// reorg removes a transfer from the chain
+func (w *fakeWallet) reorg(txid string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, t := range w.transfers {
+		if t.Txid == txid {
+			w.transfers = append(w.transfers[:i], w.transfers[i+1:]...)
+			return
+		}
+	}
+}
+
+// scanned returns the number of scans of the transfers
+func (w *fakeWallet) scanned() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.scans
+}
+
+func (w *fakeWallet) GetHeight(*wallet.GetHeightRequest) (*wallet.GetHeightResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &wallet.GetHeightResponse{Height: w.height}, nil
+}
+
+func (w *fakeWallet) GetTransfers(req *wallet.GetTransfersRequest) (*wallet.GetTransfersResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.scans++
+	resp := &wallet.GetTransfersResponse{}
+	for _, t := range w.transfers {
+		if t.Height <= req.MinHeight {
+			continue
+		}
+		t.Type = "in"
+		t.Confirmations = w.height - t.Height
+		t.Locked = t.Confirmations < 10
+		resp.In = append(resp.In, t)
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) TransferSplit(req *wallet.TransferSplitRequest) (*wallet.TransferSplitResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !req.DoNotRelay || !req.GetTxMetadata {
+		return nil, fmt.Errorf("transfer relayed without metadata")
+	}
+	// the amount is split across w.split transactions, each paying a fee of 200
+	split := w.split
+	if split == 0 {
+		split = 1
+	}
+	resp := &wallet.TransferSplitResponse{}
+	for i := 0; i < split; i++ {
+		amount := int64(req.Destinations[0].Amount) / int64(split)
+		txid := fmt.Sprintf("out%d", req.Destinations[0].Amount)
+		if split > 1 {
+			txid += fmt.Sprintf("-%d", i)
+		}
+		resp.TxHashList = append(resp.TxHashList, txid)
+		resp.AmountList = append(resp.AmountList, amount)
+		resp.FeeList = append(resp.FeeList, 200)
+		resp.TxMetadataList = append(resp.TxMetadataList, fmt.Sprintf("meta-%s-%d", txid, amount))
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failRelay > 0 {
+		w.failRelay--
+		if w.failRelay == 0 {
+			return nil, &wallet.RPCError{Code: wallet.ErrGenericTransferError, Message: "Failed to relay tx"}
+		}
+	}
+	w.relayed = append(w.relayed, req.Hex)
+	i := strings.LastIndex(req.Hex, "-")
+	amount, _ := strconv.ParseInt(req.Hex[i+1:], 10, 64)
+	w.sent += gonero.AtomicXMR(amount + 200)
+	return &wallet.RelayTxResponse{TxHash: req.Hex[len("meta-"):i]}, nil
+}
+
+func (w *fakeWallet) GetTransferByTxid(req *wallet.GetTransferByTxidRequest) (*wallet.GetTransferByTxidResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, metadata := range w.relayed {
+		if strings.HasPrefix(metadata, "meta-"+req.Txid+"-") {
+			return &wallet.GetTransferByTxidResponse{Transfer: wallet.TransferDetail{Txid: req.Txid, Type: "pending"}}, nil
+		}
+	}
+	return nil, &wallet.RPCError{Code: wallet.ErrWrongTxid, Message: "Transaction not found."}
+}
+
+func (w *fakeWallet) GetBalance(*wallet.GetBalanceRequest) (*wallet.GetBalanceResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	perSubaddr := make(map[uint64]gonero.AtomicXMR)
+	for _, t := range w.transfers {
+		perSubaddr[t.SubaddrIndex.Minor] += t.Amount
+	}
+	// change returns to the first subaddress
+	perSubaddr[0] += w.extra - w.sent
+	resp := &wallet.GetBalanceResponse{}
+	for minor := uint64(0); minor <= w.minor; minor++ {
+		resp.Balance += perSubaddr[minor]
+		resp.PerSubaddress = append(resp.PerSubaddress, wallet.SubaddressBalance{AddressIndex: minor, Balance: perSubaddr[minor]})
+	}
+	return resp, nil
+}
+
+// expectBalance polls the ledger until an account has the expected balance
+func expectBalance(t *testing.T, l *Ledger, poll func(), account string, balance int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Balance(account) != balance {
+		if time.Now().After(deadline) {
+			t.Fatalf("balance of %s is %d, expected %d", account, l.Balance(account), balance)
+		}
+		poll()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.jsonl")
+
+	w := &fakeWallet{height: 100}
+	notify := make(chan struct{}, 1)
+	poll := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	cfg := &Config{
+		Store:         store,
+		Confirmations: 2,
+		PollInterval:  time.Hour,
+		Notify:        notify,
+	}
+	l, err := New(w, cfg)
+	assert.NoError(t, err)
+
+	alice, err := l.AddUser("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "sub-0-1", alice.Address)
+	_, err = l.AddUser("alice")
+	assert.Equal(t, ErrUserExists, err)
+	bob, err := l.AddUser("bob")
+	assert.NoError(t, err)
+
+	t.Run("credits deposits", func(t *testing.T) {
+		w.pay(alice.SubaddrIndex.Minor, 1000)
+		w.pay(0, 300)
+		expectBalance(t, l, poll, UserAccount("alice"), 1000)
+		expectBalance(t, l, poll, UnassignedAccount, 300)
+		assert.Equal(t, int64(1300), l.Balance(WalletAccount))
+	})
+
+	t.Run("withdraws", func(t *testing.T) {
+		_, err := l.Withdraw("alice", "dest", 900, wallet.PriorityDefault)
+		assert.Equal(t, ErrInsufficientFunds, err)
+		_, err = l.Withdraw("carol", "dest", 1, wallet.PriorityDefault)
+		assert.Equal(t, ErrUnknownUser, err)
+
+		e, err := l.Withdraw("alice", "dest", 700, wallet.PriorityDefault)
+		assert.NoError(t, err)
+		assert.Equal(t, "withdrawal:out700", e.ID)
+		assert.Equal(t, gonero.AtomicXMR(200), e.Fee)
+		assert.Equal(t, []string{"meta-out700-700"}, w.relayed)
+		assert.Equal(t, int64(100), l.Balance(UserAccount("alice")))
+		assert.Equal(t, int64(400), l.Balance(WalletAccount))
+
+		again, err := l.RecordWithdrawal("alice", []string{"out700"}, 700, 200)
+		assert.NoError(t, err)
+		assert.Equal(t, e, again)
+		assert.Equal(t, int64(100), l.Balance(UserAccount("alice")))
+	})
+
+	t.Run("reverses reorged deposits", func(t *testing.T) {
+		txid := w.pay(bob.SubaddrIndex.Minor, 500)
+		expectBalance(t, l, poll, UserAccount("bob"), 500)
+		w.reorg(txid)
+		expectBalance(t, l, poll, UserAccount("bob"), 0)
+
+		history := l.History("bob")
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, EntryDeposit, history[0].Type)
+			assert.Equal(t, EntryReversal, history[1].Type)
+			assert.Equal(t, []string{txid}, history[1].Txids)
+		}
+		assert.Len(t, l.History("alice"), 2)
+	})
+
+	t.Run("reconciles", func(t *testing.T) {
+		r, err := l.Reconcile()
+		assert.NoError(t, err)
+		assert.False(t, r.Drifted())
+		assert.Equal(t, int64(400), r.Ledger)
+		assert.Equal(t, SubaddressReport{
+			SubaddrIndex: alice.SubaddrIndex,
+			User:         "alice",
+			Wallet:       1000,
+			Ledger:       100,
+		}, r.Subaddresses[1])
+
+		w.mu.Lock()
+		w.extra = 50
+		w.mu.Unlock()
+		r, err = l.Reconcile()
+		assert.NoError(t, err)
+		assert.True(t, r.Drifted())
+		assert.Equal(t, int64(50), r.Drift)
+	})
+
+	t.Run("creates addresses outside the lock", func(t *testing.T) {
+		var inner error
+		w.onCreate = func() {
+			w.onCreate = nil
+			// would deadlock if AddUser held the ledger lock
+			_, inner = l.AddUser("dave")
+		}
+		_, err := l.AddUser("dave")
+		assert.NoError(t, inner)
+		assert.Equal(t, ErrUserExists, err)
+		u, err := l.User("dave")
+		assert.NoError(t, err)
+		assert.Equal(t, "sub-0-3", u.Address)
+	})
+
+	assert.NoError(t, l.Close())
+	assert.Equal(t, ErrClosed, l.Close())
+	assert.NoError(t, store.Close())
+
+	t.Run("restarts", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.NoError(t, err)
+		defer store.Close()
+		cfg.Store = store
+		l, err := New(w, cfg)
+		assert.NoError(t, err)
+		defer l.Close()
+
+		// the deposit monitor starts over and redelivers every deposit
+		scans := w.scanned()
+		for w.scanned() < scans+2 {
+			poll()
+			time.Sleep(time.Millisecond)
+		}
+		assert.Len(t, l.Entries(), 6)
+		assert.Equal(t, int64(100), l.Balance(UserAccount("alice")))
+		assert.Equal(t, int64(300), l.Balance(UnassignedAccount))
+		assert.Equal(t, int64(400), l.Balance(WalletAccount))
+		u, err := l.User("bob")
+		assert.NoError(t, err)
+		assert.Equal(t, *bob, *u)
+	})
+}
+
+func TestFileStoreTornRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.jsonl")
+
+	line := `{"user":{"id":"alice","address":"a","subaddr_index":{"major":0,"minor":1}}}` + "\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(line+`{"entry":{"id":`), 0600))
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	users, entries, err := store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Empty(t, entries)
+	assert.NoError(t, store.PutUser(User{ID: "bob"}))
+	users, _, err = store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.NoError(t, store.Close())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"entry":{"id":`+"\n"+line), 0600))
+	store, err = OpenFileStore(path)
+	assert.NoError(t, err)
+	_, _, err = store.Load()
+	assert.Error(t, err)
+	assert.NoError(t, store.Close())
+}
+
+// failingStore fails to append settlements
+type failingStore struct {
+	*MemoryStore
+}
+
+func (s failingStore) Append(e *Entry) error {
+	if e.Type == EntrySettlement {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Append(e)
+}
+
+func TestWithdrawRelayFailure(t *testing.T) {
+	w := &fakeWallet{height: 100, split: 2}
+	l, err := New(w, &Config{Confirmations: 2, PollInterval: time.Millisecond})
+	assert.NoError(t, err)
+	defer l.Close()
+	_, err = l.AddUser("alice")
+	assert.NoError(t, err)
+	w.pay(1, 2000)
+	expectBalance(t, l, func() {}, UserAccount("alice"), 2000)
+
+	t.Run("refunds the transactions not relayed", func(t *testing.T) {
+		w.failRelay = 2
+		_, err := l.Withdraw("alice", "dest", 600, wallet.PriorityDefault)
+		assert.Error(t, err)
+		assert.Equal(t, []string{"meta-out600-0-300"}, w.relayed)
+
+		// the user pays for the relayed transaction only
+		assert.Equal(t, int64(2000-500), l.Balance(UserAccount("alice")))
+		assert.Equal(t, int64(2000-500), l.Balance(WalletAccount))
+		assert.Equal(t, int64(0), l.Balance(PendingAccount))
+		assert.Empty(t, l.Pending())
+		history := l.History("alice")
+		if assert.Len(t, history, 3) {
+			assert.Equal(t, EntryWithdrawal, history[1].Type)
+			assert.Equal(t, EntryRefund, history[2].Type)
+			assert.Equal(t, []string{"out600-1"}, history[2].Txids)
+		}
+	})
+
+	t.Run("resumes unrecorded settlements", func(t *testing.T) {
+		store := failingStore{NewMemoryStore()}
+		users, entries, err := l.cfg.Store.Load()
+		assert.NoError(t, err)
+		for _, u := range users {
+			assert.NoError(t, store.PutUser(u))
+		}
+		for _, e := range entries {
+			assert.NoError(t, store.MemoryStore.Append(e))
+		}
+		l.cfg.Store = store
+		w.relayed = nil
+
+		_, err = l.Withdraw("alice", "dest", 400, wallet.PriorityDefault)
+		assert.Error(t, err)
+		assert.Len(t, w.relayed, 1)
+		assert.Len(t, l.Pending(), 2)
+		// the debit holds the funds until the transactions are settled
+		assert.Equal(t, int64(1500-800), l.Balance(UserAccount("alice")))
+		assert.Equal(t, int64(800), l.Balance(PendingAccount))
+
+		l.cfg.Store = store.MemoryStore
+		assert.NoError(t, l.Resume())
+		assert.Empty(t, l.Pending())
+		assert.Equal(t, int64(1500-400), l.Balance(UserAccount("alice")))
+		assert.Equal(t, int64(1500-400), l.Balance(WalletAccount))
+		assert.Equal(t, int64(0), l.Balance(PendingAccount))
+	})
+}
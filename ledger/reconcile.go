@@ -0,0 +1,69 @@
+package ledger
+
+import (
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Report is the result of a reconciliation
+type Report struct {
+	// Balance of WalletAccount.
+	Ledger int64 `json:"ledger"`
+	// Balance of the wallet account, locked or unlocked.
+	Wallet gonero.AtomicXMR `json:"wallet"`
+	// Mined deposits included in Wallet but not credited yet.
+	InFlight gonero.AtomicXMR `json:"in_flight"`
+	// Wallet minus InFlight minus Ledger, which is 0 unless the ledger
+	// and the wallet drifted apart.
+	Drift int64 `json:"drift"`
+	// Balance of each subaddress next to the balance of its user.
+	Subaddresses []SubaddressReport `json:"subaddresses"`
+}
+
+// Drifted tells whether the ledger and the wallet disagree.
+func (r *Report) Drifted() bool {
+	return r.Drift != 0
+}
+
+// SubaddressReport is the balance of a subaddress in a Report
+type SubaddressReport struct {
+	// Index of the subaddress.
+	SubaddrIndex wallet.SubaddressIndex `json:"subaddr_index"`
+	// User of the subaddress, empty if unassigned.
+	User string `json:"user,omitempty"`
+	// Balance of the subaddress in the wallet. Withdrawals spend the
+	// outputs of any subaddress and return change to the first one, so it
+	// only matches the user balance until the first withdrawal.
+	Wallet gonero.AtomicXMR `json:"wallet"`
+	// Balance of the user in the ledger, negative after the reversal of a
+	// deposit the user already withdrew.
+	Ledger int64 `json:"ledger"`
+}
+
+// Reconcile compares the ledger with GetBalance.
+func (l *Ledger) Reconcile() (*Report, error) {
+	resp, err := l.client.GetBalance(&wallet.GetBalanceRequest{AccountIndex: l.cfg.AccountIndex})
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Wallet: resp.Balance}
+	for _, d := range l.monitor.Deposits() {
+		if d.State == wallet.DepositSeen && d.Height > 0 {
+			r.InFlight += d.Amount
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.Ledger = -l.balances[WalletAccount]
+	r.Drift = int64(r.Wallet) - int64(r.InFlight) - r.Ledger
+	for _, b := range resp.PerSubaddress {
+		index := wallet.SubaddressIndex{Major: l.cfg.AccountIndex, Minor: b.AddressIndex}
+		s := SubaddressReport{SubaddrIndex: index, Wallet: b.Balance, User: l.bySubaddr[index]}
+		if s.User != "" {
+			s.Ledger = l.balances[UserAccount(s.User)]
+		}
+		r.Subaddresses = append(r.Subaddresses, s)
+	}
+	return r, nil
+}
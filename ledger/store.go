@@ -0,0 +1,136 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// MemoryStore is a Store kept in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	users   []User
+	entries []*Entry
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load() ([]User, []*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]User{}, s.users...), append([]*Entry{}, s.entries...), nil
+}
+
+// PutUser implements Store.
+func (s *MemoryStore) PutUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, u)
+	return nil
+}
+
+// Append implements Store.
+func (s *MemoryStore) Append(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// FileStore is a Store saved as a journal of JSON lines, synced after each
+// record. A record torn by a crash is truncated by Load when it ends the
+// journal.
+type FileStore struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// record is a line of the journal of a FileStore
+type record struct {
+	User  *User  `json:"user,omitempty"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// OpenFileStore opens the journal at path, creating it if needed.
+func OpenFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{file: f}, nil
+}
+
+// Load implements Store.
+func (s *FileStore) Load() ([]User, []*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return nil, nil, err
+	}
+	var users []User
+	var entries []*Entry
+	var torn error
+	var size int64
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if torn != nil {
+			return nil, nil, torn
+		}
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			torn = fmt.Errorf("ledger: journal line %d: %w", line, err)
+			continue
+		}
+		size += int64(len(scanner.Bytes())) + 1
+		if r.User != nil {
+			users = append(users, *r.User)
+		}
+		if r.Entry != nil {
+			entries = append(entries, r.Entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if torn != nil {
+		if err := s.file.Truncate(size); err != nil {
+			return nil, nil, err
+		}
+	}
+	return users, entries, nil
+}
+
+// PutUser implements Store.
+func (s *FileStore) PutUser(u User) error {
+	return s.append(&record{User: &u})
+}
+
+// Append implements Store.
+func (s *FileStore) Append(e *Entry) error {
+	return s.append(&record{Entry: e})
+}
+
+// Close closes the journal.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileStore) append(r *record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
@@ -0,0 +1,105 @@
+package levin
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultCrawlConcurrency is the default number of simultaneous handshakes
+const DefaultCrawlConcurrency = 16
+
+// CrawlConfig configures Crawl
+type CrawlConfig struct {
+	// Configuration of the connections to each node.
+	Peer *Config
+	// Number of simultaneous handshakes.
+	Concurrency int
+	// Maximum number of nodes to contact, 0 for no limit.
+	MaxNodes int
+}
+
+// CrawlResult is the outcome of the handshake with a node
+type CrawlResult struct {
+	// Address the node was dialed on
+	Address string
+	// Handshake response, nil if Err is set
+	Handshake *HandshakeResponse
+	// Connection or handshake error
+	Err error
+}
+
+// Crawl performs a handshake with the seed nodes and then with every IPv4
+// and IPv6 peer they share, until no new peers are found, MaxNodes is
+// reached or ctx is cancelled. Connections are closed after the handshake.
+// The results are returned in the order the handshakes completed,
+// together with ctx.Err() if the crawl was cancelled.
+func Crawl(ctx context.Context, seeds []string, cfg *CrawlConfig) ([]CrawlResult, error) {
+	c := CrawlConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultCrawlConcurrency
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []CrawlResult
+		seen    = make(map[string]bool)
+		sem     = make(chan struct{}, c.Concurrency)
+	)
+
+	var visit func(addr string)
+	// enqueue must be called with mu held
+	enqueue := func(addr string) {
+		if seen[addr] || (c.MaxNodes > 0 && len(seen) >= c.MaxNodes) {
+			return
+		}
+		seen[addr] = true
+		wg.Add(1)
+		go visit(addr)
+	}
+	visit = func(addr string) {
+		defer wg.Done()
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		peer, err := DialContext(ctx, addr, c.Peer)
+		<-sem
+		if ctx.Err() != nil {
+			if peer != nil {
+				peer.Close()
+			}
+			return
+		}
+
+		res := CrawlResult{Address: addr, Err: err}
+		if err == nil {
+			res.Handshake = peer.Handshake
+			peer.Close()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, res)
+		if res.Handshake == nil {
+			return
+		}
+		for _, e := range res.Handshake.LocalPeerlist {
+			if e.Address.Type == AddressIPv4 || e.Address.Type == AddressIPv6 {
+				enqueue(e.Address.String())
+			}
+		}
+	}
+
+	mu.Lock()
+	for _, addr := range seeds {
+		enqueue(addr)
+	}
+	mu.Unlock()
+	wg.Wait()
+	return results, ctx.Err()
+}
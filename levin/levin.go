@@ -0,0 +1,165 @@
+// Package levin implements the levin protocol spoken by monerod on its P2P port.
+//
+// A levin packet is a fixed size header followed by an epee portable
+// storage payload. Peer connects to a node, performs the handshake and
+// receives the transactions and blocks it relays; Crawl walks the
+// peer lists of the network.
+package levin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Signature starts every levin header
+const Signature uint64 = 0x0101010101012101
+
+// Protocol version sent in headers
+const ProtocolVersion uint32 = 1
+
+// HeaderSize is the size of an encoded Header
+const HeaderSize = 33
+
+// MaxPacketSize is the largest payload accepted, as in monerod
+const MaxPacketSize = 100000000
+
+// Header flags
+const (
+	FlagRequest  uint32 = 0x01
+	FlagResponse uint32 = 0x02
+	// Fragmented packets are only sent by nodes padding their traffic
+	// and are not supported.
+	FlagBegin uint32 = 0x04
+	FlagEnd   uint32 = 0x08
+)
+
+// Return codes
+const (
+	ReturnOK                         int32 = 0
+	ReturnSuccess                    int32 = 1
+	ErrorConnection                  int32 = -1
+	ErrorConnectionNotFound          int32 = -2
+	ErrorConnectionDestroyed         int32 = -3
+	ErrorConnectionTimedOut          int32 = -4
+	ErrorConnectionNoDuplexProtocol  int32 = -5
+	ErrorConnectionHandlerNotDefined int32 = -6
+	ErrorFormat                      int32 = -7
+)
+
+// Commands
+const (
+	CommandHandshake    uint32 = 1001
+	CommandTimedSync    uint32 = 1002
+	CommandPing         uint32 = 1003
+	CommandSupportFlags uint32 = 1007
+
+	NotifyNewBlock        uint32 = 2001
+	NotifyNewTransactions uint32 = 2002
+	NotifyNewFluffyBlock  uint32 = 2008
+)
+
+var (
+	// ErrSignature is returned when a header does not start with Signature
+	ErrSignature = errors.New("levin: invalid signature")
+	// ErrPacketTooLarge is returned when a payload exceeds MaxPacketSize
+	ErrPacketTooLarge = errors.New("levin: packet too large")
+)
+
+// Header is a levin packet header
+type Header struct {
+	// Size of the payload following the header.
+	Size uint64
+	// Set on requests which expect a response.
+	ExpectResponse bool
+	// Command of the packet.
+	Command uint32
+	// Result of a command, negative on error.
+	ReturnCode int32
+	// FlagRequest or FlagResponse.
+	Flags uint32
+	// ProtocolVersion
+	Version uint32
+}
+
+// Packet is a levin header and its payload
+type Packet struct {
+	Header
+	Body []byte
+}
+
+// IsResponse reports whether the packet answers a request
+func (h *Header) IsResponse() bool {
+	return h.Flags&FlagResponse != 0
+}
+
+// MarshalBinary encodes the header.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	b := make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint64(b[0:], Signature)
+	binary.LittleEndian.PutUint64(b[8:], h.Size)
+	if h.ExpectResponse {
+		b[16] = 1
+	}
+	binary.LittleEndian.PutUint32(b[17:], h.Command)
+	binary.LittleEndian.PutUint32(b[21:], uint32(h.ReturnCode))
+	binary.LittleEndian.PutUint32(b[25:], h.Flags)
+	binary.LittleEndian.PutUint32(b[29:], h.Version)
+	return b, nil
+}
+
+// UnmarshalBinary decodes a header.
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) != HeaderSize {
+		return fmt.Errorf("levin: header is %d bytes, not %d", len(b), HeaderSize)
+	}
+	if binary.LittleEndian.Uint64(b[0:]) != Signature {
+		return ErrSignature
+	}
+	h.Size = binary.LittleEndian.Uint64(b[8:])
+	h.ExpectResponse = b[16] != 0
+	h.Command = binary.LittleEndian.Uint32(b[17:])
+	h.ReturnCode = int32(binary.LittleEndian.Uint32(b[21:]))
+	h.Flags = binary.LittleEndian.Uint32(b[25:])
+	h.Version = binary.LittleEndian.Uint32(b[29:])
+	return nil
+}
+
+// ReadPacket reads a packet from r.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	p := &Packet{}
+	if err := p.Header.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	if p.Size > MaxPacketSize {
+		return nil, ErrPacketTooLarge
+	}
+	p.Body = make([]byte, p.Size)
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// WritePacket writes a packet to w, setting its size and version.
+func WritePacket(w io.Writer, p *Packet) error {
+	if len(p.Body) > MaxPacketSize {
+		return ErrPacketTooLarge
+	}
+	p.Size = uint64(len(p.Body))
+	p.Version = ProtocolVersion
+	hdr, err := p.Header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(hdr, p.Body...))
+	return err
+}
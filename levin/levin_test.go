@@ -0,0 +1,70 @@
+package levin
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacket(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WritePacket(&buf, &Packet{
+		Header: Header{ExpectResponse: true, Command: CommandPing, Flags: FlagRequest, ReturnCode: -1},
+		Body:   []byte{0xaa, 0xbb},
+	}))
+	assert.Equal(t, []byte{
+		0x01, 0x21, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, // signature
+		0x02, 0, 0, 0, 0, 0, 0, 0, // size
+		0x01,             // expect response
+		0xeb, 0x03, 0, 0, // command
+		0xff, 0xff, 0xff, 0xff, // return code
+		0x01, 0, 0, 0, // flags
+		0x01, 0, 0, 0, // version
+		0xaa, 0xbb,
+	}, buf.Bytes())
+
+	p, err := ReadPacket(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, &Packet{
+		Header: Header{Size: 2, ExpectResponse: true, Command: CommandPing, ReturnCode: -1, Flags: FlagRequest, Version: ProtocolVersion},
+		Body:   []byte{0xaa, 0xbb},
+	}, p)
+
+	_, err = ReadPacket(bytes.NewReader(buf.Bytes()[:HeaderSize+1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	bad := append([]byte{}, buf.Bytes()...)
+	bad[0] = 0
+	_, err = ReadPacket(bytes.NewReader(bad))
+	assert.Equal(t, ErrSignature, err)
+	large := append([]byte{}, buf.Bytes()...)
+	large[15] = 1
+	_, err = ReadPacket(bytes.NewReader(large))
+	assert.Equal(t, ErrPacketTooLarge, err)
+}
+
+func TestNetworkAddress(t *testing.T) {
+	a := NewNetworkAddress(net.ParseIP("192.168.1.2"), 18080)
+	assert.Equal(t, AddressIPv4, a.Type)
+	assert.Equal(t, uint32(0x0201a8c0), a.Addr.IP)
+	assert.Equal(t, "192.168.1.2:18080", a.String())
+
+	a = NewNetworkAddress(net.ParseIP("2001:db8::1"), 18080)
+	assert.Equal(t, AddressIPv6, a.Type)
+	assert.Equal(t, "[2001:db8::1]:18080", a.String())
+
+	a = NetworkAddress{Type: AddressTor}
+	a.Addr.Host = "example.onion"
+	a.Addr.HostPort = 18083
+	assert.Equal(t, "example.onion:18083", a.String())
+
+	e := PeerlistEntry{Address: NewNetworkAddress(net.ParseIP("1.2.3.4"), 18080), ID: 7, LastSeen: 1666000000}
+	p := e.Peer()
+	assert.Equal(t, "1.2.3.4", p.Host)
+	assert.Equal(t, uint64(0x04030201), p.IP)
+	assert.Equal(t, uint64(18080), p.Port)
+	assert.Equal(t, uint64(7), p.ID)
+	assert.Equal(t, uint64(1666000000), p.LastSeen)
+}
@@ -0,0 +1,393 @@
+package levin
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero/epee"
+)
+
+// Config default values
+const (
+	DefaultDialTimeout = 10 * time.Second
+	DefaultTimeout     = 30 * time.Second
+	DefaultBufferSize  = 16
+)
+
+// ErrClosed is returned when using a closed Peer
+var ErrClosed = errors.New("levin: peer closed")
+
+// Config configures a Peer
+type Config struct {
+	// Network of the peer. Defaults to Mainnet.
+	Network *Network
+	// Peer ID announced in the handshake. Random if 0.
+	PeerID uint64
+	// Blockchain state announced to the peer.
+	// Defaults to the genesis block of Network,
+	// so the peer has no reason to request blocks.
+	SyncData *CoreSyncData
+	// Support flags announced to the peer.
+	// Defaults to SupportFlagFluffyBlocks.
+	SupportFlags uint32
+	// Timeout when connecting to the peer.
+	DialTimeout time.Duration
+	// Timeout waiting for responses.
+	Timeout time.Duration
+	// Buffer size of the notification channels.
+	BufferSize int
+}
+
+// RemoteError is returned when a peer answers with a negative return code
+type RemoteError struct {
+	Command    uint32
+	ReturnCode int32
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("levin: command %d failed with return code %d", e.Command, e.ReturnCode)
+}
+
+// Peer is a levin connection to a monerod node.
+// Transactions and blocks relayed by the node are received on channels,
+// notifications are dropped when a channel is full.
+// Requests of the node (timed syncs, pings and support flags) are answered.
+type Peer struct {
+	// NOTIFY_NEW_TRANSACTIONS notifications
+	Transactions <-chan *NewTransactions
+	// NOTIFY_NEW_FLUFFY_BLOCK notifications
+	Blocks <-chan *NewFluffyBlock
+	// Response of the node to the handshake
+	Handshake *HandshakeResponse
+
+	cfg          Config
+	conn         net.Conn
+	transactions chan *NewTransactions
+	blocks       chan *NewFluffyBlock
+
+	invokeMu sync.Mutex
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	pending  map[uint32]chan *Packet
+	err      error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Dial connects to a node and performs the handshake.
+func Dial(addr string, cfg *Config) (*Peer, error) {
+	return DialContext(context.Background(), addr, cfg)
+}
+
+// DialContext connects to a node and performs the handshake.
+func DialContext(ctx context.Context, addr string, cfg *Config) (*Peer, error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Network == nil {
+		c.Network = &Mainnet
+	}
+	if c.PeerID == 0 {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		c.PeerID = binary.LittleEndian.Uint64(b[:])
+	}
+	if c.SyncData == nil {
+		c.SyncData = &CoreSyncData{
+			CurrentHeight: 1,
+			TopID:         c.Network.Genesis,
+			TopVersion:    1,
+		}
+	}
+	if c.SupportFlags == 0 {
+		c.SupportFlags = SupportFlagFluffyBlocks
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = DefaultBufferSize
+	}
+
+	d := net.Dialer{Timeout: c.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	p := &Peer{
+		cfg:          c,
+		conn:         conn,
+		transactions: make(chan *NewTransactions, c.BufferSize),
+		blocks:       make(chan *NewFluffyBlock, c.BufferSize),
+		pending:      make(map[uint32]chan *Packet),
+		done:         make(chan struct{}),
+	}
+	p.Transactions = p.transactions
+	p.Blocks = p.blocks
+
+	p.wg.Add(1)
+	go p.run()
+
+	// a cancelled context aborts the handshake
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Close()
+		case <-stop:
+		}
+	}()
+
+	req := HandshakeRequest{
+		NodeData: BasicNodeData{
+			NetworkID:    c.Network.ID,
+			PeerID:       c.PeerID,
+			SupportFlags: c.SupportFlags,
+		},
+		PayloadData: *c.SyncData,
+	}
+	resp := &HandshakeResponse{}
+	if err := p.Invoke(CommandHandshake, &req, resp); err != nil {
+		p.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if resp.NodeData.NetworkID != c.Network.ID {
+		p.Close()
+		return nil, fmt.Errorf("levin: peer is on network %x", resp.NodeData.NetworkID)
+	}
+	p.Handshake = resp
+	return p, nil
+}
+
+// Ping sends a COMMAND_PING request.
+func (p *Peer) Ping() (resp *PingResponse, err error) {
+	resp = &PingResponse{}
+	err = p.Invoke(CommandPing, &PingRequest{}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// TimedSync sends a COMMAND_TIMED_SYNC request,
+// returning the blockchain state and peer list of the node.
+func (p *Peer) TimedSync() (resp *TimedSyncResponse, err error) {
+	resp = &TimedSyncResponse{}
+	err = p.Invoke(CommandTimedSync, &TimedSyncRequest{PayloadData: *p.cfg.SyncData}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SupportFlags sends a COMMAND_REQUEST_SUPPORT_FLAGS request.
+func (p *Peer) SupportFlags() (resp *SupportFlagsResponse, err error) {
+	resp = &SupportFlagsResponse{}
+	err = p.Invoke(CommandSupportFlags, &SupportFlagsRequest{}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Invoke sends a request and decodes the response into out.
+// Levin does not number requests, so invocations are serialized.
+func (p *Peer) Invoke(command uint32, in, out interface{}) error {
+	body, err := epee.Marshal(in)
+	if err != nil {
+		return err
+	}
+	p.invokeMu.Lock()
+	defer p.invokeMu.Unlock()
+
+	ch := make(chan *Packet, 1)
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return p.err
+	}
+	p.pending[command] = ch
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, command)
+		p.mu.Unlock()
+	}()
+
+	if err := p.write(&Packet{
+		Header: Header{ExpectResponse: true, Command: command, Flags: FlagRequest},
+		Body:   body,
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.ReturnCode < 0 {
+			return &RemoteError{Command: command, ReturnCode: resp.ReturnCode}
+		}
+		return epee.Unmarshal(resp.Body, out)
+	case <-p.done:
+		return p.Err()
+	case <-time.After(p.cfg.Timeout):
+		return fmt.Errorf("levin: command %d timed out", command)
+	}
+}
+
+// Notify sends a notification, which is not answered.
+func (p *Peer) Notify(command uint32, in interface{}) error {
+	body, err := epee.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return p.write(&Packet{
+		Header: Header{Command: command, Flags: FlagRequest},
+		Body:   body,
+	})
+}
+
+// Done is closed when the connection is lost or closed.
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the error which ended the connection, nil while connected.
+func (p *Peer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Close disconnects from the node and closes the notification channels.
+func (p *Peer) Close() error {
+	err := ErrClosed
+	p.closeOnce.Do(func() {
+		err = nil
+		p.fail(ErrClosed)
+	})
+	p.wg.Wait()
+	return err
+}
+
+// fail ends the connection with err, keeping the first error
+func (p *Peer) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+		close(p.done)
+	}
+	p.mu.Unlock()
+	p.conn.Close()
+}
+
+func (p *Peer) write(pkt *Packet) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(p.cfg.Timeout))
+	if err := WritePacket(p.conn, pkt); err != nil {
+		p.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (p *Peer) run() {
+	defer p.wg.Done()
+	defer close(p.blocks)
+	defer close(p.transactions)
+
+	r := bufio.NewReader(p.conn)
+	for {
+		pkt, err := ReadPacket(r)
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		if err := p.dispatch(pkt); err != nil {
+			p.fail(err)
+			return
+		}
+	}
+}
+
+// dispatch hands responses to Invoke, answers requests and delivers notifications
+func (p *Peer) dispatch(pkt *Packet) error {
+	if pkt.IsResponse() {
+		p.mu.Lock()
+		ch := p.pending[pkt.Command]
+		p.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- pkt:
+			default:
+			}
+		}
+		return nil
+	}
+
+	if pkt.ExpectResponse {
+		var resp interface{}
+		switch pkt.Command {
+		case CommandTimedSync:
+			resp = &TimedSyncResponse{PayloadData: *p.cfg.SyncData}
+		case CommandPing:
+			resp = &PingResponse{Status: PingOK, PeerID: p.cfg.PeerID}
+		case CommandSupportFlags:
+			resp = &SupportFlagsResponse{SupportFlags: p.cfg.SupportFlags}
+		}
+		answer := &Packet{Header: Header{Command: pkt.Command, Flags: FlagResponse, ReturnCode: ErrorConnectionHandlerNotDefined}}
+		if resp != nil {
+			body, err := epee.Marshal(resp)
+			if err != nil {
+				return err
+			}
+			answer.ReturnCode = ReturnSuccess
+			answer.Body = body
+		}
+		return p.write(answer)
+	}
+
+	switch pkt.Command {
+	case NotifyNewTransactions:
+		v := &NewTransactions{DandelionppFluff: true}
+		if err := epee.Unmarshal(pkt.Body, v); err != nil {
+			return fmt.Errorf("levin: NOTIFY_NEW_TRANSACTIONS: %w", err)
+		}
+		select {
+		case p.transactions <- v:
+		default:
+		}
+	case NotifyNewFluffyBlock:
+		v := &NewFluffyBlock{}
+		if err := epee.Unmarshal(pkt.Body, v); err != nil {
+			return fmt.Errorf("levin: NOTIFY_NEW_FLUFFY_BLOCK: %w", err)
+		}
+		select {
+		case p.blocks <- v:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package levin
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/epee"
+	"github.com/stretchr/testify/assert"
+)
+
+// node is a stand-in monerod P2P node
+type node struct {
+	t       *testing.T
+	ln      net.Listener
+	network Network
+	peerID  uint64
+	peers   []PeerlistEntry
+
+	handshakes chan *HandshakeRequest
+	responses  chan *Packet
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newNode(t *testing.T, network Network, peerID uint64) *node {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &node{
+		t:          t,
+		ln:         ln,
+		network:    network,
+		peerID:     peerID,
+		handshakes: make(chan *HandshakeRequest, 16),
+		responses:  make(chan *Packet, 16),
+	}
+	go n.accept()
+	return n
+}
+
+func (n *node) addr() string {
+	return n.ln.Addr().String()
+}
+
+func (n *node) entry() PeerlistEntry {
+	a := n.ln.Addr().(*net.TCPAddr)
+	return PeerlistEntry{Address: NewNetworkAddress(a.IP, uint16(a.Port)), ID: n.peerID}
+}
+
+func (n *node) accept() {
+	for {
+		conn, err := n.ln.Accept()
+		if err != nil {
+			return
+		}
+		n.mu.Lock()
+		n.conns = append(n.conns, conn)
+		n.mu.Unlock()
+		go n.serve(conn)
+	}
+}
+
+func (n *node) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		pkt, err := ReadPacket(r)
+		if err != nil {
+			return
+		}
+		if pkt.IsResponse() {
+			n.responses <- pkt
+			continue
+		}
+		var resp interface{}
+		switch pkt.Command {
+		case CommandHandshake:
+			req := &HandshakeRequest{}
+			if err := epee.Unmarshal(pkt.Body, req); err != nil {
+				n.t.Error(err)
+				return
+			}
+			n.handshakes <- req
+			resp = &HandshakeResponse{
+				NodeData:      BasicNodeData{NetworkID: n.network.ID, PeerID: n.peerID, MyPort: 18080},
+				PayloadData:   CoreSyncData{CurrentHeight: 2731375, TopID: [32]byte{1}, TopVersion: 16},
+				LocalPeerlist: n.peers,
+			}
+		case CommandPing:
+			resp = &PingResponse{Status: PingOK, PeerID: n.peerID}
+		case CommandTimedSync:
+			resp = &TimedSyncResponse{PayloadData: CoreSyncData{CurrentHeight: 2731376}}
+		}
+		answer := &Packet{Header: Header{Command: pkt.Command, Flags: FlagResponse, ReturnCode: ErrorConnectionHandlerNotDefined}}
+		if resp != nil {
+			if answer.Body, err = epee.Marshal(resp); err != nil {
+				n.t.Error(err)
+				return
+			}
+			answer.ReturnCode = ReturnSuccess
+		}
+		if err := WritePacket(conn, answer); err != nil {
+			return
+		}
+	}
+}
+
+// send writes a packet to every connection
+func (n *node) send(pkt *Packet) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		if err := WritePacket(conn, pkt); err != nil {
+			n.t.Error(err)
+		}
+	}
+}
+
+func (n *node) notify(command uint32, v interface{}) {
+	body, err := epee.Marshal(v)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	n.send(&Packet{Header: Header{Command: command, Flags: FlagRequest}, Body: body})
+}
+
+func (n *node) close() {
+	n.ln.Close()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+}
+
+func TestPeer(t *testing.T) {
+	n := newNode(t, Stagenet, 42)
+	defer n.close()
+
+	p, err := Dial(n.addr(), &Config{Network: &Stagenet, PeerID: 7})
+	assert.NoError(t, err)
+
+	t.Run("handshakes", func(t *testing.T) {
+		req := <-n.handshakes
+		assert.Equal(t, Stagenet.ID, req.NodeData.NetworkID)
+		assert.Equal(t, uint64(7), req.NodeData.PeerID)
+		assert.Equal(t, uint32(0), req.NodeData.MyPort)
+		assert.Equal(t, SupportFlagFluffyBlocks, req.NodeData.SupportFlags)
+		assert.Equal(t, CoreSyncData{CurrentHeight: 1, TopID: Stagenet.Genesis, TopVersion: 1}, req.PayloadData)
+
+		assert.Equal(t, uint64(42), p.Handshake.NodeData.PeerID)
+		assert.Equal(t, uint64(2731375), p.Handshake.PayloadData.CurrentHeight)
+		assert.Empty(t, p.Handshake.LocalPeerlist)
+	})
+
+	t.Run("invokes commands", func(t *testing.T) {
+		ping, err := p.Ping()
+		assert.NoError(t, err)
+		assert.Equal(t, &PingResponse{Status: PingOK, PeerID: 42}, ping)
+
+		sync, err := p.TimedSync()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2731376), sync.PayloadData.CurrentHeight)
+
+		_, err = p.SupportFlags()
+		assert.Equal(t, &RemoteError{Command: CommandSupportFlags, ReturnCode: ErrorConnectionHandlerNotDefined}, err)
+	})
+
+	t.Run("answers requests", func(t *testing.T) {
+		n.send(&Packet{Header: Header{ExpectResponse: true, Command: CommandPing, Flags: FlagRequest}, Body: mustMarshal(t, &PingRequest{})})
+		resp := <-n.responses
+		assert.Equal(t, ReturnSuccess, resp.ReturnCode)
+		ping := &PingResponse{}
+		assert.NoError(t, epee.Unmarshal(resp.Body, ping))
+		assert.Equal(t, &PingResponse{Status: PingOK, PeerID: 7}, ping)
+
+		n.send(&Packet{Header: Header{ExpectResponse: true, Command: CommandSupportFlags, Flags: FlagRequest}, Body: mustMarshal(t, &SupportFlagsRequest{})})
+		resp = <-n.responses
+		flags := &SupportFlagsResponse{}
+		assert.NoError(t, epee.Unmarshal(resp.Body, flags))
+		assert.Equal(t, SupportFlagFluffyBlocks, flags.SupportFlags)
+
+		n.send(&Packet{Header: Header{ExpectResponse: true, Command: 1234, Flags: FlagRequest}})
+		resp = <-n.responses
+		assert.Equal(t, ErrorConnectionHandlerNotDefined, resp.ReturnCode)
+	})
+
+	t.Run("receives notifications", func(t *testing.T) {
+		n.notify(NotifyNewTransactions, &NewTransactions{Txs: [][]byte{{1, 2}, {3}}, Padding: "xx"})
+		select {
+		case txs := <-p.Transactions:
+			assert.Equal(t, [][]byte{{1, 2}, {3}}, txs.Txs)
+			assert.False(t, txs.DandelionppFluff)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for transactions")
+		}
+
+		n.notify(NotifyNewFluffyBlock, &NewFluffyBlock{
+			Block:                   daemon.BlockCompleteEntry{Block: []byte{0x10, 0x10}, Txs: [][]byte{{4}}},
+			CurrentBlockchainHeight: 2731376,
+		})
+		select {
+		case b := <-p.Blocks:
+			assert.Equal(t, []byte{0x10, 0x10}, b.Block.Block)
+			assert.Equal(t, [][]byte{{4}}, b.Block.Txs)
+			assert.Equal(t, uint64(2731376), b.CurrentBlockchainHeight)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for block")
+		}
+	})
+
+	assert.NoError(t, p.Close())
+	assert.Equal(t, ErrClosed, p.Close())
+	assert.Equal(t, ErrClosed, p.Err())
+	_, err = p.Ping()
+	assert.Equal(t, ErrClosed, err)
+	_, ok := <-p.Transactions
+	assert.False(t, ok)
+}
+
+func TestPeerErrors(t *testing.T) {
+	n := newNode(t, Testnet, 42)
+	defer n.close()
+
+	_, err := Dial(n.addr(), &Config{Network: &Mainnet})
+	assert.EqualError(t, err, "levin: peer is on network 1230f171610441611731008216a1a111")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DialContext(ctx, n.addr(), nil)
+	assert.Equal(t, context.Canceled, err)
+
+	p, err := Dial(n.addr(), &Config{Network: &Testnet})
+	assert.NoError(t, err)
+	n.close()
+	select {
+	case <-p.Done():
+		assert.Error(t, p.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for disconnection")
+	}
+	assert.NoError(t, p.Close())
+}
+
+func TestCrawl(t *testing.T) {
+	a := newNode(t, Mainnet, 1)
+	defer a.close()
+	b := newNode(t, Mainnet, 2)
+	defer b.close()
+	c := newNode(t, Mainnet, 3)
+	defer c.close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	dead := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	tor := NetworkAddress{Type: AddressTor}
+	tor.Addr.Host = "example.onion"
+	a.peers = []PeerlistEntry{b.entry(), {Address: tor}}
+	b.peers = []PeerlistEntry{a.entry(), c.entry(), {Address: NewNetworkAddress(dead.IP, uint16(dead.Port))}}
+
+	results, err := Crawl(context.Background(), []string{a.addr()}, &CrawlConfig{Concurrency: 2})
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	ids := make(map[string]uint64)
+	for _, r := range results {
+		if r.Err != nil {
+			ids[r.Address] = 0
+			continue
+		}
+		ids[r.Address] = r.Handshake.NodeData.PeerID
+	}
+	assert.Equal(t, map[string]uint64{a.addr(): 1, b.addr(): 2, c.addr(): 3, dead.String(): 0}, ids)
+
+	results, err = Crawl(context.Background(), []string{a.addr()}, &CrawlConfig{MaxNodes: 2})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := epee.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
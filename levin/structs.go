@@ -0,0 +1,260 @@
+package levin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// Network identifies a monero network on the P2P layer
+type Network struct {
+	// Sent in handshakes, nodes of other networks drop the connection.
+	ID [16]byte
+	// Hash of the genesis block.
+	Genesis [32]byte
+}
+
+// Monero networks
+var (
+	Mainnet = Network{
+		ID:      [16]byte{0x12, 0x30, 0xf1, 0x71, 0x61, 0x04, 0x41, 0x61, 0x17, 0x31, 0x00, 0x82, 0x16, 0xa1, 0xa1, 0x10},
+		Genesis: mustHash("418015bb9ae982a1975da7d79277c2705727a56894ba0fb246adaabb1f4632e3"),
+	}
+	Testnet = Network{
+		ID:      [16]byte{0x12, 0x30, 0xf1, 0x71, 0x61, 0x04, 0x41, 0x61, 0x17, 0x31, 0x00, 0x82, 0x16, 0xa1, 0xa1, 0x11},
+		Genesis: mustHash("48ca7cd3c8de5b6a4d53d2861fbdaedca141553559f9be9520068053cda8430b"),
+	}
+	Stagenet = Network{
+		ID:      [16]byte{0x12, 0x30, 0xf1, 0x71, 0x61, 0x04, 0x41, 0x61, 0x17, 0x31, 0x00, 0x82, 0x16, 0xa1, 0xa1, 0x12},
+		Genesis: mustHash("76ee3cc98646292206cd3e86f74d88b4dcc1d937088645e9b0cbca84b7ce74eb"),
+	}
+)
+
+func mustHash(s string) [32]byte {
+	var h [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		panic("levin: invalid hash " + s)
+	}
+	copy(h[:], b)
+	return h
+}
+
+// Support flags
+const (
+	// SupportFlagFluffyBlocks announces support for NOTIFY_NEW_FLUFFY_BLOCK
+	SupportFlagFluffyBlocks uint32 = 0x01
+)
+
+// PingOK is the status of successful ping responses
+const PingOK = "OK"
+
+// BasicNodeData describes a node in handshakes
+type BasicNodeData struct {
+	// Network ID of the node
+	NetworkID [16]byte `epee:"network_id"`
+	// Random ID of the node
+	PeerID uint64 `epee:"peer_id"`
+	// P2P port the node accepts connections on, 0 if none
+	MyPort uint32 `epee:"my_port"`
+	// Restricted RPC port of the node, 0 if not advertised
+	RPCPort uint16 `epee:"rpc_port,omitempty"`
+	// RPC payment credits per hash, 0 if free
+	RPCCreditsPerHash uint32 `epee:"rpc_credits_per_hash,omitempty"`
+	// Support flags of the node
+	SupportFlags uint32 `epee:"support_flags,omitempty"`
+}
+
+// CoreSyncData is the state of the blockchain of a node
+type CoreSyncData struct {
+	// Height of the blockchain of the node
+	CurrentHeight uint64 `epee:"current_height"`
+	// Low 64 bits of the cumulative difficulty
+	CumulativeDifficulty uint64 `epee:"cumulative_difficulty"`
+	// High 64 bits of the cumulative difficulty
+	CumulativeDifficultyTop64 uint64 `epee:"cumulative_difficulty_top64"`
+	// Hash of the top block
+	TopID [32]byte `epee:"top_id"`
+	// Major version of the top block
+	TopVersion uint8 `epee:"top_version,omitempty"`
+	// Pruning seed, 0 if the node is not pruned
+	PruningSeed uint32 `epee:"pruning_seed,omitempty"`
+}
+
+// Network address types
+const (
+	AddressIPv4 uint8 = 1
+	AddressIPv6 uint8 = 2
+	AddressI2P  uint8 = 3
+	AddressTor  uint8 = 4
+)
+
+// NetworkAddress is the address of a peer
+type NetworkAddress struct {
+	// One of the Address* types
+	Type uint8 `epee:"type"`
+	// Address, which fields are set depends on Type
+	Addr struct {
+		// IPv4 address in network byte order, read as little endian
+		IP uint32 `epee:"m_ip,omitempty"`
+		// Port of IPv4 and IPv6 addresses
+		Port uint16 `epee:"m_port,omitempty"`
+		// IPv6 address
+		IPv6 [16]byte `epee:"addr,omitempty"`
+		// Hostname of Tor and I2P addresses
+		Host string `epee:"host,omitempty"`
+		// Port of Tor and I2P addresses
+		HostPort uint16 `epee:"port,omitempty"`
+	} `epee:"addr"`
+}
+
+// NewNetworkAddress returns the address of an IPv4 or IPv6 peer
+func NewNetworkAddress(ip net.IP, port uint16) NetworkAddress {
+	var a NetworkAddress
+	if ip4 := ip.To4(); ip4 != nil {
+		a.Type = AddressIPv4
+		a.Addr.IP = binary.LittleEndian.Uint32(ip4)
+		a.Addr.Port = port
+		return a
+	}
+	a.Type = AddressIPv6
+	copy(a.Addr.IPv6[:], ip.To16())
+	a.Addr.Port = port
+	return a
+}
+
+// Host returns the IP address or hostname of the peer
+func (a NetworkAddress) Host() string {
+	switch a.Type {
+	case AddressIPv4:
+		ip := make(net.IP, 4)
+		binary.LittleEndian.PutUint32(ip, a.Addr.IP)
+		return ip.String()
+	case AddressIPv6:
+		return net.IP(a.Addr.IPv6[:]).String()
+	}
+	return a.Addr.Host
+}
+
+// Port returns the P2P port of the peer
+func (a NetworkAddress) Port() uint16 {
+	if a.Type == AddressIPv4 || a.Type == AddressIPv6 {
+		return a.Addr.Port
+	}
+	return a.Addr.HostPort
+}
+
+// String returns the address as host:port
+func (a NetworkAddress) String() string {
+	return net.JoinHostPort(a.Host(), strconv.Itoa(int(a.Port())))
+}
+
+// PeerlistEntry is a peer shared in handshakes and timed syncs
+type PeerlistEntry struct {
+	// Address of the peer
+	Address NetworkAddress `epee:"adr"`
+	// Peer ID
+	ID uint64 `epee:"id"`
+	// Unix time at which the peer was last seen
+	LastSeen int64 `epee:"last_seen,omitempty"`
+	// Pruning seed, 0 if the peer is not pruned
+	PruningSeed uint32 `epee:"pruning_seed,omitempty"`
+	// Restricted RPC port of the peer, 0 if not advertised
+	RPCPort uint16 `epee:"rpc_port,omitempty"`
+	// RPC payment credits per hash, 0 if free
+	RPCCreditsPerHash uint32 `epee:"rpc_credits_per_hash,omitempty"`
+}
+
+// Peer returns the entry as reported by daemon.GetPeerList
+func (e PeerlistEntry) Peer() daemon.Peer {
+	p := daemon.Peer{
+		Host: e.Address.Host(),
+		ID:   e.ID,
+		Port: uint64(e.Address.Port()),
+	}
+	if e.LastSeen > 0 {
+		p.LastSeen = uint64(e.LastSeen)
+	}
+	if e.Address.Type == AddressIPv4 {
+		p.IP = uint64(e.Address.Addr.IP)
+	}
+	return p
+}
+
+// HandshakeRequest is a struct for COMMAND_HANDSHAKE requests
+type HandshakeRequest struct {
+	// Node sending the request
+	NodeData BasicNodeData `epee:"node_data"`
+	// Blockchain state of the node
+	PayloadData CoreSyncData `epee:"payload_data"`
+}
+
+// HandshakeResponse is a struct for COMMAND_HANDSHAKE responses
+type HandshakeResponse struct {
+	// Node answering the request
+	NodeData BasicNodeData `epee:"node_data"`
+	// Blockchain state of the node
+	PayloadData CoreSyncData `epee:"payload_data"`
+	// Peers known by the node
+	LocalPeerlist []PeerlistEntry `epee:"local_peerlist_new"`
+}
+
+// TimedSyncRequest is a struct for COMMAND_TIMED_SYNC requests
+type TimedSyncRequest struct {
+	// Blockchain state of the node
+	PayloadData CoreSyncData `epee:"payload_data"`
+}
+
+// TimedSyncResponse is a struct for COMMAND_TIMED_SYNC responses
+type TimedSyncResponse struct {
+	// Blockchain state of the node
+	PayloadData CoreSyncData `epee:"payload_data"`
+	// Peers known by the node
+	LocalPeerlist []PeerlistEntry `epee:"local_peerlist_new"`
+}
+
+// PingRequest is a struct for COMMAND_PING requests
+type PingRequest struct {
+	// None
+}
+
+// PingResponse is a struct for COMMAND_PING responses
+type PingResponse struct {
+	// PingOK
+	Status string `epee:"status"`
+	// Peer ID of the node
+	PeerID uint64 `epee:"peer_id"`
+}
+
+// SupportFlagsRequest is a struct for COMMAND_REQUEST_SUPPORT_FLAGS requests
+type SupportFlagsRequest struct {
+	// None
+}
+
+// SupportFlagsResponse is a struct for COMMAND_REQUEST_SUPPORT_FLAGS responses
+type SupportFlagsResponse struct {
+	// Support flags of the node
+	SupportFlags uint32 `epee:"support_flags"`
+}
+
+// NewTransactions is a struct for NOTIFY_NEW_TRANSACTIONS notifications
+type NewTransactions struct {
+	// Transaction blobs
+	Txs [][]byte `epee:"txs"`
+	// Random padding
+	Padding string `epee:"_,omitempty"`
+	// False while the transactions are in the dandelion++ stem phase
+	DandelionppFluff bool `epee:"dandelionpp_fluff"`
+}
+
+// NewFluffyBlock is a struct for NOTIFY_NEW_FLUFFY_BLOCK notifications.
+// Txs only holds the transactions the receiver is not expected to have.
+type NewFluffyBlock struct {
+	// The block
+	Block daemon.BlockCompleteEntry `epee:"b"`
+	// Height of the blockchain of the sender
+	CurrentBlockchainHeight uint64 `epee:"current_blockchain_height"`
+}
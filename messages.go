@@ -0,0 +1,132 @@
+package gonero
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/konraddical2/gonero/base58"
+	"github.com/konraddical2/gonero/crypto"
+)
+
+// headers of message signatures
+const (
+	messageSigV1 = "SigV1"
+	messageSigV2 = "SigV2"
+)
+
+// messagePrefix is the domain separator of v2 message signatures, with
+// its terminating zero
+var messagePrefix = []byte("MoneroMessageSignature\x00")
+
+// ErrInvalidMessageSignature is returned when a message signature is
+// malformed or does not match the message and the address.
+var ErrInvalidMessageSignature = errors.New("invalid message signature")
+
+// MessageKey is the key of an address a message is signed with
+type MessageKey int
+
+// Keys signing messages
+const (
+	// MessageSpendKey signatures prove the ownership of the address.
+	MessageSpendKey MessageKey = iota
+	// MessageViewKey signatures only prove the knowledge of its private
+	// view key.
+	MessageViewKey
+)
+
+// String returns the name of the key as in the wallet RPC.
+func (k MessageKey) String() string {
+	if k == MessageViewKey {
+		return "view"
+	}
+	return "spend"
+}
+
+// SignMessage signs data with the private spend key of the address, as
+// wallet.Sign does, and returns a SigV2 signature. The key of a
+// subaddress is given by Address.SubaddressSpendKey.
+func (b *baseAddress) SignMessage(data string, privateSpendKey Secret) (string, error) {
+	if len(b.decoded) == 0 {
+		return "", fmt.Errorf("invalid address")
+	}
+	spend, err := b.privateKey(privateSpendKey.Reveal(), MessageSpendKey)
+	if err != nil {
+		return "", fmt.Errorf("private spend key does not match address")
+	}
+	sig, err := crypto.Sign(b.messageHash(data, MessageSpendKey), spend)
+	if err != nil {
+		return "", err
+	}
+	return messageSigV2 + base58.Encode(sig.Bytes()), nil
+}
+
+// VerifyMessage verifies a SigV1 or SigV2 signature of data by the
+// address, as wallet.Verify does, and returns the key it was signed with.
+// Only MessageSpendKey signatures prove the ownership of the address.
+func (b *baseAddress) VerifyMessage(data, signature string) (MessageKey, error) {
+	if len(b.decoded) == 0 {
+		return 0, fmt.Errorf("invalid address")
+	}
+	var v2 bool
+	switch {
+	case strings.HasPrefix(signature, messageSigV1):
+	case strings.HasPrefix(signature, messageSigV2):
+		v2 = true
+	default:
+		return 0, fmt.Errorf("%w: unknown header", ErrInvalidMessageSignature)
+	}
+	// both headers have the same length
+	sig, err := crypto.ParseSignature(base58.Decode(signature[len(messageSigV1):]))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidMessageSignature, err)
+	}
+	// v1 signatures sign the hash of data with either key
+	hash := crypto.Keccak256([]byte(data))
+	// spend key signatures first, then view key signatures
+	for _, key := range []MessageKey{MessageSpendKey, MessageViewKey} {
+		if v2 {
+			hash = b.messageHash(data, key)
+		}
+		if crypto.VerifySignature(hash, b.publicKey(key), sig) == nil {
+			return key, nil
+		}
+	}
+	return 0, ErrInvalidMessageSignature
+}
+
+// messageHash returns the hash of data signed by v2 signatures with the
+// key of the address
+func (b *baseAddress) messageHash(data string, key MessageKey) crypto.Key {
+	size := make([]byte, binary.MaxVarintLen64)
+	size = size[:binary.PutUvarint(size, uint64(len(data)))]
+	return crypto.Keccak256(messagePrefix, b.decoded[1:65], []byte{byte(key)}, size, []byte(data))
+}
+
+// publicKey returns the public key of the address signing with key
+func (b *baseAddress) publicKey(key MessageKey) crypto.Key {
+	var pub crypto.Key
+	if key == MessageViewKey {
+		copy(pub[:], b.decoded[33:65])
+	} else {
+		copy(pub[:], b.decoded[1:33])
+	}
+	return pub
+}
+
+// privateKey parses the private key of the address signing with key
+func (b *baseAddress) privateKey(hexKey string, key MessageKey) (crypto.SecretKey, error) {
+	sec, err := crypto.ParseSecretKey(hexKey)
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+	pub, err := sec.PublicKey()
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+	if pub != b.publicKey(key) {
+		return crypto.SecretKey{}, fmt.Errorf("private key does not match public key")
+	}
+	return sec, nil
+}
@@ -0,0 +1,435 @@
+// Package payout sends batches of payouts exactly once.
+//
+// Each batch is journaled before its transactions are created with
+// TransferSplit, which does not relay them. Their txids and metadata are
+// journaled next, and only then are they relayed with RelayTx. After a
+// crash, Resume relays the transactions GetTransferByTxid does not know
+// yet, so that a payout is neither sent twice nor lost.
+package payout
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// DefaultMaxDestinations is the default number of payouts per batch. A
+// transaction has at most 16 outputs, one of which is the change.
+const DefaultMaxDestinations = 15
+
+// Payout errors
+var (
+	ErrInvalidPayout   = errors.New("payout: invalid payout")
+	ErrDuplicatePayout = errors.New("payout: duplicate payout")
+	ErrFeeTooHigh      = errors.New("payout: fee too high")
+	ErrNotFound        = errors.New("payout: batch not found")
+	ErrAlreadySent     = errors.New("payout: batch already sent")
+)
+
+// BatchState is the progress of a batch
+type BatchState string
+
+const (
+	// BatchPending batches are journaled, their transactions are not created yet
+	BatchPending BatchState = "pending"
+	// BatchCreated batches have their transactions journaled, some may be relayed
+	BatchCreated BatchState = "created"
+	// BatchSent batches have every transaction relayed
+	BatchSent BatchState = "sent"
+	// BatchFailed batches have no transaction relayed, their payouts may be paid again
+	BatchFailed BatchState = "failed"
+)
+
+// unresolved tells whether a batch may still send transactions
+func (s BatchState) unresolved() bool {
+	return s == BatchPending || s == BatchCreated
+}
+
+// Payout is an amount to send to an address
+type Payout struct {
+	// Unique identifier chosen by the caller, paying it twice is a no-op.
+	ID string `json:"id"`
+	// Destination address.
+	Address string `json:"address"`
+	// Amount to send, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+}
+
+// Tx is a transaction of a batch
+type Tx struct {
+	// Transaction ID.
+	Txid string `json:"txid"`
+	// Metadata given to RelayTx.
+	Metadata string `json:"metadata"`
+	// Amount sent, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Network fee, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+	// True once the transaction is relayed.
+	Relayed bool `json:"relayed"`
+}
+
+// Batch is a set of payouts sent together
+type Batch struct {
+	// Random identifier.
+	ID string `json:"id"`
+	// Progress of the batch.
+	State BatchState `json:"state"`
+	// Payouts of the batch.
+	Payouts []Payout `json:"payouts"`
+	// Transactions paying the payouts, as few as the size limits allow.
+	Txs []Tx `json:"txs,omitempty"`
+	// Network fee of every transaction, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee,omitempty"`
+	// Why the batch failed.
+	Error string `json:"error,omitempty"`
+	// Time the batch was journaled.
+	CreatedAt time.Time `json:"created_at"`
+	// Time the batch last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (b *Batch) clone() *Batch {
+	c := *b
+	c.Payouts = append([]Payout{}, b.Payouts...)
+	c.Txs = append([]Tx{}, b.Txs...)
+	return &c
+}
+
+// Config configures an Engine
+type Config struct {
+	// Journal of the batches. Defaults to a MemoryStore.
+	Store Store
+	// Account paying the payouts.
+	AccountIndex uint64
+	// Priority of the transactions.
+	Priority wallet.PriorityType
+	// Payouts per batch, defaults to DefaultMaxDestinations.
+	MaxDestinations int
+	// Fee above which a batch fails instead of being relayed, 0 for no limit.
+	MaxFee gonero.AtomicXMR
+}
+
+// Engine pays payouts in batches. Its methods are serialized.
+type Engine struct {
+	cfg    Config
+	client wallet.Client
+
+	mu      sync.Mutex
+	batches map[string]*Batch
+	order   []*Batch
+	payouts map[string]*Batch // payout ID -> batch which is not failed
+}
+
+// New loads the journal of the engine. Unresolved batches are resumed by
+// the first call to Pay or Resume.
+func New(client wallet.Client, cfg *Config) (*Engine, error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.MaxDestinations <= 0 {
+		c.MaxDestinations = DefaultMaxDestinations
+	}
+
+	e := &Engine{
+		cfg:     c,
+		client:  client,
+		batches: make(map[string]*Batch),
+		payouts: make(map[string]*Batch),
+	}
+	batches, err := c.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range batches {
+		e.index(b)
+	}
+	return e, nil
+}
+
+// Pay resumes the unresolved batches, then journals and sends the new
+// payouts in batches of at most MaxDestinations. It returns the batches of
+// the payouts, including those of payouts paid before. Payouts of failed
+// batches are paid again. On error, the payouts of the returned batches
+// are journaled and the others are not.
+func (e *Engine) Pay(payouts []Payout) ([]*Batch, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, p := range payouts {
+		if p.ID == "" || p.Address == "" || p.Amount == 0 {
+			return nil, fmt.Errorf("%w %q", ErrInvalidPayout, p.ID)
+		}
+		if seen[p.ID] {
+			return nil, fmt.Errorf("%w %q", ErrDuplicatePayout, p.ID)
+		}
+		seen[p.ID] = true
+	}
+	if err := e.resume(); err != nil {
+		return nil, err
+	}
+
+	var result []*Batch
+	var fresh []Payout
+	included := make(map[string]bool)
+	for _, p := range payouts {
+		if b, ok := e.payouts[p.ID]; ok {
+			if !included[b.ID] {
+				included[b.ID] = true
+				result = append(result, b.clone())
+			}
+			continue
+		}
+		fresh = append(fresh, p)
+	}
+	for len(fresh) > 0 {
+		n := e.cfg.MaxDestinations
+		if n > len(fresh) {
+			n = len(fresh)
+		}
+		b, err := e.journal(fresh[:n])
+		if err != nil {
+			return result, err
+		}
+		err = e.send(b, false)
+		result = append(result, b.clone())
+		if err != nil {
+			return result, err
+		}
+		fresh = fresh[n:]
+	}
+	return result, nil
+}
+
+// Resume sends the batches interrupted by a crash or an error. Created
+// transactions which GetTransferByTxid knows are marked relayed instead of
+// being relayed again.
+func (e *Engine) Resume() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resume()
+}
+
+// Abandon fails a batch which cannot be relayed, such as one whose inputs
+// were spent since. Its payouts may be paid again. Batches with a relayed
+// transaction, or one known by the wallet, return ErrAlreadySent.
+func (e *Engine) Abandon(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.batches[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch b.State {
+	case BatchFailed:
+		return nil
+	case BatchSent:
+		return ErrAlreadySent
+	}
+	for _, tx := range b.Txs {
+		if tx.Relayed {
+			return ErrAlreadySent
+		}
+		known, err := e.known(tx.Txid)
+		if err != nil {
+			return err
+		}
+		if known {
+			return ErrAlreadySent
+		}
+	}
+	err := errors.New("payout: batch abandoned")
+	if ferr := e.fail(b, err); ferr != err {
+		return ferr
+	}
+	return nil
+}
+
+// Batch returns a batch, or ErrNotFound.
+func (e *Engine) Batch(id string) (*Batch, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b, ok := e.batches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b.clone(), nil
+}
+
+// Batches returns every batch, oldest first.
+func (e *Engine) Batches() []*Batch {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	batches := make([]*Batch, len(e.order))
+	for i, b := range e.order {
+		batches[i] = b.clone()
+	}
+	return batches
+}
+
+// resume sends the unresolved batches, with e.mu held
+func (e *Engine) resume() error {
+	for _, b := range e.order {
+		if b.State.unresolved() {
+			if err := e.send(b, true); err != nil {
+				return fmt.Errorf("payout: resume batch %s: %w", b.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// journal stores a new pending batch, with e.mu held
+func (e *Engine) journal(payouts []Payout) (*Batch, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	b := &Batch{
+		ID:        hex.EncodeToString(id),
+		State:     BatchPending,
+		Payouts:   append([]Payout{}, payouts...),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.cfg.Store.Put(b); err != nil {
+		return nil, err
+	}
+	e.index(b)
+	return b, nil
+}
+
+// send creates and relays the transactions of a batch, with e.mu held.
+// Pending batches never relayed a transaction, so they are created again.
+func (e *Engine) send(b *Batch, check bool) error {
+	if b.State == BatchPending {
+		if err := e.create(b); err != nil {
+			return err
+		}
+	}
+	for i := range b.Txs {
+		if b.Txs[i].Relayed {
+			continue
+		}
+		relayed := false
+		if check {
+			known, err := e.known(b.Txs[i].Txid)
+			if err != nil {
+				return err
+			}
+			relayed = known
+		}
+		if !relayed {
+			if _, err := e.client.RelayTx(&wallet.RelayTxRequest{Hex: b.Txs[i].Metadata}); err != nil {
+				return err
+			}
+		}
+		b.Txs[i].Relayed = true
+		if err := e.put(b); err != nil {
+			return err
+		}
+	}
+	b.State = BatchSent
+	return e.put(b)
+}
+
+// create creates the transactions of a pending batch without relaying
+// them, with e.mu held
+func (e *Engine) create(b *Batch) error {
+	req := &wallet.TransferSplitRequest{
+		AccountIndex:  e.cfg.AccountIndex,
+		Priority:      e.cfg.Priority,
+		DoNotRelay:    true,
+		GetTxMetadata: true,
+	}
+	for _, p := range b.Payouts {
+		req.Destinations = append(req.Destinations, wallet.Destination{Amount: p.Amount, Address: p.Address})
+	}
+	resp, err := e.client.TransferSplit(req)
+	if err != nil {
+		return e.fail(b, err)
+	}
+	n := len(resp.TxHashList)
+	if n == 0 || len(resp.TxMetadataList) != n || len(resp.FeeList) != n || len(resp.AmountList) != n {
+		return e.fail(b, errors.New("payout: transfer_split returned no metadata"))
+	}
+	var txs []Tx
+	var fee gonero.AtomicXMR
+	for i, txid := range resp.TxHashList {
+		txs = append(txs, Tx{
+			Txid:     txid,
+			Metadata: resp.TxMetadataList[i],
+			Amount:   gonero.AtomicXMR(resp.AmountList[i]),
+			Fee:      gonero.AtomicXMR(resp.FeeList[i]),
+		})
+		fee += gonero.AtomicXMR(resp.FeeList[i])
+	}
+	if e.cfg.MaxFee > 0 && fee > e.cfg.MaxFee {
+		return e.fail(b, fmt.Errorf("%w: %s XMR", ErrFeeTooHigh, fee.Decimal()))
+	}
+
+	prev := *b
+	b.State = BatchCreated
+	b.Txs = txs
+	b.Fee = fee
+	if err := e.put(b); err != nil {
+		*b = prev
+		return err
+	}
+	return nil
+}
+
+// fail marks a batch failed and returns err, with e.mu held
+func (e *Engine) fail(b *Batch, err error) error {
+	b.State = BatchFailed
+	b.Error = err.Error()
+	if perr := e.put(b); perr != nil {
+		return perr
+	}
+	for _, p := range b.Payouts {
+		if e.payouts[p.ID] == b {
+			delete(e.payouts, p.ID)
+		}
+	}
+	return err
+}
+
+// known tells whether the wallet knows a transaction, which is relayed then
+func (e *Engine) known(txid string) (bool, error) {
+	resp, err := e.client.GetTransferByTxid(&wallet.GetTransferByTxidRequest{Txid: txid, AccountIndex: e.cfg.AccountIndex})
+	if rerr, ok := err.(*wallet.RPCError); ok && rerr.Code == wallet.ErrWrongTxid {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.Transfer.Type != "failed", nil
+}
+
+// put journals a batch, with e.mu held
+func (e *Engine) put(b *Batch) error {
+	b.UpdatedAt = time.Now()
+	return e.cfg.Store.Put(b)
+}
+
+// index adds a batch to the engine, with e.mu held
+func (e *Engine) index(b *Batch) {
+	e.batches[b.ID] = b
+	e.order = append(e.order, b)
+	if b.State != BatchFailed {
+		for _, p := range b.Payouts {
+			e.payouts[p.ID] = b
+		}
+	}
+}
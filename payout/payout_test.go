@@ -0,0 +1,274 @@
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// maxOutputs is the number of destinations per transaction of the fake wallet
+const maxOutputs = 4
+
+// fakeWallet is a stand-in wallet splitting transfers and counting broadcasts
+type fakeWallet struct {
+	wallet.Client
+
+	mu         sync.Mutex
+	created    int
+	broadcasts map[string]int
+	splitErr   error
+	// relayFault fails a relay, after broadcasting the transaction if broadcast is set
+	relayFault func(txid string) (broadcast bool, err error)
+}
+
+func newFakeWallet() *fakeWallet {
+	return &fakeWallet{broadcasts: make(map[string]int)}
+}
+
+func (w *fakeWallet) TransferSplit(req *wallet.TransferSplitRequest) (*wallet.TransferSplitResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !req.DoNotRelay || !req.GetTxMetadata {
+		return nil, errors.New("transfer relayed without metadata")
+	}
+	if w.splitErr != nil {
+		return nil, w.splitErr
+	}
+	resp := &wallet.TransferSplitResponse{}
+	for i := 0; i < len(req.Destinations); i += maxOutputs {
+		end := i + maxOutputs
+		if end > len(req.Destinations) {
+			end = len(req.Destinations)
+		}
+		var amount gonero.AtomicXMR
+		for _, d := range req.Destinations[i:end] {
+			amount += d.Amount
+		}
+		txid := fmt.Sprintf("tx%d", w.created)
+		w.created++
+		resp.TxHashList = append(resp.TxHashList, txid)
+		resp.TxMetadataList = append(resp.TxMetadataList, "meta-"+txid)
+		resp.AmountList = append(resp.AmountList, int64(amount))
+		resp.FeeList = append(resp.FeeList, int64(100+10*(end-i)))
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	txid := strings.TrimPrefix(req.Hex, "meta-")
+	if w.relayFault != nil {
+		if broadcast, err := w.relayFault(txid); err != nil {
+			if broadcast {
+				w.broadcasts[txid]++
+			}
+			return nil, err
+		}
+	}
+	w.broadcasts[txid]++
+	return &wallet.RelayTxResponse{TxHash: txid}, nil
+}
+
+func (w *fakeWallet) GetTransferByTxid(req *wallet.GetTransferByTxidRequest) (*wallet.GetTransferByTxidResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.broadcasts[req.Txid] == 0 {
+		return nil, &wallet.RPCError{Code: wallet.ErrWrongTxid, Message: "Transaction not found."}
+	}
+	return &wallet.GetTransferByTxidResponse{Transfer: wallet.TransferDetail{Txid: req.Txid, Type: "pending"}}, nil
+}
+
+// sent returns the number of broadcasts of each transaction
+func (w *fakeWallet) sent() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sent := make(map[string]int)
+	for txid, n := range w.broadcasts {
+		sent[txid] = n
+	}
+	return sent
+}
+
+func payouts(n int) []Payout {
+	var payouts []Payout
+	for i := 0; i < n; i++ {
+		payouts = append(payouts, Payout{ID: fmt.Sprintf("p%d", i), Address: fmt.Sprintf("addr%d", i), Amount: 1000})
+	}
+	return payouts
+}
+
+func TestPay(t *testing.T) {
+	w := newFakeWallet()
+	e, err := New(w, nil)
+	assert.NoError(t, err)
+
+	batches, err := e.Pay(payouts(20))
+	assert.NoError(t, err)
+	if assert.Len(t, batches, 2) {
+		assert.Len(t, batches[0].Payouts, 15)
+		assert.Len(t, batches[0].Txs, 4)
+		assert.Equal(t, gonero.AtomicXMR(4*100+15*10), batches[0].Fee)
+		assert.Len(t, batches[1].Payouts, 5)
+		assert.Len(t, batches[1].Txs, 2)
+		for _, b := range batches {
+			assert.Equal(t, BatchSent, b.State)
+		}
+	}
+	assert.Len(t, w.sent(), 6)
+
+	again, err := e.Pay(payouts(22))
+	assert.NoError(t, err)
+	if assert.Len(t, again, 3) {
+		assert.Equal(t, batches[0].ID, again[0].ID)
+		assert.Equal(t, batches[1].ID, again[1].ID)
+		assert.Len(t, again[2].Payouts, 2)
+	}
+	for txid, n := range w.sent() {
+		assert.Equal(t, 1, n, txid)
+	}
+	assert.Len(t, e.Batches(), 3)
+
+	_, err = e.Pay([]Payout{{ID: "x", Address: "addr"}})
+	assert.True(t, errors.Is(err, ErrInvalidPayout))
+	_, err = e.Pay([]Payout{{ID: "x", Address: "addr", Amount: 1}, {ID: "x", Address: "addr", Amount: 1}})
+	assert.True(t, errors.Is(err, ErrDuplicatePayout))
+	assert.Equal(t, ErrAlreadySent, e.Abandon(batches[0].ID))
+	assert.Equal(t, ErrNotFound, e.Abandon("unknown"))
+}
+
+func TestPayFailures(t *testing.T) {
+	w := newFakeWallet()
+	store := NewMemoryStore()
+	e, err := New(w, &Config{Store: store, MaxFee: 100})
+	assert.NoError(t, err)
+
+	batches, err := e.Pay(payouts(1))
+	assert.True(t, errors.Is(err, ErrFeeTooHigh))
+	if assert.Len(t, batches, 1) {
+		assert.Equal(t, BatchFailed, batches[0].State)
+		assert.Equal(t, "payout: fee too high: 0.000000000110 XMR", batches[0].Error)
+	}
+	assert.Empty(t, w.sent())
+
+	w.splitErr = &wallet.RPCError{Code: wallet.ErrGenericTransferError, Message: "not enough money"}
+	e, err = New(w, &Config{Store: store})
+	assert.NoError(t, err)
+	_, err = e.Pay(payouts(1))
+	assert.Equal(t, w.splitErr, err)
+
+	// payouts of failed batches are paid again
+	w.splitErr = nil
+	batches, err = e.Pay(payouts(1))
+	assert.NoError(t, err)
+	if assert.Len(t, batches, 1) {
+		assert.Equal(t, BatchSent, batches[0].State)
+	}
+	assert.Len(t, e.Batches(), 3)
+}
+
+// crashingStore fails the nth Put as if the process crashed before it
+type crashingStore struct {
+	Store
+	puts  int
+	crash int
+}
+
+func (s *crashingStore) Put(b *Batch) error {
+	s.puts++
+	if s.puts == s.crash {
+		return errors.New("crash")
+	}
+	return s.Store.Put(b)
+}
+
+func TestResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "payout")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payouts.json")
+
+	t.Run("relayed but not journaled", func(t *testing.T) {
+		w := newFakeWallet()
+		// the second transaction is broadcast, then the process crashes
+		w.relayFault = func(txid string) (bool, error) {
+			if txid == "tx1" {
+				return true, errors.New("crash")
+			}
+			return false, nil
+		}
+		store, err := NewFileStore(path)
+		assert.NoError(t, err)
+		e, err := New(w, &Config{Store: store})
+		assert.NoError(t, err)
+		batches, err := e.Pay(payouts(10))
+		assert.EqualError(t, err, "crash")
+		if assert.Len(t, batches, 1) {
+			assert.Equal(t, BatchCreated, batches[0].State)
+		}
+
+		w.relayFault = nil
+		store, err = NewFileStore(path)
+		assert.NoError(t, err)
+		e, err = New(w, &Config{Store: store})
+		assert.NoError(t, err)
+		assert.NoError(t, e.Resume())
+		b, err := e.Batch(batches[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, BatchSent, b.State)
+		assert.Equal(t, map[string]int{"tx0": 1, "tx1": 1, "tx2": 1}, w.sent())
+
+		// paying again after the restart sends nothing
+		_, err = e.Pay(payouts(10))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"tx0": 1, "tx1": 1, "tx2": 1}, w.sent())
+	})
+
+	t.Run("created but not journaled", func(t *testing.T) {
+		w := newFakeWallet()
+		store := &crashingStore{Store: NewMemoryStore(), crash: 2}
+		e, err := New(w, &Config{Store: store})
+		assert.NoError(t, err)
+		_, err = e.Pay(payouts(3))
+		assert.EqualError(t, err, "crash")
+		assert.Empty(t, w.sent())
+
+		e, err = New(w, &Config{Store: store.Store})
+		assert.NoError(t, err)
+		batches, err := e.Pay(payouts(3))
+		assert.NoError(t, err)
+		if assert.Len(t, batches, 1) {
+			assert.Equal(t, BatchSent, batches[0].State)
+			assert.Equal(t, "tx1", batches[0].Txs[0].Txid)
+		}
+		assert.Equal(t, map[string]int{"tx1": 1}, w.sent())
+	})
+
+	t.Run("abandons", func(t *testing.T) {
+		w := newFakeWallet()
+		w.relayFault = func(string) (bool, error) {
+			return false, &wallet.RPCError{Code: wallet.ErrGenericTransferError, Message: "double spend"}
+		}
+		e, err := New(w, nil)
+		assert.NoError(t, err)
+		batches, err := e.Pay(payouts(1))
+		assert.Error(t, err)
+		assert.Error(t, e.Resume())
+
+		assert.NoError(t, e.Abandon(batches[0].ID))
+		b, err := e.Batch(batches[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, BatchFailed, b.State)
+		assert.NoError(t, e.Resume())
+		assert.Empty(t, w.sent())
+	})
+}
@@ -0,0 +1,134 @@
+package payout
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store journals batches
+type Store interface {
+	// Put stores a batch, replacing the one with the same ID. The batch
+	// must be durable when Put returns.
+	Put(*Batch) error
+	// List returns every batch, oldest first.
+	List() ([]*Batch, error)
+}
+
+// MemoryStore is a Store kept in memory, which only survives errors
+type MemoryStore struct {
+	mu      sync.Mutex
+	batches map[string]*Batch
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{batches: make(map[string]*Batch)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(b *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[b.ID] = b.clone()
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list(), nil
+}
+
+// list returns the batches sorted, with s.mu held
+func (s *MemoryStore) list() []*Batch {
+	batches := make([]*Batch, 0, len(s.batches))
+	for _, b := range s.batches {
+		batches = append(batches, b.clone())
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		if batches[i].CreatedAt.Equal(batches[j].CreatedAt) {
+			return batches[i].ID < batches[j].ID
+		}
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches
+}
+
+// FileStore is a Store saved as a JSON file, rewritten atomically on each change
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// NewFileStore opens the store saved at path, which is created on the
+// first change if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var batches []*Batch
+	if err := json.Unmarshal(data, &batches); err != nil {
+		return nil, err
+	}
+	for _, b := range batches {
+		s.mem.batches[b.ID] = b
+	}
+	return s, nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(b *Batch) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev, ok := s.mem.batches[b.ID]
+	s.mem.batches[b.ID] = b.clone()
+	if err := s.save(); err != nil {
+		if ok {
+			s.mem.batches[b.ID] = prev
+		} else {
+			delete(s.mem.batches, b.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *FileStore) List() ([]*Batch, error) {
+	return s.mem.List()
+}
+
+// save writes the file through a temporary file, with s.mem.mu held
+func (s *FileStore) save() error {
+	data, err := json.Marshal(s.mem.list())
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
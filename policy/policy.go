@@ -0,0 +1,318 @@
+// Package policy checks every spend of a wallet against amount limits,
+// address lists and human approvals before it reaches monero-wallet-rpc.
+//
+// Wallet decorates a wallet.Client. Transfers are checked before they are
+// created. Sweeps, including dust sweeps, are created without being
+// relayed, checked once their amount is known, then relayed. Transactions
+// which were not created or signed through the Wallet are refused by
+// RelayTx, SubmitTransfer and SubmitMultisig. The decorated client is not
+// exported, so that no spend can bypass the checks.
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// DefaultApprovalExpiry is the default lifetime of approval requests
+const DefaultApprovalExpiry = 24 * time.Hour
+
+// Policy errors. Violations wrap them with the offending address or amount.
+var (
+	ErrInvalidAddress   = errors.New("policy: invalid address")
+	ErrWrongNetwork     = errors.New("policy: address of another network")
+	ErrNotAllowlisted   = errors.New("policy: address not allowlisted")
+	ErrDenylisted       = errors.New("policy: address denylisted")
+	ErrDestinationLimit = errors.New("policy: destination limit exceeded")
+	ErrDailyLimit       = errors.New("policy: daily limit exceeded")
+	ErrApprovalRequired = errors.New("policy: approval required")
+	ErrUnchecked        = errors.New("policy: transaction not checked")
+	ErrKeyExport        = errors.New("policy: key export denied")
+	ErrUnknownRequest   = errors.New("policy: unknown approval request")
+	ErrUnknownApprover  = errors.New("policy: unknown approver")
+)
+
+// Config configures a Wallet. Zero values disable the matching check.
+type Config struct {
+	// Network of the destinations: "main", "stage" or "test", as returned
+	// by gonero.Address.Net.
+	Network string
+	// Maximum amount sent to a destination by a single call, in atomic units.
+	PerDestinationLimit gonero.AtomicXMR
+	// Maximum amount sent over the last 24 hours, in atomic units. The
+	// outgoing transfers of every account are counted, including those
+	// sent without the Wallet.
+	DailyLimit gonero.AtomicXMR
+	// Only addresses of the allowlist may receive funds, if it is not empty.
+	Allowlist []string
+	// Addresses of the denylist never receive funds.
+	Denylist []string
+	// Names of the people who may approve a spend.
+	Approvers []string
+	// Number of distinct approvers needed for spends of ApprovalThreshold
+	// or more.
+	RequiredApprovals int
+	// Amount of a call from which approvals are needed, in atomic units.
+	ApprovalThreshold gonero.AtomicXMR
+	// Lifetime of approval requests, defaults to DefaultApprovalExpiry.
+	ApprovalExpiry time.Duration
+	// Allow QueryKey to return the spend key and the mnemonic seed, with
+	// which funds could be spent without the Wallet.
+	AllowKeyExport bool
+}
+
+// Request is a spend waiting for approvals
+type Request struct {
+	// Identifier of the request, derived from the method and its parameters.
+	ID string `json:"id"`
+	// Wallet method of the spend.
+	Method string `json:"method"`
+	// Destinations of the spend.
+	Destinations []wallet.Destination `json:"destinations"`
+	// Amount of the spend, in atomic units. An approved sweep may send
+	// at most this amount.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Approvers who approved the spend.
+	Approvals []string `json:"approvals"`
+	// Time the spend was first refused.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *Request) clone() *Request {
+	c := *r
+	c.Destinations = append([]wallet.Destination{}, r.Destinations...)
+	c.Approvals = append([]string{}, r.Approvals...)
+	return &c
+}
+
+// ApprovalError is returned for spends which lack approvals. It wraps
+// ErrApprovalRequired. Calling the method again with the same parameters
+// succeeds once the request is approved.
+type ApprovalError struct {
+	// Request to approve.
+	Request *Request
+	// Number of approvals needed.
+	Required int
+}
+
+func (e *ApprovalError) Error() string {
+	return fmt.Sprintf("policy: approval required for request %s (%d of %d)", e.Request.ID, len(e.Request.Approvals), e.Required)
+}
+
+// Unwrap returns ErrApprovalRequired.
+func (e *ApprovalError) Unwrap() error {
+	return ErrApprovalRequired
+}
+
+// spend is a call sending funds to destinations
+type spend struct {
+	method       string
+	params       interface{}
+	destinations []wallet.Destination
+}
+
+// total returns the amount sent by the spend
+func (s *spend) total() gonero.AtomicXMR {
+	var total gonero.AtomicXMR
+	for _, d := range s.destinations {
+		total += d.Amount
+	}
+	return total
+}
+
+// id returns the identifier of the approval request of the spend
+func (s *spend) id() string {
+	data, _ := json.Marshal(struct {
+		Method string      `json:"method"`
+		Params interface{} `json:"params"`
+	}{s.method, s.params})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Requests returns the spends waiting for approvals, oldest first.
+func (w *Wallet) Requests() []*Request {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expire()
+	requests := make([]*Request, 0, len(w.requests))
+	for _, r := range w.requests {
+		requests = append(requests, r.clone())
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].CreatedAt.Equal(requests[j].CreatedAt) {
+			return requests[i].ID < requests[j].ID
+		}
+		return requests[i].CreatedAt.Before(requests[j].CreatedAt)
+	})
+	return requests
+}
+
+// Approve records the approval of a request by an approver. Approvers are
+// identified by name only: authenticating them is up to the caller.
+func (w *Wallet) Approve(id, approver string) (*Request, error) {
+	known := false
+	for _, a := range w.cfg.Approvers {
+		known = known || a == approver
+	}
+	if !known {
+		return nil, ErrUnknownApprover
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expire()
+	r, ok := w.requests[id]
+	if !ok {
+		return nil, ErrUnknownRequest
+	}
+	for _, a := range r.Approvals {
+		if a == approver {
+			return r.clone(), nil
+		}
+	}
+	r.Approvals = append(r.Approvals, approver)
+	return r.clone(), nil
+}
+
+// Reject drops a request, along with its approvals.
+func (w *Wallet) Reject(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.requests[id]; !ok {
+		return ErrUnknownRequest
+	}
+	delete(w.requests, id)
+	return nil
+}
+
+// checkAddress checks a destination against the network and the address lists
+func (w *Wallet) checkAddress(addr string) error {
+	net, base := gonero.ParseAddress(addr)
+	if net == "" {
+		return fmt.Errorf("%w %q", ErrInvalidAddress, addr)
+	}
+	if w.cfg.Network != "" && net != w.cfg.Network {
+		return fmt.Errorf("%w: %s", ErrWrongNetwork, addr)
+	}
+	if w.denylist[addr] || w.denylist[base] {
+		return fmt.Errorf("%w: %s", ErrDenylisted, addr)
+	}
+	if len(w.allowlist) > 0 && !w.allowlist[addr] && !w.allowlist[base] {
+		return fmt.Errorf("%w: %s", ErrNotAllowlisted, addr)
+	}
+	return nil
+}
+
+// check checks every rule of a spend, returning the request approving it
+// if it needed one, with w.spendMu held
+func (w *Wallet) check(s *spend) (*Request, error) {
+	for _, d := range s.destinations {
+		if err := w.checkAddress(d.Address); err != nil {
+			return nil, err
+		}
+	}
+	return w.checkAmounts(s)
+}
+
+// checkAmounts checks the limits and the approvals of a spend, with
+// w.spendMu held
+func (w *Wallet) checkAmounts(s *spend) (*Request, error) {
+	if w.cfg.PerDestinationLimit > 0 {
+		perDestination := make(map[string]gonero.AtomicXMR)
+		for _, d := range s.destinations {
+			perDestination[d.Address] += d.Amount
+			if perDestination[d.Address] > w.cfg.PerDestinationLimit {
+				return nil, fmt.Errorf("%w: %s XMR to %s", ErrDestinationLimit, perDestination[d.Address].Decimal(), d.Address)
+			}
+		}
+	}
+	total := s.total()
+	if err := w.checkDaily(total); err != nil {
+		return nil, err
+	}
+	if w.cfg.RequiredApprovals <= 0 || total < w.cfg.ApprovalThreshold {
+		return nil, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expire()
+	id := s.id()
+	r, ok := w.requests[id]
+	if ok && len(r.Approvals) >= w.cfg.RequiredApprovals && total <= r.Amount {
+		return r, nil
+	}
+	if !ok || total > r.Amount {
+		// approvals only cover the amount they were given for
+		r = &Request{
+			ID:           id,
+			Method:       s.method,
+			Destinations: append([]wallet.Destination{}, s.destinations...),
+			Amount:       total,
+			CreatedAt:    w.now(),
+		}
+		w.requests[id] = r
+	}
+	return nil, &ApprovalError{Request: r.clone(), Required: w.cfg.RequiredApprovals}
+}
+
+// checkDaily checks that sending amount keeps the wallet under the daily limit
+func (w *Wallet) checkDaily(amount gonero.AtomicXMR) error {
+	if w.cfg.DailyLimit == 0 {
+		return nil
+	}
+	resp, err := w.client.GetTransfers(&wallet.GetTransfersRequest{Out: true, Pending: true, AllAccounts: true})
+	if err != nil {
+		return err
+	}
+	since := w.now().Add(-24 * time.Hour)
+	var spent gonero.AtomicXMR
+	for _, transfers := range [][]wallet.Transfer{resp.Out, resp.Pending} {
+		for _, t := range transfers {
+			if !time.Unix(int64(t.Timestamp), 0).Before(since) {
+				spent += t.Amount
+			}
+		}
+	}
+	if spent+amount > w.cfg.DailyLimit {
+		return fmt.Errorf("%w: %s XMR sent today", ErrDailyLimit, spent.Decimal())
+	}
+	return nil
+}
+
+// consume drops an approved request once its spend succeeded
+func (w *Wallet) consume(r *Request) {
+	if r == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.requests, r.ID)
+}
+
+// expire drops the expired requests, with w.mu held
+func (w *Wallet) expire() {
+	now := w.now()
+	for id, r := range w.requests {
+		if now.Sub(r.CreatedAt) >= w.cfg.ApprovalExpiry {
+			delete(w.requests, id)
+		}
+	}
+}
+
+// set returns a set of strings
+func set(list []string) map[string]bool {
+	s := make(map[string]bool, len(list))
+	for _, v := range list {
+		s[v] = true
+	}
+	return s
+}
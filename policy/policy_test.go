@@ -0,0 +1,338 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	mainAddr   = "42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm"
+	subAddr    = "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF"
+	deniedAddr = "82pP87g1Vkd3LUMssBCumk3MfyEsFqLAaGDf6oxddu61EgSFzt8gCwUD4tr3kp9TUfdPs2CnpD7xLZzyC1Ei9UsW3oyCWDf"
+	stageAddr  = "55hKAMnUWXaWXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmCKyGuXY"
+)
+
+// clock is a settable time
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeWallet is a stand-in wallet recording what it sends
+type fakeWallet struct {
+	wallet.Client
+
+	clock     *clock
+	out       []wallet.Transfer
+	relayed   []string
+	recipient map[string][]wallet.Destination // txset -> recipients
+	submitted []string
+}
+
+func newFakeWallet(c *clock) *fakeWallet {
+	return &fakeWallet{clock: c, recipient: make(map[string][]wallet.Destination)}
+}
+
+func (w *fakeWallet) send(amount gonero.AtomicXMR) {
+	w.out = append(w.out, wallet.Transfer{Amount: amount, Timestamp: uint64(w.clock.Now().Unix())})
+}
+
+func (w *fakeWallet) Transfer(req *wallet.TransferRequest) (*wallet.TransferResponse, error) {
+	var amount gonero.AtomicXMR
+	for _, d := range req.Destinations {
+		amount += d.Amount
+	}
+	if req.DoNotRelay {
+		return &wallet.TransferResponse{Amount: amount, TxMetadata: "meta-transfer"}, nil
+	}
+	w.send(amount)
+	return &wallet.TransferResponse{Amount: amount, TxHash: "transfer"}, nil
+}
+
+func (w *fakeWallet) SweepAll(req *wallet.SweepAllRequest) (*wallet.SweepAllResponse, error) {
+	if !req.DoNotRelay || !req.GetTxMetadata {
+		return nil, errors.New("sweep relayed unchecked")
+	}
+	return &wallet.SweepAllResponse{
+		TxHashList:     []string{"sweep0", "sweep1"},
+		AmountList:     []int64{300, 200},
+		TxMetadataList: []string{"meta-sweep0", "meta-sweep1"},
+	}, nil
+}
+
+func (w *fakeWallet) SweepDust(req *wallet.SweepDustRequest) (*wallet.SweepDustResponse, error) {
+	if !req.DoNotRelay || !req.GetTxMetadata {
+		return nil, errors.New("sweep relayed unchecked")
+	}
+	return &wallet.SweepDustResponse{
+		TxHashList:     []string{"dust0"},
+		AmountList:     []int64{600},
+		TxMetadataList: []string{"meta-dust0"},
+	}, nil
+}
+
+func (w *fakeWallet) GetAddress(req *wallet.GetAddressRequest) (*wallet.GetAddressResponse, error) {
+	return &wallet.GetAddressResponse{Address: mainAddr}, nil
+}
+
+func (w *fakeWallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	w.relayed = append(w.relayed, req.Hex)
+	return &wallet.RelayTxResponse{}, nil
+}
+
+func (w *fakeWallet) GetTransfers(req *wallet.GetTransfersRequest) (*wallet.GetTransfersResponse, error) {
+	if !req.Out || !req.Pending || !req.AllAccounts {
+		return nil, errors.New("incomplete query")
+	}
+	return &wallet.GetTransfersResponse{Out: w.out}, nil
+}
+
+func (w *fakeWallet) DescribeTransfer(req *wallet.DescribeTransferRequest) (*wallet.DescribeTransferResponse, error) {
+	return &wallet.DescribeTransferResponse{Desc: []wallet.TransferDescription{{Recipients: w.recipient[req.UnsignedTxset+req.MultisigTxset]}}}, nil
+}
+
+func (w *fakeWallet) SignTransfer(req *wallet.SignTransferRequest) (*wallet.SignTransferResponse, error) {
+	return &wallet.SignTransferResponse{SignedTxset: "signed-" + req.UnsignedTxset}, nil
+}
+
+func (w *fakeWallet) SubmitTransfer(req *wallet.SubmitTransferRequest) (*wallet.SubmitTransferResponse, error) {
+	w.submitted = append(w.submitted, req.TxDataHex)
+	return &wallet.SubmitTransferResponse{}, nil
+}
+
+func (w *fakeWallet) QueryKey(req *wallet.QueryKeyRequest) (*wallet.QueryKeyResponse, error) {
+	return &wallet.QueryKeyResponse{Key: gonero.NewSecret("key")}, nil
+}
+
+func transfer(w wallet.Client, destinations ...wallet.Destination) error {
+	_, err := w.Transfer(&wallet.TransferRequest{Destinations: destinations})
+	return err
+}
+
+func TestDestinations(t *testing.T) {
+	c := &clock{now: time.Unix(1666000000, 0)}
+	integrated := gonero.NewAddress(mainAddr).WithPaymentID("1234567890abcdef").Addr
+	w := newWallet(newFakeWallet(c), &Config{
+		Network:             "main",
+		PerDestinationLimit: 1000,
+		Allowlist:           []string{mainAddr},
+		Denylist:            []string{deniedAddr},
+	}, c.Now)
+
+	type test struct {
+		name         string
+		destinations []wallet.Destination
+		err          error
+	}
+	tests := []test{
+		{"allowed", []wallet.Destination{{Amount: 1000, Address: mainAddr}}, nil},
+		{"integrated", []wallet.Destination{{Amount: 100, Address: integrated}}, nil},
+		{"invalid", []wallet.Destination{{Amount: 100, Address: "foo"}}, ErrInvalidAddress},
+		{"network", []wallet.Destination{{Amount: 100, Address: stageAddr}}, ErrWrongNetwork},
+		{"denylisted", []wallet.Destination{{Amount: 100, Address: deniedAddr}}, ErrDenylisted},
+		{"not allowlisted", []wallet.Destination{{Amount: 100, Address: subAddr}}, ErrNotAllowlisted},
+		{"limit", []wallet.Destination{{Amount: 1001, Address: mainAddr}}, ErrDestinationLimit},
+		{"split limit", []wallet.Destination{{Amount: 600, Address: mainAddr}, {Amount: 600, Address: mainAddr}}, ErrDestinationLimit},
+		{"any denied", []wallet.Destination{{Amount: 100, Address: mainAddr}, {Amount: 100, Address: deniedAddr}}, ErrDenylisted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := transfer(w, tc.destinations...)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.err), "%v", err)
+			}
+		})
+	}
+	_, err := w.TransferSplit(&wallet.TransferSplitRequest{Address: deniedAddr, Amount: 1})
+	assert.True(t, errors.Is(err, ErrDenylisted))
+}
+
+func TestDailyLimit(t *testing.T) {
+	c := &clock{now: time.Unix(1666000000, 0)}
+	fake := newFakeWallet(c)
+	w := newWallet(fake, &Config{DailyLimit: 3000}, c.Now)
+
+	fake.send(5000)
+	c.advance(25 * time.Hour)
+	fake.send(2500)
+	c.advance(time.Hour)
+	assert.NoError(t, transfer(w, wallet.Destination{Amount: 400, Address: mainAddr}))
+	err := transfer(w, wallet.Destination{Amount: 400, Address: mainAddr})
+	assert.EqualError(t, err, "policy: daily limit exceeded: 0.000000002900 XMR sent today")
+
+	// the relay of a checked transaction is checked again
+	_, err = w.Transfer(&wallet.TransferRequest{Destinations: []wallet.Destination{{Amount: 100, Address: mainAddr}}, DoNotRelay: true})
+	assert.NoError(t, err)
+	fake.send(100)
+	_, err = w.RelayTx(&wallet.RelayTxRequest{Hex: "meta-transfer"})
+	assert.True(t, errors.Is(err, ErrDailyLimit))
+	c.advance(24 * time.Hour)
+	_, err = w.RelayTx(&wallet.RelayTxRequest{Hex: "meta-transfer"})
+	assert.NoError(t, err)
+	_, err = w.RelayTx(&wallet.RelayTxRequest{Hex: "meta-transfer"})
+	assert.Equal(t, ErrUnchecked, err)
+}
+
+func TestApprovals(t *testing.T) {
+	c := &clock{now: time.Unix(1666000000, 0)}
+	w := newWallet(newFakeWallet(c), &Config{
+		Approvers:         []string{"alice", "bob", "carol"},
+		RequiredApprovals: 2,
+		ApprovalThreshold: 800,
+	}, c.Now)
+	large := wallet.Destination{Amount: 900, Address: mainAddr}
+
+	assert.NoError(t, transfer(w, wallet.Destination{Amount: 799, Address: mainAddr}))
+	err := transfer(w, large)
+	assert.True(t, errors.Is(err, ErrApprovalRequired))
+	var approval *ApprovalError
+	if !errors.As(err, &approval) {
+		t.Fatal(err)
+	}
+	id := approval.Request.ID
+	assert.Equal(t, []*Request{approval.Request}, w.Requests())
+
+	_, err = w.Approve(id, "mallory")
+	assert.Equal(t, ErrUnknownApprover, err)
+	_, err = w.Approve("unknown", "alice")
+	assert.Equal(t, ErrUnknownRequest, err)
+	r, err := w.Approve(id, "alice")
+	assert.NoError(t, err)
+	r, err = w.Approve(id, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, r.Approvals)
+	assert.EqualError(t, transfer(w, large), "policy: approval required for request "+id+" (1 of 2)")
+
+	// another spend needs its own approvals
+	assert.True(t, errors.Is(transfer(w, wallet.Destination{Amount: 901, Address: mainAddr}), ErrApprovalRequired))
+
+	_, err = w.Approve(id, "bob")
+	assert.NoError(t, err)
+	assert.NoError(t, transfer(w, large))
+	// approvals are consumed by the spend
+	assert.True(t, errors.Is(transfer(w, large), ErrApprovalRequired))
+
+	c.advance(DefaultApprovalExpiry)
+	assert.Empty(t, w.Requests())
+	_, err = w.Approve(id, "bob")
+	assert.Equal(t, ErrUnknownRequest, err)
+}
+
+func TestSweep(t *testing.T) {
+	c := &clock{now: time.Unix(1666000000, 0)}
+	fake := newFakeWallet(c)
+	w := newWallet(fake, &Config{PerDestinationLimit: 500}, c.Now)
+
+	resp, err := w.SweepAll(&wallet.SweepAllRequest{Address: mainAddr})
+	assert.NoError(t, err)
+	assert.Nil(t, resp.TxMetadataList)
+	assert.Equal(t, []string{"meta-sweep0", "meta-sweep1"}, fake.relayed)
+
+	fake.relayed = nil
+	resp, err = w.SweepAll(&wallet.SweepAllRequest{Address: mainAddr, DoNotRelay: true, GetTxMetadata: true})
+	assert.NoError(t, err)
+	assert.Empty(t, fake.relayed)
+	_, err = w.RelayTx(&wallet.RelayTxRequest{Hex: resp.TxMetadataList[0]})
+	assert.NoError(t, err)
+	_, err = w.RelayTx(&wallet.RelayTxRequest{Hex: "meta-unknown"})
+	assert.Equal(t, ErrUnchecked, err)
+	assert.Equal(t, []string{"meta-sweep0"}, fake.relayed)
+
+	fake.relayed = nil
+	w = newWallet(fake, &Config{PerDestinationLimit: 499}, c.Now)
+	_, err = w.SweepAll(&wallet.SweepAllRequest{Address: mainAddr})
+	assert.True(t, errors.Is(err, ErrDestinationLimit))
+	assert.Empty(t, fake.relayed)
+	_, err = w.SweepAll(&wallet.SweepAllRequest{Address: "foo"})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+
+	// dust sweeps are checked too, although they return to the wallet
+	_, err = w.SweepDust(&wallet.SweepDustRequest{})
+	assert.True(t, errors.Is(err, ErrDestinationLimit))
+	assert.Empty(t, fake.relayed)
+	w = newWallet(fake, &Config{PerDestinationLimit: 600}, c.Now)
+	_, err = w.SweepDust(&wallet.SweepDustRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"meta-dust0"}, fake.relayed)
+}
+
+func TestSigning(t *testing.T) {
+	c := &clock{now: time.Unix(1666000000, 0)}
+	fake := newFakeWallet(c)
+	fake.recipient["good"] = []wallet.Destination{{Amount: 100, Address: mainAddr}}
+	fake.recipient["bad"] = []wallet.Destination{{Amount: 100, Address: mainAddr}, {Amount: 100, Address: deniedAddr}}
+	w := newWallet(fake, &Config{Denylist: []string{deniedAddr}}, c.Now)
+
+	_, err := w.SignTransfer(&wallet.SignTransferRequest{UnsignedTxset: "bad"})
+	assert.True(t, errors.Is(err, ErrDenylisted))
+	signed, err := w.SignTransfer(&wallet.SignTransferRequest{UnsignedTxset: "good"})
+	assert.NoError(t, err)
+	_, err = w.SubmitTransfer(&wallet.SubmitTransferRequest{TxDataHex: "signed-bad"})
+	assert.Equal(t, ErrUnchecked, err)
+	_, err = w.SubmitTransfer(&wallet.SubmitTransferRequest{TxDataHex: signed.SignedTxset})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"signed-good"}, fake.submitted)
+
+	_, err = w.SubmitMultisig(&wallet.SubmitMultisigRequest{TxDataHex: "unsigned"})
+	assert.Equal(t, ErrUnchecked, err)
+	_, err = w.QueryKey(&wallet.QueryKeyRequest{KeyType: wallet.QuerySpendKey})
+	assert.Equal(t, ErrKeyExport, err)
+	_, err = w.QueryKey(&wallet.QueryKeyRequest{KeyType: wallet.QueryMnemonicKey})
+	assert.Equal(t, ErrKeyExport, err)
+	_, err = w.QueryKey(&wallet.QueryKeyRequest{KeyType: wallet.QueryViewKey})
+	assert.NoError(t, err)
+}
+
+// TestMethods fails when wallet.Client gains a method, which must be
+// reviewed: either it cannot spend, or Wallet must check it.
+func TestMethods(t *testing.T) {
+	checked := []string{
+		"Transfer", "TransferSplit", "SweepAll", "SweepSingle", "SweepDust", "RelayTx",
+		"SignTransfer", "SubmitTransfer", "SignMultisig", "SubmitMultisig", "QueryKey",
+	}
+	passed := []string{
+		"SetDaemon", "GetBalance", "GetAddress", "GetAddressIndex", "CreateAddress", "LabelAddress",
+		"ValidateAddress", "GetAccounts", "CreateAccount", "LabelAccount", "GetAccountTags", "TagAccounts",
+		"UntagAccounts", "SetAccountTagDescription", "GetHeight", "Store", "GetPayments", "GetBulkPayments",
+		"IncomingTransfers", "MakeIntegratedAddress", "SplitIntegratedAddress", "StopWallet",
+		"RescanBlockchain", "SetTxNotes", "GetTxNotes", "SetAttribute", "GetAttribute", "GetTxKey",
+		"CheckTxKey", "GetTxProof", "CheckTxProof", "GetSpendProof", "CheckSpendProof", "GetReserveProof",
+		"CheckReserveProof", "GetTransfers", "GetTransferByTxid", "DescribeTransfer", "Sign", "Verify",
+		"ExportOutputs", "ImportOutputs", "ExportKeyImages", "ImportKeyImages", "MakeURI", "ParseURI",
+		"GetAddressBook", "AddAddressBook", "EditAddressBook", "DeleteAddressBook", "Refresh", "AutoRefresh",
+		"RescanSpent", "StartMining", "StopMining", "GetLanguages", "CreateWallet", "GenerateFromKeys",
+		"OpenWallet", "RestoreDeterministicWallet", "CloseWallet", "ChangeWalletPassword", "IsMultisig",
+		"PrepareMultisig", "MakeMultisig", "ExportMultisigInfo", "ImportMultisigInfo", "FinalizeMultisig",
+		"GetVersion", "Freeze", "Thaw", "Frozen", "EstimateTxSizeAndWeight", "GetDefaultFeePriority",
+		"SetLogLevel", "SetLogCategories", "ScanTx", "ExchangeMultisigKeys", "SetSubaddressLookahead",
+		"SetupBackgroundSync", "StartBackgroundSync", "StopBackgroundSync",
+	}
+	reviewed := set(append(checked, passed...))
+	client := reflect.TypeOf((*wallet.Client)(nil)).Elem()
+	for i := 0; i < client.NumMethod(); i++ {
+		name := client.Method(i).Name
+		assert.True(t, reviewed[name], "wallet.Client.%s is not reviewed by the policy", name)
+	}
+	assert.Equal(t, client.NumMethod(), len(reviewed))
+}
@@ -0,0 +1,388 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Wallet is a wallet.Client checking spends against a policy. Methods which
+// do not spend are passed through to the decorated client, which cannot be
+// reached otherwise.
+type Wallet struct {
+	client
+
+	cfg       Config
+	now       func() time.Time
+	allowlist map[string]bool
+	denylist  map[string]bool
+
+	// spendMu serializes spends, so that concurrent ones cannot exceed
+	// the daily limit together
+	spendMu sync.Mutex
+
+	mu        sync.Mutex
+	requests  map[string]*Request
+	unrelayed map[string]gonero.AtomicXMR // checked tx metadata -> amount
+	signed    map[string]gonero.AtomicXMR // checked signed txsets -> amount
+}
+
+var _ wallet.Client = (*Wallet)(nil)
+
+// client is the decorated wallet.Client, embedded unexported so that its
+// spending methods are only reachable through the checks of Wallet
+type client interface {
+	wallet.Client
+}
+
+// New returns a Wallet checking the spends made through client.
+func New(client wallet.Client, cfg *Config) *Wallet {
+	return newWallet(client, cfg, time.Now)
+}
+
+func newWallet(client wallet.Client, cfg *Config, now func() time.Time) *Wallet {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.ApprovalExpiry <= 0 {
+		c.ApprovalExpiry = DefaultApprovalExpiry
+	}
+	return &Wallet{
+		client:    client,
+		cfg:       c,
+		now:       now,
+		allowlist: set(c.Allowlist),
+		denylist:  set(c.Denylist),
+		requests:  make(map[string]*Request),
+		unrelayed: make(map[string]gonero.AtomicXMR),
+		signed:    make(map[string]gonero.AtomicXMR),
+	}
+}
+
+// transferParams identifies a transfer for approvals
+type transferParams struct {
+	AccountIndex   uint64               `json:"account_index"`
+	SubaddrIndices []uint64             `json:"subaddr_indices"`
+	Destinations   []wallet.Destination `json:"destinations"`
+}
+
+// sweepParams identifies a sweep for approvals
+type sweepParams struct {
+	Address        string           `json:"address"`
+	AccountIndex   uint64           `json:"account_index"`
+	SubaddrIndices []uint64         `json:"subaddr_indices"`
+	KeyImage       string           `json:"key_image"`
+	BelowAmount    gonero.AtomicXMR `json:"below_amount"`
+}
+
+// Transfer checks the destinations before sending them monero.
+func (w *Wallet) Transfer(req *wallet.TransferRequest) (*wallet.TransferResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	destinations := destinations(req.Destinations, req.Address, req.Amount)
+	approved, err := w.check(&spend{
+		method:       "transfer",
+		params:       transferParams{req.AccountIndex, req.SubaddrIndices, destinations},
+		destinations: destinations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Transfer(req)
+	if err != nil {
+		return nil, err
+	}
+	w.consume(approved)
+	if req.DoNotRelay && resp.TxMetadata != "" {
+		w.remember(w.unrelayed, resp.TxMetadata, resp.Amount)
+	}
+	return resp, nil
+}
+
+// TransferSplit checks the destinations before sending them monero.
+func (w *Wallet) TransferSplit(req *wallet.TransferSplitRequest) (*wallet.TransferSplitResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	destinations := destinations(req.Destinations, req.Address, req.Amount)
+	approved, err := w.check(&spend{
+		method:       "transfer_split",
+		params:       transferParams{req.AccountIndex, req.SubaddrIndices, destinations},
+		destinations: destinations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.TransferSplit(req)
+	if err != nil {
+		return nil, err
+	}
+	w.consume(approved)
+	if req.DoNotRelay {
+		for i, metadata := range resp.TxMetadataList {
+			if i < len(resp.AmountList) {
+				w.remember(w.unrelayed, metadata, gonero.AtomicXMR(resp.AmountList[i]))
+			}
+		}
+	}
+	return resp, nil
+}
+
+// SweepAll creates the sweep without relaying it, and relays it once its
+// amount passes the policy.
+func (w *Wallet) SweepAll(req *wallet.SweepAllRequest) (*wallet.SweepAllResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	if err := w.checkAddress(req.Address); err != nil {
+		return nil, err
+	}
+	created := *req
+	created.DoNotRelay = true
+	created.GetTxMetadata = true
+	resp, err := w.client.SweepAll(&created)
+	if err != nil {
+		return nil, err
+	}
+	params := sweepParams{req.Address, req.AccountIndex, req.SubaddrIndices, "", req.BelowAmount}
+	if err := w.sweep("sweep_all", params, req.Address, req.DoNotRelay, resp.AmountList, resp.TxMetadataList); err != nil {
+		return nil, err
+	}
+	if !req.GetTxMetadata {
+		resp.TxMetadataList = nil
+	}
+	return resp, nil
+}
+
+// SweepSingle creates the sweep without relaying it, and relays it once
+// its amount passes the policy.
+func (w *Wallet) SweepSingle(req *wallet.SweepSingleRequest) (*wallet.SweepSingleResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	if err := w.checkAddress(req.Address); err != nil {
+		return nil, err
+	}
+	created := *req
+	created.DoNotRelay = true
+	created.GetTxMetadata = true
+	resp, err := w.client.SweepSingle(&created)
+	if err != nil {
+		return nil, err
+	}
+	params := sweepParams{req.Address, req.AccountIndex, req.SubaddrIndices, req.KeyImage, req.BelowAmount}
+	if err := w.sweep("sweep_single", params, req.Address, req.DoNotRelay, resp.AmountList, resp.TxMetadataList); err != nil {
+		return nil, err
+	}
+	if !req.GetTxMetadata {
+		resp.TxMetadataList = nil
+	}
+	return resp, nil
+}
+
+// SweepDust creates the sweep of the unmixable outputs to the primary
+// address without relaying it, and relays it once its amount passes the
+// policy.
+func (w *Wallet) SweepDust(req *wallet.SweepDustRequest) (*wallet.SweepDustResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	addr, err := w.client.GetAddress(&wallet.GetAddressRequest{})
+	if err != nil {
+		return nil, err
+	}
+	created := *req
+	created.DoNotRelay = true
+	created.GetTxMetadata = true
+	resp, err := w.client.SweepDust(&created)
+	if err != nil {
+		return nil, err
+	}
+	params := sweepParams{Address: addr.Address}
+	if err := w.sweep("sweep_dust", params, addr.Address, req.DoNotRelay, resp.AmountList, resp.TxMetadataList); err != nil {
+		return nil, err
+	}
+	if !req.GetTxMetadata {
+		resp.TxMetadataList = nil
+	}
+	return resp, nil
+}
+
+// sweep checks the amounts of created sweep transactions, then relays them
+// unless doNotRelay is set, with w.spendMu held
+func (w *Wallet) sweep(method string, params sweepParams, address string, doNotRelay bool, amounts []int64, metadata []string) error {
+	var total gonero.AtomicXMR
+	for _, a := range amounts {
+		total += gonero.AtomicXMR(a)
+	}
+	approved, err := w.checkAmounts(&spend{
+		method:       method,
+		params:       params,
+		destinations: []wallet.Destination{{Amount: total, Address: address}},
+	})
+	if err != nil {
+		return err
+	}
+	for i, m := range metadata {
+		if doNotRelay {
+			if i < len(amounts) {
+				w.remember(w.unrelayed, m, gonero.AtomicXMR(amounts[i]))
+			}
+			continue
+		}
+		if _, err := w.client.RelayTx(&wallet.RelayTxRequest{Hex: m}); err != nil {
+			return err
+		}
+	}
+	w.consume(approved)
+	return nil
+}
+
+// RelayTx relays a transaction created through the Wallet, once the daily
+// limit allows it. Other transactions return ErrUnchecked, including those
+// created through a Wallet before a restart.
+func (w *Wallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	if err := w.recall(w.unrelayed, req.Hex); err != nil {
+		return nil, err
+	}
+	resp, err := w.client.RelayTx(req)
+	if err != nil {
+		return nil, err
+	}
+	w.forget(w.unrelayed, req.Hex)
+	return resp, nil
+}
+
+// SignTransfer checks the recipients described by the unsigned set before
+// signing it.
+func (w *Wallet) SignTransfer(req *wallet.SignTransferRequest) (*wallet.SignTransferResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	s, err := w.describe("sign_transfer", &wallet.DescribeTransferRequest{UnsignedTxset: req.UnsignedTxset})
+	if err != nil {
+		return nil, err
+	}
+	approved, err := w.check(s)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.SignTransfer(req)
+	if err != nil {
+		return nil, err
+	}
+	w.consume(approved)
+	w.remember(w.signed, resp.SignedTxset, s.total())
+	return resp, nil
+}
+
+// SubmitTransfer submits a set signed by SignTransfer, once the daily limit
+// allows it. Other sets return ErrUnchecked.
+func (w *Wallet) SubmitTransfer(req *wallet.SubmitTransferRequest) (*wallet.SubmitTransferResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	if err := w.recall(w.signed, req.TxDataHex); err != nil {
+		return nil, err
+	}
+	resp, err := w.client.SubmitTransfer(req)
+	if err != nil {
+		return nil, err
+	}
+	w.forget(w.signed, req.TxDataHex)
+	return resp, nil
+}
+
+// SignMultisig checks the recipients described by the multisig set before
+// signing it.
+func (w *Wallet) SignMultisig(req *wallet.SignMultisigRequest) (*wallet.SignMultisigResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	s, err := w.describe("sign_multisig", &wallet.DescribeTransferRequest{MultisigTxset: req.TxDataHex})
+	if err != nil {
+		return nil, err
+	}
+	approved, err := w.check(s)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.SignMultisig(req)
+	if err != nil {
+		return nil, err
+	}
+	w.consume(approved)
+	w.remember(w.signed, resp.TxDataHex, s.total())
+	return resp, nil
+}
+
+// SubmitMultisig submits a set signed by SignMultisig, once the daily limit
+// allows it. Other sets return ErrUnchecked.
+func (w *Wallet) SubmitMultisig(req *wallet.SubmitMultisigRequest) (*wallet.SubmitMultisigResponse, error) {
+	w.spendMu.Lock()
+	defer w.spendMu.Unlock()
+	if err := w.recall(w.signed, req.TxDataHex); err != nil {
+		return nil, err
+	}
+	resp, err := w.client.SubmitMultisig(req)
+	if err != nil {
+		return nil, err
+	}
+	w.forget(w.signed, req.TxDataHex)
+	return resp, nil
+}
+
+// QueryKey refuses to return the spend key and the mnemonic seed unless
+// AllowKeyExport is set.
+func (w *Wallet) QueryKey(req *wallet.QueryKeyRequest) (*wallet.QueryKeyResponse, error) {
+	if req.KeyType != wallet.QueryViewKey && !w.cfg.AllowKeyExport {
+		return nil, ErrKeyExport
+	}
+	return w.client.QueryKey(req)
+}
+
+// describe returns the spend of a set of transactions
+func (w *Wallet) describe(method string, req *wallet.DescribeTransferRequest) (*spend, error) {
+	desc, err := w.client.DescribeTransfer(req)
+	if err != nil {
+		return nil, err
+	}
+	var destinations []wallet.Destination
+	for _, d := range desc.Desc {
+		destinations = append(destinations, d.Recipients...)
+	}
+	return &spend{method: method, params: destinations, destinations: destinations}, nil
+}
+
+// recall checks that a transaction was checked, and checks its amount
+// against the daily limit again since other spends may have happened
+// meanwhile
+func (w *Wallet) recall(checked map[string]gonero.AtomicXMR, tx string) error {
+	w.mu.Lock()
+	amount, ok := checked[tx]
+	w.mu.Unlock()
+	if !ok {
+		return ErrUnchecked
+	}
+	return w.checkDaily(amount)
+}
+
+func (w *Wallet) remember(checked map[string]gonero.AtomicXMR, tx string, amount gonero.AtomicXMR) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	checked[tx] = amount
+}
+
+func (w *Wallet) forget(checked map[string]gonero.AtomicXMR, tx string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(checked, tx)
+}
+
+// destinations returns the destinations of a transfer, including the
+// single destination fields
+func destinations(list []wallet.Destination, address string, amount gonero.AtomicXMR) []wallet.Destination {
+	destinations := append([]wallet.Destination{}, list...)
+	if address != "" || amount != 0 {
+		destinations = append(destinations, wallet.Destination{Amount: amount, Address: address})
+	}
+	return destinations
+}
@@ -0,0 +1,533 @@
+// Package proof verifies the proofs of monero-wallet-rpc without a wallet,
+// reading transactions and outputs from a daemon.
+//
+// The proofs are the strings of wallet.GetTxProof (OutProofV2 and
+// InProofV2), wallet.GetSpendProof (SpendProofV1) and
+// wallet.GetReserveProof (ReserveProofV2), which a Verifier checks as
+// wallet.CheckTxProof, wallet.CheckSpendProof and wallet.CheckReserveProof
+// would.
+package proof
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/base58"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/transaction"
+)
+
+// headers of the proofs
+const (
+	headerOutProofV1     = "OutProofV1"
+	headerOutProofV2     = "OutProofV2"
+	headerInProofV1      = "InProofV1"
+	headerInProofV2      = "InProofV2"
+	headerSpendProofV1   = "SpendProofV1"
+	headerReserveProofV1 = "ReserveProofV1"
+	headerReserveProofV2 = "ReserveProofV2"
+)
+
+// sizes of the base58 encodings of a key and of a signature
+const (
+	encodedKeySize       = 44
+	encodedSignatureSize = 88
+)
+
+// Proof errors
+var (
+	ErrInvalidProof   = errors.New("proof: invalid proof")
+	ErrUnsupported    = errors.New("proof: unsupported proof")
+	ErrInvalidAddress = errors.New("proof: invalid address")
+	ErrUnexpected     = errors.New("proof: unexpected daemon response")
+)
+
+// TxProofCheck is a payment proven by a transaction proof, as
+// wallet.CheckTxProofResponse
+type TxProofCheck struct {
+	// Amount received by the address, in atomic units.
+	Received gonero.AtomicXMR `json:"received"`
+	// States if the transaction is still in the pool.
+	InPool bool `json:"in_pool"`
+	// Number of blocks from the one with the transaction to the top of
+	// the chain, both included, 0 in the pool.
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// ReserveProofCheck is the reserve proven by a reserve proof
+type ReserveProofCheck struct {
+	// Amount of the proven outputs, in atomic units.
+	Total gonero.AtomicXMR `json:"total"`
+	// Amount of the proven outputs already spent, in atomic units.
+	Spent gonero.AtomicXMR `json:"spent"`
+}
+
+// Verifier verifies proofs against the chain of a daemon
+type Verifier struct {
+	client daemon.Client
+}
+
+// NewVerifier returns a Verifier reading transactions, outputs and key
+// images from client.
+func NewVerifier(client daemon.Client) *Verifier {
+	return &Verifier{client: client}
+}
+
+// CheckTxProof verifies the proof that the transaction txid paid the
+// address, a standard address, subaddress or integrated address, signed
+// with message. OutProofV2 proofs are made by the sender with the keys of
+// the transaction, InProofV2 proofs by the recipient with its view key.
+func (v *Verifier) CheckTxProof(txid, address, message, signature string) (*TxProofCheck, error) {
+	var out bool
+	switch {
+	case strings.HasPrefix(signature, headerOutProofV2):
+		out, signature = true, signature[len(headerOutProofV2):]
+	case strings.HasPrefix(signature, headerInProofV2):
+		signature = signature[len(headerInProofV2):]
+	case strings.HasPrefix(signature, headerOutProofV1), strings.HasPrefix(signature, headerInProofV1):
+		return nil, fmt.Errorf("%w: version 1 transaction proof", ErrUnsupported)
+	default:
+		return nil, fmt.Errorf("%w: not a transaction proof", ErrInvalidProof)
+	}
+	spend, view, subaddress, err := addressKeys(address)
+	if err != nil {
+		return nil, err
+	}
+	var B *crypto.Key
+	if subaddress {
+		B = &spend
+	}
+
+	tx, info, err := v.transaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := transaction.ParseExtra(tx.Extra)
+	if err != nil || !extra.HasPublicKey {
+		return nil, fmt.Errorf("%w: no public key in transaction %s", ErrInvalidProof, txid)
+	}
+	pubs := append([]crypto.Key{extra.PublicKey}, extra.AdditionalPublicKeys...)
+	const entrySize = encodedKeySize + encodedSignatureSize
+	if len(signature) != len(pubs)*entrySize {
+		return nil, fmt.Errorf("%w: %d characters for %d public keys", ErrInvalidProof, len(signature), len(pubs))
+	}
+
+	hash, err := messageHash(txid, message)
+	if err != nil {
+		return nil, err
+	}
+	derivations := make([]crypto.Key, len(pubs))
+	for i, R := range pubs {
+		entry := signature[i*entrySize : (i+1)*entrySize]
+		D, err := decodeKey(entry[:encodedKeySize])
+		if err != nil {
+			return nil, err
+		}
+		sig, err := decodeSignature(entry[encodedKeySize:])
+		if err != nil {
+			return nil, err
+		}
+		// D = r*A for the sender, a*R for the recipient
+		if out {
+			err = crypto.VerifyTxProof(hash, R, view, B, D, sig)
+		} else {
+			err = crypto.VerifyTxProof(hash, view, R, B, D, sig)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+		if derivations[i], err = crypto.KeyDerivation(D, crypto.SecretKey{1}); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+	}
+	var additional []crypto.Key
+	if len(derivations) == len(tx.Outputs)+1 {
+		additional = derivations[1:]
+	}
+	received, err := tx.CheckDerivations(derivations[0], additional, address)
+	if err != nil {
+		return nil, err
+	}
+	check := &TxProofCheck{Received: received.Received, InPool: info.InPool}
+	if !check.InPool {
+		if check.Confirmations, err = v.confirmations(info.BlockHeight); err != nil {
+			return nil, err
+		}
+	}
+	return check, nil
+}
+
+// CheckSpendProof verifies the proof that the transaction txid was sent by
+// the owner of its inputs, signed with message.
+func (v *Verifier) CheckSpendProof(txid, message, signature string) error {
+	if !strings.HasPrefix(signature, headerSpendProofV1) {
+		return fmt.Errorf("%w: not a spend proof", ErrInvalidProof)
+	}
+	signature = signature[len(headerSpendProofV1):]
+	tx, _, err := v.transaction(txid)
+	if err != nil {
+		return err
+	}
+
+	// ring members of all the inputs
+	req := &daemon.GetOutsRequest{}
+	for _, in := range tx.Inputs {
+		if in.Coinbase {
+			continue
+		}
+		for _, index := range in.Ring() {
+			req.Outputs = append(req.Outputs, daemon.Outputs{Amount: gonero.AtomicXMR(in.Amount), Index: index})
+		}
+	}
+	if len(req.Outputs) == 0 {
+		return fmt.Errorf("%w: transaction %s has no inputs to prove", ErrInvalidProof, txid)
+	}
+	if len(signature) != len(req.Outputs)*encodedSignatureSize {
+		return fmt.Errorf("%w: %d characters for %d ring members", ErrInvalidProof, len(signature), len(req.Outputs))
+	}
+	resp, err := v.client.GetOuts(req)
+	if err != nil {
+		return fmt.Errorf("proof: get outs: %w", err)
+	}
+	if len(resp.Outs) != len(req.Outputs) {
+		return fmt.Errorf("%w: %d outputs for %d indices", ErrUnexpected, len(resp.Outs), len(req.Outputs))
+	}
+
+	hash, err := messageHash(txid, message)
+	if err != nil {
+		return err
+	}
+	for i, in := range tx.Inputs {
+		if in.Coinbase {
+			continue
+		}
+		ring := make([]crypto.Key, len(in.KeyOffsets))
+		sigs := make([]crypto.Signature, len(in.KeyOffsets))
+		for j := range ring {
+			if ring[j], err = crypto.ParseKey(resp.Outs[0].Key); err != nil {
+				return fmt.Errorf("%w: %v", ErrUnexpected, err)
+			}
+			if sigs[j], err = decodeSignature(signature[:encodedSignatureSize]); err != nil {
+				return err
+			}
+			resp.Outs, signature = resp.Outs[1:], signature[encodedSignatureSize:]
+		}
+		if err := crypto.VerifyRing(hash, in.KeyImage, ring, sigs); err != nil {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidProof, i, err)
+		}
+	}
+	return nil
+}
+
+// reserveEntry is the proof of an output of a reserve proof
+type reserveEntry struct {
+	txid            crypto.Key
+	index           uint64
+	sharedSecret    crypto.Key
+	keyImage        crypto.Key
+	sharedSecretSig crypto.Signature
+	keyImageSig     crypto.Signature
+}
+
+// spendKeySig is the signature of a subaddress spend key of a reserve
+// proof
+type spendKeySig struct {
+	key crypto.Key
+	sig crypto.Signature
+}
+
+// CheckReserveProof verifies the proof that the wallet of the standard
+// address owns the outputs of the proof, signed with message. It returns
+// their amount, and the amount of those already spent.
+func (v *Verifier) CheckReserveProof(address, message, signature string) (*ReserveProofCheck, error) {
+	if strings.HasPrefix(signature, headerReserveProofV1) {
+		return nil, fmt.Errorf("%w: version 1 reserve proof", ErrUnsupported)
+	}
+	if !strings.HasPrefix(signature, headerReserveProofV2) {
+		return nil, fmt.Errorf("%w: not a reserve proof", ErrInvalidProof)
+	}
+	a := gonero.NewAddress(address)
+	if a == nil {
+		return nil, fmt.Errorf("%w: %s is not a standard address", ErrInvalidAddress, address)
+	}
+	spend, err := crypto.ParseKey(a.SpendKey())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	view, err := crypto.ParseKey(a.ViewKey())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	entries, spendKeys, err := decodeReserveProof(signature[len(headerReserveProofV2):])
+	if err != nil {
+		return nil, err
+	}
+
+	// the message, the address and the key images are signed
+	data := [][]byte{[]byte(message), spend[:], view[:]}
+	for i := range entries {
+		data = append(data, entries[i].keyImage[:])
+	}
+	hash := crypto.Keccak256(data...)
+
+	txs := map[crypto.Key]*transaction.Transaction{}
+	keyImages := &daemon.IsKeyImageSpentRequest{}
+	for _, e := range entries {
+		keyImages.KeyImages = append(keyImages.KeyImages, e.keyImage.String())
+		if txs[e.txid] != nil {
+			continue
+		}
+		if txs[e.txid], _, err = v.transaction(e.txid.String()); err != nil {
+			return nil, err
+		}
+	}
+	spent, err := v.client.IsKeyImageSpent(keyImages)
+	if err != nil {
+		return nil, fmt.Errorf("proof: is key image spent: %w", err)
+	}
+	if len(spent.SpentStatus) != len(entries) {
+		return nil, fmt.Errorf("%w: %d statuses for %d key images", ErrUnexpected, len(spent.SpentStatus), len(entries))
+	}
+	subaddresses := make(map[crypto.Key]bool, len(spendKeys))
+	for _, s := range spendKeys {
+		subaddresses[s.key] = true
+	}
+
+	check := &ReserveProofCheck{}
+	for i, e := range entries {
+		amount, err := checkReserveEntry(hash, view, txs[e.txid], e, subaddresses)
+		if err != nil {
+			return nil, fmt.Errorf("%w: output %d of %s: %v", ErrInvalidProof, e.index, e.txid, err)
+		}
+		check.Total += gonero.AtomicXMR(amount)
+		if spent.SpentStatus[i] != 0 {
+			check.Spent += gonero.AtomicXMR(amount)
+		}
+	}
+	// the wallet owns the spend keys of the subaddresses
+	for _, s := range spendKeys {
+		if err := crypto.VerifySignature(hash, s.key, s.sig); err != nil {
+			return nil, fmt.Errorf("%w: spend key %s: %v", ErrInvalidProof, s.key, err)
+		}
+	}
+	return check, nil
+}
+
+// checkReserveEntry verifies the proof of an output, received by one of
+// the subaddresses, and returns its amount
+func checkReserveEntry(hash, view crypto.Key, tx *transaction.Transaction, e reserveEntry, subaddresses map[crypto.Key]bool) (uint64, error) {
+	if e.index >= uint64(len(tx.Outputs)) {
+		return 0, errors.New("no such output")
+	}
+	key := tx.Outputs[e.index].Key
+	extra, err := transaction.ParseExtra(tx.Extra)
+	if err != nil {
+		return 0, err
+	}
+	// the shared secret is a*R, of the public key or the additional
+	// public key of the output
+	err = crypto.VerifyTxProof(hash, view, extra.PublicKey, nil, e.sharedSecret, e.sharedSecretSig)
+	if err != nil && len(extra.AdditionalPublicKeys) == len(tx.Outputs) {
+		err = crypto.VerifyTxProof(hash, view, extra.AdditionalPublicKeys[e.index], nil, e.sharedSecret, e.sharedSecretSig)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// the key image is the one of the output
+	if err := crypto.VerifyRing(hash, e.keyImage, []crypto.Key{key}, []crypto.Signature{e.keyImageSig}); err != nil {
+		return 0, err
+	}
+	derivation, err := crypto.KeyDerivation(e.sharedSecret, crypto.SecretKey{1})
+	if err != nil {
+		return 0, err
+	}
+	subaddress, err := crypto.DeriveSubaddressPublicKey(key, derivation, e.index)
+	if err != nil {
+		return 0, err
+	}
+	if !subaddresses[subaddress] {
+		return 0, errors.New("not received by the address")
+	}
+	amount, ok := tx.OutputAmount(int(e.index), derivation)
+	if !ok {
+		return 0, errors.New("amount does not match the commitment")
+	}
+	return amount, nil
+}
+
+// decodeReserveProof decodes the entries and the spend key signatures of a
+// reserve proof, serialized as by monerod
+func decodeReserveProof(s string) ([]reserveEntry, []spendKeySig, error) {
+	b := base58.Decode(s)
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("%w: bad base58", ErrInvalidProof)
+	}
+	r := &reader{buf: b}
+	entries := make([]reserveEntry, r.count(2*crypto.KeySize+crypto.SignatureSize))
+	for i := range entries {
+		e := &entries[i]
+		e.txid = r.key()
+		e.index = r.varint()
+		e.sharedSecret = r.key()
+		e.keyImage = r.key()
+		e.sharedSecretSig = r.signature()
+		e.keyImageSig = r.signature()
+	}
+	spendKeys := make([]spendKeySig, r.count(crypto.KeySize+crypto.SignatureSize))
+	for i := range spendKeys {
+		spendKeys[i].key = r.key()
+		spendKeys[i].sig = r.signature()
+	}
+	if r.err == nil && r.pos != len(b) {
+		r.err = fmt.Errorf("%d trailing bytes", len(b)-r.pos)
+	}
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProof, r.err)
+	}
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("%w: no outputs", ErrInvalidProof)
+	}
+	return entries, spendKeys, nil
+}
+
+// reader reads the binary serialization of monerod
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n > len(r.buf)-r.pos {
+		r.err = errors.New("unexpected end of data")
+		return make([]byte, n)
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) key() crypto.Key {
+	var k crypto.Key
+	copy(k[:], r.bytes(len(k)))
+	return k
+}
+
+func (r *reader) signature() crypto.Signature {
+	sig, _ := crypto.ParseSignature(r.bytes(crypto.SignatureSize))
+	return sig
+}
+
+func (r *reader) varint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.buf[r.pos:])
+	if size <= 0 {
+		r.err = errors.New("bad varint")
+		return 0
+	}
+	r.pos += size
+	return n
+}
+
+// count reads the count of a vector of elements of size bytes
+func (r *reader) count(size int) int {
+	n := r.varint()
+	if r.err == nil && n > uint64((len(r.buf)-r.pos)/size) {
+		r.err = fmt.Errorf("%d elements", n)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// transaction returns the transaction txid from the daemon
+func (v *Verifier) transaction(txid string) (*transaction.Transaction, *daemon.Transaction, error) {
+	resp, err := v.client.GetTransactions(&daemon.GetTransactionsRequest{TxsHashes: []string{txid}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("proof: get transactions: %w", err)
+	}
+	if len(resp.Txs) != 1 {
+		return nil, nil, fmt.Errorf("%w: %s", transaction.ErrTxNotFound, txid)
+	}
+	tx, err := transaction.ParseHex(resp.Txs[0].AsHex)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hash := tx.Hash(); hash.String() != txid {
+		return nil, nil, fmt.Errorf("%w: transaction %s for %s", ErrUnexpected, hash, txid)
+	}
+	return tx, &resp.Txs[0], nil
+}
+
+// confirmations returns the confirmations of the block at height
+func (v *Verifier) confirmations(height uint64) (uint64, error) {
+	resp, err := v.client.GetHeight(&daemon.GetHeightRequest{})
+	if err != nil {
+		return 0, fmt.Errorf("proof: get height: %w", err)
+	}
+	if resp.Height <= height {
+		return 0, nil
+	}
+	return resp.Height - height, nil
+}
+
+// messageHash returns the hash signed by transaction and spend proofs, of
+// the transaction id followed by the message
+func messageHash(txid, message string) (crypto.Key, error) {
+	id, err := hex.DecodeString(txid)
+	if err != nil || len(id) != crypto.KeySize {
+		return crypto.Key{}, fmt.Errorf("%w: transaction id %q", ErrInvalidProof, txid)
+	}
+	return crypto.Keccak256(id, []byte(message)), nil
+}
+
+func decodeKey(s string) (crypto.Key, error) {
+	var k crypto.Key
+	b := base58.Decode(s)
+	if len(b) != len(k) {
+		return k, fmt.Errorf("%w: bad key", ErrInvalidProof)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+func decodeSignature(s string) (crypto.Signature, error) {
+	sig, err := crypto.ParseSignature(base58.Decode(s))
+	if err != nil {
+		return sig, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return sig, nil
+}
+
+// addressKeys returns the public keys of an address, and whether it is a
+// subaddress
+func addressKeys(address string) (spend, view crypto.Key, subaddress bool, err error) {
+	var s, v string
+	if a := gonero.NewAddress(address); a != nil {
+		s, v = a.SpendKey(), a.ViewKey()
+	} else if a := gonero.NewSubAddress(address); a != nil {
+		s, v, subaddress = a.SpendKey(), a.ViewKey(), true
+	} else if a := gonero.NewIntegratedAddress(address); a != nil {
+		s, v = a.SpendKey(), a.ViewKey()
+	} else {
+		return spend, view, false, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+	}
+	if spend, err = crypto.ParseKey(s); err != nil {
+		return spend, view, false, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	if view, err = crypto.ParseKey(v); err != nil {
+		return spend, view, false, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return spend, view, subaddress, nil
+}
@@ -0,0 +1,457 @@
+package proof
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/base58"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/transaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keys of the address used across the tests of gonero
+const (
+	testAddress   = "42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm"
+	testViewKey   = "49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"
+	testSpendKey  = "148d78d2aba7dbca5cd8f6abcfb0b3c009ffbdbea1ff373d50ed94d78286640e"
+	testSubaddr01 = "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF"
+)
+
+const testHeight = 3000
+
+// fakeDaemon is a stand-in daemon serving transactions and outputs
+type fakeDaemon struct {
+	daemon.Client
+
+	txs     map[string]daemon.Transaction
+	outputs map[uint64]crypto.Key
+	spent   map[string]uint64
+}
+
+func newFakeDaemon() *fakeDaemon {
+	return &fakeDaemon{txs: map[string]daemon.Transaction{}, outputs: map[uint64]crypto.Key{}, spent: map[string]uint64{}}
+}
+
+func (d *fakeDaemon) GetTransactions(req *daemon.GetTransactionsRequest) (*daemon.GetTransactionsResponse, error) {
+	resp := &daemon.GetTransactionsResponse{Status: daemon.RPCStatusOk}
+	for _, hash := range req.TxsHashes {
+		if tx, ok := d.txs[hash]; ok {
+			resp.Txs = append(resp.Txs, tx)
+		} else {
+			resp.MissedTx = append(resp.MissedTx, hash)
+		}
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) GetHeight(req *daemon.GetHeightRequest) (*daemon.GetHeightResponse, error) {
+	return &daemon.GetHeightResponse{Height: testHeight, Status: daemon.RPCStatusOk}, nil
+}
+
+func (d *fakeDaemon) GetOuts(req *daemon.GetOutsRequest) (*daemon.GetOutsResponse, error) {
+	resp := &daemon.GetOutsResponse{Status: daemon.RPCStatusOk}
+	for _, o := range req.Outputs {
+		key, ok := d.outputs[o.Index]
+		if !ok {
+			key, _ = crypto.NewSecretKey().PublicKey()
+			d.outputs[o.Index] = key
+		}
+		resp.Outs = append(resp.Outs, daemon.Outs{Key: key.String(), Unlocked: true})
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) IsKeyImageSpent(req *daemon.IsKeyImageSpentRequest) (*daemon.IsKeyImageSpentResponse, error) {
+	resp := &daemon.IsKeyImageSpentResponse{Status: daemon.RPCStatusOk}
+	for _, ki := range req.KeyImages {
+		resp.SpentStatus = append(resp.SpentStatus, d.spent[ki])
+	}
+	return resp, nil
+}
+
+// wallet holds the keys of the test address
+type wallet struct {
+	view, spend       crypto.SecretKey
+	viewPub, spendPub crypto.Key
+}
+
+func testWallet(t *testing.T) *wallet {
+	w := &wallet{}
+	var err error
+	w.view, err = crypto.ParseSecretKey(testViewKey)
+	require.NoError(t, err)
+	w.spend, err = crypto.ParseSecretKey(testSpendKey)
+	require.NoError(t, err)
+	w.viewPub, _ = w.view.PublicKey()
+	w.spendPub, _ = w.spend.PublicKey()
+	return w
+}
+
+// payment is an output of a test transaction
+type payment struct {
+	address string
+	amount  uint64
+}
+
+// sent is a test transaction with its secret keys
+type sent struct {
+	tx         *transaction.Transaction
+	txid       string
+	r          crypto.SecretKey
+	additional []crypto.SecretKey
+	// one-time secret key of the spent output, and its ring
+	input crypto.SecretKey
+	ring  []uint64
+}
+
+// keys returns the public keys of an address, and whether it is a subaddress
+func keys(t *testing.T, address string) (spend, view crypto.Key, subaddress bool) {
+	spend, view, subaddress, err := addressKeys(address)
+	require.NoError(t, err)
+	return spend, view, subaddress
+}
+
+// send adds to the daemon a transaction paying the payments, with
+// additional keys if asked, spending an output in a ring of 4
+func (d *fakeDaemon) send(t *testing.T, height uint64, additional bool, payments ...payment) *sent {
+	s := &sent{r: crypto.NewSecretKey(), input: crypto.NewSecretKey()}
+	R, _ := s.r.PublicKey()
+	if spend, _, sub := keys(t, payments[0].address); sub && !additional {
+		R, _ = crypto.ScalarMult(s.r, spend)
+	}
+	extra := &transaction.Extra{PublicKey: R, HasPublicKey: true}
+
+	// the spent output is the third of the ring
+	s.ring = []uint64{height * 10, height*10 + 3, height*10 + 7, height*10 + 9}
+	d.outputs[s.ring[2]], _ = s.input.PublicKey()
+	keyImage, err := crypto.KeyImage(d.outputs[s.ring[2]], s.input)
+	require.NoError(t, err)
+
+	rct := &transaction.RctSignatures{Type: transaction.RCTTypeBulletproofPlus, Fee: 30000000}
+	tx := &transaction.Transaction{
+		Version:       2,
+		Inputs:        []transaction.Input{{KeyOffsets: transaction.KeyOffsets(s.ring), KeyImage: keyImage}},
+		RctSignatures: rct,
+	}
+	for i, p := range payments {
+		spend, view, sub := keys(t, p.address)
+		r := s.r
+		if additional {
+			r = crypto.NewSecretKey()
+			s.additional = append(s.additional, r)
+			pub, _ := r.PublicKey()
+			if sub {
+				pub, _ = crypto.ScalarMult(r, spend)
+			}
+			extra.AdditionalPublicKeys = append(extra.AdditionalPublicKeys, pub)
+		}
+		derivation, err := crypto.KeyDerivation(view, r)
+		require.NoError(t, err)
+		key, err := crypto.DerivePublicKey(derivation, uint64(i), spend)
+		require.NoError(t, err)
+		shared := crypto.DerivationToScalar(derivation, uint64(i))
+		encrypted := crypto.EncodeAmount(p.amount, shared)
+		var ecdh transaction.EcdhInfo
+		copy(ecdh.Amount[:], encrypted[:])
+		commitment, err := crypto.Commit(p.amount, crypto.CommitmentMask(shared))
+		require.NoError(t, err)
+		tx.Outputs = append(tx.Outputs, transaction.Output{Key: key, HasViewTag: true, ViewTag: crypto.ViewTag(derivation, uint64(i))})
+		rct.EcdhInfo = append(rct.EcdhInfo, ecdh)
+		rct.OutPk = append(rct.OutPk, commitment)
+		rct.PseudoOuts = append(rct.PseudoOuts, commitment)
+	}
+	rct.PseudoOuts = rct.PseudoOuts[:1]
+	rct.CLSAGs = []crypto.CLSAG{{S: make([]crypto.Key, len(s.ring))}}
+	tx.Extra = extra.Bytes()
+
+	// through the wire format, as the daemon serves it
+	s.tx, err = transaction.Parse(tx.Bytes())
+	require.NoError(t, err)
+	hash := s.tx.Hash()
+	s.txid = hash.String()
+	d.txs[s.txid] = daemon.Transaction{TxHash: s.txid, AsHex: tx.Hex(), BlockHeight: height}
+	return s
+}
+
+// txProof returns the transaction proof of wallet2 for the payment to the
+// address, by the sender or by the recipient with its view key
+func txProof(t *testing.T, s *sent, address, message string, view *crypto.SecretKey) string {
+	spend, viewPub, sub := keys(t, address)
+	var B *crypto.Key
+	if sub {
+		B = &spend
+	}
+	hash, err := messageHash(s.txid, message)
+	require.NoError(t, err)
+	extra, err := transaction.ParseExtra(s.tx.Extra)
+	require.NoError(t, err)
+	secrets := append([]crypto.SecretKey{s.r}, s.additional...)
+	proof := "OutProofV2"
+	if view != nil {
+		proof = "InProofV2"
+	}
+	for i, R := range append([]crypto.Key{extra.PublicKey}, extra.AdditionalPublicKeys...) {
+		var D crypto.Key
+		var sig crypto.Signature
+		if view == nil {
+			D, err = crypto.ScalarMult(secrets[i], viewPub)
+			require.NoError(t, err)
+			sig, err = crypto.SignTxProof(hash, R, viewPub, B, D, secrets[i])
+		} else {
+			D, err = crypto.ScalarMult(*view, R)
+			require.NoError(t, err)
+			sig, err = crypto.SignTxProof(hash, viewPub, R, B, D, *view)
+		}
+		require.NoError(t, err)
+		proof += base58.Encode(D[:]) + base58.Encode(sig.Bytes())
+	}
+	return proof
+}
+
+func TestCheckTxProof(t *testing.T) {
+	d := newFakeDaemon()
+	w := testWallet(t)
+	v := NewVerifier(d)
+	other, _ := crypto.NewSecretKey().PublicKey()
+
+	// to the address, and to the subaddress
+	toAddress := d.send(t, 2990, false, payment{testAddress, 1000000000000}, payment{testAddress, 5000})
+	toSub := d.send(t, 2995, false, payment{testSubaddr01, 2000000000000}, payment{testAddress, 7000})
+	// with additional keys
+	toBoth := d.send(t, 2999, true, payment{testSubaddr01, 3000000000000}, payment{testAddress, 4000000000000})
+
+	for _, c := range []struct {
+		s        *sent
+		address  string
+		received gonero.AtomicXMR
+		out      bool
+	}{
+		{s: toAddress, address: testAddress, received: 1000000005000, out: true},
+		{s: toAddress, address: testAddress, received: 1000000005000},
+		{s: toSub, address: testSubaddr01, received: 2000000000000, out: true},
+		{s: toSub, address: testSubaddr01, received: 2000000000000},
+		{s: toBoth, address: testSubaddr01, received: 3000000000000},
+		{s: toBoth, address: testAddress, received: 4000000000000},
+	} {
+		var view *crypto.SecretKey
+		if !c.out {
+			view = &w.view
+		}
+		proof := txProof(t, c.s, c.address, "paid", view)
+		check, err := v.CheckTxProof(c.s.txid, c.address, "paid", proof)
+		require.NoError(t, err, "%s", proof[:10])
+		assert.Equal(t, c.received, check.Received)
+		assert.False(t, check.InPool)
+		assert.Equal(t, testHeight-d.txs[c.s.txid].BlockHeight, check.Confirmations)
+
+		_, err = v.CheckTxProof(c.s.txid, c.address, "not paid", proof)
+		assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	}
+
+	// a proof for another address proves nothing
+	proof := txProof(t, toAddress, testAddress, "", nil)
+	_, err := v.CheckTxProof(toAddress.txid, testSubaddr01, "", proof)
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	_, err = v.CheckTxProof(toAddress.txid, testAddress, "", proof[:len(proof)-1])
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	_, err = v.CheckTxProof(toAddress.txid, testAddress, "", "OutProofV1"+proof[10:])
+	assert.True(t, errors.Is(err, ErrUnsupported), "%v", err)
+	_, err = v.CheckTxProof(toAddress.txid, "nope", "", proof)
+	assert.True(t, errors.Is(err, ErrInvalidAddress), "%v", err)
+	_, err = v.CheckTxProof(other.String(), testAddress, "", proof)
+	assert.True(t, errors.Is(err, transaction.ErrTxNotFound), "%v", err)
+
+	// in the pool
+	tx := d.txs[toAddress.txid]
+	tx.InPool, tx.BlockHeight = true, 0
+	d.txs[toAddress.txid] = tx
+	check, err := v.CheckTxProof(toAddress.txid, testAddress, "", proof)
+	require.NoError(t, err)
+	assert.True(t, check.InPool)
+	assert.Zero(t, check.Confirmations)
+}
+
+func TestCheckTxProofStagenet(t *testing.T) {
+	// a stagenet transaction paying 0.045 XMR to a subaddress of a wallet
+	// whose view key is known
+	data, err := ioutil.ReadFile("testdata/stagenet_txs.json")
+	require.NoError(t, err)
+	var txs []daemon.Transaction
+	require.NoError(t, json.Unmarshal(data, &txs))
+	require.Len(t, txs, 1)
+	tx, err := transaction.ParseHex(txs[0].AsHex)
+	require.NoError(t, err)
+	d := newFakeDaemon()
+	d.txs[txs[0].TxHash] = txs[0]
+	view, err := crypto.ParseSecretKey("8aa763d1c8d9da4ca75cb6ca22a021b5cca376c1367be8d62bcc9cdf4b926009")
+	require.NoError(t, err)
+	address := "78hRedVbk2N3Mg2DpMMUoCbynA1uZJzAr7R7rnCtBo4Q1FtnDePx7NPAcCGPXVEBTp96AjRnR9uchhan49fbBAnuLTU11cw"
+
+	proof := txProof(t, &sent{tx: tx, txid: txs[0].TxHash}, address, "stagenet", &view)
+	check, err := NewVerifier(d).CheckTxProof(txs[0].TxHash, address, "stagenet", proof)
+	require.NoError(t, err)
+	assert.Equal(t, gonero.AtomicXMR(45000000000), check.Received)
+}
+
+func TestCheckSpendProof(t *testing.T) {
+	d := newFakeDaemon()
+	v := NewVerifier(d)
+	s := d.send(t, 2990, false, payment{testAddress, 1000000000000})
+
+	hash, err := messageHash(s.txid, "spent")
+	require.NoError(t, err)
+	ring := make([]crypto.Key, len(s.ring))
+	for i, index := range s.ring {
+		out, err := d.GetOuts(&daemon.GetOutsRequest{Outputs: []daemon.Outputs{{Index: index}}})
+		require.NoError(t, err)
+		ring[i], _ = crypto.ParseKey(out.Outs[0].Key)
+	}
+	sigs, keyImage, err := crypto.SignRing(hash, ring, 2, s.input)
+	require.NoError(t, err)
+	assert.Equal(t, s.tx.Inputs[0].KeyImage, keyImage)
+	proof := "SpendProofV1"
+	for _, sig := range sigs {
+		proof += base58.Encode(sig.Bytes())
+	}
+	assert.NoError(t, v.CheckSpendProof(s.txid, "spent", proof))
+	err = v.CheckSpendProof(s.txid, "not spent", proof)
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	err = v.CheckSpendProof(s.txid, "spent", proof[:len(proof)-88])
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	err = v.CheckSpendProof(s.txid, "spent", "OutProofV2")
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+
+	// signed by another member of the ring
+	other := crypto.NewSecretKey()
+	ring[0], _ = other.PublicKey()
+	d.outputs[s.ring[0]] = ring[0]
+	sigs, _, err = crypto.SignRing(hash, ring, 0, other)
+	require.NoError(t, err)
+	proof = "SpendProofV1"
+	for _, sig := range sigs {
+		proof += base58.Encode(sig.Bytes())
+	}
+	err = v.CheckSpendProof(s.txid, "spent", proof)
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+}
+
+// reserveProof returns the reserve proof of wallet2 for the outputs, given
+// by transaction and index, of the wallet
+func reserveProof(t *testing.T, w *wallet, message string, txs []*sent, indices []int, minors []uint32) (string, []crypto.Key) {
+	type entry struct {
+		txid, shared, keyImage, key crypto.Key
+		index                       uint64
+		R                           crypto.Key
+		secret                      crypto.SecretKey
+	}
+	entries := make([]entry, len(txs))
+	spendKeys := map[crypto.Key]crypto.SecretKey{}
+	var keyImages []crypto.Key
+	for i, s := range txs {
+		e := &entries[i]
+		e.txid, _ = crypto.ParseKey(s.txid)
+		e.index = uint64(indices[i])
+		e.key = s.tx.Outputs[e.index].Key
+		extra, err := transaction.ParseExtra(s.tx.Extra)
+		require.NoError(t, err)
+		e.R = extra.PublicKey
+		if len(extra.AdditionalPublicKeys) != 0 {
+			e.R = extra.AdditionalPublicKeys[e.index]
+		}
+		e.shared, err = crypto.ScalarMult(w.view, e.R)
+		require.NoError(t, err)
+		derivation, err := crypto.KeyDerivation(e.R, w.view)
+		require.NoError(t, err)
+		spend := w.spend
+		if minors[i] != 0 {
+			spend, err = crypto.AddSecretKeys(spend, crypto.SubaddressSecretKey(w.view, 0, minors[i]))
+			require.NoError(t, err)
+		}
+		pub, _ := spend.PublicKey()
+		spendKeys[pub] = spend
+		e.secret, err = crypto.DeriveSecretKey(derivation, e.index, spend)
+		require.NoError(t, err)
+		e.keyImage, err = crypto.KeyImage(e.key, e.secret)
+		require.NoError(t, err)
+		keyImages = append(keyImages, e.keyImage)
+	}
+
+	data := [][]byte{[]byte(message), w.spendPub[:], w.viewPub[:]}
+	for i := range keyImages {
+		data = append(data, keyImages[i][:])
+	}
+	hash := crypto.Keccak256(data...)
+	blob := appendVarint(nil, uint64(len(entries)))
+	for _, e := range entries {
+		sharedSig, err := crypto.SignTxProof(hash, w.viewPub, e.R, nil, e.shared, w.view)
+		require.NoError(t, err)
+		keyImageSigs, _, err := crypto.SignRing(hash, []crypto.Key{e.key}, 0, e.secret)
+		require.NoError(t, err)
+		blob = append(blob, e.txid[:]...)
+		blob = appendVarint(blob, e.index)
+		blob = append(blob, e.shared[:]...)
+		blob = append(blob, e.keyImage[:]...)
+		blob = append(blob, sharedSig.Bytes()...)
+		blob = append(blob, keyImageSigs[0].Bytes()...)
+	}
+	blob = appendVarint(blob, uint64(len(spendKeys)))
+	for pub, sec := range spendKeys {
+		sig, err := crypto.Sign(hash, sec)
+		require.NoError(t, err)
+		blob = append(blob, pub[:]...)
+		blob = append(blob, sig.Bytes()...)
+	}
+	return "ReserveProofV2" + base58.Encode(blob), keyImages
+}
+
+func appendVarint(b []byte, n uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, n)]...)
+}
+
+func TestCheckReserveProof(t *testing.T) {
+	d := newFakeDaemon()
+	w := testWallet(t)
+	v := NewVerifier(d)
+	toAddress := d.send(t, 2990, false, payment{testAddress, 1000000000000}, payment{testAddress, 5000})
+	toBoth := d.send(t, 2999, true, payment{testSubaddr01, 3000000000000}, payment{testAddress, 4000000000000})
+
+	proof, keyImages := reserveProof(t, w, "reserve", []*sent{toAddress, toBoth, toBoth}, []int{0, 0, 1}, []uint32{0, 1, 0})
+	check, err := v.CheckReserveProof(testAddress, "reserve", proof)
+	require.NoError(t, err)
+	assert.Equal(t, gonero.AtomicXMR(8000000000000), check.Total)
+	assert.Zero(t, check.Spent)
+
+	d.spent[keyImages[2].String()] = 1
+	check, err = v.CheckReserveProof(testAddress, "reserve", proof)
+	require.NoError(t, err)
+	assert.Equal(t, gonero.AtomicXMR(4000000000000), check.Spent)
+
+	_, err = v.CheckReserveProof(testAddress, "other", proof)
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	_, err = v.CheckReserveProof(testSubaddr01, "reserve", proof)
+	assert.True(t, errors.Is(err, ErrInvalidAddress), "%v", err)
+	_, err = v.CheckReserveProof(testAddress, "reserve", proof[:len(proof)-5])
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+	_, err = v.CheckReserveProof(testAddress, "reserve", "ReserveProofV1"+proof[14:])
+	assert.True(t, errors.Is(err, ErrUnsupported), "%v", err)
+	// version 1 proofs of monero-wallet-rpc are boost archives, from the
+	// get_reserve_proof example of its documentation
+	_, err = v.CheckReserveProof("55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt", "",
+		"ReserveProofV11BZ23sBt9sZJeGccf84mzyAmNCP3KzYbE1111112VKmH111118NfCYJQjZ6c46gT2kXgcHCaSSZeL8sRdzqjqx7i1e7FQfQGu2o113UYFVdwzHQi3iENDPa76Kn1BvywbKz3bMkXdZkBEEhBSF4kjjGaiMJ1ucKb6wvMVC4A8sA4nZEdL2Mk3wBucJCYTZwKqA8i1M113kqakDkG25FrjiDqdQTCYz2wDBmfKxF3eQiV5FWzZ6HmAyxnqTWUiMWukP9A3Edy3ZXqjP1b23dhz7Mbj39bBxe3ZeDNu9HnTSqYvHNRyqCkeUMJpHyQweqjGUJ1DSfFYr33J1E7MkhMnEi1o7trqWjVix32XLetYfePG73yvHbS24837L7Q64i5n1LSpd9yMiQZ3Dyaysi5y6jPx7TpAvnSqBFtuCciKoNzaXoA3dqt9cuVFZTXzdXKqdt3cXcVJMNxY8RvKPVQHhUur94Lpo1nSpxf7BN5a5rHrbZFqoZszsZmiWikYPkLX72XUdw6NWjLrTBxSy7KuPYH86c6udPEXLo2xgN6XHMBMBJzt8FqqK7EcpNUBkuHm2AtpGkf9CABY3oSjDQoRF5n4vNLd3qUaxNsG4XJ12L9gJ7GrK273BxkfEA8fDdxPrb1gpespbgEnCTuZHqj1A")
+	assert.True(t, errors.Is(err, ErrUnsupported), "%v", err)
+
+	// an output of a subaddress whose spend key is not signed
+	proof, _ = reserveProof(t, w, "reserve", []*sent{toBoth}, []int{0}, []uint32{1})
+	blob := base58.Decode(proof[len("ReserveProofV2"):])
+	blob = append(blob[:len(blob)-1-crypto.KeySize-crypto.SignatureSize], 0)
+	_, err = v.CheckReserveProof(testAddress, "reserve", "ReserveProofV2"+base58.Encode(blob))
+	assert.True(t, errors.Is(err, ErrInvalidProof), "%v", err)
+}
@@ -0,0 +1,71 @@
+package gonero
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// redacted is what a Secret prints instead of its contents
+const redacted = "[REDACTED]"
+
+// Secret holds sensitive data such as private keys,
+// mnemonic seeds and wallet passwords.
+// It redacts itself whenever it is formatted or logged
+// and is only revealed when marshaled to JSON for the RPC wire.
+type Secret []byte
+
+// NewSecret creates a new Secret from a string.
+// The string itself cannot be wiped, so prefer building
+// secrets from byte slices the caller controls when possible.
+func NewSecret(s string) Secret {
+	return Secret(s)
+}
+
+// Reveal returns the secret contents as a string.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// Empty returns true if the secret holds no data.
+func (s Secret) Empty() bool {
+	return len(s) == 0
+}
+
+// Wipe zeroes the underlying bytes of the secret.
+func (s Secret) Wipe() {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+// String implements fmt.Stringer and always returns a redacted placeholder.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer and always returns a redacted placeholder.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format implements fmt.Formatter so that no verb (%v, %#v, %s, %x, ...)
+// can leak the secret contents.
+func (s Secret) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, redacted)
+}
+
+// MarshalJSON implements json.Marshaler.
+// The secret is marshaled as a plain JSON string.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = Secret(str)
+	return nil
+}
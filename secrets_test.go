@@ -0,0 +1,61 @@
+package gonero
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	const plain = "hunter2"
+
+	t.Run("redacts when formatted", func(t *testing.T) {
+		s := NewSecret(plain)
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+			out := fmt.Sprintf(verb, s)
+			assert.NotContains(t, out, plain, verb)
+			assert.Contains(t, out, redacted, verb)
+		}
+		assert.Equal(t, redacted, s.String())
+		assert.Equal(t, redacted, s.GoString())
+	})
+
+	t.Run("redacts when nested and logged", func(t *testing.T) {
+		type request struct {
+			Filename string
+			Password Secret
+		}
+		var buf bytes.Buffer
+		logger := log.New(&buf, "", 0)
+		logger.Printf("%+v %#v", request{"foo", NewSecret(plain)}, &request{"bar", NewSecret(plain)})
+		assert.NotContains(t, buf.String(), plain)
+	})
+
+	t.Run("marshals on the wire", func(t *testing.T) {
+		type request struct {
+			Password Secret `json:"password"`
+			Optional Secret `json:"optional,omitempty"`
+		}
+		data, err := json.Marshal(request{Password: NewSecret(plain)})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"password":"hunter2"}`, string(data))
+
+		var req request
+		assert.NoError(t, json.Unmarshal(data, &req))
+		assert.Equal(t, plain, req.Password.Reveal())
+		assert.True(t, req.Optional.Empty())
+
+		assert.Error(t, json.Unmarshal([]byte(`{"password":1}`), &req))
+	})
+
+	t.Run("wipes underlying bytes", func(t *testing.T) {
+		s := NewSecret(plain)
+		alias := s
+		s.Wipe()
+		assert.Equal(t, make([]byte, len(plain)), []byte(alias))
+	})
+}
@@ -0,0 +1,63 @@
+package gonero
+
+import (
+	"fmt"
+
+	"github.com/konraddical2/gonero/base58"
+	"github.com/konraddical2/gonero/crypto"
+	"golang.org/x/crypto/sha3"
+)
+
+// Subaddress derives the subaddress at index (major, minor) of the address
+// from its private view key, without a wallet. Index (0, 0) is the address
+// itself and cannot be derived as a subaddress.
+func (a *Address) Subaddress(privateViewKey string, major, minor uint32) (*SubAddress, error) {
+	if major == 0 && minor == 0 {
+		return nil, fmt.Errorf("index (0, 0) is the address itself")
+	}
+	view, err := a.privateKey(privateViewKey, MessageViewKey)
+	if err != nil {
+		return nil, fmt.Errorf("private view key does not match address")
+	}
+
+	// D = B + m*G, C = a*D
+	d, err := crypto.SubaddressSpendKey(a.publicKey(MessageSpendKey), view, major, minor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public spend key: %w", err)
+	}
+	c, err := crypto.ScalarMult(view, d)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := subAddrNetBytes[netIndex[a.Net()]]
+	out := append([]byte{prefix}, d[:]...)
+	out = append(out, c[:]...)
+	h := sha3.NewLegacyKeccak256()
+	h.Write(out)
+	out = append(out, h.Sum(nil)[:4]...)
+
+	return NewSubAddress(base58.Encode(out)), nil
+}
+
+// SubaddressSpendKey derives the private spend key of the subaddress at
+// index (major, minor) of the address, b + m, from the private spend and
+// view keys of the address. It signs messages for the subaddress.
+func (a *Address) SubaddressSpendKey(privateSpendKey Secret, privateViewKey string, major, minor uint32) (Secret, error) {
+	if major == 0 && minor == 0 {
+		return nil, fmt.Errorf("index (0, 0) is the address itself")
+	}
+	view, err := a.privateKey(privateViewKey, MessageViewKey)
+	if err != nil {
+		return nil, fmt.Errorf("private view key does not match address")
+	}
+	spend, err := a.privateKey(privateSpendKey.Reveal(), MessageSpendKey)
+	if err != nil {
+		return nil, fmt.Errorf("private spend key does not match address")
+	}
+	key, err := crypto.AddSecretKeys(spend, crypto.SubaddressSecretKey(view, major, minor))
+	if err != nil {
+		return nil, err
+	}
+	return NewSecret(key.Reveal()), nil
+}
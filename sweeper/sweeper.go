@@ -0,0 +1,363 @@
+// Package sweeper moves the excess funds of a hot wallet to cold storage.
+//
+// A Sweeper polls the unlocked balance of an account. Once it goes above a
+// high-water mark, the spendable outputs beyond a buffer kept for
+// withdrawals are sent to a cold address, within the configured schedule
+// windows and only while no payout is in flight.
+package sweeper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// DefaultPollInterval is the default interval between balance checks
+const DefaultPollInterval = time.Minute
+
+// Sweeper errors
+var (
+	ErrInvalidAddress = errors.New("sweeper: invalid cold address")
+	ErrWrongNetwork   = errors.New("sweeper: cold address of another network")
+	ErrInvalidConfig  = errors.New("sweeper: invalid config")
+	ErrClosed         = errors.New("sweeper: closed")
+)
+
+// Config configures a Sweeper
+type Config struct {
+	// Cold address receiving the excess funds.
+	Address string
+	// Network of the cold address: "main", "stage" or "test", as returned
+	// by gonero.Address.Net.
+	Network string
+	// Account of the hot wallet.
+	AccountIndex uint64
+	// Unlocked balance above which the account is swept, in atomic units.
+	HighWater gonero.AtomicXMR
+	// Balance kept for withdrawals, at most HighWater, in atomic units.
+	// Fees are paid from the swept amount, so the buffer stays whole.
+	Buffer gonero.AtomicXMR
+	// Smallest amount worth sweeping, in atomic units.
+	MinAmount gonero.AtomicXMR
+	// Priority of the sweep transactions, which determines their fee.
+	Priority wallet.PriorityType
+	// Times of the day when sweeps may happen, any time if empty.
+	Windows []Window
+	// Location of the windows, defaults to UTC.
+	Location *time.Location
+	// InFlight tells whether payouts are being sent, e.g. by a payout
+	// engine. Sweeps also wait for the pending outgoing transfers of the
+	// wallet.
+	InFlight func() (bool, error)
+	// Interval between balance checks, defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Notify triggers an immediate check, e.g. on a new block.
+	Notify <-chan struct{}
+}
+
+// Window is a time range of the day. Windows whose end is before their
+// start span midnight.
+type Window struct {
+	// Start of the window, from midnight.
+	Start time.Duration
+	// End of the window, from midnight, excluded.
+	End time.Duration
+}
+
+// contains tells whether the window contains a time of the day
+func (w Window) contains(t time.Duration) bool {
+	if w.Start <= w.End {
+		return t >= w.Start && t < w.End
+	}
+	return t >= w.Start || t < w.End
+}
+
+// Sweep is a transfer of funds to the cold address
+type Sweep struct {
+	// Transactions of the sweep.
+	Txids []string `json:"txids"`
+	// Amount received by the cold address, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Fee of the transactions, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+	// Time of the sweep.
+	Time time.Time `json:"time"`
+}
+
+// Sweeper sweeps the excess funds of an account to a cold address
+type Sweeper struct {
+	// Errors receives wallet and InFlight errors, after which the sweeper
+	// retries at the next check. Errors are dropped when the channel is full.
+	Errors <-chan error
+	// Sweeps receives the sweeps made by the sweeper. Sweeps are dropped
+	// when the channel is full.
+	Sweeps <-chan *Sweep
+
+	cfg    Config
+	client wallet.Client
+	errors chan error
+	sweeps chan *Sweep
+	now    func() time.Time
+
+	// mu serializes sweeps
+	mu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New checks the cold address against the network and starts a Sweeper
+// of the wallet behind client.
+func New(client wallet.Client, cfg *Config) (*Sweeper, error) {
+	return newSweeper(client, cfg, time.Now)
+}
+
+func newSweeper(client wallet.Client, cfg *Config, now func() time.Time) (*Sweeper, error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Network == "" {
+		return nil, fmt.Errorf("%w: network required", ErrInvalidConfig)
+	}
+	if c.HighWater == 0 || c.Buffer > c.HighWater {
+		return nil, fmt.Errorf("%w: buffer must not exceed a positive high-water mark", ErrInvalidConfig)
+	}
+	net, _ := gonero.ParseAddress(c.Address)
+	if net == "" {
+		return nil, fmt.Errorf("%w %q", ErrInvalidAddress, c.Address)
+	}
+	if net != c.Network {
+		return nil, fmt.Errorf("%w: %s address on %s", ErrWrongNetwork, net, c.Network)
+	}
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+
+	s := &Sweeper{
+		cfg:    c,
+		client: client,
+		errors: make(chan error, 16),
+		sweeps: make(chan *Sweep, 16),
+		now:    now,
+		done:   make(chan struct{}),
+	}
+	s.Errors = s.errors
+	s.Sweeps = s.sweeps
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Sweep checks the account now and sweeps its excess funds if the policy
+// allows it. It returns a nil Sweep when nothing is swept.
+func (s *Sweeper) Sweep() (*Sweep, error) {
+	select {
+	case <-s.done:
+		return nil, ErrClosed
+	default:
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweep()
+}
+
+// Close stops the sweeper.
+func (s *Sweeper) Close() error {
+	err := ErrClosed
+	s.closeOnce.Do(func() {
+		err = nil
+		close(s.done)
+	})
+	s.wg.Wait()
+	return err
+}
+
+func (s *Sweeper) run() {
+	defer s.wg.Done()
+	defer close(s.errors)
+	defer close(s.sweeps)
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	notify := s.cfg.Notify
+	for {
+		s.mu.Lock()
+		sw, err := s.sweep()
+		s.mu.Unlock()
+		if err != nil {
+			s.report(err)
+		}
+		if sw != nil {
+			select {
+			case s.sweeps <- sw:
+			default:
+			}
+		}
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+			}
+		}
+	}
+}
+
+// sweep sweeps the excess funds, with s.mu held
+func (s *Sweeper) sweep() (*Sweep, error) {
+	if !s.scheduled() {
+		return nil, nil
+	}
+	balance, err := s.client.GetBalance(&wallet.GetBalanceRequest{AccountIndex: s.cfg.AccountIndex})
+	if err != nil {
+		return nil, err
+	}
+	if balance.UnlockedBalance <= s.cfg.HighWater {
+		return nil, nil
+	}
+	if busy, err := s.inFlight(); busy || err != nil {
+		return nil, err
+	}
+
+	// the unlocked balance may count outputs which cannot be spent yet,
+	// such as frozen ones
+	incoming, err := s.client.IncomingTransfers(&wallet.IncomingTransfersRequest{
+		TransferType: wallet.TransferAvailable,
+		AccountIndex: s.cfg.AccountIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var spendable gonero.AtomicXMR
+	subaddrs := make(map[uint64]bool)
+	for _, t := range incoming.Transfers {
+		if t.Spent || t.Frozen || !t.Unlocked {
+			continue
+		}
+		spendable += t.Amount
+		subaddrs[t.SubaddrIndex.Minor] = true
+	}
+	if spendable <= s.cfg.Buffer {
+		return nil, nil
+	}
+	excess := spendable - s.cfg.Buffer
+	if excess < s.cfg.MinAmount {
+		return nil, nil
+	}
+
+	if s.cfg.Buffer == 0 {
+		return s.sweepAll(subaddrs)
+	}
+	return s.transfer(excess)
+}
+
+// sweepAll sends every spendable output to the cold address
+func (s *Sweeper) sweepAll(subaddrs map[uint64]bool) (*Sweep, error) {
+	indices := make([]uint64, 0, len(subaddrs))
+	for i := range subaddrs {
+		indices = append(indices, i)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	resp, err := s.client.SweepAll(&wallet.SweepAllRequest{
+		Address:        s.cfg.Address,
+		AccountIndex:   s.cfg.AccountIndex,
+		SubaddrIndices: indices,
+		Priority:       s.cfg.Priority,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sw := &Sweep{Txids: resp.TxHashList, Time: s.now()}
+	for _, a := range resp.AmountList {
+		sw.Amount += gonero.AtomicXMR(a)
+	}
+	for _, f := range resp.FeeList {
+		sw.Fee += gonero.AtomicXMR(f)
+	}
+	return sw, nil
+}
+
+// transfer sends the excess minus the fee to the cold address, the fee
+// being estimated by a transfer which is not relayed
+func (s *Sweeper) transfer(excess gonero.AtomicXMR) (*Sweep, error) {
+	req := &wallet.TransferRequest{
+		Destinations: []wallet.Destination{{Amount: excess, Address: s.cfg.Address}},
+		AccountIndex: s.cfg.AccountIndex,
+		Priority:     s.cfg.Priority,
+		DoNotRelay:   true,
+	}
+	estimate, err := s.client.Transfer(req)
+	if err != nil {
+		return nil, err
+	}
+	fee := gonero.AtomicXMR(estimate.Fee)
+	if excess <= fee || excess-fee < s.cfg.MinAmount {
+		return nil, nil
+	}
+	req.Destinations[0].Amount = excess - fee
+	req.DoNotRelay = false
+	resp, err := s.client.Transfer(req)
+	if err != nil {
+		return nil, err
+	}
+	return &Sweep{
+		Txids:  []string{resp.TxHash},
+		Amount: resp.Amount,
+		Fee:    gonero.AtomicXMR(resp.Fee),
+		Time:   s.now(),
+	}, nil
+}
+
+// scheduled tells whether the current time is within a window
+func (s *Sweeper) scheduled() bool {
+	if len(s.cfg.Windows) == 0 {
+		return true
+	}
+	now := s.now().In(s.cfg.Location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.cfg.Location)
+	t := now.Sub(midnight)
+	for _, w := range s.cfg.Windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// inFlight tells whether payouts are being sent
+func (s *Sweeper) inFlight() (bool, error) {
+	if s.cfg.InFlight != nil {
+		busy, err := s.cfg.InFlight()
+		if busy || err != nil {
+			return busy, err
+		}
+	}
+	transfers, err := s.client.GetTransfers(&wallet.GetTransfersRequest{
+		Pending:      true,
+		AccountIndex: s.cfg.AccountIndex,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(transfers.Pending) > 0, nil
+}
+
+func (s *Sweeper) report(err error) {
+	select {
+	case s.errors <- err:
+	default:
+	}
+}
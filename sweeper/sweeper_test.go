@@ -0,0 +1,359 @@
+package sweeper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	coldAddress  = "42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm"
+	stageAddress = "55hKAMnUWXaWXd4hUQ9jzQDF3sL7Yu7fML5FpWMFMouUXjxxK2y6oKJYtEf91Vf3ZGaPE6cHccRnbV5q96uC3ChmCKyGuXY"
+	fee          = 100
+)
+
+// fakeWallet is a stand-in hot wallet spending its outputs whole
+type fakeWallet struct {
+	wallet.Client
+
+	mu        sync.Mutex
+	outputs   []wallet.IncomingTransfer
+	pending   int
+	checks    int
+	estimates int
+	transfers []wallet.Destination
+	sweeps    []*wallet.SweepAllRequest
+}
+
+func (w *fakeWallet) receive(minor uint64, amount gonero.AtomicXMR, unlocked, frozen bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.outputs = append(w.outputs, wallet.IncomingTransfer{
+		Amount:       amount,
+		SubaddrIndex: wallet.SubaddressIndex{Minor: minor},
+		Unlocked:     unlocked,
+		Frozen:       frozen,
+	})
+}
+
+func (w *fakeWallet) setPending(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = n
+}
+
+func (w *fakeWallet) checked() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checks
+}
+
+func (w *fakeWallet) GetBalance(req *wallet.GetBalanceRequest) (*wallet.GetBalanceResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.checks++
+	resp := &wallet.GetBalanceResponse{}
+	for _, o := range w.outputs {
+		if o.Spent {
+			continue
+		}
+		resp.Balance += o.Amount
+		if o.Unlocked {
+			// like monero-wallet-rpc, frozen outputs are counted
+			resp.UnlockedBalance += o.Amount
+		}
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) GetTransfers(req *wallet.GetTransfersRequest) (*wallet.GetTransfersResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &wallet.GetTransfersResponse{Pending: make([]wallet.Transfer, w.pending)}, nil
+}
+
+func (w *fakeWallet) IncomingTransfers(req *wallet.IncomingTransfersRequest) (*wallet.IncomingTransfersResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	resp := &wallet.IncomingTransfersResponse{}
+	for _, o := range w.outputs {
+		if !o.Spent {
+			resp.Transfers = append(resp.Transfers, o)
+		}
+	}
+	return resp, nil
+}
+
+func (w *fakeWallet) Transfer(req *wallet.TransferRequest) (*wallet.TransferResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	amount := req.Destinations[0].Amount
+	if req.DoNotRelay {
+		w.estimates++
+		return &wallet.TransferResponse{Amount: amount, Fee: fee, TxHash: "estimate"}, nil
+	}
+	// spends the outputs and receives the change
+	var spent gonero.AtomicXMR
+	for i := range w.outputs {
+		o := &w.outputs[i]
+		if spent >= amount+fee {
+			break
+		}
+		if !o.Spent && o.Unlocked && !o.Frozen {
+			o.Spent = true
+			spent += o.Amount
+		}
+	}
+	if spent < amount+fee {
+		return nil, errors.New("not enough money")
+	}
+	w.outputs = append(w.outputs, wallet.IncomingTransfer{Amount: spent - amount - fee})
+	w.transfers = append(w.transfers, req.Destinations[0])
+	return &wallet.TransferResponse{Amount: amount, Fee: fee, TxHash: "transfer"}, nil
+}
+
+func (w *fakeWallet) SweepAll(req *wallet.SweepAllRequest) (*wallet.SweepAllResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	indices := make(map[uint64]bool)
+	for _, i := range req.SubaddrIndices {
+		indices[i] = true
+	}
+	var swept gonero.AtomicXMR
+	for i := range w.outputs {
+		o := &w.outputs[i]
+		if !o.Spent && o.Unlocked && !o.Frozen && indices[o.SubaddrIndex.Minor] {
+			o.Spent = true
+			swept += o.Amount
+		}
+	}
+	w.sweeps = append(w.sweeps, req)
+	return &wallet.SweepAllResponse{
+		TxHashList: []string{"sweep"},
+		AmountList: []int64{int64(swept - fee)},
+		FeeList:    []int64{fee},
+	}, nil
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// start returns a sweeper once it checked the empty wallet
+func start(t *testing.T, w *fakeWallet, cfg *Config, now func() time.Time) *Sweeper {
+	cfg.Address = coldAddress
+	cfg.Network = "main"
+	cfg.PollInterval = time.Hour
+	s, err := newSweeper(w, cfg, now)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for w.checked() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return s
+}
+
+func TestConfig(t *testing.T) {
+	w := &fakeWallet{}
+	for _, c := range []struct {
+		cfg Config
+		err error
+	}{
+		{Config{Address: coldAddress, HighWater: 10}, ErrInvalidConfig},
+		{Config{Address: coldAddress, Network: "main"}, ErrInvalidConfig},
+		{Config{Address: coldAddress, Network: "main", HighWater: 10, Buffer: 11}, ErrInvalidConfig},
+		{Config{Address: "4invalid", Network: "main", HighWater: 10}, ErrInvalidAddress},
+		{Config{Address: stageAddress, Network: "main", HighWater: 10}, ErrWrongNetwork},
+		{Config{Address: coldAddress, Network: "stage", HighWater: 10}, ErrWrongNetwork},
+	} {
+		_, err := New(w, &c.cfg)
+		assert.True(t, errors.Is(err, c.err), "%+v: %v", c.cfg, err)
+	}
+
+	s, err := New(w, &Config{Address: stageAddress, Network: "stage", HighWater: 10})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+	assert.Equal(t, ErrClosed, s.Close())
+	_, err = s.Sweep()
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestSweep(t *testing.T) {
+	c := &clock{now: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	w := &fakeWallet{}
+	s := start(t, w, &Config{HighWater: 10000, Buffer: 4000, MinAmount: 500}, c.Now)
+	defer s.Close()
+
+	// below the high-water mark
+	w.receive(1, 6000, true, false)
+	w.receive(2, 4000, true, false)
+	w.receive(3, 50000, false, false)
+	sw, err := s.Sweep()
+	assert.NoError(t, err)
+	assert.Nil(t, sw)
+
+	// the excess is swept without the buffer and the fee
+	w.receive(1, 5000, true, false)
+	sw, err = s.Sweep()
+	assert.NoError(t, err)
+	if assert.NotNil(t, sw) {
+		assert.Equal(t, &Sweep{Txids: []string{"transfer"}, Amount: 15000 - 4000 - fee, Fee: fee, Time: c.Now()}, sw)
+	}
+	assert.Equal(t, []wallet.Destination{{Amount: 15000 - 4000 - fee, Address: coldAddress}}, w.transfers)
+	assert.Equal(t, 1, w.estimates)
+	balance, _ := w.GetBalance(&wallet.GetBalanceRequest{})
+	assert.Equal(t, gonero.AtomicXMR(4000+50000), balance.Balance)
+
+	// frozen outputs are not spendable, nor small excesses
+	w.receive(1, 20000, true, true)
+	w.receive(1, 400, true, false)
+	sw, err = s.Sweep()
+	assert.NoError(t, err)
+	assert.Nil(t, sw)
+	assert.Len(t, w.transfers, 1)
+}
+
+func TestSweepAll(t *testing.T) {
+	w := &fakeWallet{}
+	s := start(t, w, &Config{HighWater: 10000, Priority: wallet.PriorityUnimportant}, time.Now)
+	defer s.Close()
+
+	w.receive(3, 6000, true, false)
+	w.receive(1, 6000, true, false)
+	w.receive(2, 6000, true, true)
+	w.receive(4, 6000, false, false)
+	sw, err := s.Sweep()
+	assert.NoError(t, err)
+	if assert.NotNil(t, sw) {
+		assert.Equal(t, []string{"sweep"}, sw.Txids)
+		assert.Equal(t, gonero.AtomicXMR(12000-fee), sw.Amount)
+		assert.Equal(t, gonero.AtomicXMR(fee), sw.Fee)
+	}
+	if assert.Len(t, w.sweeps, 1) {
+		assert.Equal(t, &wallet.SweepAllRequest{
+			Address:        coldAddress,
+			SubaddrIndices: []uint64{1, 3},
+			Priority:       wallet.PriorityUnimportant,
+		}, w.sweeps[0])
+	}
+	assert.Empty(t, w.transfers)
+}
+
+func TestInFlight(t *testing.T) {
+	w := &fakeWallet{}
+	var mu sync.Mutex
+	var busy bool
+	var busyErr error
+	inFlight := func() (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return busy, busyErr
+	}
+	s := start(t, w, &Config{HighWater: 10000, InFlight: inFlight}, time.Now)
+	defer s.Close()
+	w.receive(1, 20000, true, false)
+
+	mu.Lock()
+	busy = true
+	mu.Unlock()
+	sw, err := s.Sweep()
+	assert.NoError(t, err)
+	assert.Nil(t, sw)
+
+	mu.Lock()
+	busy, busyErr = false, errors.New("payouts unknown")
+	mu.Unlock()
+	sw, err = s.Sweep()
+	assert.Equal(t, busyErr, err)
+	assert.Nil(t, sw)
+
+	mu.Lock()
+	busyErr = nil
+	mu.Unlock()
+	w.setPending(1)
+	sw, err = s.Sweep()
+	assert.NoError(t, err)
+	assert.Nil(t, sw)
+
+	w.setPending(0)
+	sw, err = s.Sweep()
+	assert.NoError(t, err)
+	assert.NotNil(t, sw)
+}
+
+func TestWindows(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	c := &clock{now: time.Date(2020, 1, 1, 23, 0, 0, 0, paris)}
+	w := &fakeWallet{}
+	s := start(t, w, &Config{
+		HighWater: 10000,
+		Windows:   []Window{{Start: 22 * time.Hour, End: 2 * time.Hour}, {Start: 6 * time.Hour, End: 7 * time.Hour}},
+		Location:  paris,
+	}, c.Now)
+	defer s.Close()
+	w.receive(1, 20000, true, false)
+
+	for _, hour := range []int{12, 2, 7, 21} {
+		c.set(time.Date(2020, 1, 1, hour, 0, 0, 0, paris))
+		sw, err := s.Sweep()
+		assert.NoError(t, err)
+		assert.Nil(t, sw, "%d:00", hour)
+	}
+	for _, hour := range []int{22, 23, 1, 6} {
+		c.set(time.Date(2020, 1, 1, hour, 0, 0, 0, paris))
+		sw, err := s.Sweep()
+		assert.NoError(t, err)
+		assert.NotNil(t, sw, "%d:00", hour)
+		w.receive(1, 20000, true, false)
+	}
+	// windows are in the configured location
+	c.set(time.Date(2020, 1, 1, 21, 30, 0, 0, time.UTC))
+	sw, err := s.Sweep()
+	assert.NoError(t, err)
+	assert.NotNil(t, sw)
+}
+
+func TestNotify(t *testing.T) {
+	w := &fakeWallet{}
+	notify := make(chan struct{})
+	s := start(t, w, &Config{HighWater: 10000, Notify: notify}, time.Now)
+	w.receive(1, 20000, true, false)
+	notify <- struct{}{}
+	select {
+	case sw := <-s.Sweeps:
+		assert.Equal(t, gonero.AtomicXMR(20000-fee), sw.Amount)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no sweep")
+	}
+
+	// a closed channel triggers a last check, then is ignored
+	checks := w.checked()
+	close(notify)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, w.checked() <= checks+1, "%d checks after closing", w.checked()-checks)
+	assert.NoError(t, s.Close())
+	_, ok := <-s.Errors
+	assert.False(t, ok)
+}
@@ -0,0 +1,151 @@
+package tracker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store persists tracked transactions
+type Store interface {
+	// Put stores a transaction, replacing the one with the same Txid.
+	Put(*Tx) error
+	// Get returns the transaction with the given Txid, or ErrNotFound.
+	Get(txid string) (*Tx, error)
+	// List returns every transaction, oldest first.
+	List() ([]*Tx, error)
+}
+
+// MemoryStore is a Store kept in memory
+type MemoryStore struct {
+	mu  sync.Mutex
+	txs map[string]*Tx
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{txs: make(map[string]*Tx)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(tx *Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txs[tx.Txid] = tx.clone()
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(txid string) (*Tx, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.txs[txid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return tx.clone(), nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]*Tx, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	txs := make([]*Tx, 0, len(s.txs))
+	for _, tx := range s.txs {
+		txs = append(txs, tx.clone())
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].TrackedAt.Equal(txs[j].TrackedAt) {
+			return txs[i].Txid < txs[j].Txid
+		}
+		return txs[i].TrackedAt.Before(txs[j].TrackedAt)
+	})
+	return txs, nil
+}
+
+// FileStore is a Store saved as a JSON file, rewritten atomically on each change
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// NewFileStore opens the store saved at path, which is created on the
+// first change if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var txs []*Tx
+	if err := json.Unmarshal(data, &txs); err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		s.mem.txs[tx.Txid] = tx
+	}
+	return s, nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(tx *Tx) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev, ok := s.mem.txs[tx.Txid]
+	s.mem.txs[tx.Txid] = tx.clone()
+	if err := s.save(); err != nil {
+		if ok {
+			s.mem.txs[tx.Txid] = prev
+		} else {
+			delete(s.mem.txs, tx.Txid)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *FileStore) Get(txid string) (*Tx, error) {
+	return s.mem.Get(txid)
+}
+
+// List implements Store.
+func (s *FileStore) List() ([]*Tx, error) {
+	return s.mem.List()
+}
+
+// save writes the file through a temporary file, with s.mem.mu held
+func (s *FileStore) save() error {
+	txs := make([]*Tx, 0, len(s.mem.txs))
+	for _, tx := range s.mem.txs {
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Txid < txs[j].Txid })
+	data, err := json.Marshal(txs)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
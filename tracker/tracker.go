@@ -0,0 +1,538 @@
+// Package tracker follows sent transactions until they are final.
+//
+// A Tracker polls the daemon for the transactions handed to Track, and
+// reports when they enter the pool, are mined, reach the required
+// confirmations and become final. Transactions missing from both the pool
+// and the chain are checked with IsKeyImageSpent: those whose inputs were
+// spent by another transaction are double spends, the others were dropped
+// and are broadcast again from their blob or relay metadata. Reorgs are
+// noticed when a mined transaction moves to another block, back to the
+// pool, or out of sight.
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/wallet"
+)
+
+// Tracker default values
+const (
+	DefaultConfirmations      = 10
+	DefaultFinalConfirmations = 60
+	DefaultPollInterval       = 20 * time.Second
+)
+
+// Tracker errors
+var (
+	ErrNotFound    = errors.New("tracker: transaction not found")
+	ErrInvalidTx   = errors.New("tracker: invalid transaction")
+	ErrNoBroadcast = errors.New("tracker: no blob nor metadata to broadcast")
+	ErrClosed      = errors.New("tracker: closed")
+)
+
+// Status is the state of a tracked transaction
+type Status string
+
+const (
+	// StatusSent transactions were broadcast but are not seen by the daemon yet
+	StatusSent Status = "sent"
+	// StatusPool transactions are in the pool of the daemon
+	StatusPool Status = "pool"
+	// StatusMined transactions are in a block, with fewer confirmations than required
+	StatusMined Status = "mined"
+	// StatusConfirmed transactions have the required confirmations
+	StatusConfirmed Status = "confirmed"
+	// StatusFinal transactions are deep enough to survive reorgs, and are
+	// not tracked anymore
+	StatusFinal Status = "final"
+	// StatusDropped transactions vanished and could not be broadcast again
+	StatusDropped Status = "dropped"
+	// StatusDoubleSpent transactions had their inputs spent by another mined
+	// transaction, and are not tracked anymore
+	StatusDoubleSpent Status = "double_spent"
+)
+
+// done tells whether a transaction is not tracked anymore
+func (s Status) done() bool {
+	return s == StatusFinal || s == StatusDoubleSpent
+}
+
+// TrackRequest is a sent transaction to track
+type TrackRequest struct {
+	// Hash of the transaction.
+	Txid string `json:"txid"`
+	// (Optional) Transaction as hex, as returned with get_tx_hex, to
+	// broadcast it again with SendRawTransaction.
+	Blob string `json:"blob,omitempty"`
+	// (Optional) Transaction metadata, as returned with get_tx_metadata, to
+	// broadcast it again with RelayTx when there is no blob.
+	Metadata string `json:"metadata,omitempty"`
+	// (Optional) Key images of the inputs, read from the daemon otherwise.
+	KeyImages []string `json:"key_images,omitempty"`
+}
+
+// Tx is a tracked transaction. Its blob and metadata are stored with it.
+type Tx struct {
+	// Hash of the transaction.
+	Txid string `json:"txid"`
+	// Transaction as hex, if known.
+	Blob string `json:"blob,omitempty"`
+	// Transaction metadata, if known.
+	Metadata string `json:"metadata,omitempty"`
+	// Key images of the inputs, once known.
+	KeyImages []string `json:"key_images,omitempty"`
+	// Status of the transaction.
+	Status Status `json:"status"`
+	// Height of the block including the transaction, 0 unless mined.
+	Height uint64 `json:"height,omitempty"`
+	// Number of confirmations, 0 unless mined.
+	Confirmations uint64 `json:"confirmations"`
+	// States if the daemon saw a double spend attempt in the pool.
+	DoubleSpendSeen bool `json:"double_spend_seen"`
+	// Number of reorgs which removed the transaction from its block.
+	Reorgs int `json:"reorgs"`
+	// Number of broadcasts after the transaction was dropped.
+	Rebroadcasts int `json:"rebroadcasts"`
+	// Last broadcast error, empty after a successful broadcast.
+	Error string `json:"error,omitempty"`
+	// Time the transaction was tracked.
+	TrackedAt time.Time `json:"tracked_at"`
+	// Time of the last change.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (tx *Tx) clone() *Tx {
+	c := *tx
+	c.KeyImages = append([]string{}, tx.KeyImages...)
+	return &c
+}
+
+// Event is a change of a tracked transaction
+type Event struct {
+	// Status before the change.
+	PreviousStatus Status `json:"previous_status"`
+	// Transaction after the change.
+	Tx *Tx `json:"tx"`
+}
+
+// Handler is called on each change of a tracked transaction: its status,
+// block, confirmations, or a new broadcast. It runs on the polling
+// goroutine, so it must not block, but it may call Track.
+type Handler func(Event)
+
+// Config configures a Tracker
+type Config struct {
+	// Store of the transactions. Defaults to a MemoryStore.
+	Store Store
+	// Confirmations of StatusConfirmed, defaults to DefaultConfirmations.
+	Confirmations uint64
+	// Confirmations of StatusFinal, defaults to DefaultFinalConfirmations.
+	FinalConfirmations uint64
+	// Interval between polls of the daemon, defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll, e.g. on a new block.
+	Notify <-chan struct{}
+}
+
+// Tracker follows sent transactions until they are final
+type Tracker struct {
+	// Errors receives RPC, store and broadcast errors, after which the
+	// tracker retries at the next poll. Errors are dropped when the channel
+	// is full.
+	Errors <-chan error
+
+	cfg     Config
+	daemon  daemon.Client
+	wallet  wallet.Client
+	handler Handler
+	errors  chan error
+	wake    chan struct{}
+	now     func() time.Time
+
+	// pollMu serializes polls, which run the RPCs and the handler
+	pollMu sync.Mutex
+	// mu serializes changes of transactions in the store
+	mu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// New starts a Tracker polling the daemon behind client. The wallet, which
+// may be nil, relays again dropped transactions which have no blob. The
+// handler may be nil.
+func New(client daemon.Client, w wallet.Client, handler Handler, cfg *Config) *Tracker {
+	return newTracker(client, w, handler, cfg, time.Now)
+}
+
+func newTracker(client daemon.Client, w wallet.Client, handler Handler, cfg *Config, now func() time.Time) *Tracker {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.Confirmations == 0 {
+		c.Confirmations = DefaultConfirmations
+	}
+	if c.FinalConfirmations == 0 {
+		c.FinalConfirmations = DefaultFinalConfirmations
+	}
+	if c.FinalConfirmations < c.Confirmations {
+		c.FinalConfirmations = c.Confirmations
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if handler == nil {
+		handler = func(Event) {}
+	}
+
+	t := &Tracker{
+		cfg:     c,
+		daemon:  client,
+		wallet:  w,
+		handler: handler,
+		errors:  make(chan error, 16),
+		wake:    make(chan struct{}, 1),
+		now:     now,
+		done:    make(chan struct{}),
+	}
+	t.Errors = t.errors
+
+	t.wg.Add(1)
+	go t.run()
+	return t
+}
+
+// Track starts tracking a sent transaction. Tracking a transaction again
+// returns it, with the blob, metadata and key images of the request added
+// if it had none.
+func (t *Tracker) Track(req *TrackRequest) (*Tx, error) {
+	select {
+	case <-t.done:
+		return nil, ErrClosed
+	default:
+	}
+	if req.Txid == "" {
+		return nil, fmt.Errorf("%w: txid required", ErrInvalidTx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx, err := t.cfg.Store.Get(req.Txid)
+	switch {
+	case err == ErrNotFound:
+		now := t.now()
+		tx = &Tx{Txid: req.Txid, Status: StatusSent, TrackedAt: now, UpdatedAt: now}
+	case err != nil:
+		return nil, err
+	}
+	if tx.Blob == "" {
+		tx.Blob = req.Blob
+	}
+	if tx.Metadata == "" {
+		tx.Metadata = req.Metadata
+	}
+	if len(tx.KeyImages) == 0 {
+		tx.KeyImages = append([]string{}, req.KeyImages...)
+	}
+	if err := t.cfg.Store.Put(tx); err != nil {
+		return nil, err
+	}
+	t.signal()
+	return tx, nil
+}
+
+// Get returns the transaction with the given Txid, or ErrNotFound.
+func (t *Tracker) Get(txid string) (*Tx, error) {
+	return t.cfg.Store.Get(txid)
+}
+
+// List returns every tracked transaction, oldest first.
+func (t *Tracker) List() ([]*Tx, error) {
+	return t.cfg.Store.List()
+}
+
+// Close stops the tracker.
+func (t *Tracker) Close() error {
+	err := ErrClosed
+	t.closeOnce.Do(func() {
+		err = nil
+		close(t.done)
+	})
+	t.wg.Wait()
+	return err
+}
+
+func (t *Tracker) run() {
+	defer t.wg.Done()
+	defer close(t.errors)
+
+	ticker := time.NewTicker(t.cfg.PollInterval)
+	defer ticker.Stop()
+	notify := t.cfg.Notify
+	for {
+		if err := t.poll(); err != nil {
+			t.report(err)
+		}
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+			}
+		}
+	}
+}
+
+// poll updates the transactions which are not done
+func (t *Tracker) poll() error {
+	t.pollMu.Lock()
+	defer t.pollMu.Unlock()
+	all, err := t.cfg.Store.List()
+	if err != nil {
+		return err
+	}
+	var txs []*Tx
+	var hashes []string
+	decode := false
+	for _, tx := range all {
+		if !tx.Status.done() {
+			txs = append(txs, tx)
+			hashes = append(hashes, tx.Txid)
+			decode = decode || len(tx.KeyImages) == 0
+		}
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	count, err := t.daemon.GetBlockCount(&daemon.GetBlockCountRequest{})
+	if err != nil {
+		return err
+	}
+	resp, err := t.daemon.GetTransactions(&daemon.GetTransactionsRequest{TxsHashes: hashes, DecodeAsJSON: decode})
+	if err != nil {
+		return err
+	}
+	found := make(map[string]daemon.Transaction, len(resp.Txs))
+	for _, d := range resp.Txs {
+		found[d.TxHash] = d
+	}
+
+	// the handler runs once the transactions are stored, without t.mu
+	var events []Event
+	defer func() {
+		for _, ev := range events {
+			t.handler(ev)
+		}
+	}()
+	for _, tx := range txs {
+		d, ok := found[tx.Txid]
+		if ok {
+			if err := t.seen(tx, d, count.Count); err != nil {
+				return err
+			}
+		} else {
+			t.missing(tx)
+		}
+		ev, err := t.update(tx)
+		if err != nil {
+			return err
+		}
+		if ev != nil {
+			events = append(events, *ev)
+		}
+	}
+	return nil
+}
+
+// seen updates a transaction found by the daemon
+func (t *Tracker) seen(tx *Tx, d daemon.Transaction, count uint64) error {
+	if len(tx.KeyImages) == 0 && d.AsJSON != "" {
+		keyImages, err := decodeKeyImages(d)
+		if err != nil {
+			return err
+		}
+		tx.KeyImages = keyImages
+	}
+	tx.DoubleSpendSeen = d.DoubleSpendSeen
+	tx.Error = ""
+	if d.InPool {
+		if tx.Height > 0 {
+			tx.Reorgs++
+		}
+		tx.Status = StatusPool
+		tx.Height = 0
+		tx.Confirmations = 0
+		return nil
+	}
+	if tx.Height > 0 && tx.Height != d.BlockHeight {
+		tx.Reorgs++
+	}
+	tx.Height = d.BlockHeight
+	tx.Confirmations = 0
+	if count > d.BlockHeight {
+		tx.Confirmations = count - d.BlockHeight
+	}
+	switch {
+	case tx.Confirmations >= t.cfg.FinalConfirmations:
+		tx.Status = StatusFinal
+	case tx.Confirmations >= t.cfg.Confirmations:
+		tx.Status = StatusConfirmed
+	default:
+		tx.Status = StatusMined
+	}
+	return nil
+}
+
+// missing updates a transaction missing from the pool and the chain:
+// double spent, or broadcast again
+func (t *Tracker) missing(tx *Tx) {
+	if tx.Height > 0 {
+		tx.Reorgs++
+		tx.Height = 0
+		tx.Confirmations = 0
+	}
+	spent, err := t.spent(tx.KeyImages)
+	if err != nil {
+		tx.Status = StatusDropped
+		tx.Error = err.Error()
+		t.report(err)
+		return
+	}
+	if spent {
+		tx.Status = StatusDoubleSpent
+		return
+	}
+	err = t.broadcast(tx)
+	if err == errDoubleSpend {
+		tx.Status = StatusDoubleSpent
+		return
+	}
+	if err != nil {
+		tx.Status = StatusDropped
+		tx.Error = err.Error()
+		t.report(fmt.Errorf("tracker: broadcast %s: %w", tx.Txid, err))
+		return
+	}
+	tx.Status = StatusSent
+	tx.Rebroadcasts++
+	tx.Error = ""
+}
+
+// errDoubleSpend is returned by broadcast when the daemon rejects a double spend
+var errDoubleSpend = errors.New("tracker: double spend")
+
+// broadcast sends a transaction again
+func (t *Tracker) broadcast(tx *Tx) error {
+	if tx.Blob != "" {
+		resp, err := t.daemon.SendRawTransaction(&daemon.SendRawTransactionRequest{TxAsHex: tx.Blob})
+		if err != nil {
+			return err
+		}
+		if resp.DoubleSpend {
+			return errDoubleSpend
+		}
+		if resp.Status != daemon.RPCStatusOk {
+			return fmt.Errorf("tracker: transaction rejected: %s %s", resp.Status, resp.Reason)
+		}
+		return nil
+	}
+	if tx.Metadata != "" && t.wallet != nil {
+		_, err := t.wallet.RelayTx(&wallet.RelayTxRequest{Hex: tx.Metadata})
+		return err
+	}
+	return ErrNoBroadcast
+}
+
+// spent tells whether key images were spent in the chain. Those spent in
+// the pool may be spent by the transaction itself, relayed meanwhile.
+func (t *Tracker) spent(keyImages []string) (bool, error) {
+	if len(keyImages) == 0 {
+		return false, nil
+	}
+	resp, err := t.daemon.IsKeyImageSpent(&daemon.IsKeyImageSpentRequest{KeyImages: keyImages})
+	if err != nil {
+		return false, err
+	}
+	for _, status := range resp.SpentStatus {
+		if status == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// update stores a changed transaction and returns its event, or nil if it
+// did not change. The blob, metadata and key images added by Track since
+// the poll started are kept.
+func (t *Tracker) update(tx *Tx) (*Event, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev, err := t.cfg.Store.Get(tx.Txid)
+	if err != nil {
+		return nil, err
+	}
+	tx.Blob = prev.Blob
+	tx.Metadata = prev.Metadata
+	if len(tx.KeyImages) == 0 {
+		tx.KeyImages = prev.KeyImages
+	}
+	if prev.Status == tx.Status && prev.Height == tx.Height && prev.Confirmations == tx.Confirmations &&
+		prev.Rebroadcasts == tx.Rebroadcasts && prev.DoubleSpendSeen == tx.DoubleSpendSeen &&
+		prev.Error == tx.Error && len(prev.KeyImages) == len(tx.KeyImages) {
+		return nil, nil
+	}
+	tx.UpdatedAt = t.now()
+	if err := t.cfg.Store.Put(tx); err != nil {
+		return nil, err
+	}
+	return &Event{PreviousStatus: prev.Status, Tx: tx.clone()}, nil
+}
+
+func (t *Tracker) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Tracker) report(err error) {
+	select {
+	case t.errors <- err:
+	default:
+	}
+}
+
+// decodeKeyImages reads the key images of a transaction decoded as JSON
+func decodeKeyImages(d daemon.Transaction) ([]string, error) {
+	var decoded struct {
+		Vin []struct {
+			Key *struct {
+				KImage string `json:"k_image"`
+			} `json:"key"`
+		} `json:"vin"`
+	}
+	if err := json.Unmarshal([]byte(d.AsJSON), &decoded); err != nil {
+		return nil, fmt.Errorf("tracker: transaction %s: %w", d.TxHash, err)
+	}
+	var keyImages []string
+	for _, in := range decoded.Vin {
+		if in.Key != nil {
+			keyImages = append(keyImages, in.Key.KImage)
+		}
+	}
+	return keyImages, nil
+}
@@ -0,0 +1,434 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// chainTx is a transaction as seen by the fake daemon
+type chainTx struct {
+	inPool          bool
+	height          uint64
+	keyImages       []string
+	doubleSpendSeen bool
+}
+
+// fakeDaemon is a stand-in daemon with a settable chain and pool
+type fakeDaemon struct {
+	daemon.Client
+
+	mu        sync.Mutex
+	count     uint64
+	txs       map[string]*chainTx
+	spent     map[string]uint64
+	blobs     map[string]string // blob -> txid
+	sent      []string
+	rejectAll bool
+	queried   [][]string
+}
+
+func newFakeDaemon() *fakeDaemon {
+	return &fakeDaemon{count: 100, txs: make(map[string]*chainTx), spent: make(map[string]uint64), blobs: make(map[string]string)}
+}
+
+func (d *fakeDaemon) set(txid string, tx *chainTx) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if tx == nil {
+		delete(d.txs, txid)
+		return
+	}
+	d.txs[txid] = tx
+}
+
+func (d *fakeDaemon) setCount(count uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.count = count
+}
+
+func (d *fakeDaemon) spend(keyImage string, status uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.spent[keyImage] = status
+}
+
+func (d *fakeDaemon) GetBlockCount(req *daemon.GetBlockCountRequest) (*daemon.GetBlockCountResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &daemon.GetBlockCountResponse{Count: d.count, Status: daemon.RPCStatusOk}, nil
+}
+
+func (d *fakeDaemon) GetTransactions(req *daemon.GetTransactionsRequest) (*daemon.GetTransactionsResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queried = append(d.queried, req.TxsHashes)
+	resp := &daemon.GetTransactionsResponse{Status: daemon.RPCStatusOk}
+	for _, hash := range req.TxsHashes {
+		tx, ok := d.txs[hash]
+		if !ok {
+			resp.MissedTx = append(resp.MissedTx, hash)
+			continue
+		}
+		t := daemon.Transaction{TxHash: hash, InPool: tx.inPool, BlockHeight: tx.height, DoubleSpendSeen: tx.doubleSpendSeen}
+		if req.DecodeAsJSON {
+			t.AsJSON = `{"vin":[`
+			for i, ki := range tx.keyImages {
+				if i > 0 {
+					t.AsJSON += ","
+				}
+				t.AsJSON += `{"key":{"k_image":"` + ki + `"}}`
+			}
+			t.AsJSON += `]}`
+		}
+		resp.Txs = append(resp.Txs, t)
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) IsKeyImageSpent(req *daemon.IsKeyImageSpentRequest) (*daemon.IsKeyImageSpentResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resp := &daemon.IsKeyImageSpentResponse{Status: daemon.RPCStatusOk}
+	for _, ki := range req.KeyImages {
+		resp.SpentStatus = append(resp.SpentStatus, d.spent[ki])
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) SendRawTransaction(req *daemon.SendRawTransactionRequest) (*daemon.SendRawTransactionResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent = append(d.sent, req.TxAsHex)
+	txid := d.blobs[req.TxAsHex]
+	for _, ki := range d.txsKeyImages(txid) {
+		if d.spent[ki] == 1 {
+			return &daemon.SendRawTransactionResponse{Status: "Failed", DoubleSpend: true}, nil
+		}
+	}
+	if d.rejectAll {
+		return &daemon.SendRawTransactionResponse{Status: "Failed", FeeTooLow: true, Reason: "fee too low"}, nil
+	}
+	d.txs[txid] = &chainTx{inPool: true}
+	return &daemon.SendRawTransactionResponse{Status: daemon.RPCStatusOk}, nil
+}
+
+// txsKeyImages returns the key images of a transaction, with d.mu held
+func (d *fakeDaemon) txsKeyImages(txid string) []string {
+	return []string{"ki-" + txid}
+}
+
+// fakeWallet relays transactions from their metadata
+type fakeWallet struct {
+	wallet.Client
+
+	mu      sync.Mutex
+	d       *fakeDaemon
+	relayed []string
+}
+
+func (w *fakeWallet) RelayTx(req *wallet.RelayTxRequest) (*wallet.RelayTxResponse, error) {
+	w.mu.Lock()
+	w.relayed = append(w.relayed, req.Hex)
+	w.mu.Unlock()
+	txid := req.Hex[len("meta-"):]
+	w.d.set(txid, &chainTx{inPool: true})
+	return &wallet.RelayTxResponse{TxHash: txid}, nil
+}
+
+// recorder records the events of a tracker
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// statuses returns the status changes of a transaction
+func (r *recorder) statuses(txid string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var statuses []string
+	for _, ev := range r.events {
+		if ev.Tx.Txid == txid && ev.PreviousStatus != ev.Tx.Status {
+			statuses = append(statuses, fmt.Sprintf("%s>%s", ev.PreviousStatus, ev.Tx.Status))
+		}
+	}
+	return statuses
+}
+
+func newTestTracker(d *fakeDaemon, r *recorder, store Store) *Tracker {
+	w := &fakeWallet{d: d}
+	return New(d, w, r.handle, &Config{Store: store, PollInterval: time.Hour})
+}
+
+// poll polls right away, the background polls racing with it see the same state
+func poll(t *testing.T, tr *Tracker) {
+	assert.NoError(t, tr.poll())
+}
+
+func get(t *testing.T, tr *Tracker, txid string) *Tx {
+	tx, err := tr.Get(txid)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return tx
+}
+
+func TestLifecycle(t *testing.T) {
+	d := newFakeDaemon()
+	r := &recorder{}
+	tr := newTestTracker(d, r, nil)
+	defer tr.Close()
+
+	d.blobs["blob1"] = "tx1"
+	d.set("tx1", &chainTx{inPool: true, keyImages: []string{"ki-tx1"}})
+	tx, err := tr.Track(&TrackRequest{Txid: "tx1", Blob: "blob1"})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusSent, tx.Status)
+	_, err = tr.Track(&TrackRequest{})
+	assert.True(t, errors.Is(err, ErrInvalidTx))
+
+	poll(t, tr)
+	tx = get(t, tr, "tx1")
+	assert.Equal(t, StatusPool, tx.Status)
+	assert.Equal(t, []string{"ki-tx1"}, tx.KeyImages)
+
+	d.set("tx1", &chainTx{height: 100, keyImages: []string{"ki-tx1"}})
+	d.setCount(101)
+	poll(t, tr)
+	tx = get(t, tr, "tx1")
+	assert.Equal(t, StatusMined, tx.Status)
+	assert.Equal(t, uint64(100), tx.Height)
+	assert.Equal(t, uint64(1), tx.Confirmations)
+
+	d.setCount(110)
+	poll(t, tr)
+	assert.Equal(t, StatusConfirmed, get(t, tr, "tx1").Status)
+
+	// reorg to another block
+	d.set("tx1", &chainTx{height: 105})
+	poll(t, tr)
+	tx = get(t, tr, "tx1")
+	assert.Equal(t, StatusMined, tx.Status)
+	assert.Equal(t, uint64(5), tx.Confirmations)
+	assert.Equal(t, 1, tx.Reorgs)
+
+	// reorg back to the pool
+	d.set("tx1", &chainTx{inPool: true})
+	poll(t, tr)
+	tx = get(t, tr, "tx1")
+	assert.Equal(t, StatusPool, tx.Status)
+	assert.Equal(t, uint64(0), tx.Height)
+	assert.Equal(t, 2, tx.Reorgs)
+
+	// dropped from the pool, broadcast again from its blob
+	d.set("tx1", nil)
+	poll(t, tr)
+	tx = get(t, tr, "tx1")
+	assert.Equal(t, StatusSent, tx.Status)
+	assert.Equal(t, 1, tx.Rebroadcasts)
+	assert.Equal(t, []string{"blob1"}, d.sent)
+	poll(t, tr)
+	assert.Equal(t, StatusPool, get(t, tr, "tx1").Status)
+
+	d.set("tx1", &chainTx{height: 120})
+	d.setCount(180)
+	poll(t, tr)
+	assert.Equal(t, StatusFinal, get(t, tr, "tx1").Status)
+
+	// final transactions are not polled anymore
+	d.mu.Lock()
+	d.queried = nil
+	d.mu.Unlock()
+	poll(t, tr)
+	assert.Empty(t, d.queried)
+
+	assert.Equal(t, []string{
+		"sent>pool", "pool>mined", "mined>confirmed", "confirmed>mined", "mined>pool",
+		"pool>sent", "sent>pool", "pool>final",
+	}, r.statuses("tx1"))
+
+	// tracking it again returns it
+	tx, err = tr.Track(&TrackRequest{Txid: "tx1"})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFinal, tx.Status)
+}
+
+func TestDropped(t *testing.T) {
+	d := newFakeDaemon()
+	r := &recorder{}
+	tr := newTestTracker(d, r, nil)
+	defer tr.Close()
+
+	// inputs spent by another mined transaction
+	d.spend("ki-a", 1)
+	_, err := tr.Track(&TrackRequest{Txid: "a", Blob: "blob-a", KeyImages: []string{"ki-a"}})
+	assert.NoError(t, err)
+	// inputs spent in the pool, maybe by the transaction itself
+	d.spend("ki-b", 2)
+	d.blobs["blob-b"] = "b"
+	_, err = tr.Track(&TrackRequest{Txid: "b", Blob: "blob-b", KeyImages: []string{"ki-b"}})
+	assert.NoError(t, err)
+	// relayed again by the wallet
+	_, err = tr.Track(&TrackRequest{Txid: "c", Metadata: "meta-c"})
+	assert.NoError(t, err)
+	// nothing to broadcast
+	_, err = tr.Track(&TrackRequest{Txid: "d"})
+	assert.NoError(t, err)
+	// rejected as a double spend when broadcast
+	d.blobs["blob-e"] = "e"
+	d.spend("ki-e", 1)
+	_, err = tr.Track(&TrackRequest{Txid: "e", Blob: "blob-e"})
+	assert.NoError(t, err)
+
+	poll(t, tr)
+	assert.Equal(t, StatusDoubleSpent, get(t, tr, "a").Status)
+	b := get(t, tr, "b")
+	assert.Equal(t, StatusSent, b.Status)
+	assert.Equal(t, 1, b.Rebroadcasts)
+	assert.Equal(t, StatusSent, get(t, tr, "c").Status)
+	dropped := get(t, tr, "d")
+	assert.Equal(t, StatusDropped, dropped.Status)
+	assert.Equal(t, ErrNoBroadcast.Error(), dropped.Error)
+	assert.Equal(t, StatusDoubleSpent, get(t, tr, "e").Status)
+
+	select {
+	case err := <-tr.Errors:
+		assert.True(t, errors.Is(err, ErrNoBroadcast), "%v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error")
+	}
+
+	// rejected broadcasts are retried
+	d.mu.Lock()
+	d.rejectAll = true
+	d.blobs["blob-d"] = "d"
+	d.mu.Unlock()
+	_, err = tr.Track(&TrackRequest{Txid: "d", Blob: "blob-d"})
+	assert.NoError(t, err)
+	poll(t, tr)
+	dropped = get(t, tr, "d")
+	assert.Equal(t, StatusDropped, dropped.Status)
+	assert.Contains(t, dropped.Error, "fee too low")
+	d.mu.Lock()
+	d.rejectAll = false
+	d.mu.Unlock()
+	poll(t, tr)
+	dropped = get(t, tr, "d")
+	assert.Equal(t, StatusSent, dropped.Status)
+	assert.Empty(t, dropped.Error)
+}
+
+func TestRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "txs.json")
+
+	d := newFakeDaemon()
+	d.set("tx1", &chainTx{height: 99})
+	store, err := NewFileStore(path)
+	assert.NoError(t, err)
+	tr := newTestTracker(d, &recorder{}, store)
+	_, err = tr.Track(&TrackRequest{Txid: "tx1", Metadata: "meta-tx1"})
+	assert.NoError(t, err)
+	poll(t, tr)
+	assert.NoError(t, tr.Close())
+	assert.Equal(t, ErrClosed, tr.Close())
+	_, err = tr.Track(&TrackRequest{Txid: "tx2"})
+	assert.Equal(t, ErrClosed, err)
+
+	store, err = NewFileStore(path)
+	assert.NoError(t, err)
+	r := &recorder{}
+	tr = newTestTracker(d, r, store)
+	defer tr.Close()
+	tx := get(t, tr, "tx1")
+	assert.Equal(t, StatusMined, tx.Status)
+	assert.Equal(t, "meta-tx1", tx.Metadata)
+	d.setCount(200)
+	poll(t, tr)
+	assert.Equal(t, []string{"mined>final"}, r.statuses("tx1"))
+}
+
+// countingStore counts the polls listing its transactions
+type countingStore struct {
+	*MemoryStore
+
+	mu    sync.Mutex
+	lists int
+}
+
+func (s *countingStore) List() ([]*Tx, error) {
+	s.mu.Lock()
+	s.lists++
+	s.mu.Unlock()
+	return s.MemoryStore.List()
+}
+
+func (s *countingStore) listed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists
+}
+
+func TestNotify(t *testing.T) {
+	d := newFakeDaemon()
+	store := &countingStore{MemoryStore: NewMemoryStore()}
+	notify := make(chan struct{})
+	tr := New(d, &fakeWallet{d: d}, (&recorder{}).handle, &Config{Store: store, PollInterval: time.Hour, Notify: notify})
+	defer tr.Close()
+	for store.listed() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	notify <- struct{}{}
+	for store.listed() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a closed channel triggers a last poll, then is ignored
+	close(notify)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, store.listed() <= 3, "%d polls", store.listed())
+}
+
+func TestHandlerTracks(t *testing.T) {
+	d := newFakeDaemon()
+	d.set("a", &chainTx{inPool: true})
+	var tr *Tracker
+	tracked := make(chan error, 1)
+	handler := func(ev Event) {
+		if ev.Tx.Txid == "a" && ev.Tx.Status == StatusPool {
+			_, err := tr.Track(&TrackRequest{Txid: "b"})
+			tracked <- err
+		}
+	}
+	tr = New(d, &fakeWallet{d: d}, handler, &Config{PollInterval: time.Hour})
+	defer tr.Close()
+	_, err := tr.Track(&TrackRequest{Txid: "a"})
+	assert.NoError(t, err)
+
+	select {
+	case err := <-tracked:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler blocked in Track")
+	}
+	_, err = tr.Get("b")
+	assert.NoError(t, err)
+}
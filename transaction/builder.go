@@ -0,0 +1,610 @@
+package transaction
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/konraddical2/gonero/decoy"
+)
+
+// Builder default values
+const (
+	DefaultPriority = 1
+	maxFeeRounds    = 10
+)
+
+// Builder errors
+var (
+	ErrInsufficientFunds = errors.New("transaction: insufficient funds")
+	ErrInvalidKeys       = errors.New("transaction: keys do not match the address")
+	ErrInvalidOutput     = errors.New("transaction: invalid owned output")
+	ErrInvalidDest       = errors.New("transaction: invalid destination")
+	ErrNoFeeEstimate     = errors.New("transaction: no fee estimate")
+)
+
+// Keys are the keys of the sending wallet
+type Keys struct {
+	// Primary address of the wallet.
+	Address *gonero.Address
+	// Private view key.
+	View crypto.SecretKey
+	// Private spend key.
+	Spend crypto.SecretKey
+}
+
+// OwnedOutput is an output of the wallet to spend
+type OwnedOutput struct {
+	// Amount of the output, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Global index of the output, among the RingCT outputs.
+	GlobalIndex uint64 `json:"global_index"`
+	// One-time public key of the output.
+	PublicKey crypto.Key `json:"public_key"`
+	// Public key of the transaction of the output, or its additional
+	// public key for the output.
+	TxPublicKey crypto.Key `json:"tx_public_key"`
+	// Index of the output in its transaction.
+	OutputIndex uint64 `json:"output_index"`
+	// Subaddress receiving the output.
+	Major uint32 `json:"major"`
+	Minor uint32 `json:"minor"`
+	// States if the output is a coinbase output, whose commitment has no
+	// mask.
+	Coinbase bool `json:"coinbase,omitempty"`
+}
+
+// Destination is a recipient of a transaction
+type Destination struct {
+	// Standard address, subaddress or integrated address.
+	Address string `json:"address"`
+	// Amount to send, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+}
+
+// Config configures a Builder
+type Config struct {
+	// Members of each ring, defaults to decoy.DefaultRingSize.
+	RingSize int
+	// Priority of the fee, 1 (unimportant) to 4 (priority), defaults to
+	// DefaultPriority.
+	Priority int
+	// Fee per byte, in atomic units, estimated by the daemon for the
+	// priority if 0.
+	FeePerByte uint64
+	// Block height or timestamp before which the outputs cannot be spent.
+	UnlockTime uint64
+}
+
+// Result is a signed transaction
+type Result struct {
+	// Transaction, ready for daemon.SendRawTransaction as Tx.Hex().
+	Tx *Transaction `json:"tx"`
+	// Hash of the transaction.
+	Hash crypto.Key `json:"hash"`
+	// Fee of the transaction, in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+	// Change returned to the wallet, in atomic units.
+	Change gonero.AtomicXMR `json:"change"`
+	// Secret transaction key, r, proving the payments as with
+	// wallet.CheckTxKey.
+	TxKey crypto.SecretKey `json:"-"`
+	// Secret additional transaction keys, one per output, of transactions
+	// to subaddresses.
+	AdditionalTxKeys []crypto.SecretKey `json:"-"`
+	// Key images of the spent outputs, in the order of the inputs.
+	KeyImages []crypto.Key `json:"key_images"`
+}
+
+// Builder builds and signs transactions from the keys of a wallet, reading
+// decoys and fees from a daemon.
+type Builder struct {
+	cfg     Config
+	keys    Keys
+	spend   crypto.Key
+	client  daemon.Client
+	decoys  *decoy.Selector
+	shuffle func(n int, swap func(i, j int))
+}
+
+// NewBuilder returns a Builder of transactions spending the outputs of the
+// wallet with keys.
+func NewBuilder(client daemon.Client, keys Keys, cfg Config) (*Builder, error) {
+	if cfg.RingSize == 0 {
+		cfg.RingSize = decoy.DefaultRingSize
+	}
+	if cfg.Priority == 0 {
+		cfg.Priority = DefaultPriority
+	}
+	if cfg.Priority < 1 || cfg.Priority > 4 {
+		return nil, fmt.Errorf("transaction: invalid priority %d", cfg.Priority)
+	}
+	if keys.Address == nil {
+		return nil, fmt.Errorf("%w: no address", ErrInvalidKeys)
+	}
+	view, err := keys.View.PublicKey()
+	if err != nil || view.String() != keys.Address.ViewKey() {
+		return nil, fmt.Errorf("%w: view key", ErrInvalidKeys)
+	}
+	spend, err := keys.Spend.PublicKey()
+	if err != nil || spend.String() != keys.Address.SpendKey() {
+		return nil, fmt.Errorf("%w: spend key", ErrInvalidKeys)
+	}
+	return &Builder{
+		cfg:     cfg,
+		keys:    keys,
+		spend:   spend,
+		client:  client,
+		decoys:  decoy.New(client, cfg.RingSize),
+		shuffle: shuffle,
+	}, nil
+}
+
+// recipient is a decoded destination
+type recipient struct {
+	spend, view crypto.Key
+	subaddress  bool
+	change      bool
+	paymentID   []byte
+	amount      uint64
+}
+
+// input is an owned output with its secrets and ring
+type input struct {
+	OwnedOutput
+	secret, mask crypto.SecretKey
+	keyImage     crypto.Key
+	ring         decoy.Ring
+}
+
+// Build builds and signs a transaction spending the outputs to the
+// destinations, the change going back to the account of the outputs.
+func (b *Builder) Build(outputs []OwnedOutput, dests []Destination) (*Result, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("%w: no outputs to spend", ErrInsufficientFunds)
+	}
+	if len(dests) == 0 || len(dests) >= crypto.MaxRangeProofOutputs {
+		return nil, fmt.Errorf("%w: %d destinations", ErrInvalidDest, len(dests))
+	}
+	recipients := make([]recipient, len(dests))
+	var sent uint64
+	for i, d := range dests {
+		r, err := b.recipient(d)
+		if err != nil {
+			return nil, err
+		}
+		if sent+r.amount < sent {
+			return nil, fmt.Errorf("%w: amount overflow", ErrInvalidDest)
+		}
+		sent += r.amount
+		recipients[i] = r
+	}
+	change, err := b.changeRecipient(outputs)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := b.inputs(outputs)
+	if err != nil {
+		return nil, err
+	}
+	var available uint64
+	for _, in := range inputs {
+		available += uint64(in.Amount)
+	}
+	if available < sent {
+		return nil, fmt.Errorf("%w: %d available, %d to send", ErrInsufficientFunds, available, sent)
+	}
+	feePerByte, mask, err := b.feePerByte()
+	if err != nil {
+		return nil, err
+	}
+
+	// the fee depends on the weight, which depends on the fee and the change
+	var fee uint64
+	var all []recipient
+	for round := 0; ; round++ {
+		if available-sent < fee {
+			return nil, fmt.Errorf("%w: %d available, %d to send with a fee of %d", ErrInsufficientFunds, available, sent, fee)
+		}
+		change.amount = available - sent - fee
+		all = append(all[:0], recipients...)
+		if change.amount > 0 || len(recipients) == 1 {
+			all = append(all, change)
+		}
+		needed := (b.skeleton(inputs, all, fee).Weight()*feePerByte + mask - 1) / mask * mask
+		if needed <= fee {
+			break
+		}
+		if round == maxFeeRounds {
+			return nil, fmt.Errorf("transaction: fee does not converge")
+		}
+		fee = needed
+	}
+	b.shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+	res, err := b.sign(inputs, all, fee)
+	if err != nil {
+		return nil, err
+	}
+	res.Change = gonero.AtomicXMR(change.amount)
+	return res, nil
+}
+
+// recipient decodes a destination
+func (b *Builder) recipient(d Destination) (recipient, error) {
+	r := recipient{amount: uint64(d.Amount)}
+	var spend, view, net string
+	if a := gonero.NewAddress(d.Address); a != nil {
+		spend, view, net = a.SpendKey(), a.ViewKey(), a.Net()
+	} else if a := gonero.NewSubAddress(d.Address); a != nil {
+		spend, view, net = a.SpendKey(), a.ViewKey(), a.Net()
+		r.subaddress = true
+	} else if a := gonero.NewIntegratedAddress(d.Address); a != nil {
+		spend, view, net = a.SpendKey(), a.ViewKey(), a.Net()
+		pid, err := hex.DecodeString(fmt.Sprintf("%016s", a.PaymentID()))
+		if err != nil || len(pid) != encryptedPaymentIDSize {
+			return r, fmt.Errorf("%w: payment ID of %s", ErrInvalidDest, d.Address)
+		}
+		r.paymentID = pid
+	} else {
+		return r, fmt.Errorf("%w: %s", ErrInvalidDest, d.Address)
+	}
+	if net != b.keys.Address.Net() {
+		return r, fmt.Errorf("%w: %s is not a %snet address", ErrInvalidDest, d.Address, b.keys.Address.Net())
+	}
+	if r.amount == 0 {
+		return r, fmt.Errorf("%w: no amount for %s", ErrInvalidDest, d.Address)
+	}
+	var err error
+	if r.spend, err = crypto.ParseKey(spend); err != nil {
+		return r, fmt.Errorf("%w: %v", ErrInvalidDest, err)
+	}
+	if r.view, err = crypto.ParseKey(view); err != nil {
+		return r, fmt.Errorf("%w: %v", ErrInvalidDest, err)
+	}
+	return r, nil
+}
+
+// changeRecipient returns the recipient of the change: the first
+// subaddress of the account of the outputs, as wallet2 does
+func (b *Builder) changeRecipient(outputs []OwnedOutput) (recipient, error) {
+	major := outputs[0].Major
+	for _, o := range outputs {
+		if o.Major != major {
+			return recipient{}, fmt.Errorf("%w: outputs of accounts %d and %d", ErrInvalidOutput, major, o.Major)
+		}
+	}
+	if major == 0 {
+		view, _ := b.keys.View.PublicKey()
+		return recipient{spend: b.spend, view: view, change: true}, nil
+	}
+	sub, err := b.keys.Address.Subaddress(b.keys.View.Reveal(), major, 0)
+	if err != nil {
+		return recipient{}, err
+	}
+	r, err := b.recipient(Destination{Address: sub.Addr, Amount: 1})
+	r.change = true
+	return r, err
+}
+
+// inputs derives the secrets of the outputs, and picks their rings
+func (b *Builder) inputs(outputs []OwnedOutput) ([]input, error) {
+	inputs := make([]input, len(outputs))
+	indices := make([]uint64, len(outputs))
+	seen := make(map[uint64]bool, len(outputs))
+	for i, o := range outputs {
+		if seen[o.GlobalIndex] {
+			return nil, fmt.Errorf("%w: output %d spent twice", ErrInvalidOutput, o.GlobalIndex)
+		}
+		seen[o.GlobalIndex] = true
+		in := input{OwnedOutput: o}
+		derivation, err := crypto.KeyDerivation(o.TxPublicKey, b.keys.View)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+		}
+		if in.secret, err = crypto.DeriveSecretKey(derivation, o.OutputIndex, b.keys.Spend); err != nil {
+			return nil, err
+		}
+		if o.Major != 0 || o.Minor != 0 {
+			m := crypto.SubaddressSecretKey(b.keys.View, o.Major, o.Minor)
+			if in.secret, err = crypto.AddSecretKeys(in.secret, m); err != nil {
+				return nil, err
+			}
+		}
+		if pub, _ := in.secret.PublicKey(); pub != o.PublicKey {
+			return nil, fmt.Errorf("%w: output %d is not owned by the keys", ErrInvalidOutput, o.GlobalIndex)
+		}
+		if in.keyImage, err = crypto.KeyImage(o.PublicKey, in.secret); err != nil {
+			return nil, err
+		}
+		in.mask = crypto.SecretKey{1}
+		if !o.Coinbase {
+			in.mask = crypto.CommitmentMask(crypto.DerivationToScalar(derivation, o.OutputIndex))
+		}
+		inputs[i] = in
+		indices[i] = o.GlobalIndex
+	}
+
+	rings, err := b.decoys.Select(indices)
+	if err != nil {
+		return nil, err
+	}
+	for i := range inputs {
+		in := &inputs[i]
+		in.ring = rings[i]
+		// the daemon must agree with the wallet on the spent output
+		real := in.ring.Members[in.ring.RealIndex]
+		commitment, err := crypto.Commit(uint64(in.Amount), in.mask)
+		if err != nil {
+			return nil, err
+		}
+		if real.Dest != in.PublicKey || real.Mask != commitment {
+			return nil, fmt.Errorf("%w: output %d does not match the daemon's", ErrInvalidOutput, in.GlobalIndex)
+		}
+	}
+
+	// inputs are sorted by key image, descending
+	sort.Slice(inputs, func(i, j int) bool {
+		return bytesGreater(inputs[i].keyImage[:], inputs[j].keyImage[:])
+	})
+	return inputs, nil
+}
+
+func bytesGreater(a, b []byte) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// feePerByte returns the fee per byte and its quantization mask
+func (b *Builder) feePerByte() (uint64, uint64, error) {
+	if b.cfg.FeePerByte != 0 {
+		return b.cfg.FeePerByte, 1, nil
+	}
+	resp, err := b.client.GetFeeEstimate(&daemon.GetFeeEstimateRequest{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("transaction: get fee estimate: %w", err)
+	}
+	fee := resp.Fee
+	if len(resp.Fees) >= b.cfg.Priority {
+		fee = resp.Fees[b.cfg.Priority-1]
+	}
+	if fee == 0 {
+		return 0, 0, ErrNoFeeEstimate
+	}
+	mask := resp.QuantizationMask
+	if mask == 0 {
+		mask = 1
+	}
+	return fee, mask, nil
+}
+
+// skeleton returns a transaction of the size of the signed one, to
+// compute its weight
+func (b *Builder) skeleton(inputs []input, recipients []recipient, fee uint64) *Transaction {
+	tx := &Transaction{Version: 2, UnlockTime: b.cfg.UnlockTime}
+	tx.Extra = b.extra(recipients, crypto.Key{}, crypto.Key{}).Bytes()
+	rct := &RctSignatures{Type: RCTTypeBulletproofPlus, Fee: fee}
+	for _, in := range inputs {
+		tx.Inputs = append(tx.Inputs, Input{KeyOffsets: KeyOffsets(in.ring.Indices)})
+		rct.CLSAGs = append(rct.CLSAGs, crypto.CLSAG{S: make([]crypto.Key, len(in.ring.Indices))})
+		rct.PseudoOuts = append(rct.PseudoOuts, crypto.Key{})
+	}
+	for range recipients {
+		tx.Outputs = append(tx.Outputs, Output{HasViewTag: true})
+		rct.EcdhInfo = append(rct.EcdhInfo, EcdhInfo{})
+		rct.OutPk = append(rct.OutPk, crypto.Key{})
+	}
+	_, rounds := rangeProofPadding(len(recipients))
+	rct.BulletproofsPlus = []crypto.BulletproofPlus{{
+		L: make([]crypto.Key, rounds+6),
+		R: make([]crypto.Key, rounds+6),
+	}}
+	tx.RctSignatures = rct
+	return tx
+}
+
+// additionalKeys tells whether the outputs need a transaction public key
+// each: when sending to subaddresses, unless to a single one
+func additionalKeys(recipients []recipient) bool {
+	standard, subaddresses := false, map[crypto.Key]bool{}
+	for _, r := range recipients {
+		if r.change {
+			continue
+		}
+		if r.subaddress {
+			subaddresses[r.spend] = true
+		} else {
+			standard = true
+		}
+	}
+	return len(subaddresses) > 0 && (standard || len(subaddresses) > 1)
+}
+
+// singleDestination returns the only recipient of the transaction, the
+// change aside
+func singleDestination(recipients []recipient) *recipient {
+	var single *recipient
+	for i := range recipients {
+		r := &recipients[i]
+		if r.change {
+			continue
+		}
+		if single != nil && (single.spend != r.spend || single.view != r.view) {
+			return nil
+		}
+		single = r
+	}
+	return single
+}
+
+// extra returns the extra field of the transaction, with a payment ID
+// encrypted with the derivation if needed
+func (b *Builder) extra(recipients []recipient, txPub, derivation crypto.Key) *Extra {
+	e := &Extra{PublicKey: txPub, HasPublicKey: true}
+	for _, r := range recipients {
+		if r.paymentID != nil {
+			e.EncryptedPaymentID = EncryptPaymentID(r.paymentID, derivation)
+		}
+	}
+	// transactions with 2 outputs get a dummy payment ID, so that they do
+	// not stand out
+	if e.EncryptedPaymentID == nil && len(recipients) == 2 && singleDestination(recipients) != nil {
+		e.EncryptedPaymentID = EncryptPaymentID(make([]byte, encryptedPaymentIDSize), derivation)
+	}
+	if additionalKeys(recipients) {
+		e.AdditionalPublicKeys = make([]crypto.Key, len(recipients))
+	}
+	return e
+}
+
+// sign builds the transaction and signs it
+func (b *Builder) sign(inputs []input, recipients []recipient, fee uint64) (*Result, error) {
+	res := &Result{TxKey: crypto.NewSecretKey(), Fee: gonero.AtomicXMR(fee)}
+	tx := &Transaction{Version: 2, UnlockTime: b.cfg.UnlockTime}
+	res.Tx = tx
+
+	// transaction keys: R = r*G, or r*D when sending to a single subaddress
+	txPub, err := res.TxKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	single := singleDestination(recipients)
+	if single == nil {
+		for _, r := range recipients {
+			if r.paymentID != nil {
+				return nil, fmt.Errorf("%w: an integrated address must be the only destination", ErrInvalidDest)
+			}
+		}
+	}
+	if single != nil && single.subaddress {
+		if txPub, err = crypto.ScalarMult(res.TxKey, single.spend); err != nil {
+			return nil, err
+		}
+	}
+	additional := additionalKeys(recipients)
+
+	rct := &RctSignatures{Type: RCTTypeBulletproofPlus, Fee: fee}
+	amounts := make([]uint64, len(recipients))
+	masks := make([]crypto.SecretKey, len(recipients))
+	var additionalPubs []crypto.Key
+	for i, r := range recipients {
+		txKey, pub := res.TxKey, txPub
+		if additional {
+			txKey = crypto.NewSecretKey()
+			if r.subaddress {
+				pub, err = crypto.ScalarMult(txKey, r.spend)
+			} else {
+				pub, err = txKey.PublicKey()
+			}
+			if err != nil {
+				return nil, err
+			}
+			res.AdditionalTxKeys = append(res.AdditionalTxKeys, txKey)
+			additionalPubs = append(additionalPubs, pub)
+		}
+		var derivation crypto.Key
+		if r.change {
+			derivation, err = crypto.KeyDerivation(txPub, b.keys.View)
+		} else {
+			derivation, err = crypto.KeyDerivation(r.view, txKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		index := uint64(i)
+		key, err := crypto.DerivePublicKey(derivation, index, r.spend)
+		if err != nil {
+			return nil, err
+		}
+		tx.Outputs = append(tx.Outputs, Output{Key: key, HasViewTag: true, ViewTag: crypto.ViewTag(derivation, index)})
+		shared := crypto.DerivationToScalar(derivation, index)
+		amounts[i], masks[i] = r.amount, crypto.CommitmentMask(shared)
+		commitment, err := crypto.Commit(r.amount, masks[i])
+		if err != nil {
+			return nil, err
+		}
+		rct.OutPk = append(rct.OutPk, commitment)
+		var ecdh EcdhInfo
+		encrypted := crypto.EncodeAmount(r.amount, shared)
+		copy(ecdh.Amount[:], encrypted[:])
+		rct.EcdhInfo = append(rct.EcdhInfo, ecdh)
+	}
+
+	var derivation crypto.Key
+	if single != nil {
+		if derivation, err = crypto.KeyDerivation(single.view, res.TxKey); err != nil {
+			return nil, err
+		}
+	}
+	extra := b.extra(recipients, txPub, derivation)
+	extra.AdditionalPublicKeys = additionalPubs
+	tx.Extra = extra.Bytes()
+
+	proof, err := crypto.ProveBulletproofPlus(amounts, masks)
+	if err != nil {
+		return nil, err
+	}
+	rct.BulletproofsPlus = []crypto.BulletproofPlus{*proof}
+
+	// pseudo outputs balance the outputs and the fee
+	pseudoMasks := make([]crypto.SecretKey, len(inputs))
+	var last crypto.SecretKey
+	for _, m := range masks {
+		if last, err = crypto.AddSecretKeys(last, m); err != nil {
+			return nil, err
+		}
+	}
+	for i, in := range inputs {
+		if i == len(inputs)-1 {
+			pseudoMasks[i] = last
+		} else {
+			pseudoMasks[i] = crypto.NewSecretKey()
+			if last, err = crypto.SubSecretKeys(last, pseudoMasks[i]); err != nil {
+				return nil, err
+			}
+		}
+		pseudoOut, err := crypto.Commit(uint64(in.Amount), pseudoMasks[i])
+		if err != nil {
+			return nil, err
+		}
+		rct.PseudoOuts = append(rct.PseudoOuts, pseudoOut)
+		tx.Inputs = append(tx.Inputs, Input{KeyOffsets: KeyOffsets(in.ring.Indices), KeyImage: in.keyImage})
+		res.KeyImages = append(res.KeyImages, in.keyImage)
+	}
+	tx.RctSignatures = rct
+
+	message, err := tx.SignatureMessage()
+	if err != nil {
+		return nil, err
+	}
+	for i, in := range inputs {
+		sig, _, err := crypto.SignCLSAG(message, in.ring.Members, in.ring.RealIndex, in.secret, in.mask, pseudoMasks[i])
+		if err != nil {
+			return nil, err
+		}
+		rct.CLSAGs = append(rct.CLSAGs, *sig)
+	}
+	res.Hash = tx.Hash()
+	return res, nil
+}
+
+// shuffle shuffles with crypto/rand, as the order of the outputs must not
+// tell the change apart
+func shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		j, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			panic(err)
+		}
+		swap(i, int(j.Int64()))
+	}
+}
@@ -0,0 +1,669 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keys of the address used across the tests of gonero
+const (
+	testAddress   = "42ey1afDFnn4886T7196doS9GPMzexD9gXpsZJDwVjeRVdFCSoHnv7KPbBeGpzJBzHRCAs9UxqeoyFQMYbqSWYTfJJQAWDm"
+	testViewKey   = "49774391fa5e8d249fc2c5b45dadef13534bf2483dede880dac88f061e809100"
+	testSpendKey  = "148d78d2aba7dbca5cd8f6abcfb0b3c009ffbdbea1ff373d50ed94d78286640e"
+	testSubaddr01 = "84QRUYawRNrU3NN1VpFRndSukeyEb3Xpv8qZjjsoJZnTYpDYceuUTpog13D7qPxpviS7J29bSgSkR11hFFoXWk2yNdsR9WF"
+)
+
+// blocks and outputs per block of the fake chain
+const (
+	testBlocks          = 3000
+	testOutputsPerBlock = 5
+)
+
+// fakeDaemon is a stand-in daemon serving a chain of random outputs
+type fakeDaemon struct {
+	daemon.Client
+
+	outputs map[uint64]daemon.Outs
+	// spent statuses of key images
+	spent   map[string]uint64
+	version uint64
+	txs     map[string]daemon.Transaction
+}
+
+func newFakeDaemon() *fakeDaemon {
+	return &fakeDaemon{outputs: map[uint64]daemon.Outs{}, spent: map[string]uint64{}, version: 16, txs: map[string]daemon.Transaction{}}
+}
+
+func (d *fakeDaemon) GetTransactions(req *daemon.GetTransactionsRequest) (*daemon.GetTransactionsResponse, error) {
+	resp := &daemon.GetTransactionsResponse{Status: daemon.RPCStatusOk}
+	for _, hash := range req.TxsHashes {
+		if tx, ok := d.txs[hash]; ok {
+			resp.Txs = append(resp.Txs, tx)
+		} else {
+			resp.MissedTx = append(resp.MissedTx, hash)
+		}
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) GetHeight(req *daemon.GetHeightRequest) (*daemon.GetHeightResponse, error) {
+	return &daemon.GetHeightResponse{Height: testBlocks, Status: daemon.RPCStatusOk}, nil
+}
+
+func (d *fakeDaemon) GetOutputDistribution(req *daemon.GetOutputDistributionRequest) (*daemon.GetOutputDistributionResponse, error) {
+	if len(req.Amounts) != 1 || req.Amounts[0] != 0 || !req.Cumulative {
+		return nil, errors.New("unexpected request")
+	}
+	offsets := make([]uint64, testBlocks)
+	for i := range offsets {
+		offsets[i] = uint64(i+1) * testOutputsPerBlock
+	}
+	return &daemon.GetOutputDistributionResponse{Distributions: []daemon.OuputDistribution{{Distribution: offsets}}}, nil
+}
+
+func (d *fakeDaemon) GetOuts(req *daemon.GetOutsRequest) (*daemon.GetOutsResponse, error) {
+	resp := &daemon.GetOutsResponse{}
+	for _, o := range req.Outputs {
+		out, ok := d.outputs[o.Index]
+		if !ok {
+			dest, _ := crypto.NewSecretKey().PublicKey()
+			mask, _ := crypto.Commit(o.Index, crypto.NewSecretKey())
+			out = daemon.Outs{Key: dest.String(), Mask: mask.String(), Unlocked: true}
+			d.outputs[o.Index] = out
+		}
+		resp.Outs = append(resp.Outs, out)
+	}
+	return resp, nil
+}
+
+func (d *fakeDaemon) GetFeeEstimate(req *daemon.GetFeeEstimateRequest) (*daemon.GetFeeEstimateResponse, error) {
+	return &daemon.GetFeeEstimateResponse{Fee: 20000, Fees: []uint64{20000, 80000, 320000, 4000000}, QuantizationMask: 10000}, nil
+}
+
+func (d *fakeDaemon) HardForkInfo(req *daemon.HardForkInfoRequest) (*daemon.HardForkInfoResponse, error) {
+	return &daemon.HardForkInfoResponse{Version: d.version, Enabled: true, Status: daemon.RPCStatusOk}, nil
+}
+
+func (d *fakeDaemon) IsKeyImageSpent(req *daemon.IsKeyImageSpentRequest) (*daemon.IsKeyImageSpentResponse, error) {
+	resp := &daemon.IsKeyImageSpentResponse{Status: daemon.RPCStatusOk}
+	for _, ki := range req.KeyImages {
+		resp.SpentStatus = append(resp.SpentStatus, d.spent[ki])
+	}
+	return resp, nil
+}
+
+func testKeys(t *testing.T) Keys {
+	view, err := crypto.ParseSecretKey(testViewKey)
+	require.NoError(t, err)
+	spend, err := crypto.ParseSecretKey(testSpendKey)
+	require.NoError(t, err)
+	return Keys{Address: gonero.NewAddress(testAddress), View: view, Spend: spend}
+}
+
+// receive adds an output of amount to the subaddress of the keys at index
+// in the chain, as a transaction to it would
+func (d *fakeDaemon) receive(t *testing.T, keys Keys, index uint64, amount gonero.AtomicXMR, major, minor uint32) OwnedOutput {
+	spend, err := crypto.ParseKey(keys.Address.SpendKey())
+	require.NoError(t, err)
+	view, err := crypto.ParseKey(keys.Address.ViewKey())
+	require.NoError(t, err)
+	if major != 0 || minor != 0 {
+		sub, err := keys.Address.Subaddress(keys.View.Reveal(), major, minor)
+		require.NoError(t, err)
+		spend, _ = crypto.ParseKey(sub.SpendKey())
+		view, _ = crypto.ParseKey(sub.ViewKey())
+	}
+	r := crypto.NewSecretKey()
+	txPub, err := r.PublicKey()
+	if major != 0 || minor != 0 {
+		txPub, err = crypto.ScalarMult(r, spend)
+	}
+	require.NoError(t, err)
+	derivation, err := crypto.KeyDerivation(view, r)
+	require.NoError(t, err)
+	key, err := crypto.DerivePublicKey(derivation, 1, spend)
+	require.NoError(t, err)
+	mask := crypto.CommitmentMask(crypto.DerivationToScalar(derivation, 1))
+	commitment, err := crypto.Commit(uint64(amount), mask)
+	require.NoError(t, err)
+	d.outputs[index] = daemon.Outs{Key: key.String(), Mask: commitment.String(), Unlocked: true}
+	return OwnedOutput{
+		Amount:      amount,
+		GlobalIndex: index,
+		PublicKey:   key,
+		TxPublicKey: txPub,
+		OutputIndex: 1,
+		Major:       major,
+		Minor:       minor,
+	}
+}
+
+// ring returns the ring members of an input from the daemon
+func (d *fakeDaemon) ring(t *testing.T, in Input) []crypto.RingMember {
+	var members []crypto.RingMember
+	for _, index := range in.Ring() {
+		out, ok := d.outputs[index]
+		require.True(t, ok, "%d", index)
+		dest, _ := crypto.ParseKey(out.Key)
+		mask, _ := crypto.ParseKey(out.Mask)
+		members = append(members, crypto.RingMember{Dest: dest, Mask: mask})
+	}
+	return members
+}
+
+// verify checks the signatures, range proofs and balance of a transaction
+func (d *fakeDaemon) verify(t *testing.T, tx *Transaction) {
+	rct := tx.RctSignatures
+	message, err := tx.SignatureMessage()
+	require.NoError(t, err)
+	for i, in := range tx.Inputs {
+		assert.Len(t, in.KeyOffsets, 16)
+		assert.NoError(t, crypto.VerifyCLSAG(message, d.ring(t, in), rct.PseudoOuts[i], in.KeyImage, &rct.CLSAGs[i]))
+		if i > 0 {
+			assert.Equal(t, 1, bytes.Compare(tx.Inputs[i-1].KeyImage[:], in.KeyImage[:]))
+		}
+	}
+	require.Len(t, rct.BulletproofsPlus, 1)
+	assert.NoError(t, crypto.VerifyBulletproofPlus(&rct.BulletproofsPlus[0]))
+	assert.NoError(t, crypto.CheckBalance(rct.PseudoOuts, rct.OutPk, rct.Fee))
+}
+
+// scan returns the amounts received by the subaddress in the transaction
+func scan(t *testing.T, keys Keys, tx *Transaction, major, minor uint32) []uint64 {
+	spend, _ := crypto.ParseKey(keys.Address.SpendKey())
+	if major != 0 || minor != 0 {
+		sub, err := keys.Address.Subaddress(keys.View.Reveal(), major, minor)
+		require.NoError(t, err)
+		spend, _ = crypto.ParseKey(sub.SpendKey())
+	}
+	extra, err := ParseExtra(tx.Extra)
+	require.NoError(t, err)
+	require.True(t, extra.HasPublicKey)
+	var amounts []uint64
+	for i, out := range tx.Outputs {
+		pubs := []crypto.Key{extra.PublicKey}
+		if extra.AdditionalPublicKeys != nil {
+			pubs = append(pubs, extra.AdditionalPublicKeys[i])
+		}
+		for _, pub := range pubs {
+			derivation, err := crypto.KeyDerivation(pub, keys.View)
+			require.NoError(t, err)
+			key, err := crypto.DerivePublicKey(derivation, uint64(i), spend)
+			require.NoError(t, err)
+			if key != out.Key {
+				continue
+			}
+			assert.Equal(t, crypto.ViewTag(derivation, uint64(i)), out.ViewTag)
+			shared := crypto.DerivationToScalar(derivation, uint64(i))
+			var encrypted [8]byte
+			copy(encrypted[:], tx.RctSignatures.EcdhInfo[i].Amount[:8])
+			amount := crypto.DecodeAmount(encrypted, shared)
+			commitment, err := crypto.Commit(amount, crypto.CommitmentMask(shared))
+			require.NoError(t, err)
+			assert.Equal(t, commitment, tx.RctSignatures.OutPk[i])
+			amounts = append(amounts, amount)
+		}
+	}
+	return amounts
+}
+
+func TestBuild(t *testing.T) {
+	d := newFakeDaemon()
+	keys := testKeys(t)
+	b, err := NewBuilder(d, keys, Config{})
+	require.NoError(t, err)
+	in1 := d.receive(t, keys, 14000, 3000000000000, 0, 0)
+	in2 := d.receive(t, keys, 14500, 500000000000, 0, 2)
+
+	// to a single subaddress, R = r*D
+	res, err := b.Build([]OwnedOutput{in1, in2}, []Destination{{Address: testSubaddr01, Amount: 1000000000000}})
+	require.NoError(t, err)
+	tx := res.Tx
+	d.verify(t, tx)
+	assert.Len(t, tx.Inputs, 2)
+	assert.Len(t, tx.Outputs, 2)
+	assert.Len(t, res.KeyImages, 2)
+	assert.Empty(t, res.AdditionalTxKeys)
+	assert.Equal(t, []uint64{1000000000000}, scan(t, keys, tx, 0, 1))
+	assert.Equal(t, []uint64{uint64(res.Change)}, scan(t, keys, tx, 0, 0))
+	assert.Equal(t, uint64(3500000000000), uint64(res.Change+res.Fee)+1000000000000)
+	assert.Equal(t, uint64(res.Fee), tx.RctSignatures.Fee)
+	assert.Zero(t, res.Fee%10000)
+	assert.True(t, uint64(res.Fee) >= tx.Weight()*20000)
+	extra, err := ParseExtra(tx.Extra)
+	require.NoError(t, err)
+	assert.Len(t, extra.EncryptedPaymentID, 8)
+
+	// key images of the spent outputs
+	for _, o := range []OwnedOutput{in1, in2} {
+		derivation, _ := crypto.KeyDerivation(o.TxPublicKey, keys.View)
+		secret, _ := crypto.DeriveSecretKey(derivation, o.OutputIndex, keys.Spend)
+		if o.Minor != 0 {
+			secret, _ = crypto.AddSecretKeys(secret, crypto.SubaddressSecretKey(keys.View, o.Major, o.Minor))
+		}
+		ki, _ := crypto.KeyImage(o.PublicKey, secret)
+		assert.Contains(t, res.KeyImages, ki)
+	}
+
+	// the blob parses back to the same transaction
+	parsed, err := ParseHex(tx.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, tx, parsed)
+	assert.Equal(t, res.Hash, parsed.Hash())
+	assert.Equal(t, tx.Bytes(), parsed.Bytes())
+
+	// to the address and a subaddress, with additional keys
+	res, err = b.Build([]OwnedOutput{in1}, []Destination{
+		{Address: testSubaddr01, Amount: 1000000000000},
+		{Address: testAddress, Amount: 200000000000},
+	})
+	require.NoError(t, err)
+	d.verify(t, res.Tx)
+	assert.Len(t, res.Tx.Outputs, 3)
+	assert.Len(t, res.AdditionalTxKeys, 3)
+	assert.Equal(t, []uint64{1000000000000}, scan(t, keys, res.Tx, 0, 1))
+	assert.ElementsMatch(t, []uint64{200000000000, uint64(res.Change)}, scan(t, keys, res.Tx, 0, 0))
+	parsed, err = Parse(res.Tx.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, res.Hash, parsed.Hash())
+}
+
+func TestBuildPaymentID(t *testing.T) {
+	d := newFakeDaemon()
+	keys := testKeys(t)
+	b, err := NewBuilder(d, keys, Config{FeePerByte: 20000})
+	require.NoError(t, err)
+	in := d.receive(t, keys, 14000, 3000000000000, 0, 0)
+	integrated := keys.Address.WithPaymentID("00a1b2c3d4e5f607")
+	require.NotNil(t, integrated)
+
+	res, err := b.Build([]OwnedOutput{in}, []Destination{{Address: integrated.Addr, Amount: 1000000000000}})
+	require.NoError(t, err)
+	d.verify(t, res.Tx)
+	extra, err := ParseExtra(res.Tx.Extra)
+	require.NoError(t, err)
+	derivation, err := crypto.KeyDerivation(extra.PublicKey, keys.View)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0xa1, 0xb2, 0xc3, 0xd4, 0xe5, 0xf6, 0x07}, EncryptPaymentID(extra.EncryptedPaymentID, derivation))
+
+	// a payment ID needs a single destination
+	_, err = b.Build([]OwnedOutput{in}, []Destination{
+		{Address: integrated.Addr, Amount: 1000000000000},
+		{Address: testSubaddr01, Amount: 1000000000000},
+	})
+	assert.True(t, errors.Is(err, ErrInvalidDest), "%v", err)
+}
+
+func TestBuildErrors(t *testing.T) {
+	d := newFakeDaemon()
+	keys := testKeys(t)
+	other := keys
+	other.View = crypto.NewSecretKey()
+	_, err := NewBuilder(d, other, Config{})
+	assert.True(t, errors.Is(err, ErrInvalidKeys))
+
+	b, err := NewBuilder(d, keys, Config{})
+	require.NoError(t, err)
+	in := d.receive(t, keys, 14000, 1000000000000, 0, 0)
+
+	_, err = b.Build([]OwnedOutput{in}, []Destination{{Address: testSubaddr01, Amount: 1000000000000}})
+	assert.True(t, errors.Is(err, ErrInsufficientFunds), "%v", err)
+	_, err = b.Build([]OwnedOutput{in}, []Destination{{Address: "nope", Amount: 1}})
+	assert.True(t, errors.Is(err, ErrInvalidDest), "%v", err)
+	_, err = b.Build([]OwnedOutput{in, in}, []Destination{{Address: testSubaddr01, Amount: 1}})
+	assert.True(t, errors.Is(err, ErrInvalidOutput), "%v", err)
+
+	// outputs the keys do not own, or the daemon disagrees on
+	bad := in
+	bad.OutputIndex = 2
+	_, err = b.Build([]OwnedOutput{bad}, []Destination{{Address: testSubaddr01, Amount: 1}})
+	assert.True(t, errors.Is(err, ErrInvalidOutput), "%v", err)
+	bad = in
+	bad.Amount++
+	_, err = b.Build([]OwnedOutput{bad}, []Destination{{Address: testSubaddr01, Amount: 1}})
+	assert.True(t, errors.Is(err, ErrInvalidOutput), "%v", err)
+}
+
+func TestParse(t *testing.T) {
+	// a coinbase transaction: version 2, no RingCT signatures
+	tx := &Transaction{
+		Version:       2,
+		UnlockTime:    2860,
+		Inputs:        []Input{{Coinbase: true, Height: 2800}},
+		Outputs:       []Output{{Amount: 600000000000, Key: crypto.Keccak256([]byte("out")), HasViewTag: true, ViewTag: 0x2a}},
+		Extra:         (&Extra{PublicKey: crypto.Keccak256([]byte("pub")), HasPublicKey: true}).Bytes(),
+		RctSignatures: &RctSignatures{},
+	}
+	parsed, err := Parse(tx.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, tx, parsed)
+	blob := tx.Bytes()
+	assert.Equal(t, crypto.Keccak256(blob[:len(blob)-1]), parsed.PrefixHash())
+
+	_, err = Parse(blob[:len(blob)-2])
+	assert.True(t, errors.Is(err, ErrMalformed), "%v", err)
+	_, err = Parse(append(blob, 0))
+	assert.True(t, errors.Is(err, ErrMalformed), "%v", err)
+	_, err = ParseHex("zz")
+	assert.True(t, errors.Is(err, ErrMalformed), "%v", err)
+	// non canonical varint
+	_, err = Parse([]byte{0x82, 0x00})
+	assert.True(t, errors.Is(err, ErrMalformed), "%v", err)
+	// a version 1 transaction spending outputs
+	v1 := append([]byte{1, 0, 1, 2, 0, 0}, make([]byte, 32+2)...)
+	_, err = Parse(v1)
+	assert.True(t, errors.Is(err, ErrUnsupported), "%v", err)
+
+	assert.Equal(t, []uint64{5, 12, 12, 40}, (&Input{KeyOffsets: KeyOffsets([]uint64{5, 12, 12, 40})}).Ring())
+}
+
+func TestParseRctTypes(t *testing.T) {
+	key := func(s string, i int) crypto.Key { return crypto.Keccak256([]byte(s), []byte{byte(i)}) }
+	keys := func(s string, n int) []crypto.Key {
+		k := make([]crypto.Key, n)
+		for i := range k {
+			k[i] = key(s, i)
+		}
+		return k
+	}
+	// commitments must be points, to restore the V of the range proofs
+	outPk := []crypto.Key{crypto.ZeroCommit(1), crypto.ZeroCommit(2)}
+	var V []crypto.Key
+	for _, c := range outPk {
+		v, err := crypto.ScaleInvEight(c)
+		require.NoError(t, err)
+		V = append(V, v)
+	}
+	bp := crypto.Bulletproof{
+		V: V, A: key("A", 0), S: key("S", 0), T1: key("T1", 0), T2: key("T2", 0), Taux: key("taux", 0), Mu: key("mu", 0),
+		L: keys("L", 7), R: keys("R", 7), InnerA: key("a", 0), InnerB: key("b", 0), T: key("t", 0),
+	}
+	var rangeSig RangeSig
+	copy(rangeSig.S0[:], keys("s0", 64))
+	copy(rangeSig.S1[:], keys("s1", 64))
+	rangeSig.EE = key("ee", 0)
+	copy(rangeSig.Ci[:], keys("Ci", 64))
+
+	for _, rct := range []*RctSignatures{
+		{Type: RCTTypeSimple, PseudoOuts: keys("pseudo", 1), RangeSigs: []RangeSig{rangeSig, rangeSig}, MLSAGs: []crypto.MLSAG{{SS: [][]crypto.Key{keys("ss0", 2), keys("ss1", 2), keys("ss2", 2)}, CC: key("cc", 0)}}},
+		{Type: RCTTypeBulletproof, PseudoOuts: keys("pseudo", 1), Bulletproofs: []crypto.Bulletproof{bp}, MLSAGs: []crypto.MLSAG{{SS: [][]crypto.Key{keys("ss0", 2), keys("ss1", 2), keys("ss2", 2)}, CC: key("cc", 0)}}},
+		{Type: RCTTypeBulletproof2, PseudoOuts: keys("pseudo", 1), Bulletproofs: []crypto.Bulletproof{bp}, MLSAGs: []crypto.MLSAG{{SS: [][]crypto.Key{keys("ss0", 2), keys("ss1", 2), keys("ss2", 2)}, CC: key("cc", 0)}}},
+		{Type: RCTTypeCLSAG, PseudoOuts: keys("pseudo", 1), Bulletproofs: []crypto.Bulletproof{bp}, CLSAGs: []crypto.CLSAG{{S: keys("s", 3), C1: key("c1", 0), D: key("D", 0)}}},
+	} {
+		rct.Fee = 30000000
+		rct.OutPk = outPk
+		rct.EcdhInfo = []EcdhInfo{{Amount: crypto.Key{1, 2, 3, 4, 5, 6, 7, 8}}, {Amount: crypto.Key{8}}}
+		if !rct.compactEcdh() {
+			rct.EcdhInfo = []EcdhInfo{{Mask: key("mask", 0), Amount: key("amount", 0)}, {Mask: key("mask", 1), Amount: key("amount", 1)}}
+		}
+		tx := &Transaction{
+			Version:       2,
+			Inputs:        []Input{{KeyOffsets: []uint64{100, 5, 7}, KeyImage: key("ki", 0)}},
+			Outputs:       []Output{{Key: key("out", 0)}, {Key: key("out", 1)}},
+			Extra:         []byte{},
+			RctSignatures: rct,
+		}
+		parsed, err := Parse(tx.Bytes())
+		require.NoError(t, err, "type %d", rct.Type)
+		assert.Equal(t, tx, parsed, "type %d", rct.Type)
+		_, err = tx.SignatureMessage()
+		assert.NoError(t, err)
+	}
+}
+
+// stagenet wallet receiving outputs of testdata/stagenet_txs.json, at its
+// subaddress testStagenetSubaddr
+const (
+	testStagenetViewKey = "8aa763d1c8d9da4ca75cb6ca22a021b5cca376c1367be8d62bcc9cdf4b926009"
+	testStagenetSubaddr = "78hRedVbk2N3Mg2DpMMUoCbynA1uZJzAr7R7rnCtBo4Q1FtnDePx7NPAcCGPXVEBTp96AjRnR9uchhan49fbBAnuLTU11cw"
+)
+
+func TestParseStagenet(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/stagenet_txs.json")
+	require.NoError(t, err)
+	var txs []struct {
+		Height uint64 `json:"block_height"`
+		Hash   string `json:"tx_hash"`
+		Hex    string `json:"as_hex"`
+	}
+	require.NoError(t, json.Unmarshal(data, &txs))
+	view, err := crypto.ParseSecretKey(testStagenetViewKey)
+	require.NoError(t, err)
+
+	received := map[string]gonero.AtomicXMR{
+		"4866f5b687b77b8829172cd727d76328db2b50a0fa34a3c03ca2ded0747e954c": 45000000000,
+		"793da06116f80b9aee790f8558bdfafbc1a7c733ff82f85640d1853dfdc0be4d": 100000000,
+	}
+	for _, x := range txs {
+		tx, err := ParseHex(x.Hex)
+		require.NoError(t, err, x.Hash)
+		assert.Equal(t, x.Hex, tx.Hex(), x.Hash)
+		assert.Equal(t, x.Hash, tx.Hash().String())
+
+		rct := tx.RctSignatures
+		require.Equal(t, uint8(RCTTypeBulletproofPlus), rct.Type, x.Hash)
+		for i := range rct.BulletproofsPlus {
+			assert.NoError(t, crypto.VerifyBulletproofPlus(&rct.BulletproofsPlus[i]), x.Hash)
+		}
+		assert.NoError(t, crypto.CheckBalance(rct.PseudoOuts, rct.OutPk, rct.Fee), x.Hash)
+		_, err = tx.SignatureMessage()
+		assert.NoError(t, err)
+
+		extra, err := ParseExtra(tx.Extra)
+		require.NoError(t, err)
+		derivation, err := crypto.KeyDerivation(extra.PublicKey, view)
+		require.NoError(t, err)
+		check, err := tx.CheckDerivations(derivation, nil, testStagenetSubaddr)
+		require.NoError(t, err)
+		assert.Equal(t, received[x.Hash], check.Received, x.Hash)
+		for _, i := range check.Outputs {
+			assert.Equal(t, tx.Outputs[i].ViewTag, crypto.ViewTag(derivation, uint64(i)), x.Hash)
+		}
+	}
+}
+
+func TestParseMainnetRctSimple(t *testing.T) {
+	// a transaction of mainnet block 1302238, before Bulletproofs: its
+	// range proofs are Borromean signatures and its ring signatures MLSAGs
+	data, err := ioutil.ReadFile("testdata/mainnet_txs.json")
+	require.NoError(t, err)
+	var txs []struct {
+		Hash string `json:"tx_hash"`
+		Hex  string `json:"as_hex"`
+	}
+	require.NoError(t, json.Unmarshal(data, &txs))
+	require.Len(t, txs, 1)
+	tx, err := ParseHex(txs[0].Hex)
+	require.NoError(t, err)
+	assert.Equal(t, txs[0].Hex, tx.Hex())
+	assert.Equal(t, txs[0].Hash, tx.Hash().String())
+	assert.Equal(t, "1bbfda600fa6affc80dae05b1124bf05ed0e20890aa42601441dbe0f6fa81f4b", tx.PrefixHash().String())
+
+	rct := tx.RctSignatures
+	require.Equal(t, uint8(RCTTypeSimple), rct.Type)
+	assert.Len(t, rct.RangeSigs, len(tx.Outputs))
+	assert.Len(t, rct.MLSAGs, len(tx.Inputs))
+	assert.NoError(t, crypto.CheckBalance(rct.PseudoOuts, rct.OutPk, rct.Fee))
+	_, err = tx.SignatureMessage()
+	assert.NoError(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	d := newFakeDaemon()
+	keys := testKeys(t)
+	b, err := NewBuilder(d, keys, Config{})
+	require.NoError(t, err)
+	in1 := d.receive(t, keys, 14000, 3000000000000, 0, 0)
+	in2 := d.receive(t, keys, 14500, 500000000000, 0, 2)
+	res, err := b.Build([]OwnedOutput{in1, in2}, []Destination{{Address: testSubaddr01, Amount: 1000000000000}})
+	require.NoError(t, err)
+	v := NewVerifier(d)
+	require.NoError(t, v.VerifyHex(res.Tx.Hex()))
+
+	rings := make([][]crypto.RingMember, len(res.Tx.Inputs))
+	for i, in := range res.Tx.Inputs {
+		rings[i] = d.ring(t, in)
+	}
+	for _, version := range []int{15, 16, 17} {
+		rules, err := RulesFor(version)
+		require.NoError(t, err)
+		assert.NoError(t, Verify(res.Tx, rings, rules), "version %d", version)
+	}
+	_, err = RulesFor(7)
+	assert.True(t, errors.Is(err, ErrUnsupported), "%v", err)
+	rules, _ := RulesFor(14)
+	assert.True(t, errors.Is(Verify(res.Tx, rings, rules), ErrInvalid))
+	rules, _ = RulesFor(16)
+	assert.True(t, errors.Is(Verify(res.Tx, rings[:1], rules), ErrInvalid))
+
+	// tampered transactions
+	tamper := func(f func(tx *Transaction)) error {
+		tx, err := Parse(res.Tx.Bytes())
+		require.NoError(t, err)
+		f(tx)
+		return Verify(tx, rings, rules)
+	}
+	for name, f := range map[string]func(tx *Transaction){
+		"fee":        func(tx *Transaction) { tx.RctSignatures.Fee++ },
+		"unlock":     func(tx *Transaction) { tx.UnlockTime = 10 },
+		"signature":  func(tx *Transaction) { tx.RctSignatures.CLSAGs[1].S[3][0]++ },
+		"proof":      func(tx *Transaction) { tx.RctSignatures.BulletproofsPlus[0].R1[0]++ },
+		"pseudo out": func(tx *Transaction) { tx.RctSignatures.PseudoOuts[0] = tx.RctSignatures.PseudoOuts[1] },
+		"order": func(tx *Transaction) {
+			tx.Inputs[0], tx.Inputs[1] = tx.Inputs[1], tx.Inputs[0]
+		},
+		"duplicate member": func(tx *Transaction) { tx.Inputs[0].KeyOffsets[1] = 0 },
+		"ring size":        func(tx *Transaction) { tx.Inputs[0].KeyOffsets = tx.Inputs[0].KeyOffsets[:11] },
+		"view tag":         func(tx *Transaction) { tx.Outputs[0].HasViewTag = false },
+		"extra":            func(tx *Transaction) { tx.Extra = make([]byte, 2000) },
+		"single output": func(tx *Transaction) {
+			tx.Outputs = tx.Outputs[:1]
+			tx.RctSignatures.EcdhInfo = tx.RctSignatures.EcdhInfo[:1]
+			tx.RctSignatures.OutPk = tx.RctSignatures.OutPk[:1]
+		},
+	} {
+		err := tamper(f)
+		assert.True(t, errors.Is(err, ErrInvalid), "%s: %v", name, err)
+	}
+
+	// spent key images and locked ring members
+	d.spent[res.KeyImages[1].String()] = 2
+	err = v.Verify(res.Tx)
+	assert.True(t, errors.Is(err, ErrDoubleSpend), "%v", err)
+	delete(d.spent, res.KeyImages[1].String())
+	index := res.Tx.Inputs[0].Ring()[4]
+	out := d.outputs[index]
+	out.Unlocked = false
+	d.outputs[index] = out
+	err = v.Verify(res.Tx)
+	assert.True(t, errors.Is(err, ErrInvalid), "%v", err)
+}
+
+func TestCheckTxKey(t *testing.T) {
+	d := newFakeDaemon()
+	keys := testKeys(t)
+	b, err := NewBuilder(d, keys, Config{})
+	require.NoError(t, err)
+	in := d.receive(t, keys, 14000, 3000000000000, 0, 0)
+	res, err := b.Build([]OwnedOutput{in}, []Destination{
+		{Address: testSubaddr01, Amount: 1000000000000},
+		{Address: testAddress, Amount: 200000000000},
+	})
+	require.NoError(t, err)
+	hash := res.Hash.String()
+	d.txs[hash] = daemon.Transaction{TxHash: hash, AsHex: res.Tx.Hex(), BlockHeight: testBlocks - 10}
+
+	// the tx key as returned by wallet.GetTxKey
+	txKey := res.TxKey.Reveal()
+	for _, k := range res.AdditionalTxKeys {
+		txKey += k.Reveal()
+	}
+	key, additional, err := ParseTxKey(gonero.NewSecret(txKey))
+	require.NoError(t, err)
+	assert.Equal(t, res.TxKey, key)
+	assert.Equal(t, res.AdditionalTxKeys, additional)
+	_, _, err = ParseTxKey(gonero.NewSecret(txKey[:100]))
+	assert.True(t, errors.Is(err, ErrInvalidTxKey), "%v", err)
+
+	check, err := CheckTxKey(d, hash, key, additional, testSubaddr01)
+	require.NoError(t, err)
+	assert.Equal(t, gonero.AtomicXMR(1000000000000), check.Received)
+	assert.Len(t, check.Outputs, 1)
+	assert.False(t, check.InPool)
+	assert.Equal(t, uint64(10), check.Confirmations)
+	// the address receives the payment and the change
+	check, err = CheckTxKey(d, hash, key, additional, testAddress)
+	require.NoError(t, err)
+	assert.Equal(t, 200000000000+res.Change, check.Received)
+	assert.Len(t, check.Outputs, 2)
+
+	// another key proves nothing
+	check, err = CheckTxKey(d, hash, crypto.NewSecretKey(), nil, testSubaddr01)
+	require.NoError(t, err)
+	assert.Zero(t, check.Received)
+	assert.Empty(t, check.Outputs)
+
+	d.txs[hash] = daemon.Transaction{TxHash: hash, AsHex: res.Tx.Hex(), InPool: true}
+	check, err = CheckTxKey(d, hash, key, additional, testSubaddr01)
+	require.NoError(t, err)
+	assert.True(t, check.InPool)
+	assert.Zero(t, check.Confirmations)
+
+	_, err = CheckTxKey(d, crypto.Key{}.String(), key, additional, testSubaddr01)
+	assert.True(t, errors.Is(err, ErrTxNotFound), "%v", err)
+	_, err = CheckTxKey(d, hash, key, additional[:1], testSubaddr01)
+	assert.True(t, errors.Is(err, ErrInvalidTxKey), "%v", err)
+	_, err = CheckTxKey(d, hash, key, additional, "nope")
+	assert.True(t, errors.Is(err, ErrInvalidDest), "%v", err)
+}
+
+func TestCheckTxKeyFullEcdh(t *testing.T) {
+	keys := testKeys(t)
+	spend, _ := crypto.ParseKey(keys.Address.SpendKey())
+	view, _ := crypto.ParseKey(keys.Address.ViewKey())
+	r := crypto.NewSecretKey()
+	derivation, err := crypto.KeyDerivation(view, r)
+	require.NoError(t, err)
+	key, err := crypto.DerivePublicKey(derivation, 0, spend)
+	require.NoError(t, err)
+
+	// before RCTTypeBulletproof2, the mask and the amount are masked with
+	// Hs(shared) and Hs(Hs(shared))
+	shared := crypto.DerivationToScalar(derivation, 0)
+	h := crypto.HashToScalar(shared[:])
+	hh := crypto.HashToScalar(h[:])
+	mask := crypto.NewSecretKey()
+	maskedMask, err := crypto.AddSecretKeys(mask, crypto.SecretKey(h))
+	require.NoError(t, err)
+	maskedAmount, err := crypto.AddSecretKeys(crypto.SecretKey{0x40, 0x42, 0x0f}, crypto.SecretKey(hh))
+	require.NoError(t, err)
+	commitment, err := crypto.Commit(1000000, mask)
+	require.NoError(t, err)
+	tx := &Transaction{
+		Version: 2,
+		Outputs: []Output{{Key: key}},
+		RctSignatures: &RctSignatures{
+			Type:     RCTTypeBulletproof,
+			EcdhInfo: []EcdhInfo{{Mask: crypto.Key(maskedMask), Amount: crypto.Key(maskedAmount)}},
+			OutPk:    []crypto.Key{commitment},
+		},
+	}
+	check, err := tx.CheckTxKey(r, nil, testAddress)
+	require.NoError(t, err)
+	assert.Equal(t, gonero.AtomicXMR(1000000), check.Received)
+
+	// a commitment not matching the amount
+	tx.RctSignatures.OutPk[0] = crypto.ZeroCommit(1000000)
+	check, err = tx.CheckTxKey(r, nil, testAddress)
+	require.NoError(t, err)
+	assert.Zero(t, check.Received)
+}
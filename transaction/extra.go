@@ -0,0 +1,117 @@
+package transaction
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/konraddical2/gonero/crypto"
+)
+
+// tags of the extra field
+const (
+	extraPadding             = 0x00
+	extraPublicKey           = 0x01
+	extraNonce               = 0x02
+	extraMergeMining         = 0x03
+	extraAdditionalKeys      = 0x04
+	extraMinergate           = 0xde
+	noncePaymentID           = 0x00
+	nonceEncryptedPaymentID  = 0x01
+	maxExtraNonceSize        = 255
+	encryptedPaymentIDSize   = 8
+	encryptedPaymentIDSuffix = 0x8d
+)
+
+// Extra is the parsed extra field of a transaction
+type Extra struct {
+	// Transaction public key, r*G.
+	PublicKey crypto.Key `json:"public_key"`
+	// States if the extra field has a transaction public key.
+	HasPublicKey bool `json:"has_public_key"`
+	// Additional public keys, one per output, of transactions to subaddresses.
+	AdditionalPublicKeys []crypto.Key `json:"additional_public_keys,omitempty"`
+	// Unencrypted 32 byte payment ID.
+	PaymentID []byte `json:"payment_id,omitempty"`
+	// Encrypted 8 byte payment ID.
+	EncryptedPaymentID []byte `json:"encrypted_payment_id,omitempty"`
+}
+
+// ParseExtra parses the extra field of a transaction. As monerod, it keeps
+// the first public key and stops at the first unknown field.
+func ParseExtra(extra []byte) (*Extra, error) {
+	e := &Extra{}
+	r := &reader{buf: extra}
+	for r.pos < len(extra) && r.err == nil {
+		switch tag := r.byte(); tag {
+		case extraPadding:
+			// padding is zeros up to the end
+			for _, b := range r.bytes(len(extra) - r.pos) {
+				if b != 0 {
+					return e, fmt.Errorf("%w: extra padding", ErrMalformed)
+				}
+			}
+		case extraPublicKey:
+			k := r.key()
+			if !e.HasPublicKey {
+				e.PublicKey, e.HasPublicKey = k, true
+			}
+		case extraNonce:
+			nonce := r.bytes(r.count(maxExtraNonceSize))
+			switch {
+			case len(nonce) == 1+32 && nonce[0] == noncePaymentID:
+				e.PaymentID = nonce[1:]
+			case len(nonce) == 1+encryptedPaymentIDSize && nonce[0] == nonceEncryptedPaymentID:
+				e.EncryptedPaymentID = nonce[1:]
+			}
+		case extraMergeMining, extraMinergate:
+			r.bytes(r.count(len(extra)))
+		case extraAdditionalKeys:
+			if e.AdditionalPublicKeys != nil {
+				return e, fmt.Errorf("%w: duplicate additional public keys", ErrMalformed)
+			}
+			e.AdditionalPublicKeys = r.keys(r.count(len(extra) / 32))
+		default:
+			return e, nil
+		}
+	}
+	return e, r.err
+}
+
+// Bytes returns the extra field: the public key, the payment ID and the
+// additional public keys, in the order of wallet2.
+func (e *Extra) Bytes() []byte {
+	w := &bytes.Buffer{}
+	if e.HasPublicKey {
+		w.WriteByte(extraPublicKey)
+		w.Write(e.PublicKey[:])
+	}
+	if e.PaymentID != nil {
+		w.WriteByte(extraNonce)
+		writeVarint(w, uint64(1+len(e.PaymentID)))
+		w.WriteByte(noncePaymentID)
+		w.Write(e.PaymentID)
+	}
+	if e.EncryptedPaymentID != nil {
+		w.WriteByte(extraNonce)
+		writeVarint(w, uint64(1+len(e.EncryptedPaymentID)))
+		w.WriteByte(nonceEncryptedPaymentID)
+		w.Write(e.EncryptedPaymentID)
+	}
+	if len(e.AdditionalPublicKeys) > 0 {
+		w.WriteByte(extraAdditionalKeys)
+		writeVarint(w, uint64(len(e.AdditionalPublicKeys)))
+		writeKeys(w, e.AdditionalPublicKeys...)
+	}
+	return w.Bytes()
+}
+
+// EncryptPaymentID encrypts, or decrypts, an 8 byte payment ID with the
+// key derivation of the transaction public key and a view key.
+func EncryptPaymentID(paymentID []byte, derivation crypto.Key) []byte {
+	mask := crypto.Keccak256(derivation[:], []byte{encryptedPaymentIDSuffix})
+	out := make([]byte, len(paymentID))
+	for i := range paymentID {
+		out[i] = paymentID[i] ^ mask[i]
+	}
+	return out
+}
@@ -0,0 +1,683 @@
+// Package transaction reads, writes, builds, signs and verifies Monero
+// transactions without a wallet.
+//
+// Transactions are in the wire format of monerod: the blob of
+// daemon.SendRawTransaction and of the as_hex of daemon.GetTransactions.
+package transaction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/konraddical2/gonero/crypto"
+)
+
+// RingCT signature types
+const (
+	RCTTypeNull            = 0
+	RCTTypeFull            = 1
+	RCTTypeSimple          = 2
+	RCTTypeBulletproof     = 3
+	RCTTypeBulletproof2    = 4
+	RCTTypeCLSAG           = 5
+	RCTTypeBulletproofPlus = 6
+)
+
+// variant tags of inputs and outputs
+const (
+	tagInputGen         = 0xff
+	tagInputToKey       = 0x02
+	tagOutputToKey      = 0x02
+	tagOutputTaggedKey  = 0x03
+	maxRingSize         = 1024
+	maxInputsAndOutputs = 1 << 16
+)
+
+// Transaction errors
+var (
+	ErrMalformed   = errors.New("transaction: malformed transaction")
+	ErrUnsupported = errors.New("transaction: unsupported transaction")
+)
+
+// Transaction is a Monero transaction
+type Transaction struct {
+	// Version, 2 for RingCT transactions.
+	Version uint64 `json:"version"`
+	// Block height or timestamp before which the outputs cannot be spent.
+	UnlockTime uint64 `json:"unlock_time"`
+	// Inputs of the transaction.
+	Inputs []Input `json:"vin"`
+	// Outputs of the transaction.
+	Outputs []Output `json:"vout"`
+	// Extra field, holding the transaction public keys.
+	Extra []byte `json:"extra"`
+	// RingCT signatures, of version 2 transactions.
+	RctSignatures *RctSignatures `json:"rct_signatures,omitempty"`
+}
+
+// Input is an input of a transaction
+type Input struct {
+	// States if the input is the coinbase input of a miner transaction.
+	Coinbase bool `json:"coinbase,omitempty"`
+	// Height of the block of a coinbase input.
+	Height uint64 `json:"height,omitempty"`
+	// Amount of the input, 0 for RingCT inputs.
+	Amount uint64 `json:"amount"`
+	// Global indices of the ring members, each relative to the previous one.
+	KeyOffsets []uint64 `json:"key_offsets"`
+	// Key image of the spent output.
+	KeyImage crypto.Key `json:"k_image"`
+}
+
+// Ring returns the absolute global indices of the ring members.
+func (in *Input) Ring() []uint64 {
+	ring := make([]uint64, len(in.KeyOffsets))
+	var index uint64
+	for i, o := range in.KeyOffsets {
+		index += o
+		ring[i] = index
+	}
+	return ring
+}
+
+// KeyOffsets returns the relative offsets of sorted global indices.
+func KeyOffsets(ring []uint64) []uint64 {
+	offsets := make([]uint64, len(ring))
+	var prev uint64
+	for i, index := range ring {
+		offsets[i] = index - prev
+		prev = index
+	}
+	return offsets
+}
+
+// Output is an output of a transaction
+type Output struct {
+	// Amount of the output, 0 for RingCT outputs.
+	Amount uint64 `json:"amount"`
+	// One-time public key of the output.
+	Key crypto.Key `json:"key"`
+	// States if the output has a view tag, since v0.18.
+	HasViewTag bool `json:"has_view_tag,omitempty"`
+	// View tag of the output.
+	ViewTag byte `json:"view_tag,omitempty"`
+}
+
+// EcdhInfo is the encrypted amount of an output
+type EcdhInfo struct {
+	// Encrypted mask, of types before RCTTypeBulletproof2.
+	Mask crypto.Key `json:"mask"`
+	// Encrypted amount. Since RCTTypeBulletproof2, only its first 8 bytes
+	// are used.
+	Amount crypto.Key `json:"amount"`
+}
+
+// RctSignatures are the RingCT signatures of a transaction
+type RctSignatures struct {
+	// RingCT type.
+	Type byte `json:"type"`
+	// Fee of the transaction, in atomic units.
+	Fee uint64 `json:"txnFee"`
+	// Encrypted amounts of the outputs.
+	EcdhInfo []EcdhInfo `json:"ecdhInfo"`
+	// Commitments of the outputs.
+	OutPk []crypto.Key `json:"outPk"`
+	// Commitments of the inputs, since RCTTypeSimple.
+	PseudoOuts []crypto.Key `json:"pseudoOuts"`
+	// Range proofs of the outputs, before RCTTypeBulletproof.
+	RangeSigs []RangeSig `json:"rangeSigs,omitempty"`
+	// Range proofs of the outputs, from RCTTypeBulletproof to RCTTypeCLSAG.
+	Bulletproofs []crypto.Bulletproof `json:"bp,omitempty"`
+	// Range proofs of the outputs, since RCTTypeBulletproofPlus.
+	BulletproofsPlus []crypto.BulletproofPlus `json:"bpp,omitempty"`
+	// Ring signatures of the inputs, before RCTTypeCLSAG.
+	MLSAGs []crypto.MLSAG `json:"MGs,omitempty"`
+	// Ring signatures of the inputs, since RCTTypeCLSAG.
+	CLSAGs []crypto.CLSAG `json:"CLSAGs,omitempty"`
+}
+
+// RangeSig is the Borromean range proof of an output, before
+// RCTTypeBulletproof
+type RangeSig struct {
+	S0 [64]crypto.Key `json:"s0"`
+	S1 [64]crypto.Key `json:"s1"`
+	EE crypto.Key     `json:"ee"`
+	// Commitments to the bits of the amount.
+	Ci [64]crypto.Key `json:"Ci"`
+}
+
+// compactEcdh tells whether the encrypted amounts are 8 bytes, without mask
+func (rct *RctSignatures) compactEcdh() bool {
+	return rct.Type >= RCTTypeBulletproof2
+}
+
+// ParseHex parses a hex encoded transaction.
+func ParseHex(s string) (*Transaction, error) {
+	blob, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return Parse(blob)
+}
+
+// Parse parses a transaction blob.
+func Parse(blob []byte) (*Transaction, error) {
+	r := &reader{buf: blob}
+	tx := &Transaction{}
+	tx.readPrefix(r)
+	if r.err == nil && tx.Version >= 2 {
+		tx.RctSignatures = readRctSignatures(r, tx)
+	} else if r.err == nil && !tx.coinbase() {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupported, tx.Version)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(blob) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrMalformed, len(blob)-r.pos)
+	}
+	return tx, nil
+}
+
+// coinbase tells whether the transaction is a miner transaction
+func (tx *Transaction) coinbase() bool {
+	return len(tx.Inputs) == 1 && tx.Inputs[0].Coinbase
+}
+
+func (tx *Transaction) readPrefix(r *reader) {
+	tx.Version = r.varint()
+	tx.UnlockTime = r.varint()
+	tx.Inputs = make([]Input, r.count(maxInputsAndOutputs))
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		switch tag := r.byte(); tag {
+		case tagInputGen:
+			in.Coinbase = true
+			in.Height = r.varint()
+		case tagInputToKey:
+			in.Amount = r.varint()
+			in.KeyOffsets = make([]uint64, r.count(maxRingSize))
+			for j := range in.KeyOffsets {
+				in.KeyOffsets[j] = r.varint()
+			}
+			in.KeyImage = r.key()
+		default:
+			r.fail(fmt.Errorf("%w: input tag %#x", ErrUnsupported, tag))
+			return
+		}
+	}
+	tx.Outputs = make([]Output, r.count(maxInputsAndOutputs))
+	for i := range tx.Outputs {
+		out := &tx.Outputs[i]
+		out.Amount = r.varint()
+		switch tag := r.byte(); tag {
+		case tagOutputToKey:
+			out.Key = r.key()
+		case tagOutputTaggedKey:
+			out.Key = r.key()
+			out.HasViewTag = true
+			out.ViewTag = r.byte()
+		default:
+			r.fail(fmt.Errorf("%w: output tag %#x", ErrUnsupported, tag))
+			return
+		}
+	}
+	tx.Extra = r.bytes(r.count(len(r.buf)))
+}
+
+func readRctSignatures(r *reader, tx *Transaction) *RctSignatures {
+	rct := &RctSignatures{Type: r.byte()}
+	if rct.Type == RCTTypeNull || r.err != nil {
+		return rct
+	}
+	if rct.Type > RCTTypeBulletproofPlus {
+		r.fail(fmt.Errorf("%w: RingCT type %d", ErrUnsupported, rct.Type))
+		return nil
+	}
+	inputs, outputs := len(tx.Inputs), len(tx.Outputs)
+	if inputs == 0 {
+		r.fail(fmt.Errorf("%w: no inputs", ErrMalformed))
+		return nil
+	}
+	rct.Fee = r.varint()
+	if rct.Type == RCTTypeSimple {
+		rct.PseudoOuts = r.keys(inputs)
+	}
+	rct.EcdhInfo = make([]EcdhInfo, outputs)
+	for i := range rct.EcdhInfo {
+		if rct.compactEcdh() {
+			copy(rct.EcdhInfo[i].Amount[:8], r.bytes(8))
+			continue
+		}
+		rct.EcdhInfo[i].Mask = r.key()
+		rct.EcdhInfo[i].Amount = r.key()
+	}
+	rct.OutPk = r.keys(outputs)
+
+	// prunable part
+	switch rct.Type {
+	case RCTTypeFull, RCTTypeSimple:
+		rct.RangeSigs = make([]RangeSig, outputs)
+		for i := range rct.RangeSigs {
+			sig := &rct.RangeSigs[i]
+			copy(sig.S0[:], r.keys(64))
+			copy(sig.S1[:], r.keys(64))
+			sig.EE = r.key()
+			copy(sig.Ci[:], r.keys(64))
+		}
+	case RCTTypeBulletproofPlus:
+		rct.BulletproofsPlus = make([]crypto.BulletproofPlus, r.count(maxInputsAndOutputs))
+		for i := range rct.BulletproofsPlus {
+			p := &rct.BulletproofsPlus[i]
+			p.A, p.A1, p.B = r.key(), r.key(), r.key()
+			p.R1, p.S1, p.D1 = r.key(), r.key(), r.key()
+			p.L = r.keys(r.count(64))
+			p.R = r.keys(r.count(64))
+		}
+	default:
+		var n int
+		if rct.Type == RCTTypeBulletproof {
+			n = int(binary.LittleEndian.Uint32(r.bytes(4)))
+			if n > outputs {
+				r.fail(fmt.Errorf("%w: %d range proofs", ErrMalformed, n))
+				return nil
+			}
+		} else {
+			n = r.count(maxInputsAndOutputs)
+		}
+		rct.Bulletproofs = make([]crypto.Bulletproof, n)
+		for i := range rct.Bulletproofs {
+			p := &rct.Bulletproofs[i]
+			p.A, p.S, p.T1, p.T2 = r.key(), r.key(), r.key(), r.key()
+			p.Taux, p.Mu = r.key(), r.key()
+			p.L = r.keys(r.count(64))
+			p.R = r.keys(r.count(64))
+			p.InnerA, p.InnerB, p.T = r.key(), r.key(), r.key()
+		}
+	}
+	if r.err != nil {
+		return nil
+	}
+
+	ringSize := len(tx.Inputs[0].KeyOffsets)
+	switch rct.Type {
+	case RCTTypeCLSAG, RCTTypeBulletproofPlus:
+		rct.CLSAGs = make([]crypto.CLSAG, inputs)
+		for i := range rct.CLSAGs {
+			rct.CLSAGs[i].S = r.keys(ringSize)
+			rct.CLSAGs[i].C1 = r.key()
+			rct.CLSAGs[i].D = r.key()
+		}
+	default:
+		// a single signature over all inputs for RCTTypeFull, with a
+		// column per input and one for the commitments
+		sigs, columns := inputs, 2
+		if rct.Type == RCTTypeFull {
+			sigs, columns = 1, inputs+1
+		}
+		rct.MLSAGs = make([]crypto.MLSAG, sigs)
+		for i := range rct.MLSAGs {
+			rct.MLSAGs[i].SS = make([][]crypto.Key, ringSize)
+			for j := range rct.MLSAGs[i].SS {
+				rct.MLSAGs[i].SS[j] = r.keys(columns)
+			}
+			rct.MLSAGs[i].CC = r.key()
+		}
+	}
+	if rct.Type >= RCTTypeBulletproof {
+		rct.PseudoOuts = r.keys(inputs)
+	}
+	if r.err == nil {
+		r.fail(restoreCommitments(rct))
+	}
+	return rct
+}
+
+// restoreCommitments sets the V of the range proofs from the commitments
+// of the outputs
+func restoreCommitments(rct *RctSignatures) error {
+	outPk := rct.OutPk
+	// each proof covers the next outputs, up to its padded size
+	next := func(rounds int) ([]crypto.Key, error) {
+		n := 1 << uint(rounds-6)
+		if rounds < 6 || n > len(outPk) {
+			n = len(outPk)
+		}
+		v := make([]crypto.Key, n)
+		for j := range v {
+			var err error
+			if v[j], err = crypto.ScaleInvEight(outPk[j]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+			}
+		}
+		outPk = outPk[n:]
+		return v, nil
+	}
+	var err error
+	for i := range rct.Bulletproofs {
+		p := &rct.Bulletproofs[i]
+		if p.V, err = next(len(p.L)); err != nil {
+			return err
+		}
+	}
+	for i := range rct.BulletproofsPlus {
+		p := &rct.BulletproofsPlus[i]
+		if p.V, err = next(len(p.L)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytes returns the blob of the transaction.
+func (tx *Transaction) Bytes() []byte {
+	w := &bytes.Buffer{}
+	tx.writePrefix(w)
+	if tx.RctSignatures != nil {
+		tx.RctSignatures.writeBase(w)
+		tx.RctSignatures.writePrunable(w)
+	}
+	return w.Bytes()
+}
+
+// Hex returns the blob of the transaction as hex, as sent with
+// daemon.SendRawTransaction.
+func (tx *Transaction) Hex() string {
+	return hex.EncodeToString(tx.Bytes())
+}
+
+// PrefixHash returns the hash of the transaction prefix: everything but
+// the signatures.
+func (tx *Transaction) PrefixHash() crypto.Key {
+	w := &bytes.Buffer{}
+	tx.writePrefix(w)
+	return crypto.Keccak256(w.Bytes())
+}
+
+// Hash returns the hash of the transaction, its txid.
+func (tx *Transaction) Hash() crypto.Key {
+	if tx.Version < 2 || tx.RctSignatures == nil {
+		return crypto.Keccak256(tx.Bytes())
+	}
+	base := &bytes.Buffer{}
+	tx.RctSignatures.writeBase(base)
+	var prunable crypto.Key
+	if tx.RctSignatures.Type != RCTTypeNull {
+		w := &bytes.Buffer{}
+		tx.RctSignatures.writePrunable(w)
+		prunable = crypto.Keccak256(w.Bytes())
+	}
+	prefix := tx.PrefixHash()
+	baseHash := crypto.Keccak256(base.Bytes())
+	return crypto.Keccak256(prefix[:], baseHash[:], prunable[:])
+}
+
+// SignatureMessage returns the message signed by the ring signatures of
+// the inputs: the hash of the prefix, of the RingCT base and of the range
+// proofs.
+func (tx *Transaction) SignatureMessage() (crypto.Key, error) {
+	rct := tx.RctSignatures
+	if rct == nil || rct.Type == RCTTypeNull {
+		return crypto.Key{}, fmt.Errorf("%w: no RingCT signatures", ErrUnsupported)
+	}
+	base := &bytes.Buffer{}
+	rct.writeBase(base)
+	var proofs [][]byte
+	for _, k := range rct.proofKeys() {
+		k := k
+		proofs = append(proofs, k[:])
+	}
+	prefix := tx.PrefixHash()
+	baseHash := crypto.Keccak256(base.Bytes())
+	proofsHash := crypto.Keccak256(proofs...)
+	return crypto.Keccak256(prefix[:], baseHash[:], proofsHash[:]), nil
+}
+
+// proofKeys returns the keys of the range proofs
+func (rct *RctSignatures) proofKeys() []crypto.Key {
+	var keys []crypto.Key
+	for _, sig := range rct.RangeSigs {
+		keys = append(keys, sig.S0[:]...)
+		keys = append(keys, sig.S1[:]...)
+		keys = append(keys, sig.EE)
+		keys = append(keys, sig.Ci[:]...)
+	}
+	for i := range rct.Bulletproofs {
+		keys = append(keys, rct.Bulletproofs[i].Keys()...)
+	}
+	for i := range rct.BulletproofsPlus {
+		keys = append(keys, rct.BulletproofsPlus[i].Keys()...)
+	}
+	return keys
+}
+
+// Weight returns the weight of the transaction, on which its fee is
+// based: its size, plus a part of the size saved by aggregating range
+// proofs of more than 2 outputs.
+func (tx *Transaction) Weight() uint64 {
+	weight := uint64(len(tx.Bytes()))
+	rct := tx.RctSignatures
+	if rct == nil || rct.Type < RCTTypeBulletproof {
+		return weight
+	}
+	outputs, rounds := rangeProofPadding(len(tx.Outputs))
+	if outputs <= 2 {
+		return weight
+	}
+	// keys of a proof besides L and R
+	fixed := 9
+	if rct.Type == RCTTypeBulletproofPlus {
+		fixed = 6
+	}
+	// notional size of a 2 output proof, per output
+	base := uint64(32*(fixed+7*2)) / 2
+	size := uint64(32 * (fixed + 2*(rounds+6)))
+	return weight + (base*uint64(outputs)-size)*4/5
+}
+
+// rangeProofPadding returns the padded number of outputs of a range proof
+// and its log2
+func rangeProofPadding(n int) (int, int) {
+	m, log := 1, 0
+	for m < n {
+		m *= 2
+		log++
+	}
+	return m, log
+}
+
+func (tx *Transaction) writePrefix(w *bytes.Buffer) {
+	writeVarint(w, tx.Version)
+	writeVarint(w, tx.UnlockTime)
+	writeVarint(w, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		if in.Coinbase {
+			w.WriteByte(tagInputGen)
+			writeVarint(w, in.Height)
+			continue
+		}
+		w.WriteByte(tagInputToKey)
+		writeVarint(w, in.Amount)
+		writeVarint(w, uint64(len(in.KeyOffsets)))
+		for _, o := range in.KeyOffsets {
+			writeVarint(w, o)
+		}
+		w.Write(in.KeyImage[:])
+	}
+	writeVarint(w, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		writeVarint(w, out.Amount)
+		if out.HasViewTag {
+			w.WriteByte(tagOutputTaggedKey)
+			w.Write(out.Key[:])
+			w.WriteByte(out.ViewTag)
+			continue
+		}
+		w.WriteByte(tagOutputToKey)
+		w.Write(out.Key[:])
+	}
+	writeVarint(w, uint64(len(tx.Extra)))
+	w.Write(tx.Extra)
+}
+
+func (rct *RctSignatures) writeBase(w *bytes.Buffer) {
+	w.WriteByte(rct.Type)
+	if rct.Type == RCTTypeNull {
+		return
+	}
+	writeVarint(w, rct.Fee)
+	if rct.Type == RCTTypeSimple {
+		writeKeys(w, rct.PseudoOuts...)
+	}
+	for _, e := range rct.EcdhInfo {
+		if rct.compactEcdh() {
+			w.Write(e.Amount[:8])
+			continue
+		}
+		w.Write(e.Mask[:])
+		w.Write(e.Amount[:])
+	}
+	writeKeys(w, rct.OutPk...)
+}
+
+func (rct *RctSignatures) writePrunable(w *bytes.Buffer) {
+	switch rct.Type {
+	case RCTTypeNull:
+		return
+	case RCTTypeFull, RCTTypeSimple:
+		for _, sig := range rct.RangeSigs {
+			writeKeys(w, sig.S0[:]...)
+			writeKeys(w, sig.S1[:]...)
+			writeKeys(w, sig.EE)
+			writeKeys(w, sig.Ci[:]...)
+		}
+	case RCTTypeBulletproofPlus:
+		writeVarint(w, uint64(len(rct.BulletproofsPlus)))
+		for _, p := range rct.BulletproofsPlus {
+			writeKeys(w, p.A, p.A1, p.B, p.R1, p.S1, p.D1)
+			writeVarint(w, uint64(len(p.L)))
+			writeKeys(w, p.L...)
+			writeVarint(w, uint64(len(p.R)))
+			writeKeys(w, p.R...)
+		}
+	default:
+		if rct.Type == RCTTypeBulletproof {
+			var n [4]byte
+			binary.LittleEndian.PutUint32(n[:], uint32(len(rct.Bulletproofs)))
+			w.Write(n[:])
+		} else {
+			writeVarint(w, uint64(len(rct.Bulletproofs)))
+		}
+		for _, p := range rct.Bulletproofs {
+			writeKeys(w, p.A, p.S, p.T1, p.T2, p.Taux, p.Mu)
+			writeVarint(w, uint64(len(p.L)))
+			writeKeys(w, p.L...)
+			writeVarint(w, uint64(len(p.R)))
+			writeKeys(w, p.R...)
+			writeKeys(w, p.InnerA, p.InnerB, p.T)
+		}
+	}
+	for _, sig := range rct.MLSAGs {
+		for _, ss := range sig.SS {
+			writeKeys(w, ss...)
+		}
+		writeKeys(w, sig.CC)
+	}
+	for _, sig := range rct.CLSAGs {
+		writeKeys(w, sig.S...)
+		writeKeys(w, sig.C1, sig.D)
+	}
+	if rct.Type >= RCTTypeBulletproof {
+		writeKeys(w, rct.PseudoOuts...)
+	}
+}
+
+func writeKeys(w *bytes.Buffer, keys ...crypto.Key) {
+	for i := range keys {
+		w.Write(keys[i][:])
+	}
+}
+
+func writeVarint(w *bytes.Buffer, n uint64) {
+	for n >= 0x80 {
+		w.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	w.WriteByte(byte(n))
+}
+
+// reader reads a blob, keeping the first error
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n > len(r.buf)-r.pos {
+		r.fail(fmt.Errorf("%w: unexpected end", ErrMalformed))
+		return make([]byte, n)
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	return r.bytes(1)[0]
+}
+
+func (r *reader) key() crypto.Key {
+	var k crypto.Key
+	copy(k[:], r.bytes(len(k)))
+	return k
+}
+
+func (r *reader) keys(n int) []crypto.Key {
+	keys := make([]crypto.Key, n)
+	for i := range keys {
+		keys[i] = r.key()
+	}
+	return keys
+}
+
+func (r *reader) varint() uint64 {
+	var n uint64
+	for shift := uint(0); r.err == nil; shift += 7 {
+		b := r.byte()
+		if shift == 63 && b > 1 || shift > 63 {
+			r.fail(fmt.Errorf("%w: varint overflow", ErrMalformed))
+			return 0
+		}
+		n |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			if b == 0 && shift > 0 {
+				r.fail(fmt.Errorf("%w: non canonical varint", ErrMalformed))
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+// count reads a number of elements, at most max
+func (r *reader) count(max int) int {
+	n := r.varint()
+	if n > uint64(max) || n > uint64(len(r.buf)-r.pos) {
+		r.fail(fmt.Errorf("%w: %d elements", ErrMalformed, n))
+		return 0
+	}
+	return int(n)
+}
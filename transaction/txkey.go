@@ -0,0 +1,207 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// Transaction key errors
+var (
+	ErrInvalidTxKey = errors.New("transaction: invalid transaction key")
+	ErrTxNotFound   = errors.New("transaction: transaction not found")
+)
+
+// TxKeyCheck is a payment to an address proven with the key of its
+// transaction, as wallet.CheckTxKeyResponse
+type TxKeyCheck struct {
+	// Amount received by the address, in atomic units.
+	Received gonero.AtomicXMR `json:"received"`
+	// Indices of the outputs to the address in the transaction.
+	Outputs []int `json:"outputs"`
+	// States if the transaction is still in the pool.
+	InPool bool `json:"in_pool"`
+	// Number of blocks from the one with the transaction to the top of
+	// the chain, both included, 0 in the pool.
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// ParseTxKey parses a transaction key as returned by wallet.GetTxKey: the
+// secret key r, followed by the additional keys of transactions to
+// subaddresses.
+func ParseTxKey(txKey gonero.Secret) (crypto.SecretKey, []crypto.SecretKey, error) {
+	s := txKey.Reveal()
+	if len(s) == 0 || len(s)%64 != 0 {
+		return crypto.SecretKey{}, nil, fmt.Errorf("%w: %d characters", ErrInvalidTxKey, len(s))
+	}
+	keys := make([]crypto.SecretKey, len(s)/64)
+	for i := range keys {
+		var err error
+		if keys[i], err = crypto.ParseSecretKey(s[i*64 : (i+1)*64]); err != nil {
+			return crypto.SecretKey{}, nil, fmt.Errorf("%w: %v", ErrInvalidTxKey, err)
+		}
+	}
+	return keys[0], keys[1:], nil
+}
+
+// CheckTxKey checks the payment of the transaction txid to the address, a
+// standard address, subaddress or integrated address, with the key of the
+// transaction and its additional keys, reading the transaction and the
+// height of the chain from client. Unlike wallet.CheckTxKey, it needs no
+// wallet.
+func CheckTxKey(client daemon.Client, txid string, txKey crypto.SecretKey, additional []crypto.SecretKey, address string) (*TxKeyCheck, error) {
+	resp, err := client.GetTransactions(&daemon.GetTransactionsRequest{TxsHashes: []string{txid}})
+	if err != nil {
+		return nil, fmt.Errorf("transaction: get transactions: %w", err)
+	}
+	if len(resp.Txs) != 1 {
+		return nil, fmt.Errorf("%w: %s", ErrTxNotFound, txid)
+	}
+	tx, err := ParseHex(resp.Txs[0].AsHex)
+	if err != nil {
+		return nil, err
+	}
+	if hash := tx.Hash(); hash.String() != txid {
+		return nil, fmt.Errorf("%w: transaction %s for %s", ErrUnexpected, hash, txid)
+	}
+	check, err := tx.CheckTxKey(txKey, additional, address)
+	if err != nil {
+		return nil, err
+	}
+	check.InPool = resp.Txs[0].InPool
+	if check.InPool {
+		return check, nil
+	}
+	height, err := client.GetHeight(&daemon.GetHeightRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("transaction: get height: %w", err)
+	}
+	if height.Height > resp.Txs[0].BlockHeight {
+		check.Confirmations = height.Height - resp.Txs[0].BlockHeight
+	}
+	return check, nil
+}
+
+// CheckTxKey returns the outputs of the transaction to the address, a
+// standard address, subaddress or integrated address, found with the key
+// of the transaction and its additional keys, and their amounts.
+func (tx *Transaction) CheckTxKey(txKey crypto.SecretKey, additional []crypto.SecretKey, address string) (*TxKeyCheck, error) {
+	_, view, err := addressKeys(address)
+	if err != nil {
+		return nil, err
+	}
+	derivations := make([]crypto.Key, len(additional)+1)
+	for i, k := range append([]crypto.SecretKey{txKey}, additional...) {
+		if derivations[i], err = crypto.KeyDerivation(view, k); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTxKey, err)
+		}
+	}
+	return tx.CheckDerivations(derivations[0], derivations[1:], address)
+}
+
+// CheckDerivations returns the outputs of the transaction to the address,
+// found with the derivation of the transaction and its additional
+// derivations, and their amounts. Outputs whose amount does not match
+// their commitment are not counted.
+func (tx *Transaction) CheckDerivations(derivation crypto.Key, additional []crypto.Key, address string) (*TxKeyCheck, error) {
+	spend, _, err := addressKeys(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(additional) != 0 && len(additional) != len(tx.Outputs) {
+		return nil, fmt.Errorf("%w: %d additional keys for %d outputs", ErrInvalidTxKey, len(additional), len(tx.Outputs))
+	}
+	check := &TxKeyCheck{}
+	for i, out := range tx.Outputs {
+		// the output is derived from the main key, or its additional key
+		candidates := []crypto.Key{derivation}
+		if len(additional) != 0 {
+			candidates = append(candidates, additional[i])
+		}
+		for _, d := range candidates {
+			key, err := crypto.DerivePublicKey(d, uint64(i), spend)
+			if err != nil {
+				return nil, err
+			}
+			if key != out.Key {
+				continue
+			}
+			if amount, ok := tx.OutputAmount(i, d); ok {
+				check.Received += gonero.AtomicXMR(amount)
+				check.Outputs = append(check.Outputs, i)
+			}
+			break
+		}
+	}
+	return check, nil
+}
+
+// OutputAmount decrypts the amount of the output at index with the
+// derivation it was received with, and tells if it matches its commitment.
+func (tx *Transaction) OutputAmount(index int, derivation crypto.Key) (uint64, bool) {
+	if index < 0 || index >= len(tx.Outputs) {
+		return 0, false
+	}
+	rct := tx.RctSignatures
+	if rct == nil || rct.Type == RCTTypeNull {
+		return tx.Outputs[index].Amount, true
+	}
+	shared := crypto.DerivationToScalar(derivation, uint64(index))
+	ecdh := rct.EcdhInfo[index]
+	var amount uint64
+	var mask crypto.SecretKey
+	if rct.compactEcdh() {
+		var encrypted [8]byte
+		copy(encrypted[:], ecdh.Amount[:8])
+		amount = crypto.DecodeAmount(encrypted, shared)
+		mask = crypto.CommitmentMask(shared)
+	} else {
+		// the mask and the amount are masked by Hs(shared) and Hs(Hs(shared))
+		h := crypto.HashToScalar(shared[:])
+		hh := crypto.HashToScalar(h[:])
+		var err error
+		if mask, err = crypto.SubSecretKeys(crypto.SecretKey(ecdh.Mask), crypto.SecretKey(h)); err != nil {
+			return 0, false
+		}
+		a, err := crypto.SubSecretKeys(crypto.SecretKey(ecdh.Amount), crypto.SecretKey(hh))
+		if err != nil {
+			return 0, false
+		}
+		for _, b := range a[8:] {
+			if b != 0 {
+				return 0, false
+			}
+		}
+		amount = binary.LittleEndian.Uint64(a[:8])
+	}
+	commitment, err := crypto.Commit(amount, mask)
+	if err != nil || commitment != rct.OutPk[index] {
+		return 0, false
+	}
+	return amount, true
+}
+
+// addressKeys returns the public spend and view keys of an address
+func addressKeys(address string) (spend, view crypto.Key, err error) {
+	var s, v string
+	if a := gonero.NewAddress(address); a != nil {
+		s, v = a.SpendKey(), a.ViewKey()
+	} else if a := gonero.NewSubAddress(address); a != nil {
+		s, v = a.SpendKey(), a.ViewKey()
+	} else if a := gonero.NewIntegratedAddress(address); a != nil {
+		s, v = a.SpendKey(), a.ViewKey()
+	} else {
+		return spend, view, fmt.Errorf("%w: %s", ErrInvalidDest, address)
+	}
+	if spend, err = crypto.ParseKey(s); err != nil {
+		return spend, view, fmt.Errorf("%w: %v", ErrInvalidDest, err)
+	}
+	if view, err = crypto.ParseKey(v); err != nil {
+		return spend, view, fmt.Errorf("%w: %v", ErrInvalidDest, err)
+	}
+	return spend, view, nil
+}
@@ -0,0 +1,315 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/konraddical2/gonero/crypto"
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// Verification errors
+var (
+	ErrInvalid     = errors.New("transaction: invalid transaction")
+	ErrDoubleSpend = errors.New("transaction: key image already spent")
+	ErrUnexpected  = errors.New("transaction: unexpected daemon response")
+)
+
+// hard-fork versions changing the rules of transactions
+const (
+	hfBulletproofs     = 8
+	hfSmallerBP        = 10
+	hfMin2Outputs      = 12
+	hfCLSAG            = 13
+	hfBulletproofsPlus = 15
+	hfViewTags         = 15
+
+	// maxTxWeight is half the full reward zone, minus the reserved size
+	// of the miner transaction
+	maxTxWeight = 300000/2 - 600
+	// maxTxExtraSize is the largest extra field relayed by the daemons
+	maxTxExtraSize = 1060
+	// maxOutputs is the most outputs of a range proof
+	maxOutputs = 16
+)
+
+// Rules are the rules of a hard-fork version checked by Verify
+type Rules struct {
+	// Hard-fork version.
+	Version int
+	// Members of each ring, any if 0.
+	RingSize int
+	// Outputs of each transaction.
+	MinOutputs, MaxOutputs int
+	// RingCT types allowed.
+	Types []byte
+	// States if outputs may be tagged with a view tag, or not.
+	TaggedOutputs, UntaggedOutputs bool
+	// Weight of each transaction, any if 0.
+	MaxWeight uint64
+	// Size of the extra field, any if 0. It is a relay rule rather than a
+	// consensus rule.
+	MaxExtraSize int
+}
+
+// RulesFor returns the rules of the hard-fork version, from the version
+// enforcing Bulletproofs.
+func RulesFor(version int) (Rules, error) {
+	if version < hfBulletproofs {
+		return Rules{}, fmt.Errorf("%w: hard-fork version %d", ErrUnsupported, version)
+	}
+	rules := Rules{
+		Version:         version,
+		RingSize:        11,
+		MinOutputs:      1,
+		MaxOutputs:      maxOutputs,
+		UntaggedOutputs: version <= hfViewTags,
+		TaggedOutputs:   version >= hfViewTags,
+		MaxWeight:       maxTxWeight,
+		MaxExtraSize:    maxTxExtraSize,
+	}
+	if version >= hfBulletproofsPlus {
+		rules.RingSize = 16
+	}
+	if version >= hfMin2Outputs {
+		rules.MinOutputs = 2
+	}
+	// each type is allowed from its fork, and alone after it
+	switch {
+	case version < hfSmallerBP:
+		rules.Types = []byte{RCTTypeBulletproof}
+	case version == hfSmallerBP:
+		rules.Types = []byte{RCTTypeBulletproof, RCTTypeBulletproof2}
+	case version < hfCLSAG:
+		rules.Types = []byte{RCTTypeBulletproof2}
+	case version == hfCLSAG:
+		rules.Types = []byte{RCTTypeBulletproof2, RCTTypeCLSAG}
+	case version < hfBulletproofsPlus:
+		rules.Types = []byte{RCTTypeCLSAG}
+	case version == hfBulletproofsPlus:
+		rules.Types = []byte{RCTTypeCLSAG, RCTTypeBulletproofPlus}
+	default:
+		rules.Types = []byte{RCTTypeBulletproofPlus}
+	}
+	return rules, nil
+}
+
+// Verify verifies a RingCT transaction spending from rings, the ring
+// members of each input in the order of its key offsets: the rules, the
+// key images, the range proofs, the balance of the commitments with the
+// fee and the ring signatures. Whether the ring members are spendable and
+// the key images unspent is left to the caller, as Verifier does.
+func Verify(tx *Transaction, rings [][]crypto.RingMember, rules Rules) error {
+	if err := checkRules(tx, rules); err != nil {
+		return err
+	}
+	rct := tx.RctSignatures
+	if len(rings) != len(tx.Inputs) {
+		return fmt.Errorf("%w: %d rings for %d inputs", ErrInvalid, len(rings), len(tx.Inputs))
+	}
+	for i, in := range tx.Inputs {
+		if len(rings[i]) != len(in.KeyOffsets) {
+			return fmt.Errorf("%w: %d ring members for %d key offsets of input %d", ErrInvalid, len(rings[i]), len(in.KeyOffsets), i)
+		}
+	}
+
+	var commitments int
+	for i := range rct.Bulletproofs {
+		commitments += len(rct.Bulletproofs[i].V)
+		if err := crypto.VerifyBulletproof(&rct.Bulletproofs[i]); err != nil {
+			return fmt.Errorf("%w: range proof %d: %v", ErrInvalid, i, err)
+		}
+	}
+	for i := range rct.BulletproofsPlus {
+		commitments += len(rct.BulletproofsPlus[i].V)
+		if err := crypto.VerifyBulletproofPlus(&rct.BulletproofsPlus[i]); err != nil {
+			return fmt.Errorf("%w: range proof %d: %v", ErrInvalid, i, err)
+		}
+	}
+	if commitments != len(tx.Outputs) {
+		return fmt.Errorf("%w: range proofs of %d outputs for %d outputs", ErrInvalid, commitments, len(tx.Outputs))
+	}
+	if err := crypto.CheckBalance(rct.PseudoOuts, rct.OutPk, rct.Fee); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	message, err := tx.SignatureMessage()
+	if err != nil {
+		return err
+	}
+	for i, in := range tx.Inputs {
+		if rct.Type >= RCTTypeCLSAG {
+			err = crypto.VerifyCLSAG(message, rings[i], rct.PseudoOuts[i], in.KeyImage, &rct.CLSAGs[i])
+		} else {
+			err = crypto.VerifyMLSAG(message, rings[i], rct.PseudoOuts[i], in.KeyImage, &rct.MLSAGs[i])
+		}
+		if err != nil {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalid, i, err)
+		}
+	}
+	return nil
+}
+
+// checkRules checks the transaction against the rules, without the rings
+func checkRules(tx *Transaction, rules Rules) error {
+	rct := tx.RctSignatures
+	if tx.Version != 2 || rct == nil {
+		return fmt.Errorf("%w: version %d transaction", ErrUnsupported, tx.Version)
+	}
+	if !containsType(rules.Types, rct.Type) {
+		return fmt.Errorf("%w: RingCT type %d at hard-fork version %d", ErrInvalid, rct.Type, rules.Version)
+	}
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("%w: no inputs", ErrInvalid)
+	}
+	if len(tx.Outputs) < rules.MinOutputs || len(tx.Outputs) > rules.MaxOutputs {
+		return fmt.Errorf("%w: %d outputs", ErrInvalid, len(tx.Outputs))
+	}
+	if rules.MaxWeight != 0 && tx.Weight() > rules.MaxWeight {
+		return fmt.Errorf("%w: weight %d", ErrInvalid, tx.Weight())
+	}
+	if rules.MaxExtraSize != 0 && len(tx.Extra) > rules.MaxExtraSize {
+		return fmt.Errorf("%w: %d bytes of extra", ErrInvalid, len(tx.Extra))
+	}
+
+	for i, in := range tx.Inputs {
+		if in.Coinbase || in.Amount != 0 {
+			return fmt.Errorf("%w: input %d is not a RingCT input", ErrInvalid, i)
+		}
+		if rules.RingSize != 0 && len(in.KeyOffsets) != rules.RingSize {
+			return fmt.Errorf("%w: ring size %d of input %d", ErrInvalid, len(in.KeyOffsets), i)
+		}
+		for j, offset := range in.KeyOffsets {
+			if j > 0 && offset == 0 {
+				return fmt.Errorf("%w: duplicate ring member of input %d", ErrInvalid, i)
+			}
+		}
+		// key images are unique, sorted in descending order
+		if i > 0 && bytes.Compare(tx.Inputs[i-1].KeyImage[:], in.KeyImage[:]) <= 0 {
+			return fmt.Errorf("%w: key images not sorted or duplicate", ErrInvalid)
+		}
+	}
+	for i, out := range tx.Outputs {
+		if out.Amount != 0 {
+			return fmt.Errorf("%w: output %d has an amount", ErrInvalid, i)
+		}
+		if out.HasViewTag && !rules.TaggedOutputs || !out.HasViewTag && !rules.UntaggedOutputs {
+			return fmt.Errorf("%w: view tag of output %d at hard-fork version %d", ErrInvalid, i, rules.Version)
+		}
+	}
+	return nil
+}
+
+func containsType(types []byte, t byte) bool {
+	for _, allowed := range types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier verifies transactions against the chain of a daemon, before
+// they are relayed with daemon.SendRawTransaction
+type Verifier struct {
+	client daemon.Client
+}
+
+// NewVerifier returns a Verifier reading rings, key images and the
+// hard-fork version from client.
+func NewVerifier(client daemon.Client) *Verifier {
+	return &Verifier{client: client}
+}
+
+// Verify verifies the transaction with Verify, under the rules of the
+// current hard-fork version of the daemon, with rings of spendable outputs
+// and key images spent neither in the chain nor in the pool.
+func (v *Verifier) Verify(tx *Transaction) error {
+	info, err := v.client.HardForkInfo(&daemon.HardForkInfoRequest{})
+	if err != nil {
+		return fmt.Errorf("transaction: hard fork info: %w", err)
+	}
+	rules, err := RulesFor(int(info.Version))
+	if err != nil {
+		return err
+	}
+	if err := checkRules(tx, rules); err != nil {
+		return err
+	}
+	if err := v.unspent(tx); err != nil {
+		return err
+	}
+	rings, err := v.rings(tx)
+	if err != nil {
+		return err
+	}
+	return Verify(tx, rings, rules)
+}
+
+// VerifyHex verifies the hex encoded blob of a transaction with Verify.
+func (v *Verifier) VerifyHex(blob string) error {
+	tx, err := ParseHex(blob)
+	if err != nil {
+		return err
+	}
+	return v.Verify(tx)
+}
+
+// unspent checks that the key images of the transaction are unspent
+func (v *Verifier) unspent(tx *Transaction) error {
+	req := &daemon.IsKeyImageSpentRequest{KeyImages: make([]string, len(tx.Inputs))}
+	for i, in := range tx.Inputs {
+		req.KeyImages[i] = in.KeyImage.String()
+	}
+	resp, err := v.client.IsKeyImageSpent(req)
+	if err != nil {
+		return fmt.Errorf("transaction: is key image spent: %w", err)
+	}
+	if len(resp.SpentStatus) != len(req.KeyImages) {
+		return fmt.Errorf("%w: %d statuses for %d key images", ErrUnexpected, len(resp.SpentStatus), len(req.KeyImages))
+	}
+	for i, status := range resp.SpentStatus {
+		// 1 when spent in the chain, 2 in the pool
+		if status != 0 {
+			return fmt.Errorf("%w: %s", ErrDoubleSpend, req.KeyImages[i])
+		}
+	}
+	return nil
+}
+
+// rings returns the ring members of the inputs, which must be spendable
+func (v *Verifier) rings(tx *Transaction) ([][]crypto.RingMember, error) {
+	req := &daemon.GetOutsRequest{}
+	for _, in := range tx.Inputs {
+		for _, index := range in.Ring() {
+			req.Outputs = append(req.Outputs, daemon.Outputs{Index: index})
+		}
+	}
+	resp, err := v.client.GetOuts(req)
+	if err != nil {
+		return nil, fmt.Errorf("transaction: get outs: %w", err)
+	}
+	if len(resp.Outs) != len(req.Outputs) {
+		return nil, fmt.Errorf("%w: %d outputs for %d indices", ErrUnexpected, len(resp.Outs), len(req.Outputs))
+	}
+	rings := make([][]crypto.RingMember, len(tx.Inputs))
+	outs := resp.Outs
+	for i, in := range tx.Inputs {
+		rings[i] = make([]crypto.RingMember, len(in.KeyOffsets))
+		for j := range rings[i] {
+			index := req.Outputs[len(req.Outputs)-len(outs)].Index
+			if !outs[0].Unlocked {
+				return nil, fmt.Errorf("%w: ring member %d of input %d is locked", ErrInvalid, index, i)
+			}
+			if rings[i][j].Dest, err = crypto.ParseKey(outs[0].Key); err != nil {
+				return nil, fmt.Errorf("%w: output %d: %v", ErrUnexpected, index, err)
+			}
+			if rings[i][j].Mask, err = crypto.ParseKey(outs[0].Mask); err != nil {
+				return nil, fmt.Errorf("%w: output %d: %v", ErrUnexpected, index, err)
+			}
+			outs = outs[1:]
+		}
+	}
+	return rings, nil
+}
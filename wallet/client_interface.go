@@ -163,6 +163,32 @@ type Client interface {
 	SubmitMultisig(*SubmitMultisigRequest) (*SubmitMultisigResponse, error)
 	// Get RPC version Major & Minor integer-format, where Major is the first 16 bits and Minor the last 16 bits.
 	GetVersion(*GetVersionRequest) (*GetVersionResponse, error)
+	// Freeze an output so that it is not spent.
+	Freeze(*FreezeRequest) (*FreezeResponse, error)
+	// Thaw a frozen output so that it can be spent again.
+	Thaw(*ThawRequest) (*ThawResponse, error)
+	// Check if an output is frozen.
+	Frozen(*FrozenRequest) (*FrozenResponse, error)
+	// Estimate the size and weight of a transaction.
+	EstimateTxSizeAndWeight(*EstimateTxSizeAndWeightRequest) (*EstimateTxSizeAndWeightResponse, error)
+	// Get the priority used when a transfer is sent with the default priority.
+	GetDefaultFeePriority(*GetDefaultFeePriorityRequest) (*GetDefaultFeePriorityResponse, error)
+	// Set the wallet log level.
+	SetLogLevel(*SetLogLevelRequest) (*SetLogLevelResponse, error)
+	// Set the wallet log categories.
+	SetLogCategories(*SetLogCategoriesRequest) (*SetLogCategoriesResponse, error)
+	// Scan the given transactions, e.g. to pick up transfers missed because of the subaddress lookahead.
+	ScanTx(*ScanTxRequest) (*ScanTxResponse, error)
+	// Run a round of the multisig key exchange. Repeat with the peers' multisig info until the wallet is ready.
+	ExchangeMultisigKeys(*ExchangeMultisigKeysRequest) (*ExchangeMultisigKeysResponse, error)
+	// Set the number of accounts and subaddresses to look ahead when scanning.
+	SetSubaddressLookahead(*SetSubaddressLookaheadRequest) (*SetSubaddressLookaheadResponse, error)
+	// Set up background sync, which lets the wallet sync without the spend key loaded.
+	SetupBackgroundSync(*SetupBackgroundSyncRequest) (*SetupBackgroundSyncResponse, error)
+	// Switch the wallet to background sync, wiping the spend key from memory.
+	StartBackgroundSync(*StartBackgroundSyncRequest) (*StartBackgroundSyncResponse, error)
+	// Leave background sync and process the transfers it found.
+	StopBackgroundSync(*StopBackgroundSyncRequest) (*StopBackgroundSyncResponse, error)
 
 	// Other RPC Methods
 
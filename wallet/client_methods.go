@@ -693,6 +693,7 @@ func (c *client) ImportMultisigInfo(req *ImportMultisigInfoRequest) (resp *Impor
 }
 
 // FinalizeMultisig Turn this wallet into a multisig wallet, extra step for N-1/N wallets.
+// Deprecated: current wallets finish the key exchange with ExchangeMultisigKeys.
 func (c *client) FinalizeMultisig(req *FinalizeMultisigRequest) (resp *FinalizeMultisigResponse, err error) {
 	err = c.do("finalize_multisig", &req, &resp)
 	if err != nil {
@@ -728,6 +729,123 @@ func (c *client) GetVersion(req *GetVersionRequest) (resp *GetVersionResponse, e
 	return
 }
 
+// Freeze Freeze an output so that it is not spent.
+func (c *client) Freeze(req *FreezeRequest) (resp *FreezeResponse, err error) {
+	err = c.do("freeze", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Thaw Thaw a frozen output so that it can be spent again.
+func (c *client) Thaw(req *ThawRequest) (resp *ThawResponse, err error) {
+	err = c.do("thaw", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Frozen Check if an output is frozen.
+func (c *client) Frozen(req *FrozenRequest) (resp *FrozenResponse, err error) {
+	err = c.do("frozen", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// EstimateTxSizeAndWeight Estimate the size and weight of a transaction.
+func (c *client) EstimateTxSizeAndWeight(req *EstimateTxSizeAndWeightRequest) (resp *EstimateTxSizeAndWeightResponse, err error) {
+	err = c.do("estimate_tx_size_and_weight", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetDefaultFeePriority Get the priority used when a transfer is sent with the default priority.
+func (c *client) GetDefaultFeePriority(req *GetDefaultFeePriorityRequest) (resp *GetDefaultFeePriorityResponse, err error) {
+	err = c.do("get_default_fee_priority", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetLogLevel Set the wallet log level.
+func (c *client) SetLogLevel(req *SetLogLevelRequest) (resp *SetLogLevelResponse, err error) {
+	err = c.do("set_log_level", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetLogCategories Set the wallet log categories.
+func (c *client) SetLogCategories(req *SetLogCategoriesRequest) (resp *SetLogCategoriesResponse, err error) {
+	err = c.do("set_log_categories", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ScanTx Scan the given transactions, e.g. to pick up transfers missed because of the subaddress lookahead.
+func (c *client) ScanTx(req *ScanTxRequest) (resp *ScanTxResponse, err error) {
+	err = c.do("scan_tx", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ExchangeMultisigKeys Run a round of the multisig key exchange. Repeat with the peers' multisig info until the wallet is ready.
+func (c *client) ExchangeMultisigKeys(req *ExchangeMultisigKeysRequest) (resp *ExchangeMultisigKeysResponse, err error) {
+	err = c.do("exchange_multisig_keys", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetSubaddressLookahead Set the number of accounts and subaddresses to look ahead when scanning.
+func (c *client) SetSubaddressLookahead(req *SetSubaddressLookaheadRequest) (resp *SetSubaddressLookaheadResponse, err error) {
+	err = c.do("set_subaddress_lookahead", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetupBackgroundSync Set up background sync, which lets the wallet sync without the spend key loaded.
+func (c *client) SetupBackgroundSync(req *SetupBackgroundSyncRequest) (resp *SetupBackgroundSyncResponse, err error) {
+	err = c.do("setup_background_sync", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// StartBackgroundSync Switch the wallet to background sync, wiping the spend key from memory.
+func (c *client) StartBackgroundSync(req *StartBackgroundSyncRequest) (resp *StartBackgroundSyncResponse, err error) {
+	err = c.do("start_background_sync", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// StopBackgroundSync Leave background sync and process the transfers it found.
+func (c *client) StopBackgroundSync(req *StopBackgroundSyncRequest) (resp *StopBackgroundSyncResponse, err error) {
+	err = c.do("stop_background_sync", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
 //#####################
 // Other RPC Methods
 //#####################
@@ -1,9 +1,15 @@
 package wallet
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/konraddical2/gonero"
@@ -110,6 +116,19 @@ func TestClientMethods(t *testing.T) {
 		{method: "SignMultisig", request: &SignMultisigRequest{}},
 		{method: "SubmitMultisig", request: &SubmitMultisigRequest{}},
 		{method: "GetVersion", request: &GetVersionRequest{}},
+		{method: "Freeze", request: &FreezeRequest{}},
+		{method: "Thaw", request: &ThawRequest{}},
+		{method: "Frozen", request: &FrozenRequest{}},
+		{method: "EstimateTxSizeAndWeight", request: &EstimateTxSizeAndWeightRequest{}},
+		{method: "GetDefaultFeePriority", request: &GetDefaultFeePriorityRequest{}},
+		{method: "SetLogLevel", request: &SetLogLevelRequest{}},
+		{method: "SetLogCategories", request: &SetLogCategoriesRequest{}},
+		{method: "ScanTx", request: &ScanTxRequest{}},
+		{method: "ExchangeMultisigKeys", request: &ExchangeMultisigKeysRequest{}},
+		{method: "SetSubaddressLookahead", request: &SetSubaddressLookaheadRequest{}},
+		{method: "SetupBackgroundSync", request: &SetupBackgroundSyncRequest{}},
+		{method: "StartBackgroundSync", request: &StartBackgroundSyncRequest{}},
+		{method: "StopBackgroundSync", request: &StopBackgroundSyncRequest{}},
 	}
 
 	clType := reflect.ValueOf(cl)
@@ -124,3 +143,189 @@ func TestClientMethods(t *testing.T) {
 		})
 	}
 }
+
+// standIn is a stand-in monero-wallet-rpc answering with canned results
+// and recording the params of the last request to each method
+type standIn struct {
+	results  map[string]string
+	requests map[string]string
+}
+
+func newStandIn(results map[string]string) (*standIn, Client, func()) {
+	s := &standIn{results: results, requests: make(map[string]string)}
+	srv := httptest.NewServer(s)
+	u, _ := url.Parse(srv.URL)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.ParseUint(portStr, 10, 0)
+	cfg, _ := gonero.NewRPCConfig("http", host, uint(port), "", "", "")
+	return s, New(cfg), srv.Close
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     uint64          `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	s.requests[req.Method] = string(req.Params)
+	result, ok := s.results[req.Method]
+	if !ok {
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"Method not found"}}`, req.ID)
+		return
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+}
+
+func TestClientMethodsStandIn(t *testing.T) {
+	autosave := false
+
+	type test struct {
+		method   string
+		key      string
+		request  interface{}
+		params   string
+		result   string
+		expected interface{}
+	}
+
+	tests := []test{
+		{
+			method:   "Freeze",
+			key:      "freeze",
+			request:  &FreezeRequest{KeyImage: "d0"},
+			params:   `{"key_image":"d0"}`,
+			result:   `{}`,
+			expected: &FreezeResponse{},
+		},
+		{
+			method:   "Thaw",
+			key:      "thaw",
+			request:  &ThawRequest{KeyImage: "d0"},
+			params:   `{"key_image":"d0"}`,
+			result:   `{}`,
+			expected: &ThawResponse{},
+		},
+		{
+			method:   "Frozen",
+			key:      "frozen",
+			request:  &FrozenRequest{KeyImage: "d0"},
+			params:   `{"key_image":"d0"}`,
+			result:   `{"frozen":true}`,
+			expected: &FrozenResponse{Frozen: true},
+		},
+		{
+			method:   "EstimateTxSizeAndWeight",
+			key:      "estimate_tx_size_and_weight",
+			request:  &EstimateTxSizeAndWeightRequest{NInputs: 2, NOutputs: 2},
+			params:   `{"n_inputs":2,"n_outputs":2}`,
+			result:   `{"size":1534,"weight":1534}`,
+			expected: &EstimateTxSizeAndWeightResponse{Size: 1534, Weight: 1534},
+		},
+		{
+			method:   "GetDefaultFeePriority",
+			key:      "get_default_fee_priority",
+			request:  &GetDefaultFeePriorityRequest{},
+			params:   `{}`,
+			result:   `{"priority":4}`,
+			expected: &GetDefaultFeePriorityResponse{Priority: PriorityPriority},
+		},
+		{
+			method:   "SetLogCategories",
+			key:      "set_log_categories",
+			request:  &SetLogCategoriesRequest{Categories: "wallet.wallet2:DEBUG"},
+			params:   `{"categories":"wallet.wallet2:DEBUG"}`,
+			result:   `{"categories":"wallet.wallet2:DEBUG"}`,
+			expected: &SetLogCategoriesResponse{Categories: "wallet.wallet2:DEBUG"},
+		},
+		{
+			method:   "ScanTx",
+			key:      "scan_tx",
+			request:  &ScanTxRequest{Txids: []string{"aa", "bb"}},
+			params:   `{"txids":["aa","bb"]}`,
+			result:   `{}`,
+			expected: &ScanTxResponse{},
+		},
+		{
+			method:   "ExchangeMultisigKeys",
+			key:      "exchange_multisig_keys",
+			request:  &ExchangeMultisigKeysRequest{Password: gonero.NewSecret("secret"), MultisigInfo: []string{"MultisigxV2R1"}},
+			params:   `{"password":"secret","multisig_info":["MultisigxV2R1"]}`,
+			result:   `{"address":"","multisig_info":"MultisigxV2Rn"}`,
+			expected: &ExchangeMultisigKeysResponse{MultisigInfo: "MultisigxV2Rn"},
+		},
+		{
+			method:   "SetSubaddressLookahead",
+			key:      "set_subaddress_lookahead",
+			request:  &SetSubaddressLookaheadRequest{MajorIdx: 50, MinorIdx: 2000},
+			params:   `{"major_idx":50,"minor_idx":2000}`,
+			result:   `{}`,
+			expected: &SetSubaddressLookaheadResponse{},
+		},
+		{
+			method:   "SetupBackgroundSync",
+			key:      "setup_background_sync",
+			request:  &SetupBackgroundSyncRequest{BackgroundSyncType: BackgroundSyncReusePassword, WalletPassword: gonero.NewSecret("secret")},
+			params:   `{"background_sync_type":"reuse-wallet-password","wallet_password":"secret"}`,
+			result:   `{}`,
+			expected: &SetupBackgroundSyncResponse{},
+		},
+		{
+			method:   "StopBackgroundSync",
+			key:      "stop_background_sync",
+			request:  &StopBackgroundSyncRequest{WalletPassword: gonero.NewSecret("secret")},
+			params:   `{"wallet_password":"secret"}`,
+			result:   `{}`,
+			expected: &StopBackgroundSyncResponse{},
+		},
+		{
+			method:   "GetLanguages",
+			key:      "get_languages",
+			request:  &GetLanguagesRequest{},
+			params:   `{}`,
+			result:   `{"languages":["German","English"],"languages_local":["Deutsch","English"]}`,
+			expected: &GetLanguagesResponse{Languages: []SeedLanguage{SeedLanguageGerman, SeedLanguageEnglish}, LanguagesLocal: []string{"Deutsch", "English"}},
+		},
+		{
+			method:   "RestoreDeterministicWallet",
+			key:      "restore_deterministic_wallet",
+			request:  &RestoreDeterministicWalletRequest{Name: "w", Seed: gonero.NewSecret("abbey"), Language: SeedLanguageEnglish},
+			params:   `{"name":"w","password":"","seed":"abbey","language":"English"}`,
+			result:   `{"address":"5","seed":"abbey","was_deprecated":false}`,
+			expected: &RestoreDeterministicWalletResponse{Address: "5", Seed: gonero.NewSecret("abbey")},
+		},
+		{
+			method:   "GenerateFromKeys",
+			key:      "generate_from_keys",
+			request:  &GenerateFromKeysRequest{Filename: "w", Address: "5", Viewkey: gonero.NewSecret("0a"), AutosaveCurrent: &autosave},
+			params:   `{"filename":"w","address":"5","viewkey":"0a","password":"","autosave_current":false}`,
+			result:   `{"address":"5","info":"Watch-only wallet has been generated successfully."}`,
+			expected: &GenerateFromKeysResponse{Address: "5", Info: "Watch-only wallet has been generated successfully."},
+		},
+		{
+			method:   "IsMultisig",
+			key:      "is_multisig",
+			request:  &IsMultisigRequest{},
+			params:   `{}`,
+			result:   `{"multisig":true,"kex_is_done":true,"ready":true,"threshold":2,"total":3}`,
+			expected: &IsMultisigResponse{Multisig: true, KexIsDone: true, Ready: true, Threshold: 2, Total: 3},
+		},
+	}
+
+	results := make(map[string]string)
+	for _, tc := range tests {
+		results[tc.key] = tc.result
+	}
+	s, cl, done := newStandIn(results)
+	defer done()
+
+	clType := reflect.ValueOf(cl)
+	for _, tc := range tests {
+		t.Run(tc.method, func(t *testing.T) {
+			vals := clType.MethodByName(tc.method).Call([]reflect.Value{reflect.ValueOf(tc.request)})
+			assert.Nil(t, vals[1].Interface())
+			assert.Equal(t, tc.expected, vals[0].Interface())
+			assert.JSONEq(t, tc.params, s.requests[tc.key])
+		})
+	}
+}
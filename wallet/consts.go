@@ -3,13 +3,14 @@ package wallet
 // PriorityType is a transaction priority
 type PriorityType uint
 
-// Accepted Values are: 0-3 for:
-// default, unimportant, normal, and elevated priority.
+// Accepted Values are: 0-4 for:
+// default, unimportant, normal, elevated and priority.
 const (
 	PriorityDefault     PriorityType = 0
 	PriorityUnimportant PriorityType = 1
 	PriorityNormal      PriorityType = 2
 	PriorityElevated    PriorityType = 3
+	PriorityPriority    PriorityType = 4
 )
 
 // TransferType is a string used in IncomingTransferRequest
@@ -39,3 +40,34 @@ const (
 	// QuerySpendKey is the private spend key
 	QuerySpendKey QueryKeyType = "spend_key"
 )
+
+// SeedLanguage is the language of a mnemonic seed,
+// as listed by client.GetLanguages()
+type SeedLanguage string
+
+const (
+	SeedLanguageGerman            SeedLanguage = "German"
+	SeedLanguageEnglish           SeedLanguage = "English"
+	SeedLanguageSpanish           SeedLanguage = "Spanish"
+	SeedLanguageFrench            SeedLanguage = "French"
+	SeedLanguageItalian           SeedLanguage = "Italian"
+	SeedLanguageDutch             SeedLanguage = "Dutch"
+	SeedLanguagePortuguese        SeedLanguage = "Portuguese"
+	SeedLanguageRussian           SeedLanguage = "Russian"
+	SeedLanguageJapanese          SeedLanguage = "Japanese"
+	SeedLanguageChineseSimplified SeedLanguage = "Chinese (simplified)"
+	SeedLanguageEsperanto         SeedLanguage = "Esperanto"
+	SeedLanguageLojban            SeedLanguage = "Lojban"
+)
+
+// BackgroundSyncType is the parameter to send with client.SetupBackgroundSync()
+type BackgroundSyncType string
+
+const (
+	// BackgroundSyncOff disables background sync
+	BackgroundSyncOff BackgroundSyncType = "off"
+	// BackgroundSyncReusePassword encrypts the background cache with the wallet password
+	BackgroundSyncReusePassword BackgroundSyncType = "reuse-wallet-password"
+	// BackgroundSyncCustomPassword encrypts the background cache with a separate password
+	BackgroundSyncCustomPassword BackgroundSyncType = "custom-background-password"
+)
@@ -0,0 +1,455 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/konraddical2/gonero"
+)
+
+// DepositMonitor default values
+const (
+	DefaultDepositPollInterval        = 10 * time.Second
+	DefaultDepositReorgDepth   uint64 = 10
+)
+
+// ErrDepositMonitorClosed is returned when using a closed DepositMonitor
+var ErrDepositMonitorClosed = errors.New("wallet: deposit monitor closed")
+
+// DepositState is the progress of an incoming transfer
+type DepositState int
+
+const (
+	// DepositSeen is reached when the transfer is first seen, in the pool or in a block
+	DepositSeen DepositState = iota + 1
+	// DepositConfirmed is reached when the transfer has the required confirmations
+	DepositConfirmed
+	// DepositUnlocked is reached when the confirmed transfer is spendable.
+	// It is final: the deposit is not watched anymore.
+	DepositUnlocked
+	// DepositReorged is reached when a transfer seen in a block leaves the
+	// chain, or when a confirmed transfer goes back to the pool or below the
+	// required confirmations. It is followed by DepositSeen if the transfer
+	// is still there.
+	DepositReorged
+	// DepositDropped is reached when a transfer seen in the pool leaves it without being mined
+	DepositDropped
+)
+
+func (s DepositState) String() string {
+	switch s {
+	case DepositSeen:
+		return "DepositSeen"
+	case DepositConfirmed:
+		return "DepositConfirmed"
+	case DepositUnlocked:
+		return "DepositUnlocked"
+	case DepositReorged:
+		return "DepositReorged"
+	case DepositDropped:
+		return "DepositDropped"
+	}
+	return fmt.Sprintf("DepositState(%d)", int(s))
+}
+
+// Deposit is an incoming transfer to one subaddress
+type Deposit struct {
+	// Transaction ID.
+	Txid string `json:"txid"`
+	// Address receiving the transfer.
+	Address string `json:"address"`
+	// Account and subaddress index receiving the transfer.
+	SubaddrIndex SubaddressIndex `json:"subaddr_index"`
+	// Amount received, in atomic units.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Height of the block including the transfer, 0 while in the pool.
+	Height uint64 `json:"height"`
+	// Number of confirmations when the state was reached.
+	Confirmations uint64 `json:"confirmations"`
+	// Number of confirmations required to reach DepositConfirmed.
+	RequiredConfirmations uint64 `json:"required_confirmations"`
+	// States if the transfer has been seen as double spend.
+	DoubleSpendSeen bool `json:"double_spend_seen"`
+	// Last state handled.
+	State DepositState `json:"state"`
+}
+
+// Key identifies the deposit among those of the wallet.
+func (d *Deposit) Key() string {
+	return fmt.Sprintf("%s:%d:%d", d.Txid, d.SubaddrIndex.Major, d.SubaddrIndex.Minor)
+}
+
+// DepositHandler handles a deposit which reached a new state. When it returns
+// an error, the state is not stored and the deposit is handled again at the
+// next poll. A crash after the handler returned but before the state was
+// stored also handles it again, so handlers must be idempotent on the
+// deposit key and state.
+type DepositHandler func(Deposit) error
+
+// DepositStore persists the deposits of a DepositMonitor
+type DepositStore interface {
+	// Load returns the last scanned wallet height and the stored deposits.
+	Load() (uint64, []Deposit, error)
+	// PutDeposit stores a deposit, replacing the one with the same key.
+	PutDeposit(Deposit) error
+	// SetHeight stores the last scanned wallet height.
+	SetHeight(uint64) error
+}
+
+// DepositAccount selects the subaddresses of an account watched for deposits
+type DepositAccount struct {
+	// Index of the account.
+	Index uint64
+	// Indices of the subaddresses, empty for all.
+	Subaddresses []uint64
+}
+
+// DepositMonitorConfig configures a DepositMonitor
+type DepositMonitorConfig struct {
+	// Store of the deposits. Defaults to a MemoryDepositStore.
+	Store DepositStore
+	// Accounts to watch. Defaults to every subaddress of account 0.
+	Accounts []DepositAccount
+	// Confirmations required to reach DepositConfirmed. Defaults to the
+	// suggested_confirmations_threshold of each transfer, at least 1.
+	Confirmations uint64
+	// Interval between polls of the wallet.
+	PollInterval time.Duration
+	// Notify triggers an immediate poll when it receives a value, e.g. from
+	// a goroutine forwarding daemon.Follower events.
+	Notify <-chan struct{}
+	// Number of blocks below the last scanned height scanned again at each
+	// poll to notice reorgs.
+	ReorgDepth uint64
+}
+
+// DepositMonitor watches the incoming transfers of a wallet and hands each
+// of them to a DepositHandler as it goes through DepositSeen,
+// DepositConfirmed and DepositUnlocked, or DepositReorged and DepositDropped
+// when it leaves the chain or the pool. Each poll costs one GetHeight call
+// and one GetTransfers call per account, filtered by height from the lowest
+// deposit still waiting for confirmations or from the last scanned height
+// minus ReorgDepth.
+type DepositMonitor struct {
+	// Errors receives RPC, store and handler errors, after which the monitor
+	// retries at the next poll. Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg     DepositMonitorConfig
+	client  Client
+	handler DepositHandler
+	errors  chan error
+
+	mu       sync.Mutex
+	height   uint64
+	deposits map[string]Deposit
+	started  bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewDepositMonitor starts watching the deposits of the wallet behind client.
+func NewDepositMonitor(client Client, handler DepositHandler, cfg *DepositMonitorConfig) *DepositMonitor {
+	c := DepositMonitorConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Store == nil {
+		c.Store = NewMemoryDepositStore()
+	}
+	if len(c.Accounts) == 0 {
+		c.Accounts = []DepositAccount{{Index: 0}}
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = DefaultDepositPollInterval
+	}
+	if c.ReorgDepth == 0 {
+		c.ReorgDepth = DefaultDepositReorgDepth
+	}
+
+	m := &DepositMonitor{
+		cfg:      c,
+		client:   client,
+		handler:  handler,
+		errors:   make(chan error, 16),
+		deposits: make(map[string]Deposit),
+		done:     make(chan struct{}),
+	}
+	m.Errors = m.errors
+
+	m.wg.Add(1)
+	go m.run()
+	return m
+}
+
+// Deposits returns the deposits in the state last handled, sorted by key.
+func (m *DepositMonitor) Deposits() []Deposit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deposits := make([]Deposit, 0, len(m.deposits))
+	for _, d := range m.deposits {
+		deposits = append(deposits, d)
+	}
+	sort.Slice(deposits, func(i, j int) bool { return deposits[i].Key() < deposits[j].Key() })
+	return deposits
+}
+
+// Close stops the monitor and closes its channels.
+func (m *DepositMonitor) Close() error {
+	err := ErrDepositMonitorClosed
+	m.closeOnce.Do(func() {
+		err = nil
+		close(m.done)
+	})
+	m.wg.Wait()
+	return err
+}
+
+func (m *DepositMonitor) run() {
+	defer m.wg.Done()
+	defer close(m.errors)
+
+	notify := m.cfg.Notify
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.poll(); err != nil {
+			if err == ErrDepositMonitorClosed {
+				return
+			}
+			select {
+			case m.errors <- err:
+			default:
+			}
+		}
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+			}
+		}
+	}
+}
+
+// poll scans the transfers of the watched accounts and handles state changes
+func (m *DepositMonitor) poll() error {
+	if !m.started {
+		height, deposits, err := m.cfg.Store.Load()
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.height = height
+		for _, d := range deposits {
+			m.deposits[d.Key()] = d
+		}
+		m.mu.Unlock()
+		m.started = true
+	}
+
+	height, err := m.client.GetHeight(&GetHeightRequest{})
+	if err != nil {
+		return err
+	}
+
+	minHeight := m.minHeight()
+	seen := make(map[string]bool)
+	for _, account := range m.cfg.Accounts {
+		resp, err := m.client.GetTransfers(&GetTransfersRequest{
+			In:             true,
+			Pool:           true,
+			FilterByHeight: true,
+			MinHeight:      minHeight,
+			AccountIndex:   account.Index,
+			SubaddrIndices: account.Subaddresses,
+		})
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(resp.In, func(i, j int) bool { return resp.In[i].Height < resp.In[j].Height })
+		for _, t := range append(resp.In, resp.Pool...) {
+			d := m.deposit(t)
+			seen[d.Key()] = true
+			if err := m.update(d, m.target(t)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// deposits still waiting which the scan did not return left the chain or the pool
+	for _, d := range m.Deposits() {
+		if seen[d.Key()] || !m.watched(d) {
+			continue
+		}
+		switch {
+		case d.State == DepositSeen && d.Height == 0:
+			err = m.update(d, DepositDropped)
+		case d.State == DepositSeen || d.State == DepositConfirmed:
+			err = m.update(d, DepositReorged)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := m.cfg.Store.SetHeight(height.Height); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.height = height.Height
+	m.mu.Unlock()
+	return nil
+}
+
+// minHeight is the exclusive lower bound of the scan
+func (m *DepositMonitor) minHeight() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	min := uint64(0)
+	if m.height > m.cfg.ReorgDepth {
+		min = m.height - m.cfg.ReorgDepth
+	}
+	for _, d := range m.deposits {
+		if d.Height == 0 || (d.State != DepositSeen && d.State != DepositConfirmed) {
+			continue
+		}
+		if d.Height-1 < min {
+			min = d.Height - 1
+		}
+	}
+	return min
+}
+
+// deposit returns the deposit of a transfer, in the state last handled
+func (m *DepositMonitor) deposit(t Transfer) Deposit {
+	required := m.cfg.Confirmations
+	if required == 0 {
+		required = t.SuggestedConfirmationsThreshold
+	}
+	if required == 0 {
+		required = 1
+	}
+	d := Deposit{
+		Txid:                  t.Txid,
+		Address:               t.Address,
+		SubaddrIndex:          t.SubaddrIndex,
+		Amount:                t.Amount,
+		Height:                t.Height,
+		Confirmations:         t.Confirmations,
+		RequiredConfirmations: required,
+		DoubleSpendSeen:       t.DoubleSpendSeen,
+	}
+	m.mu.Lock()
+	d.State = m.deposits[d.Key()].State
+	m.mu.Unlock()
+	return d
+}
+
+// target is the state reached by a transfer
+func (m *DepositMonitor) target(t Transfer) DepositState {
+	if t.Type == "pool" || t.Height == 0 {
+		return DepositSeen
+	}
+	d := m.deposit(t)
+	if t.Confirmations < d.RequiredConfirmations {
+		return DepositSeen
+	}
+	if t.Locked {
+		return DepositConfirmed
+	}
+	return DepositUnlocked
+}
+
+// update hands the deposit to the handler for each state up to target, in
+// order. A deposit back after leaving the chain or the pool starts over, and
+// so does a confirmed deposit back in the pool or below the required
+// confirmations, after being reorged.
+func (m *DepositMonitor) update(d Deposit, target DepositState) error {
+	state := d.State
+	if state == DepositReorged || state == DepositDropped {
+		if target == DepositReorged || target == DepositDropped {
+			return nil
+		}
+		state = 0
+	}
+	if state == DepositUnlocked {
+		return nil
+	}
+
+	var next []DepositState
+	switch target {
+	case DepositReorged, DepositDropped:
+		next = []DepositState{target}
+	default:
+		if target < state {
+			next = append(next, DepositReorged)
+			state = 0
+		}
+		for s := state + 1; s <= target; s++ {
+			next = append(next, s)
+		}
+	}
+	if len(next) == 0 {
+		// keep the height current for the scan bound, e.g. when mined
+		return m.store(d)
+	}
+
+	for _, s := range next {
+		select {
+		case <-m.done:
+			return ErrDepositMonitorClosed
+		default:
+		}
+		d.State = s
+		if err := m.handler(d); err != nil {
+			return fmt.Errorf("wallet: deposit %s %s: %w", d.Key(), s, err)
+		}
+		if err := m.store(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store persists a deposit when it changed
+func (m *DepositMonitor) store(d Deposit) error {
+	m.mu.Lock()
+	prev, ok := m.deposits[d.Key()]
+	m.mu.Unlock()
+	if ok && prev.State == d.State && prev.Height == d.Height && prev.DoubleSpendSeen == d.DoubleSpendSeen {
+		return nil
+	}
+	if err := m.cfg.Store.PutDeposit(d); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.deposits[d.Key()] = d
+	m.mu.Unlock()
+	return nil
+}
+
+// watched tells whether a stored deposit belongs to the watched subaddresses
+func (m *DepositMonitor) watched(d Deposit) bool {
+	for _, account := range m.cfg.Accounts {
+		if account.Index != d.SubaddrIndex.Major {
+			continue
+		}
+		if len(account.Subaddresses) == 0 {
+			return true
+		}
+		for _, minor := range account.Subaddresses {
+			if minor == d.SubaddrIndex.Minor {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ledger is a stand-in wallet serving mutable incoming transfers
+type ledger struct {
+	Client
+
+	mu        sync.Mutex
+	height    uint64
+	transfers map[string]Transfer // txid -> transfer, height 0 in the pool
+	scans     []uint64            // min heights of the scans
+	err       error
+}
+
+func newLedger(height uint64) *ledger {
+	return &ledger{height: height, transfers: make(map[string]Transfer)}
+}
+
+func (l *ledger) receive(txid string, major, minor uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transfers[txid] = Transfer{
+		Txid:                            txid,
+		Address:                         "addr",
+		Amount:                          1e12,
+		SubaddrIndex:                    SubaddressIndex{Major: major, Minor: minor},
+		SuggestedConfirmationsThreshold: 1,
+	}
+}
+
+// scanned returns the min heights of the scans since the nth
+func (l *ledger) scanned(n int) []uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]uint64{}, l.scans[n:]...)
+}
+
+func (l *ledger) mine(txid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := l.transfers[txid]
+	t.Height = l.height
+	l.transfers[txid] = t
+}
+
+// unmine puts a transfer back in the pool
+func (l *ledger) unmine(txid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := l.transfers[txid]
+	t.Height = 0
+	l.transfers[txid] = t
+}
+
+func (l *ledger) advance(n uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.height += n
+}
+
+func (l *ledger) remove(txid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.transfers, txid)
+}
+
+func (l *ledger) GetHeight(*GetHeightRequest) (*GetHeightResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &GetHeightResponse{Height: l.height}, nil
+}
+
+func (l *ledger) GetTransfers(req *GetTransfersRequest) (*GetTransfersResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scans = append(l.scans, req.MinHeight)
+	resp := &GetTransfersResponse{}
+	for _, t := range l.transfers {
+		if t.SubaddrIndex.Major != req.AccountIndex {
+			continue
+		}
+		if len(req.SubaddrIndices) > 0 && !containsIndex(req.SubaddrIndices, t.SubaddrIndex.Minor) {
+			continue
+		}
+		if t.Height == 0 {
+			t.Type = "pool"
+			resp.Pool = append(resp.Pool, t)
+			continue
+		}
+		if req.FilterByHeight && t.Height <= req.MinHeight {
+			continue
+		}
+		t.Type = "in"
+		t.Confirmations = l.height - t.Height
+		t.Locked = t.Confirmations < 10
+		resp.In = append(resp.In, t)
+	}
+	sort.Slice(resp.In, func(i, j int) bool { return resp.In[i].Txid < resp.In[j].Txid })
+	return resp, nil
+}
+
+func containsIndex(indices []uint64, index uint64) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+type deposit struct {
+	Txid  string
+	State DepositState
+}
+
+// handler records handled deposits, failing while err is set
+type handler struct {
+	mu      sync.Mutex
+	handled chan Deposit
+	err     error
+}
+
+func newHandler() *handler {
+	return &handler{handled: make(chan Deposit, 64)}
+}
+
+func (h *handler) handle(d Deposit) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return h.err
+	}
+	h.handled <- d
+	return nil
+}
+
+func expectDeposits(t *testing.T, h *handler, expected ...deposit) []Deposit {
+	var deposits []Deposit
+	for range expected {
+		select {
+		case d := <-h.handled:
+			deposits = append(deposits, d)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for deposits")
+		}
+	}
+	got := make([]deposit, len(deposits))
+	for i, d := range deposits {
+		got[i] = deposit{d.Txid, d.State}
+	}
+	assert.Equal(t, expected, got)
+	return deposits
+}
+
+func TestDepositMonitor(t *testing.T) {
+	l := newLedger(100)
+	l.receive("t1", 0, 1)
+	h := newHandler()
+	notify := make(chan struct{}, 1)
+	poll := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	m := NewDepositMonitor(l, h.handle, &DepositMonitorConfig{
+		Accounts:      []DepositAccount{{Index: 0, Subaddresses: []uint64{1, 2}}},
+		Confirmations: 3,
+		PollInterval:  time.Hour,
+		ReorgDepth:    1,
+		Notify:        notify,
+	})
+
+	t.Run("sees pool transfers", func(t *testing.T) {
+		d := expectDeposits(t, h, deposit{"t1", DepositSeen})
+		assert.Equal(t, Deposit{
+			Txid:                  "t1",
+			Address:               "addr",
+			SubaddrIndex:          SubaddressIndex{Major: 0, Minor: 1},
+			Amount:                1e12,
+			RequiredConfirmations: 3,
+			State:                 DepositSeen,
+		}, d[0])
+	})
+
+	t.Run("ignores other subaddresses", func(t *testing.T) {
+		l.receive("t2", 0, 3)
+		l.receive("t3", 1, 1)
+		l.receive("t4", 0, 2)
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositSeen})
+	})
+
+	t.Run("confirms and unlocks", func(t *testing.T) {
+		n := len(l.scanned(0))
+		l.mine("t1")
+		l.advance(3)
+		poll()
+		expectDeposits(t, h, deposit{"t1", DepositConfirmed})
+		l.advance(7)
+		poll()
+		expectDeposits(t, h, deposit{"t1", DepositUnlocked})
+		// the second scan starts below t1, which still waited for confirmations
+		assert.Equal(t, []uint64{99, 99}, l.scanned(n)[:2])
+	})
+
+	t.Run("reports reorgs and drops", func(t *testing.T) {
+		l.mine("t4")
+		l.advance(4)
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositConfirmed})
+		l.remove("t4")
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositReorged})
+
+		l.receive("t5", 0, 2)
+		poll()
+		expectDeposits(t, h, deposit{"t5", DepositSeen})
+		l.remove("t5")
+		poll()
+		expectDeposits(t, h, deposit{"t5", DepositDropped})
+
+		l.receive("t4", 0, 2)
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositSeen})
+	})
+
+	t.Run("reorgs confirmed transfers back in the pool", func(t *testing.T) {
+		l.mine("t4")
+		l.advance(3)
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositConfirmed})
+		l.unmine("t4")
+		poll()
+		d := expectDeposits(t, h, deposit{"t4", DepositReorged}, deposit{"t4", DepositSeen})
+		assert.Zero(t, d[1].Height)
+
+		// re-mined, it is confirmed again
+		l.mine("t4")
+		l.advance(3)
+		poll()
+		expectDeposits(t, h, deposit{"t4", DepositConfirmed})
+	})
+
+	t.Run("retries failed handlers", func(t *testing.T) {
+		h.mu.Lock()
+		h.err = errors.New("database is locked")
+		h.mu.Unlock()
+		l.receive("t6", 0, 1)
+		poll()
+		assert.EqualError(t, <-m.Errors, "wallet: deposit t6:0:1 DepositSeen: database is locked")
+		h.mu.Lock()
+		h.err = nil
+		h.mu.Unlock()
+		poll()
+		expectDeposits(t, h, deposit{"t6", DepositSeen})
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		l.mu.Lock()
+		l.err = errors.New("connection refused")
+		l.mu.Unlock()
+		poll()
+		assert.EqualError(t, <-m.Errors, "connection refused")
+	})
+
+	assert.NoError(t, m.Close())
+	assert.Equal(t, ErrDepositMonitorClosed, m.Close())
+	_, ok := <-m.Errors
+	assert.False(t, ok)
+}
+
+func TestDepositMonitorRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deposits")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deposits.json")
+
+	l := newLedger(100)
+	l.receive("t1", 0, 0)
+	l.mine("t1")
+	l.receive("t2", 0, 0)
+	l.advance(1)
+
+	store, err := NewFileDepositStore(path)
+	assert.NoError(t, err)
+	h := newHandler()
+	m := NewDepositMonitor(l, h.handle, &DepositMonitorConfig{Store: store})
+	expectDeposits(t, h,
+		deposit{"t1", DepositSeen},
+		deposit{"t1", DepositConfirmed},
+		deposit{"t2", DepositSeen},
+	)
+	assert.NoError(t, m.Close())
+
+	l.mine("t2")
+	l.receive("t3", 0, 0)
+	l.advance(9)
+
+	store, err = NewFileDepositStore(path)
+	assert.NoError(t, err)
+	height, deposits, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), height)
+	assert.Len(t, deposits, 2)
+
+	h = newHandler()
+	m = NewDepositMonitor(l, h.handle, &DepositMonitorConfig{Store: store})
+	defer m.Close()
+	expectDeposits(t, h,
+		deposit{"t1", DepositUnlocked},
+		deposit{"t2", DepositConfirmed},
+		deposit{"t3", DepositSeen},
+	)
+	select {
+	case d := <-h.handled:
+		t.Fatalf("unexpected deposit %+v", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
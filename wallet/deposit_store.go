@@ -0,0 +1,147 @@
+package wallet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryDepositStore is a DepositStore kept in memory, for tests or
+// services which rescan from the start at each run
+type MemoryDepositStore struct {
+	mu       sync.Mutex
+	height   uint64
+	deposits map[string]Deposit
+}
+
+// NewMemoryDepositStore returns an empty MemoryDepositStore.
+func NewMemoryDepositStore() *MemoryDepositStore {
+	return &MemoryDepositStore{deposits: make(map[string]Deposit)}
+}
+
+// Load implements DepositStore.
+func (s *MemoryDepositStore) Load() (uint64, []Deposit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deposits := make([]Deposit, 0, len(s.deposits))
+	for _, d := range s.deposits {
+		deposits = append(deposits, d)
+	}
+	return s.height, deposits, nil
+}
+
+// PutDeposit implements DepositStore.
+func (s *MemoryDepositStore) PutDeposit(d Deposit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deposits[d.Key()] = d
+	return nil
+}
+
+// SetHeight implements DepositStore.
+func (s *MemoryDepositStore) SetHeight(height uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.height = height
+	return nil
+}
+
+// FileDepositStore is a DepositStore saved as a JSON file, rewritten
+// atomically on each change
+type FileDepositStore struct {
+	path string
+	mem  *MemoryDepositStore
+}
+
+type depositFile struct {
+	Height   uint64    `json:"height"`
+	Deposits []Deposit `json:"deposits"`
+}
+
+// NewFileDepositStore opens the store saved at path, which is created
+// on the first change if it does not exist.
+func NewFileDepositStore(path string) (*FileDepositStore, error) {
+	s := &FileDepositStore{path: path, mem: NewMemoryDepositStore()}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f depositFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	s.mem.height = f.Height
+	for _, d := range f.Deposits {
+		s.mem.deposits[d.Key()] = d
+	}
+	return s, nil
+}
+
+// Load implements DepositStore.
+func (s *FileDepositStore) Load() (uint64, []Deposit, error) {
+	return s.mem.Load()
+}
+
+// PutDeposit implements DepositStore.
+func (s *FileDepositStore) PutDeposit(d Deposit) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev, ok := s.mem.deposits[d.Key()]
+	s.mem.deposits[d.Key()] = d
+	if err := s.save(); err != nil {
+		if ok {
+			s.mem.deposits[d.Key()] = prev
+		} else {
+			delete(s.mem.deposits, d.Key())
+		}
+		return err
+	}
+	return nil
+}
+
+// SetHeight implements DepositStore.
+func (s *FileDepositStore) SetHeight(height uint64) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev := s.mem.height
+	s.mem.height = height
+	if err := s.save(); err != nil {
+		s.mem.height = prev
+		return err
+	}
+	return nil
+}
+
+// save writes the file through a temporary file, with s.mem.mu held
+func (s *FileDepositStore) save() error {
+	f := depositFile{Height: s.mem.height, Deposits: make([]Deposit, 0, len(s.mem.deposits))}
+	for _, d := range s.mem.deposits {
+		f.Deposits = append(f.Deposits, d)
+	}
+	data, err := json.Marshal(&f)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -361,7 +361,7 @@ type TransferResponse struct {
 	// String for the publically searchable transaction hash.
 	TxHash string `json:"tx_hash"`
 	// String for the transaction key if get_tx_key is true, otherwise, blank string.
-	TxKey string `json:"tx_key"`
+	TxKey gonero.Secret `json:"tx_key"`
 	// Set of transaction metadata needed to relay this transfer later, if get_tx_metadata is true.
 	TxMetadata string `json:"tx_metadata"`
 	// signing purposes.
@@ -413,7 +413,7 @@ type TransferSplitResponse struct {
 	// The tx hashes of every transaction.
 	TxHashList []string `json:"tx_hash_list"`
 	// The transaction keys for every transaction.
-	TxKeyList []string `json:"tx_key_list"`
+	TxKeyList []gonero.Secret `json:"tx_key_list"`
 	// The amount transferred for every transaction.
 	AmountList []int64 `json:"amount_list"`
 	// The amount of fees paid for every transaction.
@@ -475,7 +475,7 @@ type SweepDustResponse struct {
 	// The tx hashes of every transaction.
 	TxHashList []string `json:"tx_hash_list"`
 	// The transaction keys for every transaction.
-	TxKeyList []string `json:"tx_key_list"`
+	TxKeyList []gonero.Secret `json:"tx_key_list"`
 	// The amount transferred for every transaction.
 	AmountList []int64 `json:"amount_list"`
 	// The amount of fees paid for every transaction.
@@ -523,7 +523,7 @@ type SweepAllResponse struct {
 	// The tx hashes of every transaction.
 	TxHashList []string `json:"tx_hash_list"`
 	// The transaction keys for every transaction.
-	TxKeyList []string `json:"tx_key_list"`
+	TxKeyList []gonero.Secret `json:"tx_key_list"`
 	// The amount transferred for every transaction.
 	AmountList []int64 `json:"amount_list"`
 	// The amount of fees paid for every transaction.
@@ -573,7 +573,7 @@ type SweepSingleResponse struct {
 	// The tx hashes of every transaction.
 	TxHashList []string `json:"tx_hash_list"`
 	// The transaction keys for every transaction.
-	TxKeyList []string `json:"tx_key_list"`
+	TxKeyList []gonero.Secret `json:"tx_key_list"`
 	// The amount transferred for every transaction.
 	AmountList []int64 `json:"amount_list"`
 	// The amount of fees paid for every transaction.
@@ -682,18 +682,26 @@ type IncomingTransfersResponse struct {
 type IncomingTransfer struct {
 	// Amount of this transfer.
 	Amount gonero.AtomicXMR `json:"amount"`
+	// Height of the block containing the transfer.
+	BlockHeight uint64 `json:"block_height"`
+	// Indicates if this transfer is frozen, see Freeze().
+	Frozen bool `json:"frozen"`
 	// Mostly internal use, can be ignored by most users.
 	GlobalIndex uint64 `json:"global_index"`
 	// Key image for the incoming transfer's unspent output (empty unless verbose is true).
 	KeyImage string `json:"key_image"`
+	// Public key of the output.
+	Pubkey string `json:"pubkey"`
 	// Indicates if this transfer has been spent.
 	Spent bool `json:"spent"`
 	// Subaddress index for incoming transfer.
-	SubaddrIndex uint64 `json:"subaddr_index"`
+	SubaddrIndex SubaddressIndex `json:"subaddr_index"`
 	// Several incoming transfers may share the same hash if they were in the same transaction.
 	TxHash string `json:"tx_hash"`
 	// Size of transaction in bytes.
 	TxSize uint64 `json:"tx_size"`
+	// Indicates if this transfer is unlocked, i.e. can be spent.
+	Unlocked bool `json:"unlocked"`
 }
 
 // QueryKeyRequest is a struct for QueryKey() requests
@@ -705,7 +713,7 @@ type QueryKeyRequest struct {
 // QueryKeyResponse is a struct for QueryKey() responses
 type QueryKeyResponse struct {
 	// The view key will be hex encoded, while the mnemonic will be a string of words.
-	Key string `json:"key"`
+	Key gonero.Secret `json:"key"`
 }
 
 // MakeIntegratedAddressRequest is a struct for MakeIntegratedAddress() requests
@@ -819,7 +827,7 @@ type GetTxKeyRequest struct {
 // GetTxKeyResponse is a struct for GetTxKey() responses
 type GetTxKeyResponse struct {
 	// transaction secret key.
-	TxKey string `json:"tx_key"`
+	TxKey gonero.Secret `json:"tx_key"`
 }
 
 // CheckTxKeyRequest is a struct for CheckTxKey() requests
@@ -827,7 +835,7 @@ type CheckTxKeyRequest struct {
 	// transaction id.
 	Txid string `json:"txid"`
 	// transaction secret key.
-	TxKey string `json:"tx_key"`
+	TxKey gonero.Secret `json:"tx_key"`
 	// destination public address of the transaction.
 	Address string `json:"address"`
 }
@@ -968,6 +976,8 @@ type GetTransfersRequest struct {
 	AccountIndex uint64 `json:"account_index,omitempty"`
 	// (Optional) List of subaddress indices to query for transfers. (Defaults to empty - all indices)
 	SubaddrIndices []uint64 `json:"subaddr_indices,omitempty"`
+	// (Optional) Query the transfers of every account, ignoring AccountIndex. (Defaults to false)
+	AllAccounts bool `json:"all_accounts,omitempty"`
 }
 
 // GetTransfersResponse is a struct for GetTransfers() responses
@@ -975,13 +985,13 @@ type GetTransfersResponse struct {
 	// array of incoming transfers
 	In []Transfer `json:"in"`
 	// array of outgoing transfers
-	Out []Transfer `json:"out array of transfers (see above)."`
+	Out []Transfer `json:"out"`
 	// array of pending transfers
-	Pending []Transfer `json:"pending array of transfers (see above)."`
+	Pending []Transfer `json:"pending"`
 	// array of failed transfers
-	Failed []Transfer `json:"failed array of transfers (see above)."`
+	Failed []Transfer `json:"failed"`
 	// array of transfers in the mempool
-	Pool []Transfer `json:"pool array of transfers (see above)."`
+	Pool []Transfer `json:"pool"`
 }
 
 // Transfer is a struct for GetTransfers()
@@ -1020,6 +1030,8 @@ type Transfer struct {
 	Type string `json:"type"`
 	//  Number of blocks until transfer is safely spendable.
 	UnlockTime uint64 `json:"unlock_time"`
+	//  States if the transfer is still locked (true) or spendable (false).
+	Locked bool `json:"locked"`
 }
 
 // GetTransferByTxidRequest is a struct for GetTransferByTxid() requests
@@ -1033,7 +1045,9 @@ type GetTransferByTxidRequest struct {
 // GetTransferByTxidResponse is a struct for GetTransferByTxid() responses
 type GetTransferByTxidResponse struct {
 	// JSON object containing payment information
-	Transfer []TransferDetail `json:"transfer"`
+	Transfer TransferDetail `json:"transfer"`
+	// Every transfer of the transaction, one per receiving subaddress for incoming ones.
+	Transfers []TransferDetail `json:"transfers"`
 }
 
 // TransferDetail is
@@ -1086,10 +1100,54 @@ type DescribeTransferRequest struct {
 
 // DescribeTransferResponse is a struct for DescribeTransfer() responses
 type DescribeTransferResponse struct {
-	// (Optional) A hexadecimal string representing a set of unsigned transactions (empty for multisig transactions;non-multisig signed transactions are not supported).
-	UnsignedTxset string `json:"unsigned_txset,omitempty"`
-	// (Optional) A hexadecimal string representing the set of signing keys used in a multisig transaction (empty for unsigned transactions;non-multisig signed transactions are not supported).
-	MultisigTxset string `json:"multisig_txset,omitempty"`
+	// Description of each transaction of the set.
+	Desc []TransferDescription `json:"desc"`
+	// Summary of the whole set.
+	Summary TransferSummary `json:"summary"`
+}
+
+// TransferDescription is a struct returned by DescribeTransfer()
+// that describes a transaction of a set
+type TransferDescription struct {
+	// The sum of the inputs spent by the transaction in atomic units.
+	AmountIn gonero.AtomicXMR `json:"amount_in"`
+	// The sum of the outputs created by the transaction in atomic units.
+	AmountOut gonero.AtomicXMR `json:"amount_out"`
+	// List of recipients of the transaction.
+	Recipients []Destination `json:"recipients"`
+	// Payment ID matching the input parameter.
+	PaymentID string `json:"payment_id"`
+	// The amount sent back to the change address in atomic units.
+	ChangeAmount gonero.AtomicXMR `json:"change_amount"`
+	// The address of the change recipient.
+	ChangeAddress string `json:"change_address"`
+	// The fee charged for the transaction in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
+	// The number of inputs in the ring (1 real output + the number of decoys from the blockchain).
+	RingSize uint64 `json:"ring_size"`
+	// The number of blocks before the monero can be spent (0 for no lock).
+	UnlockTime uint64 `json:"unlock_time"`
+	// The number of fake outputs added to single-output transactions.
+	DummyOutputs uint64 `json:"dummy_outputs"`
+	// Arbitrary transaction extra data.
+	Extra string `json:"extra"`
+}
+
+// TransferSummary is a struct returned by DescribeTransfer()
+// that sums up a set of transactions
+type TransferSummary struct {
+	// The sum of the inputs spent by the transactions in atomic units.
+	AmountIn gonero.AtomicXMR `json:"amount_in"`
+	// The sum of the outputs created by the transactions in atomic units.
+	AmountOut gonero.AtomicXMR `json:"amount_out"`
+	// List of recipients of the transactions.
+	Recipients []Destination `json:"recipients"`
+	// The amount sent back to the change address in atomic units.
+	ChangeAmount gonero.AtomicXMR `json:"change_amount"`
+	// The address of the change recipient.
+	ChangeAddress string `json:"change_address"`
+	// The fee charged for the transactions in atomic units.
+	Fee gonero.AtomicXMR `json:"fee"`
 }
 
 // SignRequest is a struct for Sign() requests
@@ -1382,7 +1440,9 @@ type GetLanguagesRequest struct {
 // GetLanguagesResponse is a struct for GetLanguages() responses
 type GetLanguagesResponse struct {
 	// List of available languages
-	Languages []string `json:"languages"`
+	Languages []SeedLanguage `json:"languages"`
+	// List of available languages, in their own language
+	LanguagesLocal []string `json:"languages_local"`
 }
 
 // CreateWalletRequest is a struct for CreateWallet() requests
@@ -1390,9 +1450,9 @@ type CreateWalletRequest struct {
 	// Wallet file name.
 	Filename string `json:"filename"`
 	// (Optional) password to protect the wallet.
-	Password string `json:"password,omitempty"`
+	Password gonero.Secret `json:"password,omitempty"`
 	// Language for your wallets' seed.
-	Language string `json:"language"`
+	Language SeedLanguage `json:"language"`
 }
 
 // CreateWalletResponse is a struct for CreateWallet() responses
@@ -1409,13 +1469,13 @@ type GenerateFromKeysRequest struct {
 	// The wallet's primary address.
 	Address string `json:"address"`
 	// (Optional;omit to create a view-only wallet) The wallet's private spend key.
-	Spendkey string `json:"spendkey,omitempty"`
+	Spendkey gonero.Secret `json:"spendkey,omitempty"`
 	// The wallet's private view key.
-	Viewkey string `json:"viewkey"`
+	Viewkey gonero.Secret `json:"viewkey"`
 	// The wallet's password.
-	Password string `json:"password"`
-	// (Defaults to true) If true, save the current wallet before generating the new wallet.
-	AutosaveCurrent bool `json:"autosave_current"`
+	Password gonero.Secret `json:"password"`
+	// (Optional; defaults to true) If true, save the current wallet before generating the new wallet.
+	AutosaveCurrent *bool `json:"autosave_current,omitempty"`
 }
 
 // GenerateFromKeysResponse is a struct for GenerateFromKeys() responses
@@ -1431,7 +1491,7 @@ type OpenWalletRequest struct {
 	// wallet name stored in âwallet-dir.
 	Filename string `json:"filename"`
 	// (Optional) only needed if the wallet has a password defined.
-	Password string `json:"password,omitempty"`
+	Password gonero.Secret `json:"password,omitempty"`
 }
 
 // OpenWalletResponse is a struct for OpenWallet() responses
@@ -1444,17 +1504,17 @@ type RestoreDeterministicWalletRequest struct {
 	// Name of the wallet.
 	Name string `json:"name"`
 	// Password of the wallet.
-	Password string `json:"password"`
+	Password gonero.Secret `json:"password"`
 	// Mnemonic phrase of the wallet to restore.
-	Seed string `json:"seed"`
+	Seed gonero.Secret `json:"seed"`
 	// (Optional) Block height to restore the wallet from (default = 0).
 	RestoreHeight int64 `json:"restore_height,omitempty"`
 	// (Optional) Language of the mnemonic phrase in case the old language is invalid.
-	Language string `json:"language,omitempty"`
+	Language SeedLanguage `json:"language,omitempty"`
 	// (Optional) Offset used to derive a new seed from the given mnemonic to recover a secret wallet from the mnemonic phrase.
-	SeedOffset string `json:"seed_offset,omitempty"`
-	// Whether to save the currently open RPC wallet before closing it (Defaults to true).
-	AutosaveCurrent bool `json:"autosave_current"`
+	SeedOffset gonero.Secret `json:"seed_offset,omitempty"`
+	// (Optional; defaults to true) Whether to save the currently open RPC wallet before closing it.
+	AutosaveCurrent *bool `json:"autosave_current,omitempty"`
 }
 
 // RestoreDeterministicWalletResponse is a struct for RestoreDeterministicWallet() responses
@@ -1464,7 +1524,7 @@ type RestoreDeterministicWalletResponse struct {
 	// Message describing the success or failure of the attempt to restore the wallet.
 	Info string `json:"info"`
 	// Mnemonic phrase of the restored wallet, which is updated if the wallet was restored from a deprecated-style mnemonic phrase.
-	Seed string `json:"seed"`
+	Seed gonero.Secret `json:"seed"`
 	// Indicates if the restored wallet was created from a deprecated-style mnemonic phrase.
 	WasDeprecated bool `json:"was_deprecated"`
 }
@@ -1482,9 +1542,9 @@ type CloseWalletResponse struct {
 // ChangeWalletPasswordRequest is a struct for ChangeWalletPassword() requests
 type ChangeWalletPasswordRequest struct {
 	// (Optional) Current wallet password, if defined.
-	OldPassword string `json:"old_password,omitempty"`
+	OldPassword gonero.Secret `json:"old_password,omitempty"`
 	// (Optional) New wallet password, if not blank.
-	NewPassword string `json:"new_password,omitempty"`
+	NewPassword gonero.Secret `json:"new_password,omitempty"`
 }
 
 // ChangeWalletPasswordResponse is a struct for ChangeWalletPassword() responses
@@ -1501,6 +1561,8 @@ type IsMultisigRequest struct {
 type IsMultisigResponse struct {
 	// States if the wallet is multisig
 	Multisig bool `json:"multisig"`
+	// States if the multisig key exchange is complete
+	KexIsDone bool `json:"kex_is_done"`
 	// boolean
 	Ready bool `json:"ready"`
 	// Amount of signature needed to sign a transfer.
@@ -1527,7 +1589,7 @@ type MakeMultisigRequest struct {
 	// Amount of signatures needed to sign a transfer. Must be less or equal than the amount of signature in multisig_info.
 	Threshold uint64 `json:"threshold"`
 	// Wallet password
-	Password string `json:"password"`
+	Password gonero.Secret `json:"password"`
 }
 
 // MakeMultisigResponse is a struct for MakeMultisig() responses
@@ -1566,7 +1628,7 @@ type FinalizeMultisigRequest struct {
 	// List of multisig string from peers.
 	MultisigInfo []string `json:"multisig_info"`
 	// Wallet password
-	Password string `json:"password"`
+	Password gonero.Secret `json:"password"`
 }
 
 // FinalizeMultisigResponse is a struct for FinalizeMultisig() responses
@@ -1612,6 +1674,176 @@ type GetVersionResponse struct {
 	Version uint64 `json:"version"`
 }
 
+// FreezeRequest is a struct for Freeze() requests
+type FreezeRequest struct {
+	// Key image of the output to freeze.
+	KeyImage string `json:"key_image"`
+}
+
+// FreezeResponse is a struct for Freeze() responses
+type FreezeResponse struct {
+	// None
+}
+
+// ThawRequest is a struct for Thaw() requests
+type ThawRequest struct {
+	// Key image of the output to thaw.
+	KeyImage string `json:"key_image"`
+}
+
+// ThawResponse is a struct for Thaw() responses
+type ThawResponse struct {
+	// None
+}
+
+// FrozenRequest is a struct for Frozen() requests
+type FrozenRequest struct {
+	// Key image of the output to check.
+	KeyImage string `json:"key_image"`
+}
+
+// FrozenResponse is a struct for Frozen() responses
+type FrozenResponse struct {
+	// States if the output is frozen (true) or not (false).
+	Frozen bool `json:"frozen"`
+}
+
+// EstimateTxSizeAndWeightRequest is a struct for EstimateTxSizeAndWeight() requests
+type EstimateTxSizeAndWeightRequest struct {
+	// Number of inputs.
+	NInputs uint32 `json:"n_inputs"`
+	// Number of outputs.
+	NOutputs uint32 `json:"n_outputs"`
+	// (Optional; defaults to the current ring size) Ring size of the inputs.
+	RingSize uint32 `json:"ring_size,omitempty"`
+	// (Optional; defaults to true) States if the transaction is a ring confidential transaction.
+	Rct *bool `json:"rct,omitempty"`
+}
+
+// EstimateTxSizeAndWeightResponse is a struct for EstimateTxSizeAndWeight() responses
+type EstimateTxSizeAndWeightResponse struct {
+	// Estimated transaction size in bytes.
+	Size uint64 `json:"size"`
+	// Estimated transaction weight.
+	Weight uint64 `json:"weight"`
+}
+
+// GetDefaultFeePriorityRequest is a struct for GetDefaultFeePriority() requests
+type GetDefaultFeePriorityRequest struct {
+	// None
+}
+
+// GetDefaultFeePriorityResponse is a struct for GetDefaultFeePriority() responses
+type GetDefaultFeePriorityResponse struct {
+	// Priority used when a transfer is sent with the default priority.
+	Priority PriorityType `json:"priority"`
+}
+
+// SetLogLevelRequest is a struct for SetLogLevel() requests
+type SetLogLevelRequest struct {
+	// Wallet log level to set from 0 (less verbose) to 4 (most verbose).
+	Level int8 `json:"level"`
+}
+
+// SetLogLevelResponse is a struct for SetLogLevel() responses
+type SetLogLevelResponse struct {
+	// None
+}
+
+// SetLogCategoriesRequest is a struct for SetLogCategories() requests
+type SetLogCategoriesRequest struct {
+	// Wallet log categories, as a comma separated list of <Category>:<level>.
+	Categories string `json:"categories"`
+}
+
+// SetLogCategoriesResponse is a struct for SetLogCategories() responses
+type SetLogCategoriesResponse struct {
+	// Wallet log categories which are enabled.
+	Categories string `json:"categories"`
+}
+
+// ScanTxRequest is a struct for ScanTx() requests
+type ScanTxRequest struct {
+	// List of transaction IDs to scan.
+	Txids []string `json:"txids"`
+}
+
+// ScanTxResponse is a struct for ScanTx() responses
+type ScanTxResponse struct {
+	// None
+}
+
+// ExchangeMultisigKeysRequest is a struct for ExchangeMultisigKeys() requests
+type ExchangeMultisigKeysRequest struct {
+	// Wallet password
+	Password gonero.Secret `json:"password"`
+	// List of multisig string from peers.
+	MultisigInfo []string `json:"multisig_info"`
+	// (Optional) Force an update of the multisig keys after the key exchange is done.
+	ForceUpdateUseWithCaution bool `json:"force_update_use_with_caution,omitempty"`
+}
+
+// ExchangeMultisigKeysResponse is a struct for ExchangeMultisigKeys() responses
+type ExchangeMultisigKeysResponse struct {
+	// Multisig wallet address, once the key exchange is done.
+	Address string `json:"address"`
+	// Multisig string to share with peers for the next round, empty once the key exchange is done.
+	MultisigInfo string `json:"multisig_info"`
+}
+
+// SetSubaddressLookaheadRequest is a struct for SetSubaddressLookahead() requests
+type SetSubaddressLookaheadRequest struct {
+	// Number of accounts to look ahead.
+	MajorIdx uint32 `json:"major_idx"`
+	// Number of subaddresses per account to look ahead.
+	MinorIdx uint32 `json:"minor_idx"`
+}
+
+// SetSubaddressLookaheadResponse is a struct for SetSubaddressLookahead() responses
+type SetSubaddressLookaheadResponse struct {
+	// None
+}
+
+// SetupBackgroundSyncRequest is a struct for SetupBackgroundSync() requests
+type SetupBackgroundSyncRequest struct {
+	// Type of background sync.
+	BackgroundSyncType BackgroundSyncType `json:"background_sync_type"`
+	// Wallet password
+	WalletPassword gonero.Secret `json:"wallet_password"`
+	// (Optional) Password of the background cache, needed with BackgroundSyncCustomPassword.
+	BackgroundCachePassword gonero.Secret `json:"background_cache_password,omitempty"`
+}
+
+// SetupBackgroundSyncResponse is a struct for SetupBackgroundSync() responses
+type SetupBackgroundSyncResponse struct {
+	// None
+}
+
+// StartBackgroundSyncRequest is a struct for StartBackgroundSync() requests
+type StartBackgroundSyncRequest struct {
+	// None
+}
+
+// StartBackgroundSyncResponse is a struct for StartBackgroundSync() responses
+type StartBackgroundSyncResponse struct {
+	// None
+}
+
+// StopBackgroundSyncRequest is a struct for StopBackgroundSync() requests
+type StopBackgroundSyncRequest struct {
+	// Wallet password
+	WalletPassword gonero.Secret `json:"wallet_password"`
+	// (Optional) Mnemonic seed, needed instead of the password for wallets without spend key access.
+	Seed gonero.Secret `json:"seed,omitempty"`
+	// (Optional) Offset of the mnemonic seed.
+	SeedOffset gonero.Secret `json:"seed_offset,omitempty"`
+}
+
+// StopBackgroundSyncResponse is a struct for StopBackgroundSync() responses
+type StopBackgroundSyncResponse struct {
+	// None
+}
+
 //#####################
 // Other RPC Structs
 //#####################
@@ -0,0 +1,205 @@
+package zmqsub
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/konraddical2/gonero"
+	"github.com/konraddical2/gonero/daemon"
+)
+
+// Topic is a monerod ZMQ publisher topic
+type Topic string
+
+const (
+	// TopicMinimalChainMain publishes the hashes of blocks added to the main chain
+	TopicMinimalChainMain Topic = "json-minimal-chain_main"
+	// TopicFullChainMain publishes the blocks added to the main chain
+	TopicFullChainMain Topic = "json-full-chain_main"
+	// TopicMinimalTxPoolAdd publishes the ids, sizes and fees of transactions added to the pool
+	TopicMinimalTxPoolAdd Topic = "json-minimal-txpool_add"
+	// TopicFullTxPoolAdd publishes the transactions added to the pool
+	TopicFullTxPoolAdd Topic = "json-full-txpool_add"
+)
+
+// MinimalChain is a json-minimal-chain_main notification.
+// After a reorg FirstHeight is below the previous top block.
+type MinimalChain struct {
+	// Height of the first block in IDs.
+	FirstHeight uint64 `json:"first_height"`
+	// Hash of the block preceding the first block in IDs.
+	FirstPrevID string `json:"first_prev_id"`
+	// Hashes of the blocks added to the main chain, in order.
+	IDs []string `json:"ids"`
+}
+
+// Block is a block of a json-full-chain_main notification
+type Block struct {
+	// The major version of the monero protocol at this block height.
+	MajorVersion uint64 `json:"major_version"`
+	// The minor version of the monero protocol at this block height.
+	MinorVersion uint64 `json:"minor_version"`
+	// The unix time at which the block was recorded into the blockchain.
+	Timestamp uint64 `json:"timestamp"`
+	// The hash of the block immediately preceding this block in the chain.
+	PrevID string `json:"prev_id"`
+	// a cryptographic random one-time number used in mining a Monero block.
+	Nonce uint64 `json:"nonce"`
+	// Coinbase transaction of the block.
+	MinerTx Transaction `json:"miner_tx"`
+	// List of hashes of non coinbase transactions in the block.
+	TxHashes []string `json:"tx_hashes"`
+}
+
+// Height returns the height of the block, taken from its coinbase input.
+func (b *Block) Height() (uint64, bool) {
+	if len(b.MinerTx.Inputs) != 1 || b.MinerTx.Inputs[0].Gen == nil {
+		return 0, false
+	}
+	return b.MinerTx.Inputs[0].Gen.Height, true
+}
+
+// Header returns the fields of a daemon.BlockHeader that are known from the block.
+// Hash, size, depth and difficulty are not published and left empty.
+func (b *Block) Header() daemon.BlockHeader {
+	height, _ := b.Height()
+	var reward uint64
+	for _, out := range b.MinerTx.Outputs {
+		reward += uint64(out.Amount)
+	}
+	return daemon.BlockHeader{
+		Height:       height,
+		MajorVersion: b.MajorVersion,
+		MinorVersion: b.MinorVersion,
+		Nonce:        b.Nonce,
+		NumTxes:      uint64(len(b.TxHashes)),
+		PrevHash:     b.PrevID,
+		Reward:       reward,
+		Timestamp:    b.Timestamp,
+	}
+}
+
+// TxPoolEntry is a transaction of a json-minimal-txpool_add notification
+type TxPoolEntry struct {
+	// Transaction ID
+	ID string `json:"id"`
+	// Transaction size in bytes
+	BlobSize uint64 `json:"blob_size"`
+	// Transaction weight
+	Weight uint64 `json:"weight"`
+	// Transaction fee
+	Fee gonero.AtomicXMR `json:"fee"`
+}
+
+// Transaction is a transaction of a json-full-txpool_add notification
+// or the coinbase transaction of a Block
+type Transaction struct {
+	// Transaction version
+	Version uint64 `json:"version"`
+	// If not 0, this tells when a transaction output is spendable.
+	UnlockTime uint64 `json:"unlock_time"`
+	// List of inputs into transaction
+	Inputs []TxInput `json:"inputs"`
+	// List of outputs from transaction
+	Outputs []TxOutput `json:"outputs"`
+	// Transaction extra field
+	Extra Extra `json:"extra"`
+	// Ring signatures of version 1 transactions.
+	Signatures json.RawMessage `json:"signatures,omitempty"`
+	// Ring confidential signatures of version 2 transactions.
+	RingCT *RingCT `json:"ringct,omitempty"`
+}
+
+// TxInput is a transaction input, either a coinbase (Gen) or a key input
+type TxInput struct {
+	Gen   *GenInput `json:"gen,omitempty"`
+	ToKey *KeyInput `json:"to_key,omitempty"`
+}
+
+// GenInput is the input of a coinbase transaction
+type GenInput struct {
+	// This block height, a.k.a. when the coinbase is generated.
+	Height uint64 `json:"height"`
+}
+
+// KeyInput is an input spending one of a ring of outputs
+type KeyInput struct {
+	// The amount of the input, in atomic units, 0 for RingCT.
+	Amount gonero.AtomicXMR `json:"amount"`
+	// Relative global output indices of the ring members.
+	KeyOffsets []uint64 `json:"key_offsets"`
+	// The key image for the given input
+	KeyImage string `json:"key_image"`
+}
+
+// TxOutput is a transaction output, with or without a view tag
+type TxOutput struct {
+	// The amount of the output, in atomic units, 0 for RingCT.
+	Amount      gonero.AtomicXMR `json:"amount"`
+	ToKey       *KeyOutput       `json:"to_key,omitempty"`
+	ToTaggedKey *KeyOutput       `json:"to_tagged_key,omitempty"`
+}
+
+// Key returns the one-time public key of the output.
+func (o TxOutput) Key() string {
+	switch {
+	case o.ToTaggedKey != nil:
+		return o.ToTaggedKey.Key
+	case o.ToKey != nil:
+		return o.ToKey.Key
+	}
+	return ""
+}
+
+// KeyOutput is the one-time public key of an output
+type KeyOutput struct {
+	Key     string `json:"key"`
+	ViewTag string `json:"view_tag,omitempty"`
+}
+
+// RingCT contains the ring confidential signatures of a transaction
+type RingCT struct {
+	// RingCT type
+	Type uint8 `json:"type"`
+	// Encrypted amounts of the outputs
+	Encrypted []daemon.EcdhInfo `json:"encrypted"`
+	// Pedersen commitments of the outputs
+	Commitments []string `json:"commitments"`
+	// Transaction fee
+	Fee gonero.AtomicXMR `json:"fee"`
+	// Range proofs and ring signatures, omitted for pruned transactions
+	Prunable json.RawMessage `json:"prunable,omitempty"`
+}
+
+// Extra is the transaction extra field.
+// It is published as an array of bytes and also accepted as a hex string.
+type Extra []byte
+
+// MarshalJSON implements json.Marshaler.
+func (e Extra) MarshalJSON() ([]byte, error) {
+	ints := make([]uint16, len(e))
+	for i, b := range e {
+		ints[i] = uint16(b)
+	}
+	return json.Marshal(ints)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Extra) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		b, err := hex.DecodeString(str)
+		if err != nil {
+			return fmt.Errorf("extra: %w", err)
+		}
+		*e = b
+		return nil
+	}
+	var ints []uint8
+	if err := json.Unmarshal(data, &ints); err != nil {
+		return fmt.Errorf("extra: %w", err)
+	}
+	*e = ints
+	return nil
+}
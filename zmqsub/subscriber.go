@@ -0,0 +1,309 @@
+// Package zmqsub subscribes to the notifications monerod publishes
+// over ZMQ when started with --zmq-pub.
+package zmqsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config default values
+const (
+	DefaultDialTimeout          = 10 * time.Second
+	DefaultReconnectInterval    = time.Second
+	DefaultMaxReconnectInterval = time.Minute
+	DefaultBufferSize           = 16
+)
+
+// ErrClosed is returned when using a closed Subscriber
+var ErrClosed = errors.New("zmqsub: subscriber closed")
+
+// Config configures a Subscriber
+type Config struct {
+	// Address of the monerod publisher as given to --zmq-pub,
+	// e.g. tcp://127.0.0.1:18083
+	Address string
+	// Topics to subscribe to.
+	// Defaults to TopicMinimalChainMain and TopicMinimalTxPoolAdd.
+	Topics []Topic
+	// Timeout when connecting to the publisher.
+	DialTimeout time.Duration
+	// Delay before reconnecting after the connection is lost,
+	// doubled after each failed attempt up to MaxReconnectInterval.
+	ReconnectInterval    time.Duration
+	MaxReconnectInterval time.Duration
+	// Buffer size of the notification channels.
+	BufferSize int
+}
+
+// Subscriber receives monerod ZMQ notifications on channels.
+// Channels of topics which are not subscribed are nil.
+// Notifications published while disconnected are lost,
+// so consumers should resync through RPC after each value on Connected.
+// All channels are closed once the Subscriber is closed.
+type Subscriber struct {
+	// json-minimal-chain_main notifications
+	ChainMain <-chan *MinimalChain
+	// json-full-chain_main notifications
+	FullChainMain <-chan []Block
+	// json-minimal-txpool_add notifications
+	TxPoolAdd <-chan []TxPoolEntry
+	// json-full-txpool_add notifications
+	FullTxPoolAdd <-chan []Transaction
+	// Connected receives a value each time the subscriptions are (re)established.
+	Connected <-chan struct{}
+	// Errors receives connection and decoding errors.
+	// Errors are dropped when the channel is full.
+	Errors <-chan error
+
+	cfg           Config
+	addr          string
+	chainMain     chan *MinimalChain
+	fullChainMain chan []Block
+	txPoolAdd     chan []TxPoolEntry
+	fullTxPoolAdd chan []Transaction
+	connected     chan struct{}
+	errors        chan error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	conn      net.Conn
+	wg        sync.WaitGroup
+}
+
+// New connects to a monerod ZMQ publisher and subscribes to the configured topics.
+// Connection errors are reported on Errors and retried until Close is called.
+func New(cfg *Config) (*Subscriber, error) {
+	c := *cfg
+	addr := c.Address
+	if i := strings.Index(addr, "://"); i >= 0 {
+		if addr[:i] != "tcp" {
+			return nil, fmt.Errorf("zmqsub: unsupported transport %q", addr[:i])
+		}
+		addr = addr[i+3:]
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("zmqsub: %w", err)
+	}
+	if len(c.Topics) == 0 {
+		c.Topics = []Topic{TopicMinimalChainMain, TopicMinimalTxPoolAdd}
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.ReconnectInterval == 0 {
+		c.ReconnectInterval = DefaultReconnectInterval
+	}
+	if c.MaxReconnectInterval == 0 {
+		c.MaxReconnectInterval = DefaultMaxReconnectInterval
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = DefaultBufferSize
+	}
+
+	s := &Subscriber{
+		cfg:       c,
+		addr:      addr,
+		connected: make(chan struct{}, 1),
+		errors:    make(chan error, c.BufferSize),
+		done:      make(chan struct{}),
+	}
+	for _, topic := range c.Topics {
+		switch topic {
+		case TopicMinimalChainMain:
+			s.chainMain = make(chan *MinimalChain, c.BufferSize)
+			s.ChainMain = s.chainMain
+		case TopicFullChainMain:
+			s.fullChainMain = make(chan []Block, c.BufferSize)
+			s.FullChainMain = s.fullChainMain
+		case TopicMinimalTxPoolAdd:
+			s.txPoolAdd = make(chan []TxPoolEntry, c.BufferSize)
+			s.TxPoolAdd = s.txPoolAdd
+		case TopicFullTxPoolAdd:
+			s.fullTxPoolAdd = make(chan []Transaction, c.BufferSize)
+			s.FullTxPoolAdd = s.fullTxPoolAdd
+		default:
+			return nil, fmt.Errorf("zmqsub: unknown topic %q", topic)
+		}
+	}
+	s.Connected = s.connected
+	s.Errors = s.errors
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Close disconnects from the publisher and closes all channels.
+func (s *Subscriber) Close() error {
+	err := ErrClosed
+	s.closeOnce.Do(func() {
+		err = nil
+		close(s.done)
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+	s.wg.Wait()
+	return err
+}
+
+func (s *Subscriber) run() {
+	defer s.wg.Done()
+	defer s.closeChannels()
+
+	wait := s.cfg.ReconnectInterval
+	for {
+		subscribed, err := s.session()
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.report(err)
+		if subscribed {
+			wait = s.cfg.ReconnectInterval
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > s.cfg.MaxReconnectInterval {
+			wait = s.cfg.MaxReconnectInterval
+		}
+	}
+}
+
+// session connects, subscribes and reads notifications until the connection fails
+func (s *Subscriber) session() (bool, error) {
+	conn, err := net.DialTimeout("tcp", s.addr, s.cfg.DialTimeout)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		conn.Close()
+		return false, ErrClosed
+	default:
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(s.cfg.DialTimeout))
+	peer, err := handshake(conn, r, "SUB")
+	if err != nil {
+		return false, err
+	}
+	if peer != "PUB" && peer != "XPUB" {
+		return false, fmt.Errorf("zmqsub: peer socket type is %q, not PUB", peer)
+	}
+	for _, topic := range s.cfg.Topics {
+		if err := writeFrame(conn, 0, append([]byte{1}, topic...)); err != nil {
+			return false, err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return true, err
+		}
+		if err := s.dispatch(msg); err != nil {
+			s.report(err)
+		}
+	}
+}
+
+// dispatch decodes a "topic:json" message and delivers it
+func (s *Subscriber) dispatch(msg []byte) error {
+	i := bytes.IndexByte(msg, ':')
+	if i < 0 {
+		return fmt.Errorf("zmqsub: invalid message")
+	}
+	topic, payload := Topic(msg[:i]), msg[i+1:]
+
+	var err error
+	switch {
+	case topic == TopicMinimalChainMain && s.chainMain != nil:
+		v := &MinimalChain{}
+		if err = json.Unmarshal(payload, v); err == nil {
+			select {
+			case s.chainMain <- v:
+			case <-s.done:
+			}
+		}
+	case topic == TopicFullChainMain && s.fullChainMain != nil:
+		var v []Block
+		if err = json.Unmarshal(payload, &v); err == nil {
+			select {
+			case s.fullChainMain <- v:
+			case <-s.done:
+			}
+		}
+	case topic == TopicMinimalTxPoolAdd && s.txPoolAdd != nil:
+		var v []TxPoolEntry
+		if err = json.Unmarshal(payload, &v); err == nil {
+			select {
+			case s.txPoolAdd <- v:
+			case <-s.done:
+			}
+		}
+	case topic == TopicFullTxPoolAdd && s.fullTxPoolAdd != nil:
+		var v []Transaction
+		if err = json.Unmarshal(payload, &v); err == nil {
+			select {
+			case s.fullTxPoolAdd <- v:
+			case <-s.done:
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("zmqsub: %s: %w", topic, err)
+	}
+	return nil
+}
+
+func (s *Subscriber) report(err error) {
+	select {
+	case s.errors <- err:
+	default:
+	}
+}
+
+func (s *Subscriber) closeChannels() {
+	if s.chainMain != nil {
+		close(s.chainMain)
+	}
+	if s.fullChainMain != nil {
+		close(s.fullChainMain)
+	}
+	if s.txPoolAdd != nil {
+		close(s.txPoolAdd)
+	}
+	if s.fullTxPoolAdd != nil {
+		close(s.fullTxPoolAdd)
+	}
+	close(s.connected)
+	close(s.errors)
+}
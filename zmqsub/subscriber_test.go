@@ -0,0 +1,265 @@
+package zmqsub
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konraddical2/gonero/daemon"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	minimalChain = `{"first_height":2731375,"first_prev_id":"a1","ids":["b2","c3"]}`
+	fullChain    = `[{"major_version":16,"minor_version":16,"timestamp":1666000000,"prev_id":"a1","nonce":1234,` +
+		`"miner_tx":{"version":2,"unlock_time":2731435,"inputs":[{"gen":{"height":2731375}}],` +
+		`"outputs":[{"amount":600000000000,"to_tagged_key":{"key":"d4","view_tag":"e5"}}],"extra":[1,96,2],"ringct":{"type":0,"encrypted":[],"commitments":[],"fee":0}},` +
+		`"tx_hashes":["f6"]}]`
+	minimalTxPool = `[{"id":"f6","blob_size":1534,"weight":1534,"fee":30700000}]`
+	fullTxPool    = `[{"version":2,"unlock_time":0,"inputs":[{"to_key":{"amount":0,"key_offsets":[1,2,3],"key_image":"07"}}],` +
+		`"outputs":[{"amount":0,"to_key":{"key":"08"}}],"extra":"0109",` +
+		`"ringct":{"type":6,"encrypted":[{"amount":"0a"}],"commitments":["0b"],"fee":30700000,"prunable":{"clsags":[]}}}]`
+)
+
+// publisher is a stand-in monerod ZMQ publisher
+type publisher struct {
+	t          *testing.T
+	ln         net.Listener
+	socketType string
+	subscribed chan string
+
+	mu    sync.Mutex
+	conns map[net.Conn][]string
+}
+
+func newPublisher(t *testing.T, socketType string) *publisher {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &publisher{
+		t:          t,
+		ln:         ln,
+		socketType: socketType,
+		subscribed: make(chan string, 16),
+		conns:      make(map[net.Conn][]string),
+	}
+	go p.accept()
+	return p
+}
+
+func (p *publisher) addr() string {
+	return "tcp://" + p.ln.Addr().String()
+}
+
+func (p *publisher) accept() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.serve(conn)
+	}
+}
+
+func (p *publisher) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if _, err := handshake(conn, r, p.socketType); err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.conns[conn] = nil
+	p.mu.Unlock()
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+			return
+		}
+		if len(f.body) > 0 && f.body[0] == 1 {
+			p.mu.Lock()
+			p.conns[conn] = append(p.conns[conn], string(f.body[1:]))
+			p.mu.Unlock()
+			p.subscribed <- string(f.body[1:])
+		}
+	}
+}
+
+// waitSubscribed waits for n subscriptions
+func (p *publisher) waitSubscribed(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-p.subscribed:
+		case <-time.After(5 * time.Second):
+			p.t.Fatal("timeout waiting for subscriptions")
+		}
+	}
+}
+
+func (p *publisher) publish(topic Topic, payload string) {
+	msg := []byte(string(topic) + ":" + payload)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn, topics := range p.conns {
+		for _, prefix := range topics {
+			if bytes.HasPrefix(msg, []byte(prefix)) {
+				writeFrame(conn, 0, msg)
+				break
+			}
+		}
+	}
+}
+
+// drop closes all subscriber connections
+func (p *publisher) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+func (p *publisher) close() {
+	p.ln.Close()
+	p.drop()
+}
+
+func waitConnected(t *testing.T, s *Subscriber) {
+	select {
+	case <-s.Connected:
+	case err := <-s.Errors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	p := newPublisher(t, "PUB")
+	defer p.close()
+
+	s, err := New(&Config{
+		Address:           p.addr(),
+		Topics:            []Topic{TopicMinimalChainMain, TopicFullChainMain, TopicMinimalTxPoolAdd, TopicFullTxPoolAdd},
+		ReconnectInterval: 10 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	waitConnected(t, s)
+	p.waitSubscribed(4)
+
+	t.Run("decodes notifications", func(t *testing.T) {
+		p.publish(TopicMinimalChainMain, minimalChain)
+		assert.Equal(t, &MinimalChain{FirstHeight: 2731375, FirstPrevID: "a1", IDs: []string{"b2", "c3"}}, <-s.ChainMain)
+
+		p.publish(TopicFullChainMain, fullChain)
+		blocks := <-s.FullChainMain
+		assert.Len(t, blocks, 1)
+		assert.Equal(t, daemon.BlockHeader{
+			Height:       2731375,
+			MajorVersion: 16,
+			MinorVersion: 16,
+			Nonce:        1234,
+			NumTxes:      1,
+			PrevHash:     "a1",
+			Reward:       600000000000,
+			Timestamp:    1666000000,
+		}, blocks[0].Header())
+		assert.Equal(t, "d4", blocks[0].MinerTx.Outputs[0].Key())
+		assert.Equal(t, Extra{1, 96, 2}, blocks[0].MinerTx.Extra)
+
+		p.publish(TopicMinimalTxPoolAdd, minimalTxPool)
+		assert.Equal(t, []TxPoolEntry{{ID: "f6", BlobSize: 1534, Weight: 1534, Fee: 30700000}}, <-s.TxPoolAdd)
+
+		p.publish(TopicFullTxPoolAdd, fullTxPool)
+		txs := <-s.FullTxPoolAdd
+		assert.Len(t, txs, 1)
+		assert.Equal(t, &KeyInput{KeyOffsets: []uint64{1, 2, 3}, KeyImage: "07"}, txs[0].Inputs[0].ToKey)
+		assert.Equal(t, "08", txs[0].Outputs[0].Key())
+		assert.Equal(t, Extra{1, 9}, txs[0].Extra)
+		assert.Equal(t, uint8(6), txs[0].RingCT.Type)
+		assert.Equal(t, []string{"0b"}, txs[0].RingCT.Commitments)
+		assert.Nil(t, txs[0].Inputs[0].Gen)
+	})
+
+	t.Run("reports decoding errors", func(t *testing.T) {
+		p.publish(TopicMinimalChainMain, `{"first_height":"foo"}`)
+		select {
+		case err := <-s.Errors:
+			assert.Contains(t, err.Error(), string(TopicMinimalChainMain))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for error")
+		}
+	})
+
+	t.Run("reconnects", func(t *testing.T) {
+		p.drop()
+		assert.EqualError(t, <-s.Errors, "EOF")
+		waitConnected(t, s)
+		p.waitSubscribed(4)
+		p.publish(TopicMinimalChainMain, minimalChain)
+		assert.Equal(t, uint64(2731375), (<-s.ChainMain).FirstHeight)
+	})
+
+	assert.NoError(t, s.Close())
+	assert.Equal(t, ErrClosed, s.Close())
+	_, ok := <-s.ChainMain
+	assert.False(t, ok)
+}
+
+func TestSubscriberDefaults(t *testing.T) {
+	p := newPublisher(t, "PUB")
+	defer p.close()
+
+	s, err := New(&Config{Address: strings.TrimPrefix(p.addr(), "tcp://")})
+	assert.NoError(t, err)
+	defer s.Close()
+	waitConnected(t, s)
+	p.waitSubscribed(2)
+	assert.NotNil(t, s.ChainMain)
+	assert.NotNil(t, s.TxPoolAdd)
+	assert.Nil(t, s.FullChainMain)
+	assert.Nil(t, s.FullTxPoolAdd)
+}
+
+func TestSubscriberErrors(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Address: "ipc:///tmp/monerod.sock"},
+		{Address: "tcp://localhost"},
+		{Address: "tcp://localhost:18083", Topics: []Topic{"json-minimal-foo"}},
+	} {
+		_, err := New(cfg)
+		assert.Error(t, err, cfg.Address)
+	}
+
+	p := newPublisher(t, "REP")
+	defer p.close()
+	s, err := New(&Config{Address: p.addr(), ReconnectInterval: time.Hour})
+	assert.NoError(t, err)
+	select {
+	case err := <-s.Errors:
+		assert.EqualError(t, err, `zmqsub: peer socket type is "REP", not PUB`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+	assert.NoError(t, s.Close())
+}
+
+func TestExtra(t *testing.T) {
+	var e Extra
+	assert.NoError(t, e.UnmarshalJSON([]byte(`[2,33,0]`)))
+	assert.Equal(t, Extra{2, 33, 0}, e)
+	assert.NoError(t, e.UnmarshalJSON([]byte(`"022100"`)))
+	assert.Equal(t, Extra{2, 33, 0}, e)
+	data, err := e.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `[2,33,0]`, string(data))
+	assert.Error(t, e.UnmarshalJSON([]byte(`"zz"`)))
+	assert.Error(t, e.UnmarshalJSON([]byte(`[256]`)))
+}
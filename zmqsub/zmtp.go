@@ -0,0 +1,214 @@
+package zmqsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Minimal ZMTP 3.0 implementation (https://rfc.zeromq.org/spec/23/)
+// for a SUB socket with the NULL security mechanism.
+
+const (
+	greetingSize = 64
+
+	flagMore    byte = 0x01
+	flagLong    byte = 0x02
+	flagCommand byte = 0x04
+
+	// maxFrameSize bounds the memory used by a single frame,
+	// full blocks can be a few megabytes
+	maxFrameSize = 64 << 20
+)
+
+var errFrameTooLarge = errors.New("zmtp: frame too large")
+
+// greeting returns the ZMTP 3.0 greeting with the NULL mechanism
+func greeting() []byte {
+	g := make([]byte, greetingSize)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3 // version major
+	g[11] = 0 // version minor
+	copy(g[12:32], "NULL")
+	return g
+}
+
+// checkGreeting validates the greeting sent by the peer
+func checkGreeting(g []byte) error {
+	if g[0] != 0xff || g[9] != 0x7f {
+		return fmt.Errorf("zmtp: invalid greeting signature")
+	}
+	if g[10] < 3 {
+		return fmt.Errorf("zmtp: unsupported version %d.%d", g[10], g[11])
+	}
+	if mech := string(bytes.TrimRight(g[12:32], "\x00")); mech != "NULL" {
+		return fmt.Errorf("zmtp: unsupported security mechanism %q", mech)
+	}
+	return nil
+}
+
+type frame struct {
+	flags byte
+	body  []byte
+}
+
+func (f frame) more() bool    { return f.flags&flagMore != 0 }
+func (f frame) command() bool { return f.flags&flagCommand != 0 }
+
+func writeFrame(w io.Writer, flags byte, body []byte) error {
+	var hdr []byte
+	if len(body) > 255 {
+		hdr = make([]byte, 9)
+		hdr[0] = flags | flagLong
+		binary.BigEndian.PutUint64(hdr[1:], uint64(len(body)))
+	} else {
+		hdr = []byte{flags, byte(len(body))}
+	}
+	if _, err := w.Write(append(hdr, body...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readFrame(r *bufio.Reader) (frame, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return frame{}, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return frame{}, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return frame{}, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return frame{}, errFrameTooLarge
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return frame{}, err
+	}
+	return frame{flags: flags, body: body}, nil
+}
+
+// readyCommand builds a READY command body with the given properties
+func readyCommand(props map[string]string) []byte {
+	body := []byte{5}
+	body = append(body, "READY"...)
+	for name, value := range props {
+		body = append(body, byte(len(name)))
+		body = append(body, name...)
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(value)))
+		body = append(body, size[:]...)
+		body = append(body, value...)
+	}
+	return body
+}
+
+// parseCommand splits a command body into its name and data
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil, fmt.Errorf("zmtp: invalid command")
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:], nil
+}
+
+// parseProperties parses the properties of a READY command
+func parseProperties(data []byte) (map[string]string, error) {
+	props := make(map[string]string)
+	for len(data) > 0 {
+		l := int(data[0])
+		if len(data) < 1+l+4 {
+			return nil, fmt.Errorf("zmtp: invalid property")
+		}
+		name := string(data[1 : 1+l])
+		data = data[1+l:]
+		vl := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(vl) {
+			return nil, fmt.Errorf("zmtp: invalid property")
+		}
+		props[name] = string(data[:vl])
+		data = data[vl:]
+	}
+	return props, nil
+}
+
+// handshake exchanges greetings and READY commands,
+// announcing socketType and returning the peer's socket type
+func handshake(rw io.ReadWriter, r *bufio.Reader, socketType string) (string, error) {
+	if _, err := rw.Write(greeting()); err != nil {
+		return "", err
+	}
+	g := make([]byte, greetingSize)
+	if _, err := io.ReadFull(r, g); err != nil {
+		return "", err
+	}
+	if err := checkGreeting(g); err != nil {
+		return "", err
+	}
+
+	if err := writeFrame(rw, flagCommand, readyCommand(map[string]string{"Socket-Type": socketType})); err != nil {
+		return "", err
+	}
+	f, err := readFrame(r)
+	if err != nil {
+		return "", err
+	}
+	if !f.command() {
+		return "", fmt.Errorf("zmtp: expected READY command")
+	}
+	name, data, err := parseCommand(f.body)
+	if err != nil {
+		return "", err
+	}
+	switch name {
+	case "READY":
+	case "ERROR":
+		if len(data) > 0 && int(data[0]) <= len(data)-1 {
+			data = data[1 : 1+data[0]]
+		}
+		return "", fmt.Errorf("zmtp: peer error: %s", data)
+	default:
+		return "", fmt.Errorf("zmtp: unexpected command %q", name)
+	}
+	props, err := parseProperties(data)
+	if err != nil {
+		return "", err
+	}
+	return props["Socket-Type"], nil
+}
+
+// readMessage reads a (possibly multi-part) message, skipping commands
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	var msg []byte
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		if f.command() {
+			continue
+		}
+		if len(msg)+len(f.body) > maxFrameSize {
+			return nil, errFrameTooLarge
+		}
+		msg = append(msg, f.body...)
+		if !f.more() {
+			return msg, nil
+		}
+	}
+}